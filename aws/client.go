@@ -0,0 +1,51 @@
+package aws
+
+import (
+	"os"
+	"strings"
+)
+
+// client 是各服务客户端共用的区域、地址和凭据
+type client struct {
+	region      string
+	endpoint    string
+	credentials CredentialsProvider
+}
+
+// Option 定义服务客户端（Lambda、SQS）的构造选项
+type Option func(*client)
+
+// WithRegion 设置默认区域，默认读取 AWS_REGION 或 AWS_DEFAULT_REGION 环境变量
+func WithRegion(region string) Option {
+	return func(c *client) {
+		c.region = region
+	}
+}
+
+// WithCredentials 设置凭据来源，默认为 DefaultCredentials
+func WithCredentials(p CredentialsProvider) Option {
+	return func(c *client) {
+		c.credentials = p
+	}
+}
+
+// WithEndpoint 设置服务 API 的地址，如本地测试使用的 "http://localhost:4566"；默认按区域使用 AWS 的地址
+func WithEndpoint(endpoint string) Option {
+	return func(c *client) {
+		c.endpoint = strings.TrimRight(endpoint, "/")
+	}
+}
+
+func newClient(opts []Option) client {
+	c := client{region: os.Getenv("AWS_REGION")}
+	if c.region == "" {
+		c.region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	if c.credentials == nil {
+		c.credentials = DefaultCredentials()
+	}
+	return c
+}
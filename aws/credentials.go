@@ -0,0 +1,248 @@
+// Package aws 以 IAM 凭据调用 AWS 服务的任务类型，如 Lambda 和 SQS。
+// 直接使用 Signature Version 4 签名的 HTTP 请求，不依赖 AWS SDK；
+// 单独成包是为了让不使用 AWS 的程序无需链接这些代码
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Credentials 是签名请求使用的 IAM 凭据
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string    // 临时凭据的会话令牌
+	Expires         time.Time // 为零值时不过期
+}
+
+// CredentialsProvider 提供凭据，实现需要可被并发调用
+type CredentialsProvider interface {
+	Retrieve(ctx context.Context) (Credentials, error)
+}
+
+// CredentialsFunc 把函数适配为 CredentialsProvider
+type CredentialsFunc func(ctx context.Context) (Credentials, error)
+
+func (f CredentialsFunc) Retrieve(ctx context.Context) (Credentials, error) {
+	return f(ctx)
+}
+
+// StaticCredentials 返回固定的凭据
+func StaticCredentials(accessKeyID, secretAccessKey, sessionToken string) CredentialsProvider {
+	return CredentialsFunc(func(context.Context) (Credentials, error) {
+		return Credentials{AccessKeyID: accessKeyID, SecretAccessKey: secretAccessKey, SessionToken: sessionToken}, nil
+	})
+}
+
+// EnvCredentials 从 AWS_ACCESS_KEY_ID、AWS_SECRET_ACCESS_KEY 和 AWS_SESSION_TOKEN 环境变量读取凭据
+func EnvCredentials() CredentialsProvider {
+	return CredentialsFunc(func(context.Context) (Credentials, error) {
+		id, secret := os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY")
+		if id == "" || secret == "" {
+			return Credentials{}, fmt.Errorf("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY are not set")
+		}
+		return Credentials{AccessKeyID: id, SecretAccessKey: secret, SessionToken: os.Getenv("AWS_SESSION_TOKEN")}, nil
+	})
+}
+
+// WebIdentityCredentials 以 OIDC 令牌文件调用 STS AssumeRoleWithWebIdentity 换取角色的临时凭据，
+// 用于 EKS 的服务账号角色（IRSA）；令牌文件在每次换取时重新读取
+func WebIdentityCredentials(roleARN, tokenFile, sessionName string) CredentialsProvider {
+	if sessionName == "" {
+		sessionName = "workflow-engine"
+	}
+	return CredentialsFunc(func(ctx context.Context) (Credentials, error) {
+		token, err := os.ReadFile(tokenFile)
+		if err != nil {
+			return Credentials{}, fmt.Errorf("failed to read web identity token: %v", err)
+		}
+		endpoint := "https://sts.amazonaws.com/"
+		if region := os.Getenv("AWS_REGION"); region != "" {
+			endpoint = "https://sts." + region + ".amazonaws.com/"
+		}
+		query := url.Values{
+			"Action":           {"AssumeRoleWithWebIdentity"},
+			"Version":          {"2011-06-15"},
+			"RoleArn":          {roleARN},
+			"RoleSessionName":  {sessionName},
+			"WebIdentityToken": {strings.TrimSpace(string(token))},
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(query.Encode()))
+		if err != nil {
+			return Credentials{}, err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		body, err := send(req)
+		if err != nil {
+			return Credentials{}, fmt.Errorf("failed to assume role %s: %v", roleARN, err)
+		}
+		var resp struct {
+			Credentials struct {
+				AccessKeyID     string    `xml:"AccessKeyId"`
+				SecretAccessKey string    `xml:"SecretAccessKey"`
+				SessionToken    string    `xml:"SessionToken"`
+				Expiration      time.Time `xml:"Expiration"`
+			} `xml:"AssumeRoleWithWebIdentityResult>Credentials"`
+		}
+		if err := xml.Unmarshal(body, &resp); err != nil {
+			return Credentials{}, fmt.Errorf("failed to decode sts response: %v", err)
+		}
+		c := resp.Credentials
+		return Credentials{AccessKeyID: c.AccessKeyID, SecretAccessKey: c.SecretAccessKey, SessionToken: c.SessionToken, Expires: c.Expiration}, nil
+	})
+}
+
+// ContainerCredentials 从 ECS 任务角色或 EKS Pod Identity 的凭据端点读取凭据，
+// 端点由 AWS_CONTAINER_CREDENTIALS_FULL_URI 或 AWS_CONTAINER_CREDENTIALS_RELATIVE_URI 指定
+func ContainerCredentials() CredentialsProvider {
+	return CredentialsFunc(func(ctx context.Context) (Credentials, error) {
+		endpoint := os.Getenv("AWS_CONTAINER_CREDENTIALS_FULL_URI")
+		if rel := os.Getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI"); endpoint == "" && rel != "" {
+			endpoint = "http://169.254.170.2" + rel
+		}
+		if endpoint == "" {
+			return Credentials{}, fmt.Errorf("container credentials endpoint is not set")
+		}
+		header := http.Header{}
+		token := os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN")
+		if file := os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE"); file != "" {
+			b, err := os.ReadFile(file)
+			if err != nil {
+				return Credentials{}, fmt.Errorf("failed to read container authorization token: %v", err)
+			}
+			token = strings.TrimSpace(string(b))
+		}
+		if token != "" {
+			header.Set("Authorization", token)
+		}
+		body, err := get(ctx, endpoint, header)
+		if err != nil {
+			return Credentials{}, fmt.Errorf("failed to get container credentials: %v", err)
+		}
+		return decodeJSONCredentials(body)
+	})
+}
+
+// InstanceCredentials 通过 IMDSv2 读取 EC2 实例角色的凭据
+func InstanceCredentials() CredentialsProvider {
+	const imds = "http://169.254.169.254"
+	return CredentialsFunc(func(ctx context.Context) (Credentials, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPut, imds+"/latest/api/token", nil)
+		if err != nil {
+			return Credentials{}, err
+		}
+		req.Header.Set("X-aws-ec2-metadata-token-ttl-seconds", "300")
+		token, err := send(req)
+		if err != nil {
+			return Credentials{}, fmt.Errorf("failed to get instance metadata token: %v", err)
+		}
+		header := http.Header{"X-Aws-Ec2-Metadata-Token": {string(token)}}
+		role, err := get(ctx, imds+"/latest/meta-data/iam/security-credentials/", header)
+		if err != nil {
+			return Credentials{}, fmt.Errorf("failed to get instance role: %v", err)
+		}
+		name := strings.TrimSpace(strings.SplitN(string(role), "\n", 2)[0])
+		body, err := get(ctx, imds+"/latest/meta-data/iam/security-credentials/"+url.PathEscape(name), header)
+		if err != nil {
+			return Credentials{}, fmt.Errorf("failed to get instance credentials: %v", err)
+		}
+		return decodeJSONCredentials(body)
+	})
+}
+
+// DefaultCredentials 依次尝试环境变量、服务账号角色（AWS_ROLE_ARN 和 AWS_WEB_IDENTITY_TOKEN_FILE）、
+// 容器凭据端点和 EC2 实例角色，与 AWS SDK 的默认顺序一致；凭据缓存到过期前5分钟
+func DefaultCredentials() CredentialsProvider {
+	return NewCachedCredentials(CredentialsFunc(func(ctx context.Context) (Credentials, error) {
+		if os.Getenv("AWS_ACCESS_KEY_ID") != "" {
+			return EnvCredentials().Retrieve(ctx)
+		}
+		if role, file := os.Getenv("AWS_ROLE_ARN"), os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE"); role != "" && file != "" {
+			return WebIdentityCredentials(role, file, os.Getenv("AWS_ROLE_SESSION_NAME")).Retrieve(ctx)
+		}
+		if os.Getenv("AWS_CONTAINER_CREDENTIALS_FULL_URI") != "" || os.Getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI") != "" {
+			return ContainerCredentials().Retrieve(ctx)
+		}
+		return InstanceCredentials().Retrieve(ctx)
+	}))
+}
+
+// CachedCredentials 缓存另一个 CredentialsProvider 的凭据，临时凭据在过期前5分钟刷新
+type CachedCredentials struct {
+	provider CredentialsProvider
+
+	mu    sync.Mutex
+	creds *Credentials
+}
+
+// NewCachedCredentials 创建缓存 provider 凭据的 CachedCredentials
+func NewCachedCredentials(provider CredentialsProvider) *CachedCredentials {
+	return &CachedCredentials{provider: provider}
+}
+
+func (c *CachedCredentials) Retrieve(ctx context.Context) (Credentials, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.creds != nil && (c.creds.Expires.IsZero() || time.Until(c.creds.Expires) > 5*time.Minute) {
+		return *c.creds, nil
+	}
+	creds, err := c.provider.Retrieve(ctx)
+	if err != nil {
+		return Credentials{}, err
+	}
+	c.creds = &creds
+	return creds, nil
+}
+
+func decodeJSONCredentials(body []byte) (Credentials, error) {
+	var resp struct {
+		AccessKeyID     string    `json:"AccessKeyId"`
+		SecretAccessKey string    `json:"SecretAccessKey"`
+		Token           string    `json:"Token"`
+		Expiration      time.Time `json:"Expiration"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return Credentials{}, fmt.Errorf("failed to decode credentials: %v", err)
+	}
+	return Credentials{AccessKeyID: resp.AccessKeyID, SecretAccessKey: resp.SecretAccessKey, SessionToken: resp.Token, Expires: resp.Expiration}, nil
+}
+
+// credentialsClient 是读取凭据使用的客户端，元数据端点不可达时应尽快失败
+var credentialsClient = &http.Client{Timeout: 5 * time.Second}
+
+func get(ctx context.Context, target string, header http.Header) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	return send(req)
+}
+
+func send(req *http.Request) ([]byte, error) {
+	resp, err := credentialsClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s %s returned %d: %s", req.Method, req.URL.Host+req.URL.Path, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
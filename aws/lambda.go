@@ -0,0 +1,203 @@
+package aws
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"workflow/graph"
+)
+
+// TaskTypeLambda 是调用 AWS Lambda 函数的任务类型名称
+const TaskTypeLambda = "aws_lambda"
+
+// maxLambdaResponse 是读取函数返回值的上限，同步调用的响应最大为 6MB
+const maxLambdaResponse = 6 << 20
+
+// LambdaSpec 描述作为任务调用的 Lambda 函数
+type LambdaSpec struct {
+	Function  string // 函数名称或 ARN
+	Qualifier string // 版本或别名，为空时调用 $LATEST
+	Region    string // 为空时从 ARN 或 Lambda 的默认区域取得
+	// Async 为 true 时以 Event 方式调用，不等待函数执行，任务结果为 nil
+	Async bool
+	// Payload 为空时把任务输入编码为 JSON 作为事件
+	Payload *graph.PayloadTemplate
+}
+
+// FunctionError 表示函数执行时返回了错误
+type FunctionError struct {
+	Function string
+	Type     string // 函数返回的 errorType，或 X-Amz-Function-Error 的值
+	Message  string
+	Log      string // 函数日志的最后 4KB
+}
+
+func (e *FunctionError) Error() string {
+	return fmt.Sprintf("lambda function %s failed with %s: %s", e.Function, e.Type, e.Message)
+}
+
+// Lambda 以 IAM 凭据调用 Lambda 函数，可被多个 goroutine 并发使用
+type Lambda struct {
+	client
+}
+
+// NewLambda 创建 Lambda 客户端
+func NewLambda(opts ...Option) *Lambda {
+	return &Lambda{client: newClient(opts)}
+}
+
+// Invoke 调用函数并返回它的原始响应；函数返回错误时返回 *FunctionError
+func (l *Lambda) Invoke(ctx context.Context, spec LambdaSpec, payload []byte) ([]byte, error) {
+	region := spec.Region
+	if region == "" {
+		// ARN 形如 arn:aws:lambda:<region>:<account>:function:<name>
+		if parts := strings.Split(spec.Function, ":"); len(parts) > 3 && parts[0] == "arn" {
+			region = parts[3]
+		} else {
+			region = l.region
+		}
+	}
+	if region == "" {
+		return nil, fmt.Errorf("no region for lambda function %s", spec.Function)
+	}
+	endpoint := l.endpoint
+	if endpoint == "" {
+		endpoint = "https://lambda." + region + ".amazonaws.com"
+	}
+	target := endpoint + "/2015-03-31/functions/" + url.PathEscape(spec.Function) + "/invocations"
+	if spec.Qualifier != "" {
+		target += "?Qualifier=" + url.QueryEscape(spec.Qualifier)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if spec.Async {
+		req.Header.Set("X-Amz-Invocation-Type", "Event")
+	} else {
+		req.Header.Set("X-Amz-Invocation-Type", "RequestResponse")
+		req.Header.Set("X-Amz-Log-Type", "Tail")
+	}
+	creds, err := l.credentials.Retrieve(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get aws credentials: %v", err)
+	}
+	Sign(req, payload, creds, region, "lambda", time.Now())
+
+	resp, err := graph.HTTPClient(ctx).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to invoke lambda function %s: %v", spec.Function, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxLambdaResponse+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lambda response: %v", err)
+	}
+	if len(body) > maxLambdaResponse {
+		return nil, fmt.Errorf("lambda response exceeds %d bytes", maxLambdaResponse)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var apiErr struct {
+			Message string `json:"message"`
+			Type    string `json:"Type"`
+		}
+		msg := strings.TrimSpace(string(body))
+		if json.Unmarshal(body, &apiErr) == nil && apiErr.Message != "" {
+			msg = apiErr.Message
+		}
+		return nil, fmt.Errorf("lambda api returned %d for function %s: %s", resp.StatusCode, spec.Function, msg)
+	}
+	if kind := resp.Header.Get("X-Amz-Function-Error"); kind != "" {
+		ferr := &FunctionError{Function: spec.Function, Type: kind, Message: strings.TrimSpace(string(body))}
+		var payloadErr struct {
+			ErrorMessage string `json:"errorMessage"`
+			ErrorType    string `json:"errorType"`
+		}
+		if json.Unmarshal(body, &payloadErr) == nil && payloadErr.ErrorMessage != "" {
+			ferr.Message, ferr.Type = payloadErr.ErrorMessage, payloadErr.ErrorType
+		}
+		if log, err := base64.StdEncoding.DecodeString(resp.Header.Get("X-Amz-Log-Result")); err == nil {
+			ferr.Log = string(log)
+		}
+		return nil, ferr
+	}
+	return body, nil
+}
+
+// Execute 返回调用 spec 函数的任务函数，可直接作为 graph.Task 的 Execute：
+// 返回值是 JSON 时解码后作为任务结果，否则作为字符串
+func (l *Lambda) Execute(spec LambdaSpec) func(ctx context.Context, inputs map[string]interface{}) (interface{}, error) {
+	return func(ctx context.Context, inputs map[string]interface{}) (interface{}, error) {
+		payload, err := graph.RenderPayload(ctx, spec.Payload, inputs)
+		if err != nil {
+			return nil, err
+		}
+		body, err := l.Invoke(ctx, spec, payload)
+		if err != nil {
+			return nil, err
+		}
+		if spec.Async || len(bytes.TrimSpace(body)) == 0 {
+			graph.LoggerFrom(ctx).Debug("lambda function invoked", slog.String("function", spec.Function), slog.Bool("async", spec.Async))
+			return nil, nil
+		}
+		var result interface{}
+		if err := json.Unmarshal(body, &result); err != nil {
+			return string(body), nil
+		}
+		return result, nil
+	}
+}
+
+// TaskType 返回可在定义文件中使用的 aws_lambda 任务类型，
+// 通过 graph.RegisterTaskType 或 graph.WithTaskType 注册
+func (l *Lambda) TaskType() graph.TaskType {
+	return lambdaTaskType{lambda: l}
+}
+
+type lambdaTaskType struct {
+	lambda *Lambda
+}
+
+func (lambdaTaskType) Name() string {
+	return TaskTypeLambda
+}
+
+func (lambdaTaskType) ConfigSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type":     "object",
+		"required": []string{"function"},
+		"properties": map[string]interface{}{
+			"function":  map[string]interface{}{"type": "string"},
+			"qualifier": map[string]interface{}{"type": "string"},
+			"region":    map[string]interface{}{"type": "string"},
+			"async":     map[string]interface{}{"type": "boolean"},
+			"payload":   map[string]interface{}{"type": "string"},
+		},
+		"additionalProperties": false,
+	}
+}
+
+func (t lambdaTaskType) New(config map[string]interface{}) (func(ctx context.Context, inputs map[string]interface{}) (interface{}, error), error) {
+	spec := LambdaSpec{Function: config["function"].(string)}
+	spec.Qualifier, _ = config["qualifier"].(string)
+	spec.Region, _ = config["region"].(string)
+	spec.Async, _ = config["async"].(bool)
+	if text, ok := config["payload"].(string); ok {
+		tmpl, err := graph.ParsePayloadTemplate(text)
+		if err != nil {
+			return nil, err
+		}
+		spec.Payload = tmpl
+	}
+	return t.lambda.Execute(spec), nil
+}
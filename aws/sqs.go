@@ -0,0 +1,134 @@
+package aws
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"workflow/graph"
+	"workflow/publish"
+)
+
+// TaskTypeSQS 是向 SQS 队列发送消息的任务类型名称
+const TaskTypeSQS = "aws_sqs_send"
+
+// SQS 以 JSON 协议向队列发送消息，实现 publish.Publisher：消息的 Topic 是队列 URL，
+// Key 作为 FIFO 队列的 MessageGroupId，消息头作为 String 类型的消息属性
+type SQS struct {
+	client
+}
+
+// NewSQS 创建 SQS 客户端
+func NewSQS(opts ...Option) *SQS {
+	return &SQS{client: newClient(opts)}
+}
+
+// TaskType 返回 aws_sqs_send 任务类型，配置见 publish.NewTaskType，其中 topic 为队列 URL
+func (s *SQS) TaskType() graph.TaskType {
+	return publish.NewTaskType(TaskTypeSQS, s)
+}
+
+type sqsAttribute struct {
+	DataType    string
+	StringValue string
+}
+
+// Publish 发送消息，返回消息ID和 FIFO 队列的序列号。
+// FIFO 队列的 MessageDeduplicationId 由 run_id 和消息内容计算，同一次运行重试时不会重复投递
+func (s *SQS) Publish(ctx context.Context, msg publish.Message) (map[string]interface{}, error) {
+	queue, err := url.Parse(msg.Topic)
+	if err != nil || queue.Host == "" {
+		return nil, fmt.Errorf("invalid sqs queue url %q", msg.Topic)
+	}
+	region := s.region
+	// 队列 URL 形如 https://sqs.<region>.amazonaws.com/<account>/<name>
+	if parts := strings.Split(queue.Host, "."); len(parts) > 3 && parts[0] == "sqs" {
+		region = parts[1]
+	}
+	if region == "" {
+		return nil, fmt.Errorf("no region for sqs queue %s", msg.Topic)
+	}
+
+	input := map[string]interface{}{"QueueUrl": msg.Topic, "MessageBody": string(msg.Value)}
+	if len(msg.Key) > 0 {
+		input["MessageGroupId"] = string(msg.Key)
+	}
+	if strings.HasSuffix(queue.Path, ".fifo") {
+		sum := sha256.Sum256([]byte(graph.RunIDFrom(ctx) + "\x00" + string(msg.Value)))
+		input["MessageDeduplicationId"] = hex.EncodeToString(sum[:])
+	}
+	if len(msg.Headers) > 0 {
+		attrs := make(map[string]sqsAttribute, len(msg.Headers))
+		for k, v := range msg.Headers {
+			attrs[k] = sqsAttribute{DataType: "String", StringValue: v}
+		}
+		input["MessageAttributes"] = attrs
+	}
+	body, err := json.Marshal(input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode sqs request: %v", err)
+	}
+
+	endpoint := s.endpoint
+	if endpoint == "" {
+		endpoint = "https://sqs." + region + ".amazonaws.com"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint+"/", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.0")
+	req.Header.Set("X-Amz-Target", "AmazonSQS.SendMessage")
+	creds, err := s.credentials.Retrieve(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get aws credentials: %v", err)
+	}
+	Sign(req, body, creds, region, "sqs", time.Now())
+
+	resp, err := graph.HTTPClient(ctx).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send sqs message: %v", err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sqs response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Type    string `json:"__type"`
+			Message string `json:"message"`
+		}
+		msg := strings.TrimSpace(string(respBody))
+		if json.Unmarshal(respBody, &apiErr) == nil && apiErr.Type != "" {
+			// __type 形如 com.amazonaws.sqs#QueueDoesNotExist
+			msg = apiErr.Type[strings.LastIndex(apiErr.Type, "#")+1:] + ": " + apiErr.Message
+		}
+		return nil, fmt.Errorf("sqs returned %d: %s", resp.StatusCode, msg)
+	}
+	var out struct {
+		MessageId      string
+		SequenceNumber string
+	}
+	if err := json.Unmarshal(respBody, &out); err != nil {
+		return nil, fmt.Errorf("invalid sqs response: %v", err)
+	}
+	result := map[string]interface{}{"message_id": out.MessageId}
+	if out.SequenceNumber != "" {
+		result["sequence_number"] = out.SequenceNumber
+	}
+	return result, nil
+}
+
+// Close 实现 publish.Publisher，SQS 客户端没有需要释放的连接
+func (s *SQS) Close() error {
+	return nil
+}
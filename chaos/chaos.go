@@ -0,0 +1,206 @@
+// Package chaos 为集成测试注入故障：在任务执行中途杀掉运行、延迟和重复投递消息、
+// 丢弃 Store 的写入并返回错误，用于验证 Resume、去重、副作用日志等机制在故障下仍然成立，
+// 如副作用只生效一次（见 Ledger）。
+//
+// 故障由 Injector 按 Faults 中的概率以固定种子随机产生，失败的用例可以用相同的种子重现；
+// 包装函数在 Injector 为 nil 时原样返回被包装的对象，因此测试可以始终调用它们，
+// 只在设置了 WORKFLOW_CHAOS 环境变量时开启故障（见 FromEnv）
+package chaos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"workflow/graph"
+)
+
+var (
+	// ErrInjected 是注入的写入失败返回的错误
+	ErrInjected = errors.New("chaos: injected failure")
+	// ErrKilled 是被 Kill 杀掉的运行的取消原因
+	ErrKilled = errors.New("chaos: worker killed")
+)
+
+// Faults 是各类故障的概率（0 到 1）和时长上限，零值字段不注入对应的故障
+type Faults struct {
+	// Kill 是任务开始执行时杀掉整个运行的概率，见 Injector.Kill
+	Kill float64
+	// KillDelay 是决定杀掉运行后随机等待的最长时间，使运行在任务执行中途而不是开始时被杀掉
+	KillDelay time.Duration
+	// DeliveryDelay 是每条消息投递前随机延迟的最长时间
+	DeliveryDelay time.Duration
+	// Redeliver 是消息被确认后再次投递的概率，模拟至少一次投递的重复消息
+	Redeliver float64
+	// WriteFailure 是 Store 的每次写入被丢弃并返回 ErrInjected 的概率
+	WriteFailure float64
+}
+
+// DefaultFaults 是 FromEnv 使用的故障配置
+var DefaultFaults = Faults{
+	Kill:          0.1,
+	KillDelay:     50 * time.Millisecond,
+	DeliveryDelay: 20 * time.Millisecond,
+	Redeliver:     0.2,
+	WriteFailure:  0.05,
+}
+
+// Stats 是已注入的故障次数，测试可以据此确认故障确实发生过
+type Stats struct {
+	Kills         int
+	Delays        int
+	Redeliveries  int
+	WriteFailures int
+}
+
+// Injector 按 Faults 随机注入故障，可被多个 goroutine 并发使用
+type Injector struct {
+	faults Faults
+	seed   int64
+
+	mu    sync.Mutex
+	rand  *rand.Rand
+	stats Stats
+}
+
+// New 创建以 seed 为随机种子的故障注入器
+func New(seed int64, faults Faults) *Injector {
+	return &Injector{faults: faults, seed: seed, rand: rand.New(rand.NewSource(seed))}
+}
+
+// FromEnv 在设置了环境变量 WORKFLOW_CHAOS 时以其值为种子、DefaultFaults 为配置创建故障注入器，
+// 值为 "random" 时使用当前时间作为种子；未设置时返回 nil，即不注入故障
+func FromEnv() (*Injector, error) {
+	value := os.Getenv("WORKFLOW_CHAOS")
+	switch value {
+	case "":
+		return nil, nil
+	case "random":
+		return New(time.Now().UnixNano(), DefaultFaults), nil
+	}
+	seed, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid WORKFLOW_CHAOS seed %q: %v", value, err)
+	}
+	return New(seed, DefaultFaults), nil
+}
+
+// Seed 返回随机种子，测试失败时应输出它以便重现
+func (in *Injector) Seed() int64 {
+	return in.seed
+}
+
+// Stats 返回已注入的故障次数
+func (in *Injector) Stats() Stats {
+	if in == nil {
+		return Stats{}
+	}
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	return in.stats
+}
+
+// roll 以概率 p 返回 true，并在返回 true 时调用 count 记录故障
+func (in *Injector) roll(p float64, count func(s *Stats)) bool {
+	if in == nil || p <= 0 {
+		return false
+	}
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	if in.rand.Float64() >= p {
+		return false
+	}
+	count(&in.stats)
+	return true
+}
+
+// record 记录一次注入的故障
+func (in *Injector) record(count func(s *Stats)) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	count(&in.stats)
+}
+
+// duration 返回 [0, max) 内的随机时长
+func (in *Injector) duration(max time.Duration) time.Duration {
+	if in == nil || max <= 0 {
+		return 0
+	}
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	return time.Duration(in.rand.Int63n(int64(max)))
+}
+
+// Kill 返回的执行选项在任务开始执行时以 Faults.Kill 的概率调用 cancel(ErrKilled)，
+// 模拟执行运行的工作进程崩溃：正在执行的任务的上下文被取消，运行以失败结束，
+// 之后可以通过 NamespaceRegistry.RetryFailed 或 Engine.Resume 继续。cancel 通常来自 context.WithCancelCause：
+//
+//	ctx, cancel := context.WithCancelCause(ctx)
+//	defer cancel(nil)
+//	report, err := registry.Start(ctx, "orders", opts, injector.Kill(cancel))
+//
+// 执行选项中已有的 OnTaskTransition 仍会被调用
+func (in *Injector) Kill(cancel context.CancelCauseFunc) graph.ExecuteOption {
+	return func(o *graph.ExecuteOptions) {
+		if in == nil || in.faults.Kill <= 0 {
+			return
+		}
+		next := o.OnTaskTransition
+		o.OnTaskTransition = func(tr graph.TaskReport, from graph.TaskStatus) {
+			if next != nil {
+				next(tr, from)
+			}
+			if tr.Status != graph.TaskStatusRunning || !in.roll(in.faults.Kill, func(s *Stats) { s.Kills++ }) {
+				return
+			}
+			if delay := in.duration(in.faults.KillDelay); delay > 0 {
+				time.AfterFunc(delay, func() { cancel(ErrKilled) })
+				return
+			}
+			cancel(ErrKilled)
+		}
+	}
+}
+
+// Ledger 记录副作用实际发生的次数，用于断言在重试、杀掉和重新执行之后每个副作用只生效一次。
+// 在副作用函数（如 Journal.Once 的 fn）中调用 Perform，结束后检查 Duplicates
+type Ledger struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+// Perform 记录 key 对应的副作用发生了一次，返回包括本次在内的次数
+func (l *Ledger) Perform(key string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.counts == nil {
+		l.counts = make(map[string]int)
+	}
+	l.counts[key]++
+	return l.counts[key]
+}
+
+// Count 返回 key 对应的副作用发生的次数
+func (l *Ledger) Count(key string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.counts[key]
+}
+
+// Duplicates 返回发生了不止一次的副作用及其次数，没有时返回空映射表
+func (l *Ledger) Duplicates() map[string]int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	dups := make(map[string]int)
+	for key, n := range l.counts {
+		if n > 1 {
+			dups[key] = n
+		}
+	}
+	return dups
+}
@@ -0,0 +1,81 @@
+package chaos
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"workflow/trigger"
+)
+
+// Source 包装消息源：每条消息投递前随机延迟至多 Faults.DeliveryDelay，
+// 消息被确认后以 Faults.Redeliver 的概率再次投递，模拟消息系统的至少一次投递。
+// 重复投递的消息 ID 与原消息相同，其 Ack 和 Nack 不再传给原消息
+func (in *Injector) Source(source trigger.Source) trigger.Source {
+	if in == nil {
+		return source
+	}
+	return &faultySource{Source: source, in: in}
+}
+
+type faultySource struct {
+	trigger.Source
+	in *Injector
+
+	mu      sync.Mutex
+	pending []trigger.Message // 等待重复投递的消息
+}
+
+func (s *faultySource) Receive(ctx context.Context) (trigger.Message, error) {
+	if delay := s.in.duration(s.in.faults.DeliveryDelay); delay > 0 {
+		s.in.record(func(st *Stats) { st.Delays++ })
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	s.mu.Lock()
+	if len(s.pending) > 0 {
+		msg := s.pending[0]
+		s.pending = s.pending[1:]
+		s.mu.Unlock()
+		return &redelivered{Message: msg}, nil
+	}
+	s.mu.Unlock()
+
+	msg, err := s.Source.Receive(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &faultyMessage{Message: msg, source: s}, nil
+}
+
+// faultyMessage 是从原消息源收到的消息，确认后可能被安排重复投递
+type faultyMessage struct {
+	trigger.Message
+	source *faultySource
+}
+
+func (m *faultyMessage) Ack(ctx context.Context) error {
+	if err := m.Message.Ack(ctx); err != nil {
+		return err
+	}
+	if m.source.in.roll(m.source.in.faults.Redeliver, func(s *Stats) { s.Redeliveries++ }) {
+		m.source.mu.Lock()
+		m.source.pending = append(m.source.pending, m.Message)
+		m.source.mu.Unlock()
+	}
+	return nil
+}
+
+// redelivered 是重复投递的消息，它的原消息已经确认
+type redelivered struct {
+	trigger.Message
+}
+
+func (m *redelivered) Ack(ctx context.Context) error  { return nil }
+func (m *redelivered) Nack(ctx context.Context) error { return nil }
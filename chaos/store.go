@@ -0,0 +1,76 @@
+package chaos
+
+import (
+	"context"
+	"fmt"
+
+	"workflow/graph"
+)
+
+// Store 包装 store，使 SaveRun 以 Faults.WriteFailure 的概率丢弃写入并返回 ErrInjected，读取不受影响。
+// 返回的 Store 只实现 graph.Store，原 Store 的检查点、副作用日志等能力需要分别用
+// Checkpoints、Effects 包装后通过 ExecuteOptions 指定
+func (in *Injector) Store(store graph.Store) graph.Store {
+	if in == nil {
+		return store
+	}
+	return &faultyStore{Store: store, in: in}
+}
+
+type faultyStore struct {
+	graph.Store
+	in *Injector
+}
+
+func (s *faultyStore) SaveRun(ctx context.Context, record *graph.RunRecord) error {
+	if s.in.dropWrite() {
+		return fmt.Errorf("%w: save run %s", ErrInjected, record.RunID)
+	}
+	return s.Store.SaveRun(ctx, record)
+}
+
+// Effects 包装副作用日志，使 SaveEffect 以 Faults.WriteFailure 的概率失败，
+// 模拟副作用已经发生但没有记录下来的情况
+func (in *Injector) Effects(store graph.EffectStore) graph.EffectStore {
+	if in == nil {
+		return store
+	}
+	return &faultyEffects{EffectStore: store, in: in}
+}
+
+type faultyEffects struct {
+	graph.EffectStore
+	in *Injector
+}
+
+func (s *faultyEffects) SaveEffect(ctx context.Context, effect *graph.Effect) error {
+	if s.in.dropWrite() {
+		return fmt.Errorf("%w: save effect %s of task %s", ErrInjected, effect.Key, effect.TaskID)
+	}
+	return s.EffectStore.SaveEffect(ctx, effect)
+}
+
+// Checkpoints 包装检查点存储，使 SaveCheckpoint 以 Faults.WriteFailure 的概率失败
+func (in *Injector) Checkpoints(store graph.CheckpointStore) graph.CheckpointStore {
+	if in == nil {
+		return store
+	}
+	return &faultyCheckpoints{CheckpointStore: store, in: in}
+}
+
+type faultyCheckpoints struct {
+	graph.CheckpointStore
+	in *Injector
+}
+
+func (s *faultyCheckpoints) SaveCheckpoint(ctx context.Context, cp *graph.Checkpoint) error {
+	if s.in.dropWrite() {
+		return fmt.Errorf("%w: save checkpoint of task %s", ErrInjected, cp.TaskID)
+	}
+	return s.CheckpointStore.SaveCheckpoint(ctx, cp)
+}
+
+// dropWrite 判断本次写入是否应被丢弃
+func (in *Injector) dropWrite() bool {
+	return in.roll(in.faults.WriteFailure, func(s *Stats) { s.WriteFailures++ })
+}
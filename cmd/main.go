@@ -1,16 +1,82 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"workflow/engine"
 	"workflow/graph/example"
 )
 
 func main() {
-	fmt.Println("Running Task Example:")
-	fmt.Println("--------------------")
-	example.RunTaskExample()
+	// stress 子命令对合成的任务图压测，见 runStress
+	if len(os.Args) > 1 && os.Args[1] == "stress" {
+		os.Exit(runStress(os.Args[2:]))
+	}
+	// lint 子命令检查定义文件，见 runLint
+	if len(os.Args) > 1 && os.Args[1] == "lint" {
+		os.Exit(runLint(os.Args[2:]))
+	}
+
+	configPath := flag.String("config", "", "JSON 配置文件路径，WORKFLOW_ 前缀的环境变量会覆盖文件中的配置")
+	grace := flag.Duration("grace", 0, "收到 SIGINT/SIGTERM 后等待执行中任务结束的时间，为0时使用配置中的 shutdown_grace")
+	flag.Parse()
+
+	cfg, err := engine.LoadConfig(*configPath)
+	if err != nil {
+		fmt.Printf("Invalid configuration: %v\n", err)
+		os.Exit(1)
+	}
+	if *grace <= 0 {
+		*grace = time.Duration(cfg.ShutdownGrace)
+	}
+	e, err := engine.NewFromConfig(cfg)
+	if err != nil {
+		fmt.Printf("Failed to create engine: %v\n", err)
+		os.Exit(1)
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ctx := context.Background()
+
+		fmt.Println("Running Task Example:")
+		fmt.Println("--------------------")
+		example.RunTaskExample(ctx, e)
+
+		fmt.Println("\nRunning Conditional Example:")
+		fmt.Println("---------------------------")
+		example.RunConditionalExample(ctx, e)
+	}()
+
+	// 收到信号后优雅关闭：不再启动新的运行，等待执行中的任务结束，超时后中断并保存检查点；
+	// 关闭期间再次收到信号时按默认行为立即退出
+	sigCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	select {
+	case <-done:
+		stop()
+		return
+	case <-sigCtx.Done():
+		stop()
+	}
+
+	fmt.Printf("\nShutting down, waiting up to %v for running tasks...\n", *grace)
+	ctx, cancel := context.WithTimeout(context.Background(), *grace)
+	defer cancel()
+	err = e.Shutdown(ctx)
+	<-done
 
-	fmt.Println("\nRunning Conditional Example:")
-	fmt.Println("---------------------------")
-	example.RunConditionalExample()
+	for _, run := range e.Interrupted() {
+		fmt.Printf("Interrupted run %s of %s/%s, completed tasks are checkpointed in the store.\n", run.RunID, run.Namespace, run.Workflow)
+		fmt.Printf("  resume: engine.Resume(ctx, %q, %q, graph.ExecuteOptions{})\n", run.Namespace, run.RunID)
+	}
+	if err != nil {
+		fmt.Printf("Shutdown: %v\n", err)
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,386 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"os"
+	"os/signal"
+	"runtime"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	"workflow/graph"
+)
+
+// stressOptions 是 stress 子命令的参数
+type stressOptions struct {
+	tasks       int
+	fanout      int
+	duration    time.Duration
+	concurrency int
+	workers     int
+	work        time.Duration
+	errorRate   float64
+	strategy    string
+	interval    time.Duration
+	seed        int64
+}
+
+// discardLogger 丢弃运行的日志，避免注入的任务失败刷屏
+var discardLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// runStress 实现 stress 子命令：反复执行合成的任务图，按间隔输出进度，结束时输出调度开销、内存和错误率。
+// 返回进程退出码，有运行因引擎错误失败时为1
+func runStress(args []string) int {
+	var opts stressOptions
+	fs := flag.NewFlagSet("stress", flag.ExitOnError)
+	fs.IntVar(&opts.tasks, "tasks", 1000, "每个合成任务图的任务数")
+	fs.IntVar(&opts.fanout, "fanout", 10, "每个任务的下游任务数，任务图是以此为分支数的树")
+	fs.DurationVar(&opts.duration, "duration", time.Minute, "压测持续时间")
+	fs.IntVar(&opts.concurrency, "concurrency", 4, "同时执行的运行数")
+	fs.IntVar(&opts.workers, "workers", 16, "每个运行的并发数（ExecuteOptions.WorkerCount）")
+	fs.DurationVar(&opts.work, "work", 0, "每个任务模拟的耗时，为0时只测量引擎本身的开销")
+	fs.Float64Var(&opts.errorRate, "error-rate", 0, "任务注入失败的概率；合成任务都是可选任务，失败不会使运行失败")
+	fs.StringVar(&opts.strategy, "strategy", "", "调度方式：layered 或 work-stealing，为空时使用默认方式")
+	fs.DurationVar(&opts.interval, "interval", 10*time.Second, "输出进度的间隔")
+	fs.Int64Var(&opts.seed, "seed", 1, "合成任务失败的随机种子")
+	fs.Parse(args)
+	if opts.tasks <= 0 || opts.fanout <= 0 || opts.concurrency <= 0 || opts.workers <= 0 || opts.duration <= 0 {
+		fmt.Println("tasks, fanout, concurrency, workers and duration must be positive")
+		return 2
+	}
+	if opts.errorRate < 0 || opts.errorRate > 1 {
+		fmt.Println("error-rate must be between 0 and 1")
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	fmt.Printf("Stress: %d tasks, fanout %d, %d concurrent runs x %d workers, work %v, error rate %.2f%%, for %v\n",
+		opts.tasks, opts.fanout, opts.concurrency, opts.workers, opts.work, opts.errorRate*100, opts.duration)
+	execOpts := graph.ExecuteOptions{
+		WorkerCount: opts.workers,
+		Strategy:    graph.Strategy(opts.strategy),
+		Logger:      discardLogger,
+	}
+
+	// 每个执行者使用自己的任务图，任务状态不会在并发的运行之间共享。
+	// 任务图的第一次运行会编译执行计划，在计时开始前完成，不计入压测结果
+	graphs := make([]*graph.TaskGraph, opts.concurrency)
+	var ideal time.Duration
+	prepareStart := time.Now()
+	var prepare sync.WaitGroup
+	prepareErrs := make([]error, opts.concurrency)
+	for i := range graphs {
+		tg, layers, err := syntheticGraph(opts, rand.New(rand.NewSource(opts.seed+int64(i))))
+		if err != nil {
+			fmt.Printf("Failed to build synthetic graph: %v\n", err)
+			return 1
+		}
+		if i == 0 {
+			ideal = idealDuration(opts, layers)
+			fmt.Printf("Graph: %d layers, ideal run duration %v\n", len(layers), ideal)
+		}
+		graphs[i] = tg
+		prepare.Add(1)
+		go func(i int) {
+			defer prepare.Done()
+			_, prepareErrs[i] = tg.ExecuteWithReport(ctx, execOpts)
+		}(i)
+	}
+	prepare.Wait()
+	if ctx.Err() != nil {
+		return 1
+	}
+	for _, err := range prepareErrs {
+		if err != nil {
+			fmt.Printf("Warm-up run failed: %v\n", err)
+			return 1
+		}
+	}
+	fmt.Printf("Prepared %d graphs in %v (first run compiles the execution plan)\n",
+		len(graphs), time.Since(prepareStart).Truncate(time.Millisecond))
+
+	ctx, cancel := context.WithTimeout(ctx, opts.duration)
+	defer cancel()
+	s := newStressStats(opts)
+	s.ideal = ideal
+	go s.sampleMemory(ctx)
+
+	var wg sync.WaitGroup
+	for _, tg := range graphs {
+		wg.Add(1)
+		go func(tg *graph.TaskGraph) {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				report, err := tg.ExecuteWithReport(ctx, execOpts)
+				// 压测时间到时被中断的运行不计入
+				if ctx.Err() != nil {
+					return
+				}
+				s.add(report, err)
+			}
+		}(tg)
+	}
+
+	ticker := time.NewTicker(opts.interval)
+	defer ticker.Stop()
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	for {
+		select {
+		case <-ticker.C:
+			s.progress()
+		case <-done:
+			return s.summary()
+		}
+	}
+}
+
+// syntheticGraph 生成以 fanout 为分支数的树形任务图，返回任务图和各层的任务数
+func syntheticGraph(opts stressOptions, rng *rand.Rand) (*graph.TaskGraph, []int, error) {
+	tg := graph.NewTaskGraph()
+	tasks := make([]*graph.Task, opts.tasks)
+	depth := make([]int, opts.tasks)
+	var layers []int
+	var mu sync.Mutex
+	for i := range tasks {
+		task := &graph.Task{
+			ID:       fmt.Sprintf("t%05d", i),
+			Optional: true,
+			Execute: func(ctx context.Context, inputs map[string]interface{}) (interface{}, error) {
+				if opts.work > 0 {
+					timer := time.NewTimer(opts.work)
+					select {
+					case <-ctx.Done():
+						timer.Stop()
+						return nil, ctx.Err()
+					case <-timer.C:
+					}
+				}
+				mu.Lock()
+				fail := opts.errorRate > 0 && rng.Float64() < opts.errorRate
+				mu.Unlock()
+				if fail {
+					return nil, fmt.Errorf("injected failure")
+				}
+				return len(inputs), nil
+			},
+		}
+		if i > 0 {
+			parent := (i - 1) / opts.fanout
+			task.Depends = []*graph.Task{tasks[parent]}
+			depth[i] = depth[parent] + 1
+		}
+		if depth[i] == len(layers) {
+			layers = append(layers, 0)
+		}
+		layers[depth[i]]++
+		if err := tg.AddTask(task); err != nil {
+			return nil, nil, err
+		}
+		tasks[i] = task
+	}
+	return tg, layers, nil
+}
+
+// idealDuration 是没有调度开销时一次运行的耗时：按层执行时每层需要 ceil(任务数/并发数) 轮，
+// 工作窃取时受关键路径和总工作量的限制
+func idealDuration(opts stressOptions, layers []int) time.Duration {
+	if opts.work <= 0 {
+		return 0
+	}
+	if graph.Strategy(opts.strategy) == graph.StrategyWorkStealing {
+		rounds := (opts.tasks + opts.workers - 1) / opts.workers
+		if len(layers) > rounds {
+			rounds = len(layers)
+		}
+		return time.Duration(rounds) * opts.work
+	}
+	var rounds int
+	for _, n := range layers {
+		rounds += (n + opts.workers - 1) / opts.workers
+	}
+	return time.Duration(rounds) * opts.work
+}
+
+// maxSamples 是计算分位数时保留的耗时样本数，超过后按蓄水池抽样替换
+const maxSamples = 100000
+
+// stressStats 汇总压测结果，可被多个执行者并发更新
+type stressStats struct {
+	opts  stressOptions
+	start time.Time
+	ideal time.Duration
+
+	mu          sync.Mutex
+	runs        int
+	runErrors   int
+	lastErr     error
+	tasks       int
+	taskErrors  int
+	runTime     []time.Duration
+	queueWait   []time.Duration
+	seen        int // 已经见过的等待时间样本数，用于蓄水池抽样
+	rng         *rand.Rand
+	peakHeap    uint64
+	peakRoutine int
+	lastRuns    int
+	lastTime    time.Time
+}
+
+func newStressStats(opts stressOptions) *stressStats {
+	now := time.Now()
+	return &stressStats{opts: opts, start: now, lastTime: now, rng: rand.New(rand.NewSource(opts.seed))}
+}
+
+// add 记录一次运行的结果
+func (s *stressStats) add(report *graph.ExecutionReport, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.runs++
+	if err != nil {
+		s.runErrors++
+		s.lastErr = err
+	}
+	if report == nil {
+		return
+	}
+	s.runTime = append(s.runTime, report.Duration)
+	for _, tr := range report.Tasks {
+		s.tasks++
+		if tr.Status == graph.TaskStatusFailed {
+			s.taskErrors++
+		}
+		if tr.StartTime.IsZero() {
+			continue
+		}
+		s.seen++
+		if len(s.queueWait) < maxSamples {
+			s.queueWait = append(s.queueWait, tr.QueueWait)
+		} else if j := s.rng.Intn(s.seen); j < maxSamples {
+			s.queueWait[j] = tr.QueueWait
+		}
+	}
+}
+
+// sampleMemory 每秒记录一次堆内存和 goroutine 数的峰值
+func (s *stressStats) sampleMemory(ctx context.Context) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		var m runtime.MemStats
+		runtime.ReadMemStats(&m)
+		routines := runtime.NumGoroutine()
+		s.mu.Lock()
+		if m.HeapAlloc > s.peakHeap {
+			s.peakHeap = m.HeapAlloc
+		}
+		if routines > s.peakRoutine {
+			s.peakRoutine = routines
+		}
+		s.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// progress 输出自上次进度以来的吞吐量和当前内存
+func (s *stressStats) progress() {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	rate := float64(s.runs-s.lastRuns) / now.Sub(s.lastTime).Seconds()
+	s.lastRuns, s.lastTime = s.runs, now
+	fmt.Printf("[%v] runs %d (%.1f/s), run errors %d, task error rate %.2f%%, heap %s, goroutines %d\n",
+		now.Sub(s.start).Truncate(time.Second), s.runs, rate, s.runErrors, percent(s.taskErrors, s.tasks),
+		formatBytes(m.HeapAlloc), runtime.NumGoroutine())
+}
+
+// summary 输出最终结果并返回退出码
+func (s *stressStats) summary() int {
+	runtime.GC()
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	elapsed := time.Since(s.start)
+
+	fmt.Println()
+	fmt.Println("Results")
+	fmt.Println("-------")
+	fmt.Printf("Duration:        %v\n", elapsed.Truncate(time.Millisecond))
+	fmt.Printf("Runs:            %d (%.2f/s)\n", s.runs, float64(s.runs)/elapsed.Seconds())
+	fmt.Printf("Tasks:           %d (%.0f/s)\n", s.tasks, float64(s.tasks)/elapsed.Seconds())
+	if len(s.runTime) > 0 {
+		p50, p99 := quantile(s.runTime, 0.50), quantile(s.runTime, 0.99)
+		fmt.Printf("Run duration:    p50 %v, p99 %v, max %v\n", p50, p99, quantile(s.runTime, 1))
+		// 调度开销：实际耗时超出理想耗时的部分平摊到每个任务，没有模拟耗时时即为每个任务的引擎开销
+		overhead := p50 - s.ideal
+		if overhead < 0 {
+			overhead = 0
+		}
+		fmt.Printf("Sched overhead:  %v per task (p50 run %v vs ideal %v)\n",
+			overhead/time.Duration(s.opts.tasks), p50, s.ideal)
+	}
+	if len(s.queueWait) > 0 {
+		fmt.Printf("Queue wait:      p50 %v, p99 %v, max %v\n",
+			quantile(s.queueWait, 0.50), quantile(s.queueWait, 0.99), quantile(s.queueWait, 1))
+	}
+	fmt.Printf("Memory:          peak heap %s, heap after GC %s, %d GCs (pause total %v), peak goroutines %d\n",
+		formatBytes(s.peakHeap), formatBytes(m.HeapAlloc), m.NumGC, time.Duration(m.PauseTotalNs), s.peakRoutine)
+	fmt.Printf("Task errors:     %d (%.2f%%, injected %.2f%%)\n", s.taskErrors, percent(s.taskErrors, s.tasks), s.opts.errorRate*100)
+	fmt.Printf("Run errors:      %d (%.2f%%)\n", s.runErrors, percent(s.runErrors, s.runs))
+	if s.runErrors > 0 {
+		fmt.Printf("Last run error:  %v\n", s.lastErr)
+		return 1
+	}
+	return 0
+}
+
+// quantile 返回样本的 q 分位数，会对 samples 原地排序
+func quantile(samples []time.Duration, q float64) time.Duration {
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+	i := int(q*float64(len(samples))+0.5) - 1
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(samples) {
+		i = len(samples) - 1
+	}
+	return samples[i]
+}
+
+func percent(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) * 100 / float64(total)
+}
+
+func formatBytes(n uint64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1f GiB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MiB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KiB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", n)
+}
@@ -0,0 +1,432 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"workflow/graph"
+)
+
+// EnvPrefix 是从环境变量读取配置时使用的前缀，如 WORKFLOW_WORKER_COUNT
+const EnvPrefix = "WORKFLOW_"
+
+// Duration 是配置文件中的时长，JSON 中以 "1.5s"、"2m" 这样的字符串表示
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"2s\": %v", err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// Config 是引擎的声明式配置，可从 JSON 文件和环境变量加载，通过 NewFromConfig 创建引擎
+type Config struct {
+	// WorkerCount 是每个运行默认的并发数，Executors 是命名执行器及其并发数
+	WorkerCount int            `json:"worker_count"`
+	Executors   map[string]int `json:"executors,omitempty"`
+	// GlobalSlots 是进程内所有运行共享的工作者名额，为0时不限制
+	GlobalSlots int `json:"global_slots,omitempty"`
+
+	// MaxInFlightRuns 是同时执行的运行数上限，为0时不限制；
+	// 超出时最多 AdmissionQueue 个运行等待 AdmissionTimeout
+	MaxInFlightRuns  int      `json:"max_in_flight_runs,omitempty"`
+	AdmissionQueue   int      `json:"admission_queue,omitempty"`
+	AdmissionTimeout Duration `json:"admission_timeout,omitempty"`
+
+	// DefaultTimeout 和 DefaultRetries 是未设置 Timeout 或 Retries 的任务使用的默认值
+	DefaultTimeout Duration `json:"default_timeout,omitempty"`
+	DefaultRetries int      `json:"default_retries,omitempty"`
+
+	// StoreDSN 指定保存运行记录的 Store，如 "memory://"；其他协议需通过 RegisterStoreDriver 注册
+	StoreDSN string `json:"store_dsn,omitempty"`
+	// Codec 是持久化任务输出时默认的编码名称，为空时使用 JSON；其他编码需通过 graph.RegisterCodec 注册
+	Codec string `json:"codec,omitempty"`
+	// SpillThreshold 大于0时，编码后超过该字节数的任务结果溢出到 SpillDir（为空时为系统临时目录）
+	SpillThreshold int    `json:"spill_threshold,omitempty"`
+	SpillDir       string `json:"spill_dir,omitempty"`
+	// MaxResultBytes 大于0时开启结果内存统计，运行持有的任务结果超过该字节数时失败
+	MaxResultBytes int `json:"max_result_bytes,omitempty"`
+	// ProfileLabels 为 true 时设置 task_id 和 run_id 的 pprof 标签，CPU profile 可按任务筛选
+	ProfileLabels bool `json:"profile_labels,omitempty"`
+	// EventLogPath 设置时把任务事件以 JSON Lines 追加写入该文件
+	EventLogPath string `json:"event_log_path,omitempty"`
+	// ResultEncryptionKey 是 base64 编码的 AES 主密钥，设置后任务结果在持久化前加密
+	ResultEncryptionKey string `json:"result_encryption_key,omitempty"`
+	// TelemetryEndpoint 是遥测数据的上报地址，供使用 Config 的遥测导出器读取，引擎本身不上报
+	TelemetryEndpoint string `json:"telemetry_endpoint,omitempty"`
+	// OpenLineageURL 设置时向该地址（如 http://marquez:5000/api/v1/lineage）发送 OpenLineage 事件，
+	// OpenLineageNamespace 为空时使用工作流的命名空间，OpenLineageAPIKey 不为空时作为 Bearer 令牌
+	OpenLineageURL       string `json:"openlineage_url,omitempty"`
+	OpenLineageNamespace string `json:"openlineage_namespace,omitempty"`
+	OpenLineageAPIKey    string `json:"openlineage_api_key,omitempty"`
+
+	// DefinitionsDir 是启动时加载的工作流定义目录，DefinitionsPoll 大于0时按该间隔热加载变更
+	DefinitionsDir  string   `json:"definitions_dir,omitempty"`
+	DefinitionsPoll Duration `json:"definitions_poll,omitempty"`
+	// DefinitionsGitRepo 设置时从该仓库的 DefinitionsGitBranch 分支的 DefinitionsGitPath 目录同步定义，
+	// 每隔 DefinitionsPoll 拉取一次
+	DefinitionsGitRepo   string `json:"definitions_git_repo,omitempty"`
+	DefinitionsGitBranch string `json:"definitions_git_branch,omitempty"`
+	DefinitionsGitPath   string `json:"definitions_git_path,omitempty"`
+
+	// ListenAddr 是 REST 服务的监听地址，ShutdownGrace 是关闭时等待执行中运行的时间
+	ListenAddr    string   `json:"listen_addr,omitempty"`
+	ShutdownGrace Duration `json:"shutdown_grace,omitempty"`
+}
+
+// DefaultConfig 返回默认配置
+func DefaultConfig() Config {
+	return Config{
+		WorkerCount:   5,
+		StoreDSN:      "memory://",
+		ListenAddr:    ":8080",
+		ShutdownGrace: Duration(30 * time.Second),
+	}
+}
+
+// LoadConfig 依次应用默认配置、path 指定的 JSON 文件（为空时跳过）和 WORKFLOW_ 前缀的环境变量，
+// 校验后返回配置
+func LoadConfig(path string) (*Config, error) {
+	cfg := DefaultConfig()
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config: %v", err)
+		}
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&cfg); err != nil {
+			return nil, fmt.Errorf("failed to parse config %s: %v", path, err)
+		}
+	}
+	if err := cfg.ApplyEnv(os.LookupEnv); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// ApplyEnv 用环境变量覆盖配置项，变量名为 EnvPrefix 加上配置项 JSON 名称的大写形式，
+// 如 WORKFLOW_DEFAULT_TIMEOUT=2s；WORKFLOW_EXECUTORS 的格式为 "cpu=4,io=16"
+func (c *Config) ApplyEnv(lookup func(key string) (string, bool)) error {
+	ints := map[string]*int{
+		"WORKER_COUNT":       &c.WorkerCount,
+		"GLOBAL_SLOTS":       &c.GlobalSlots,
+		"MAX_IN_FLIGHT_RUNS": &c.MaxInFlightRuns,
+		"ADMISSION_QUEUE":    &c.AdmissionQueue,
+		"DEFAULT_RETRIES":    &c.DefaultRetries,
+		"SPILL_THRESHOLD":    &c.SpillThreshold,
+		"MAX_RESULT_BYTES":   &c.MaxResultBytes,
+	}
+	bools := map[string]*bool{
+		"PROFILE_LABELS": &c.ProfileLabels,
+	}
+	durations := map[string]*Duration{
+		"ADMISSION_TIMEOUT": &c.AdmissionTimeout,
+		"DEFAULT_TIMEOUT":   &c.DefaultTimeout,
+		"SHUTDOWN_GRACE":    &c.ShutdownGrace,
+		"DEFINITIONS_POLL":  &c.DefinitionsPoll,
+	}
+	strs := map[string]*string{
+		"STORE_DSN":              &c.StoreDSN,
+		"CODEC":                  &c.Codec,
+		"SPILL_DIR":              &c.SpillDir,
+		"EVENT_LOG_PATH":         &c.EventLogPath,
+		"RESULT_ENCRYPTION_KEY":  &c.ResultEncryptionKey,
+		"TELEMETRY_ENDPOINT":     &c.TelemetryEndpoint,
+		"OPENLINEAGE_URL":        &c.OpenLineageURL,
+		"OPENLINEAGE_NAMESPACE":  &c.OpenLineageNamespace,
+		"OPENLINEAGE_API_KEY":    &c.OpenLineageAPIKey,
+		"LISTEN_ADDR":            &c.ListenAddr,
+		"DEFINITIONS_DIR":        &c.DefinitionsDir,
+		"DEFINITIONS_GIT_REPO":   &c.DefinitionsGitRepo,
+		"DEFINITIONS_GIT_BRANCH": &c.DefinitionsGitBranch,
+		"DEFINITIONS_GIT_PATH":   &c.DefinitionsGitPath,
+	}
+
+	var errs []error
+	for name, dst := range ints {
+		if v, ok := lookup(EnvPrefix + name); ok {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s%s: %v", EnvPrefix, name, err))
+				continue
+			}
+			*dst = n
+		}
+	}
+	for name, dst := range bools {
+		if v, ok := lookup(EnvPrefix + name); ok {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s%s: %v", EnvPrefix, name, err))
+				continue
+			}
+			*dst = b
+		}
+	}
+	for name, dst := range durations {
+		if v, ok := lookup(EnvPrefix + name); ok {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s%s: %v", EnvPrefix, name, err))
+				continue
+			}
+			*dst = Duration(d)
+		}
+	}
+	for name, dst := range strs {
+		if v, ok := lookup(EnvPrefix + name); ok {
+			*dst = v
+		}
+	}
+	if v, ok := lookup(EnvPrefix + "EXECUTORS"); ok {
+		executors, err := parseExecutors(v)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%sEXECUTORS: %v", EnvPrefix, err))
+		} else {
+			c.Executors = executors
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// parseExecutors 解析 "cpu=4,io=16" 格式的命名执行器配置
+func parseExecutors(v string) (map[string]int, error) {
+	executors := make(map[string]int)
+	for _, part := range strings.Split(v, ",") {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+		name, count, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid executor %q, expected name=concurrency", part)
+		}
+		n, err := strconv.Atoi(count)
+		if err != nil {
+			return nil, fmt.Errorf("invalid concurrency for executor %s: %v", name, err)
+		}
+		executors[strings.TrimSpace(name)] = n
+	}
+	return executors, nil
+}
+
+// Validate 检查配置，返回所有问题合并后的错误
+func (c *Config) Validate() error {
+	var errs []error
+	if c.WorkerCount <= 0 {
+		errs = append(errs, fmt.Errorf("worker_count must be positive, got %d", c.WorkerCount))
+	}
+	for _, name := range sortedNames(c.Executors) {
+		if c.Executors[name] <= 0 {
+			errs = append(errs, fmt.Errorf("executor %s concurrency must be positive, got %d", name, c.Executors[name]))
+		}
+	}
+	if c.GlobalSlots < 0 {
+		errs = append(errs, fmt.Errorf("global_slots must not be negative, got %d", c.GlobalSlots))
+	}
+	if c.MaxInFlightRuns < 0 {
+		errs = append(errs, fmt.Errorf("max_in_flight_runs must not be negative, got %d", c.MaxInFlightRuns))
+	}
+	if c.MaxInFlightRuns == 0 && (c.AdmissionQueue != 0 || c.AdmissionTimeout != 0) {
+		errs = append(errs, fmt.Errorf("admission_queue and admission_timeout require max_in_flight_runs"))
+	}
+	if c.AdmissionQueue < 0 || c.AdmissionTimeout < 0 || c.DefaultTimeout < 0 || c.ShutdownGrace < 0 || c.DefinitionsPoll < 0 {
+		errs = append(errs, fmt.Errorf("admission_queue, admission_timeout, default_timeout, shutdown_grace and definitions_poll must not be negative"))
+	}
+	if c.DefaultRetries < 0 {
+		errs = append(errs, fmt.Errorf("default_retries must not be negative, got %d", c.DefaultRetries))
+	}
+	if c.DefinitionsDir == "" && c.DefinitionsGitRepo == "" && c.DefinitionsPoll != 0 {
+		errs = append(errs, fmt.Errorf("definitions_poll requires definitions_dir or definitions_git_repo"))
+	}
+	if c.DefinitionsGitRepo == "" && (c.DefinitionsGitBranch != "" || c.DefinitionsGitPath != "") {
+		errs = append(errs, fmt.Errorf("definitions_git_branch and definitions_git_path require definitions_git_repo"))
+	}
+	if _, err := storeDriver(c.StoreDSN); err != nil {
+		errs = append(errs, err)
+	}
+	if c.MaxResultBytes < 0 {
+		errs = append(errs, fmt.Errorf("max_result_bytes must not be negative, got %d", c.MaxResultBytes))
+	}
+	if c.SpillThreshold < 0 {
+		errs = append(errs, fmt.Errorf("spill_threshold must not be negative, got %d", c.SpillThreshold))
+	}
+	if c.SpillThreshold == 0 && c.SpillDir != "" {
+		errs = append(errs, fmt.Errorf("spill_dir requires spill_threshold"))
+	}
+	if _, ok := graph.LookupCodec(c.Codec); !ok {
+		errs = append(errs, fmt.Errorf("no codec registered for %q", c.Codec))
+	}
+	if c.ResultEncryptionKey != "" {
+		if _, err := c.resultKMS(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if c.TelemetryEndpoint != "" {
+		if u, err := url.Parse(c.TelemetryEndpoint); err != nil || u.Scheme == "" || u.Host == "" {
+			errs = append(errs, fmt.Errorf("telemetry_endpoint must be an absolute URL, got %q", c.TelemetryEndpoint))
+		}
+	}
+	if c.OpenLineageURL != "" {
+		if u, err := url.Parse(c.OpenLineageURL); err != nil || u.Scheme == "" || u.Host == "" {
+			errs = append(errs, fmt.Errorf("openlineage_url must be an absolute URL, got %q", c.OpenLineageURL))
+		}
+	} else if c.OpenLineageNamespace != "" || c.OpenLineageAPIKey != "" {
+		errs = append(errs, fmt.Errorf("openlineage_namespace and openlineage_api_key require openlineage_url"))
+	}
+	return errors.Join(errs...)
+}
+
+// Options 将配置转换为引擎选项，会按 StoreDSN 打开 Store
+func (c *Config) Options() ([]Option, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	open, _ := storeDriver(c.StoreDSN)
+	store, err := open(c.StoreDSN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open store %s: %v", c.StoreDSN, err)
+	}
+
+	opts := []Option{
+		WithStore(store),
+		WithWorkers(c.WorkerCount, c.Executors),
+		WithTaskDefaults(time.Duration(c.DefaultTimeout), c.DefaultRetries),
+	}
+	if c.Codec != "" {
+		opts = append(opts, WithCodec(c.Codec))
+	}
+	if c.MaxResultBytes > 0 {
+		opts = append(opts, WithMemoryAccounting(int64(c.MaxResultBytes)))
+	}
+	if c.ProfileLabels {
+		opts = append(opts, WithProfileLabels())
+	}
+	if c.SpillThreshold > 0 {
+		var artifacts graph.ArtifactStore
+		if c.SpillDir != "" {
+			if artifacts, err = graph.NewFileArtifactStore(c.SpillDir); err != nil {
+				return nil, err
+			}
+		}
+		opts = append(opts, WithSpillover(int64(c.SpillThreshold), artifacts))
+	}
+	if c.EventLogPath != "" {
+		log, err := graph.OpenEventLog(c.EventLogPath, nil)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, WithEventLog(log))
+	}
+	if c.ResultEncryptionKey != "" {
+		kms, _ := c.resultKMS()
+		opts = append(opts, WithResultEncryption(kms))
+	}
+	if c.OpenLineageURL != "" {
+		transport := graph.NewOpenLineageHTTPTransport(c.OpenLineageURL, c.OpenLineageAPIKey, nil)
+		opts = append(opts, WithOpenLineage(graph.NewOpenLineageEmitter(transport, graph.WithOpenLineageNamespace(c.OpenLineageNamespace))))
+	}
+	if c.GlobalSlots > 0 {
+		opts = append(opts, WithGlobalSlots(c.GlobalSlots))
+	}
+	if c.MaxInFlightRuns > 0 {
+		opts = append(opts, WithAdmission(c.MaxInFlightRuns, graph.WithAdmissionQueue(c.AdmissionQueue, time.Duration(c.AdmissionTimeout))))
+	}
+	if c.DefinitionsDir != "" {
+		opts = append(opts, WithDefinitions(c.DefinitionsDir, time.Duration(c.DefinitionsPoll)))
+	}
+	if c.DefinitionsGitRepo != "" {
+		opts = append(opts, WithGitDefinitions(c.DefinitionsGitRepo, c.DefinitionsGitBranch, c.DefinitionsGitPath, time.Duration(c.DefinitionsPoll)))
+	}
+	return opts, nil
+}
+
+// resultKMS 根据 ResultEncryptionKey 创建本地 KMS
+func (c *Config) resultKMS() (graph.KMS, error) {
+	key, err := base64.StdEncoding.DecodeString(c.ResultEncryptionKey)
+	if err != nil {
+		return nil, fmt.Errorf("result_encryption_key must be base64: %v", err)
+	}
+	kms, err := graph.NewLocalKMS(key)
+	if err != nil {
+		return nil, fmt.Errorf("result_encryption_key: %v", err)
+	}
+	return kms, nil
+}
+
+// NewFromConfig 根据配置创建引擎，extra 中的选项在配置之后应用
+func NewFromConfig(cfg *Config, extra ...Option) (*Engine, error) {
+	opts, err := cfg.Options()
+	if err != nil {
+		return nil, err
+	}
+	return New(append(opts, extra...)...)
+}
+
+// StoreDriver 根据 DSN 打开 Store
+type StoreDriver func(dsn string) (graph.Store, error)
+
+var (
+	storeDriversMu sync.RWMutex
+	storeDrivers   = map[string]StoreDriver{
+		"memory": func(string) (graph.Store, error) { return graph.NewMemoryStore(), nil },
+	}
+)
+
+// RegisterStoreDriver 注册 DSN 协议（如 "postgres"）对应的 Store 驱动，通常在驱动包的 init 中调用
+func RegisterStoreDriver(scheme string, driver StoreDriver) {
+	storeDriversMu.Lock()
+	defer storeDriversMu.Unlock()
+	storeDrivers[scheme] = driver
+}
+
+// storeDriver 返回 DSN 协议对应的驱动，DSN 为空时使用内存 Store
+func storeDriver(dsn string) (StoreDriver, error) {
+	scheme := "memory"
+	if dsn != "" {
+		var ok bool
+		if scheme, _, ok = strings.Cut(dsn, "://"); !ok {
+			return nil, fmt.Errorf("store_dsn %q must have the form scheme://...", dsn)
+		}
+	}
+	storeDriversMu.RLock()
+	defer storeDriversMu.RUnlock()
+	driver, ok := storeDrivers[scheme]
+	if !ok {
+		return nil, fmt.Errorf("no store driver registered for %q", scheme)
+	}
+	return driver, nil
+}
+
+func sortedNames(m map[string]int) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
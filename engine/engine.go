@@ -0,0 +1,612 @@
+// Package engine 提供长期运行的工作流引擎服务对象：一次构造即组装好注册表、Store、
+// 执行器并发、全局调度器、准入控制和 REST 服务，并负责运行的生命周期和优雅关闭
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"workflow/graph"
+	"workflow/server"
+)
+
+// ErrShuttingDown 表示引擎正在关闭，不再接受新的运行
+var ErrShuttingDown = errors.New("engine is shutting down")
+
+// InterruptedRun 是关闭时未能在宽限期内结束而被中断的运行。
+// 中断的运行以失败状态和已完成任务的报告保存在 Store 中，可通过 Resume 只重新执行未完成的任务
+type InterruptedRun struct {
+	Namespace string
+	Workflow  string
+	RunID     string
+}
+
+// Engine 是长期运行的工作流引擎。通过 Start、Resume 和 REST 服务启动的运行都会被跟踪，
+// 以便在关闭时排空或中断
+type Engine struct {
+	registry      *graph.Registry
+	store         graph.Store
+	logger        *slog.Logger
+	scheduler     *graph.Scheduler
+	admission     *graph.AdmissionController
+	services      *graph.Services
+	lineage       *graph.OpenLineageEmitter
+	codec         string
+	spill         *graph.SpillOptions
+	profileLabels bool
+	eventLog      *graph.EventLog
+	rates         graph.RateStore
+	locks         graph.LockStore
+	// maxResultBytes 为负数时不开启结果内存统计
+	maxResultBytes int64
+	kms            graph.KMS
+	redaction      []graph.RedactionRule
+
+	// 构造选项，在 New 中组装为注册表和服务
+	workerCount   int
+	executors     map[string]int
+	taskTimeout   time.Duration
+	taskRetries   int
+	slots         int
+	schedulerOpts []graph.SchedulerOption
+	maxInFlight   int
+	admissionOpts []graph.AdmissionOption
+	serverOpts    []server.Option
+	defsDir       string
+	defsInterval  time.Duration
+	defsOpts      []graph.DefinitionOption
+	gitRepo       string
+	gitBranch     string
+	gitPath       string
+	gitInterval   time.Duration
+
+	definitions *graph.DefinitionLoader
+	git         *graph.GitSource
+	stopWatch   context.CancelFunc
+
+	server     *server.Server
+	httpMu     sync.Mutex
+	httpServer *http.Server
+
+	mu          sync.Mutex
+	closed      bool
+	active      map[string]InterruptedRun // 执行中的运行
+	interrupted []InterruptedRun
+	wg          sync.WaitGroup
+
+	// runCtx 在宽限期结束时以 ErrShuttingDown 为原因取消，所有运行的上下文都派生自它的取消信号
+	runCtx     context.Context
+	cancelRuns context.CancelCauseFunc
+}
+
+// Option 定义引擎的构造选项
+type Option func(*Engine)
+
+// WithRegistry 使用已有的注册表，未设置时创建使用引擎 Store 的注册表。
+// WithWorkers、WithTaskDefaults、WithGlobalSlots、WithServices、WithOpenLineage、WithCodec、WithSpillover、
+// WithMemoryAccounting、WithProfileLabels、WithEventLog、WithRateStore、WithLockStore 和 WithResultEncryption
+// 是注册表级的配置，只在引擎创建注册表时生效；使用已有注册表时需要在创建注册表时指定，同时设置时 New 返回错误
+func WithRegistry(registry *graph.Registry) Option {
+	return func(e *Engine) {
+		e.registry = registry
+	}
+}
+
+// WithStore 设置保存运行记录的 Store，未设置时使用 MemoryStore；
+// 与 WithRegistry 同时使用时应与注册表的 Store 相同
+func WithStore(store graph.Store) Option {
+	return func(e *Engine) {
+		e.store = store
+	}
+}
+
+// WithResultEncryption 在持久化前按 rules 脱敏并用 kms 信封加密运行记录和死信中的任务结果，
+// 引擎的 Store 会被包装为 graph.EncryptedStore
+func WithResultEncryption(kms graph.KMS, rules ...graph.RedactionRule) Option {
+	return func(e *Engine) {
+		e.kms = kms
+		e.redaction = rules
+	}
+}
+
+// WithLogger 设置引擎和 REST 服务的日志器
+func WithLogger(logger *slog.Logger) Option {
+	return func(e *Engine) {
+		e.logger = logger
+	}
+}
+
+// WithServices 设置注入到所有运行的任务上下文中的服务集合，未设置时创建新的集合；
+// 集合中没有 *http.Client 时注册 graph.NewHTTPClient 创建的默认客户端；
+// 可通过 Services 获取后用 graph.Provide 注册服务
+func WithServices(services *graph.Services) Option {
+	return func(e *Engine) {
+		e.services = services
+	}
+}
+
+// WithOpenLineage 设置发送运行和任务 OpenLineage 事件的发送器，引擎关闭时等待已排队的事件发送完毕
+func WithOpenLineage(emitter *graph.OpenLineageEmitter) Option {
+	return func(e *Engine) {
+		e.lineage = emitter
+	}
+}
+
+// WithCodec 设置持久化任务输出时默认的编码（如 graph.CodecMsgpack），任务可通过 Task.Codec 覆盖
+func WithCodec(name string) Option {
+	return func(e *Engine) {
+		e.codec = name
+	}
+}
+
+// WithSpillover 把编码后超过 threshold 字节的任务结果溢出到 store（为空时为系统临时目录），
+// 下游任务通过 graph.Resolve 读取
+func WithSpillover(threshold int64, store graph.ArtifactStore) Option {
+	return func(e *Engine) {
+		e.spill = &graph.SpillOptions{Threshold: threshold, Store: store}
+	}
+}
+
+// WithMemoryAccounting 为所有运行开启结果内存统计（见 graph.ResultBytesInFlight），
+// maxResultBytes 大于0时运行持有的结果超过该值即失败
+func WithMemoryAccounting(maxResultBytes int64) Option {
+	return func(e *Engine) {
+		e.maxResultBytes = maxResultBytes
+	}
+}
+
+// WithEventLog 把所有运行的任务事件以 JSON Lines 写入 log，引擎关闭时刷新并关闭日志
+func WithEventLog(log *graph.EventLog) Option {
+	return func(e *Engine) {
+		e.eventLog = log
+	}
+}
+
+// WithRateStore 设置任务配额（graph.RateLimit）使用的令牌桶，多个工作进程共享同一 RateStore
+// （如 redis.NewRateStore）时合计遵守第三方 API 的全局配额
+func WithRateStore(store graph.RateStore) Option {
+	return func(e *Engine) {
+		e.rates = store
+	}
+}
+
+// WithLockStore 设置任务互斥组（graph.Task.Mutex）使用的锁，多个工作进程共享同一 LockStore
+// （如 redis.NewLockStore）时同名互斥组的任务不会在不同进程中同时执行
+func WithLockStore(store graph.LockStore) Option {
+	return func(e *Engine) {
+		e.locks = store
+	}
+}
+
+// WithProfileLabels 为所有运行的任务设置 runtime/pprof 标签 task_id 和 run_id，
+// 繁忙工作进程的 CPU profile 可以按任务筛选（如 go tool pprof -tagfocus task_id=resize）
+func WithProfileLabels() Option {
+	return func(e *Engine) {
+		e.profileLabels = true
+	}
+}
+
+// WithWorkers 设置每个运行默认的并发数和命名执行器，运行的执行选项中指定时以执行选项为准
+func WithWorkers(workerCount int, executors map[string]int) Option {
+	return func(e *Engine) {
+		e.workerCount = workerCount
+		e.executors = executors
+	}
+}
+
+// WithTaskDefaults 设置未配置 Timeout 或 Retries 的任务默认的单次超时和重试次数
+func WithTaskDefaults(timeout time.Duration, retries int) Option {
+	return func(e *Engine) {
+		e.taskTimeout = timeout
+		e.taskRetries = retries
+	}
+}
+
+// WithGlobalSlots 设置所有运行共享的全局工作者名额，限制进程内同时执行的任务总数
+func WithGlobalSlots(slots int, opts ...graph.SchedulerOption) Option {
+	return func(e *Engine) {
+		e.slots = slots
+		e.schedulerOpts = opts
+	}
+}
+
+// WithAdmission 设置同时执行的运行数上限，超出时按 opts 排队或直接拒绝；
+// 对 Start、Resume 和 REST 服务触发的运行都生效
+func WithAdmission(maxInFlight int, opts ...graph.AdmissionOption) Option {
+	return func(e *Engine) {
+		e.maxInFlight = maxInFlight
+		e.admissionOpts = opts
+	}
+}
+
+// WithServerOptions 设置 REST 服务的额外选项，如认证方式和回调配置
+func WithServerOptions(opts ...server.Option) Option {
+	return func(e *Engine) {
+		e.serverOpts = append(e.serverOpts, opts...)
+	}
+}
+
+// WithDefinitions 在创建引擎时从 dir 加载工作流定义文件，interval 大于0时按该间隔监视目录并热加载变更；
+// 定义引用的处理函数通过 WithDefinitionOptions 注册
+func WithDefinitions(dir string, interval time.Duration) Option {
+	return func(e *Engine) {
+		e.defsDir = dir
+		e.defsInterval = interval
+	}
+}
+
+// WithGitDefinitions 在创建引擎时从 git 仓库分支的 path 目录同步工作流定义，
+// interval 大于0时按该间隔拉取新提交；运行记录中的 Revision 为定义所在的提交 SHA
+func WithGitDefinitions(repo, branch, path string, interval time.Duration) Option {
+	return func(e *Engine) {
+		e.gitRepo, e.gitBranch, e.gitPath = repo, branch, path
+		e.gitInterval = interval
+	}
+}
+
+// WithDefinitionOptions 设置定义加载器的选项，如 graph.WithHandler 和 graph.WithDefinitionDecoder
+func WithDefinitionOptions(opts ...graph.DefinitionOption) Option {
+	return func(e *Engine) {
+		e.defsOpts = append(e.defsOpts, opts...)
+	}
+}
+
+// registryOptions 返回设置了的注册表级选项的名称（见 WithRegistry）
+func (e *Engine) registryOptions() []string {
+	var set []string
+	for _, opt := range []struct {
+		name string
+		set  bool
+	}{
+		{"WithWorkers", e.workerCount > 0 || e.executors != nil},
+		{"WithTaskDefaults", e.taskTimeout > 0 || e.taskRetries > 0},
+		{"WithGlobalSlots", e.slots > 0},
+		{"WithServices", e.services != nil},
+		{"WithOpenLineage", e.lineage != nil},
+		{"WithCodec", e.codec != ""},
+		{"WithSpillover", e.spill != nil},
+		{"WithMemoryAccounting", e.maxResultBytes >= 0},
+		{"WithProfileLabels", e.profileLabels},
+		{"WithEventLog", e.eventLog != nil},
+		{"WithRateStore", e.rates != nil},
+		{"WithLockStore", e.locks != nil},
+		{"WithResultEncryption", e.kms != nil},
+	} {
+		if opt.set {
+			set = append(set, opt.name)
+		}
+	}
+	return set
+}
+
+// New 创建引擎
+func New(opts ...Option) (*Engine, error) {
+	e := &Engine{
+		logger:         slog.Default(),
+		active:         make(map[string]InterruptedRun),
+		maxResultBytes: -1,
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	if e.registry != nil {
+		if set := e.registryOptions(); len(set) > 0 {
+			return nil, fmt.Errorf("%s cannot be applied to an existing registry", strings.Join(set, ", "))
+		}
+	}
+	if e.store == nil {
+		e.store = graph.NewMemoryStore()
+	}
+	if e.kms != nil {
+		store, err := graph.NewEncryptedStore(e.store, e.kms, e.redaction...)
+		if err != nil {
+			return nil, err
+		}
+		e.store = store
+	}
+
+	var err error
+	if e.slots > 0 {
+		if e.scheduler, err = graph.NewScheduler(e.slots, e.schedulerOpts...); err != nil {
+			return nil, err
+		}
+	}
+	if e.maxInFlight > 0 {
+		if e.admission, err = graph.NewAdmissionController(e.maxInFlight, e.admissionOpts...); err != nil {
+			return nil, err
+		}
+	}
+	if e.registry == nil {
+		if e.services == nil {
+			e.services = graph.NewServices()
+		}
+		// 任务默认可以通过 graph.HTTPClient 或 graph.Service[*http.Client] 获取共享的 HTTP 客户端
+		if _, ok := graph.Lookup[*http.Client](e.services); !ok {
+			graph.Provide(e.services, graph.NewHTTPClient(graph.HTTPClientOptions{}))
+		}
+		registryOpts := []graph.RegistryOption{
+			graph.WithStore(e.store),
+			graph.WithScheduler(e.scheduler),
+			graph.WithServices(e.services),
+			graph.WithOpenLineage(e.lineage),
+			graph.WithCodec(e.codec),
+			graph.WithSpill(e.spill),
+			graph.WithEventLog(e.eventLog),
+			graph.WithWorkerDefaults(e.workerCount, e.executors),
+			graph.WithTaskDefaults(e.taskTimeout, e.taskRetries),
+			graph.WithRateStore(e.rates),
+			graph.WithLockStore(e.locks),
+		}
+		if e.profileLabels {
+			registryOpts = append(registryOpts, graph.WithProfileLabels())
+		}
+		if e.maxResultBytes >= 0 {
+			registryOpts = append(registryOpts, graph.WithMemoryAccounting(e.maxResultBytes))
+		}
+		e.registry = graph.NewRegistry(registryOpts...)
+	}
+
+	e.definitions = graph.NewDefinitionLoader(e.registry, append([]graph.DefinitionOption{graph.WithDefinitionLogger(e.logger)}, e.defsOpts...)...)
+	if e.defsDir != "" {
+		if _, err := e.definitions.LoadDir(e.defsDir); err != nil {
+			return nil, err
+		}
+	}
+	if e.gitRepo != "" {
+		e.git = graph.NewGitSource(e.definitions, e.gitRepo, e.gitBranch, e.gitPath)
+		if _, err := e.git.Sync(context.Background()); err != nil {
+			return nil, err
+		}
+	}
+
+	e.runCtx, e.cancelRuns = context.WithCancelCause(context.Background())
+	serverOpts := append([]server.Option{
+		server.WithLogger(e.logger),
+		server.WithAdmission(e.admission),
+		server.WithRunner(e),
+		server.WithStatusProvider(e),
+		server.WithScheduler(e.scheduler),
+	}, e.serverOpts...)
+	e.server = server.New(e.registry, e.store, serverOpts...)
+
+	var watchCtx context.Context
+	watchCtx, e.stopWatch = context.WithCancel(context.Background())
+	if e.defsDir != "" && e.defsInterval > 0 {
+		go func() {
+			if err := e.definitions.Watch(watchCtx, e.defsDir, e.defsInterval); err != nil {
+				e.logger.Warn("definition watch stopped", slog.String("dir", e.defsDir), slog.Any("error", err))
+			}
+		}()
+	}
+	if e.git != nil && e.gitInterval > 0 {
+		go e.git.Run(watchCtx, e.gitInterval)
+	}
+	return e, nil
+}
+
+// Definitions 返回引擎的定义加载器，可用于手动加载定义文件
+func (e *Engine) Definitions() *graph.DefinitionLoader {
+	return e.definitions
+}
+
+// GitSource 返回 git 定义源，未设置 WithGitDefinitions 时为空
+func (e *Engine) GitSource() *graph.GitSource {
+	return e.git
+}
+
+// Registry 返回引擎使用的注册表
+func (e *Engine) Registry() *graph.Registry {
+	return e.registry
+}
+
+// Store 返回引擎使用的 Store
+func (e *Engine) Store() graph.Store {
+	return e.store
+}
+
+// Services 返回注入到所有运行的任务上下文中的服务集合，使用 WithRegistry 时为空
+func (e *Engine) Services() *graph.Services {
+	return e.services
+}
+
+// Scheduler 返回所有运行共享的全局调度器，未设置 WithGlobalSlots 时为空
+func (e *Engine) Scheduler() *graph.Scheduler {
+	return e.scheduler
+}
+
+// Admission 返回运行的准入控制器，未设置 WithAdmission 时为空
+func (e *Engine) Admission() *graph.AdmissionController {
+	return e.admission
+}
+
+// Handler 返回引擎的 REST 服务
+func (e *Engine) Handler() http.Handler {
+	return e.server
+}
+
+// ListenAndServe 在 addr 上提供 REST 服务，直到 Shutdown 被调用
+func (e *Engine) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %v", addr, err)
+	}
+	return e.Serve(l)
+}
+
+// Serve 在 l 上提供 REST 服务，直到 Shutdown 被调用；正常关闭时返回 nil
+func (e *Engine) Serve(l net.Listener) error {
+	e.mu.Lock()
+	closed := e.closed
+	e.mu.Unlock()
+	if closed {
+		return ErrShuttingDown
+	}
+	e.httpMu.Lock()
+	if e.httpServer != nil {
+		e.httpMu.Unlock()
+		return fmt.Errorf("engine is already serving")
+	}
+	e.httpServer = &http.Server{Handler: e.server}
+	hs := e.httpServer
+	e.httpMu.Unlock()
+
+	if err := hs.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// Start 使用命名空间内工作流的最新版本执行一次运行，引擎关闭后返回 ErrShuttingDown
+func (e *Engine) Start(ctx context.Context, namespace, workflow string, opts graph.ExecuteOptions, extra ...graph.ExecuteOption) (*graph.ExecutionReport, error) {
+	for _, opt := range extra {
+		opt(&opts)
+	}
+	if opts.RunID == "" {
+		opts.RunID = graph.NewRunID()
+	}
+	ns := e.registry.Namespace(namespace)
+	return e.track(ctx, InterruptedRun{Namespace: ns.Name(), Workflow: workflow, RunID: opts.RunID}, func(ctx context.Context) (*graph.ExecutionReport, error) {
+		return ns.Start(ctx, workflow, opts)
+	})
+}
+
+// Resume 重新执行一次失败或被中断的运行中未完成的任务，复用已完成任务的结果
+func (e *Engine) Resume(ctx context.Context, namespace, runID string, opts graph.ExecuteOptions, extra ...graph.ExecuteOption) (*graph.ExecutionReport, error) {
+	ns := e.registry.Namespace(namespace)
+	rec, err := ns.GetRun(ctx, runID)
+	if err != nil {
+		return nil, err
+	}
+	return e.track(ctx, InterruptedRun{Namespace: ns.Name(), Workflow: rec.Workflow, RunID: runID}, func(ctx context.Context) (*graph.ExecutionReport, error) {
+		return ns.RetryFailed(ctx, runID, opts, extra...)
+	})
+}
+
+// Go 实现 server.Runner，REST 服务触发的运行由引擎跟踪，关闭后不再接受
+func (e *Engine) Go(ctx context.Context, run server.RunRef, fn func(ctx context.Context) error) error {
+	ctx, finish, err := e.begin(ctx, InterruptedRun(run))
+	if err != nil {
+		return err
+	}
+	go func() { finish(fn(ctx)) }()
+	return nil
+}
+
+// EngineStatus 实现 server.StatusProvider
+func (e *Engine) EngineStatus() server.EngineStatus {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return server.EngineStatus{Draining: e.closed, ActiveRuns: len(e.active)}
+}
+
+// track 登记并执行一次运行，准入控制在登记之前进行
+func (e *Engine) track(ctx context.Context, run InterruptedRun, fn func(ctx context.Context) (*graph.ExecutionReport, error)) (*graph.ExecutionReport, error) {
+	release, err := e.admission.Admit(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	ctx, finish, err := e.begin(ctx, run)
+	if err != nil {
+		return nil, err
+	}
+	report, err := fn(ctx)
+	finish(err)
+	return report, err
+}
+
+// begin 登记一次运行并返回其上下文和结束时调用的函数；宽限期结束时取消该上下文，
+// 运行因此失败时记录为中断
+func (e *Engine) begin(ctx context.Context, run InterruptedRun) (context.Context, func(err error), error) {
+	e.mu.Lock()
+	if e.closed {
+		e.mu.Unlock()
+		return nil, nil, fmt.Errorf("%w: run of %s rejected", ErrShuttingDown, run.Workflow)
+	}
+	e.active[run.RunID] = run
+	e.wg.Add(1)
+	e.mu.Unlock()
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	stop := context.AfterFunc(e.runCtx, func() { cancel(context.Cause(e.runCtx)) })
+	finish := func(err error) {
+		stop()
+		interrupted := err != nil && errors.Is(context.Cause(ctx), ErrShuttingDown)
+		cancel(nil)
+		e.mu.Lock()
+		delete(e.active, run.RunID)
+		if interrupted {
+			e.interrupted = append(e.interrupted, run)
+		}
+		e.mu.Unlock()
+		e.wg.Done()
+	}
+	return ctx, finish, nil
+}
+
+// Shutdown 优雅关闭引擎：立即停止接受新的运行和 REST 请求，等待执行中的运行结束；
+// ctx 结束（宽限期到达）时仍未结束的运行被取消，已完成任务的结果随运行记录保存为检查点，
+// 等所有运行保存完毕后返回。有运行被中断时返回错误，中断的运行可通过 Interrupted 获取
+func (e *Engine) Shutdown(ctx context.Context) error {
+	e.mu.Lock()
+	e.closed = true
+	e.mu.Unlock()
+	e.stopWatch()
+	if e.lineage != nil {
+		defer e.lineage.Close()
+	}
+	if e.eventLog != nil {
+		defer e.eventLog.Close()
+	}
+
+	e.httpMu.Lock()
+	hs := e.httpServer
+	e.httpMu.Unlock()
+	if hs != nil {
+		if err := hs.Shutdown(ctx); err != nil {
+			e.logger.Warn("failed to shut down http server", slog.Any("error", err))
+		}
+	}
+
+	drained := make(chan struct{})
+	go func() {
+		e.wg.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+	}
+
+	e.mu.Lock()
+	pending := len(e.active)
+	e.mu.Unlock()
+	e.logger.Warn("shutdown grace period expired, interrupting runs", slog.Int("runs", pending))
+	e.cancelRuns(ErrShuttingDown)
+	<-drained
+
+	if interrupted := e.Interrupted(); len(interrupted) > 0 {
+		return fmt.Errorf("%d runs interrupted by shutdown: %v", len(interrupted), ctx.Err())
+	}
+	return nil
+}
+
+// Interrupted 返回关闭时被中断的运行（按 run_id 排序）
+func (e *Engine) Interrupted() []InterruptedRun {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	runs := append([]InterruptedRun(nil), e.interrupted...)
+	sort.Slice(runs, func(i, j int) bool { return runs[i].RunID < runs[j].RunID })
+	return runs
+}
@@ -0,0 +1,260 @@
+// Package gcp 调用 Google Cloud 服务的任务类型，如以 HTTP 触发的 Cloud Functions 和 Cloud Run 服务。
+// 以服务账号的 ID 令牌认证，不依赖 Google Cloud SDK；
+// 单独成包是为了让不使用 Google Cloud 的程序无需链接这些代码
+package gcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"workflow/graph"
+)
+
+// TaskTypeFunction 是调用 Cloud Function 的任务类型名称
+const TaskTypeFunction = "gcp_cloud_function"
+
+// maxFunctionResponse 是读取函数返回值的上限
+const maxFunctionResponse = 32 << 20
+
+// metadataIdentityURL 是元数据服务器签发 ID 令牌的地址
+const metadataIdentityURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/identity"
+
+// TokenSource 为指定受众签发 ID 令牌，实现需要可被并发调用
+type TokenSource interface {
+	IDToken(ctx context.Context, audience string) (string, error)
+}
+
+// TokenFunc 把函数适配为 TokenSource
+type TokenFunc func(ctx context.Context, audience string) (string, error)
+
+func (f TokenFunc) IDToken(ctx context.Context, audience string) (string, error) {
+	return f(ctx, audience)
+}
+
+// MetadataTokenSource 从元数据服务器获取运行环境服务账号的 ID 令牌，
+// 适用于 GCE、GKE（Workload Identity）、Cloud Run 和 Cloud Functions；令牌按受众缓存到过期前5分钟
+func MetadataTokenSource() TokenSource {
+	return &metadataTokens{tokens: make(map[string]cachedToken), client: &http.Client{Timeout: 5 * time.Second}}
+}
+
+type cachedToken struct {
+	token   string
+	expires time.Time
+}
+
+type metadataTokens struct {
+	client *http.Client
+	mu     sync.Mutex
+	tokens map[string]cachedToken
+}
+
+func (m *metadataTokens) IDToken(ctx context.Context, audience string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if t, ok := m.tokens[audience]; ok && time.Until(t.expires) > 5*time.Minute {
+		return t.token, nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, metadataIdentityURL+"?format=full&audience="+url.QueryEscape(audience), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to get id token from metadata server: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return "", fmt.Errorf("failed to read id token: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("metadata server returned %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	token := strings.TrimSpace(string(body))
+	m.tokens[audience] = cachedToken{token: token, expires: tokenExpiry(token)}
+	return token, nil
+}
+
+// tokenExpiry 读取 JWT 的 exp，无法解析时视为1小时后过期
+func tokenExpiry(token string) time.Time {
+	parts := strings.Split(token, ".")
+	if len(parts) == 3 {
+		payload, err := decodeSegment(parts[1])
+		var claims struct {
+			Exp int64 `json:"exp"`
+		}
+		if err == nil && json.Unmarshal(payload, &claims) == nil && claims.Exp > 0 {
+			return time.Unix(claims.Exp, 0)
+		}
+	}
+	return time.Now().Add(time.Hour)
+}
+
+func decodeSegment(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
+
+// FunctionSpec 描述作为任务调用的函数
+type FunctionSpec struct {
+	URL string // 函数的 HTTPS 触发地址
+	// Audience 是 ID 令牌的受众，为空时为去掉查询参数的 URL；
+	// 调用 Cloud Run 服务或配置了自定义受众时需要设置
+	Audience string
+	// Payload 为空时把任务输入编码为 JSON 作为请求体
+	Payload *graph.PayloadTemplate
+	// Unauthenticated 为 true 时不附加 ID 令牌，用于允许公开调用的函数
+	Unauthenticated bool
+}
+
+// FunctionError 表示函数返回了非 2xx 响应
+type FunctionError struct {
+	URL        string
+	StatusCode int
+	Body       string
+}
+
+func (e *FunctionError) Error() string {
+	return fmt.Sprintf("cloud function %s returned %d: %s", e.URL, e.StatusCode, e.Body)
+}
+
+// Functions 以服务账号身份调用 Cloud Functions，可被多个 goroutine 并发使用
+type Functions struct {
+	tokens TokenSource
+}
+
+// FunctionsOption 定义 Functions 的构造选项
+type FunctionsOption func(*Functions)
+
+// WithTokenSource 设置 ID 令牌的来源，默认为 MetadataTokenSource
+func WithTokenSource(ts TokenSource) FunctionsOption {
+	return func(f *Functions) {
+		f.tokens = ts
+	}
+}
+
+// NewFunctions 创建调用 Cloud Functions 的客户端
+func NewFunctions(opts ...FunctionsOption) *Functions {
+	f := &Functions{}
+	for _, opt := range opts {
+		opt(f)
+	}
+	if f.tokens == nil {
+		f.tokens = MetadataTokenSource()
+	}
+	return f
+}
+
+// Invoke 以 POST 调用函数并返回响应体
+func (f *Functions) Invoke(ctx context.Context, spec FunctionSpec, payload []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, spec.URL, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if !spec.Unauthenticated {
+		audience := spec.Audience
+		if audience == "" {
+			audience = req.URL.Scheme + "://" + req.URL.Host + req.URL.Path
+		}
+		token, err := f.tokens.IDToken(ctx, audience)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := graph.HTTPClient(ctx).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to invoke cloud function %s: %v", spec.URL, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxFunctionResponse+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cloud function response: %v", err)
+	}
+	if len(body) > maxFunctionResponse {
+		return nil, fmt.Errorf("cloud function response exceeds %d bytes", maxFunctionResponse)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, &FunctionError{URL: spec.URL, StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(body))}
+	}
+	return body, nil
+}
+
+// Execute 返回调用 spec 函数的任务函数，可直接作为 graph.Task 的 Execute：
+// 响应是 JSON 时解码后作为任务结果，否则作为字符串
+func (f *Functions) Execute(spec FunctionSpec) func(ctx context.Context, inputs map[string]interface{}) (interface{}, error) {
+	return func(ctx context.Context, inputs map[string]interface{}) (interface{}, error) {
+		payload, err := graph.RenderPayload(ctx, spec.Payload, inputs)
+		if err != nil {
+			return nil, err
+		}
+		body, err := f.Invoke(ctx, spec, payload)
+		if err != nil {
+			return nil, err
+		}
+		if len(bytes.TrimSpace(body)) == 0 {
+			return nil, nil
+		}
+		var result interface{}
+		if err := json.Unmarshal(body, &result); err != nil {
+			return string(body), nil
+		}
+		return result, nil
+	}
+}
+
+// TaskType 返回可在定义文件中使用的 gcp_cloud_function 任务类型，
+// 通过 graph.RegisterTaskType 或 graph.WithTaskType 注册
+func (f *Functions) TaskType() graph.TaskType {
+	return functionTaskType{functions: f}
+}
+
+type functionTaskType struct {
+	functions *Functions
+}
+
+func (functionTaskType) Name() string {
+	return TaskTypeFunction
+}
+
+func (functionTaskType) ConfigSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type":     "object",
+		"required": []string{"url"},
+		"properties": map[string]interface{}{
+			"url":             map[string]interface{}{"type": "string"},
+			"audience":        map[string]interface{}{"type": "string"},
+			"payload":         map[string]interface{}{"type": "string"},
+			"unauthenticated": map[string]interface{}{"type": "boolean"},
+		},
+		"additionalProperties": false,
+	}
+}
+
+func (t functionTaskType) New(config map[string]interface{}) (func(ctx context.Context, inputs map[string]interface{}) (interface{}, error), error) {
+	spec := FunctionSpec{URL: config["url"].(string)}
+	if u, err := url.Parse(spec.URL); err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid cloud function url %q", spec.URL)
+	}
+	spec.Audience, _ = config["audience"].(string)
+	spec.Unauthenticated, _ = config["unauthenticated"].(bool)
+	if text, ok := config["payload"].(string); ok {
+		tmpl, err := graph.ParsePayloadTemplate(text)
+		if err != nil {
+			return nil, err
+		}
+		spec.Payload = tmpl
+	}
+	return t.functions.Execute(spec), nil
+}
@@ -4,5 +4,6 @@ go 1.22.5
 
 require (
 	github.com/dominikbraun/graph v0.23.0
+	github.com/tetratelabs/wazero v1.9.0
 	golang.org/x/sync v0.9.0
 )
@@ -0,0 +1,170 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrOverloaded 表示同时执行的运行已达上限且等待队列已满或等待超时，调用方应稍后重试
+var ErrOverloaded = errors.New("too many runs in flight")
+
+// AdmissionController 在运行开始前做准入控制：同时执行的运行数不超过上限，
+// 超出的运行在有限长度的队列中按到达顺序等待，队列已满或等待超时时立即以 ErrOverloaded 拒绝，
+// 使过载表现为快速失败而不是无限堆积的运行耗尽内存
+type AdmissionController struct {
+	mu           sync.Mutex
+	maxInFlight  int
+	inFlight     int
+	queueSize    int
+	queueTimeout time.Duration
+	queue        []*admissionWaiter
+	admitted     uint64
+	rejected     uint64
+}
+
+// admissionWaiter 是队列中等待准入的运行
+type admissionWaiter struct {
+	ready   chan struct{} // 获得准入时关闭
+	granted bool
+}
+
+// AdmissionOption 定义准入控制器的构造选项
+type AdmissionOption func(*AdmissionController)
+
+// WithAdmissionQueue 设置等待准入的队列长度和最长等待时间，timeout 为0时一直等待到上下文取消；
+// 未设置时不排队，超出上限的运行直接被拒绝
+func WithAdmissionQueue(size int, timeout time.Duration) AdmissionOption {
+	return func(a *AdmissionController) {
+		a.queueSize = size
+		a.queueTimeout = timeout
+	}
+}
+
+// NewAdmissionController 创建最多允许 maxInFlight 个运行同时执行的准入控制器
+func NewAdmissionController(maxInFlight int, opts ...AdmissionOption) (*AdmissionController, error) {
+	if maxInFlight <= 0 {
+		return nil, fmt.Errorf("admission controller requires max in-flight runs above 0, got %d", maxInFlight)
+	}
+	a := &AdmissionController{maxInFlight: maxInFlight}
+	for _, opt := range opts {
+		opt(a)
+	}
+	if a.queueSize < 0 {
+		return nil, fmt.Errorf("admission queue size must not be negative, got %d", a.queueSize)
+	}
+	return a, nil
+}
+
+// AdmissionStats 是准入控制器的当前状态和累计计数
+type AdmissionStats struct {
+	MaxInFlight int
+	InFlight    int
+	Queued      int
+	Admitted    uint64
+	Rejected    uint64
+}
+
+// Stats 返回准入控制器的当前状态
+func (a *AdmissionController) Stats() AdmissionStats {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return AdmissionStats{
+		MaxInFlight: a.maxInFlight,
+		InFlight:    a.inFlight,
+		Queued:      len(a.queue),
+		Admitted:    a.admitted,
+		Rejected:    a.rejected,
+	}
+}
+
+// Admit 为一个运行申请准入，成功时返回运行结束后必须调用的释放函数；
+// 被拒绝时返回包装了 ErrOverloaded 的错误。控制器为空时总是准入
+func (a *AdmissionController) Admit(ctx context.Context) (func(), error) {
+	if a == nil {
+		return func() {}, nil
+	}
+
+	a.mu.Lock()
+	if a.inFlight < a.maxInFlight && len(a.queue) == 0 {
+		a.inFlight++
+		a.admitted++
+		a.mu.Unlock()
+		return a.releaser(), nil
+	}
+	if len(a.queue) >= a.queueSize {
+		a.rejected++
+		a.mu.Unlock()
+		return nil, fmt.Errorf("%w: %d running, admission queue full", ErrOverloaded, a.maxInFlight)
+	}
+	w := &admissionWaiter{ready: make(chan struct{})}
+	a.queue = append(a.queue, w)
+	a.mu.Unlock()
+
+	var timeout <-chan time.Time
+	if a.queueTimeout > 0 {
+		timer := time.NewTimer(a.queueTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	var err error
+	select {
+	case <-w.ready:
+		return a.releaser(), nil
+	case <-timeout:
+		err = fmt.Errorf("%w: not admitted within %v", ErrOverloaded, a.queueTimeout)
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	a.mu.Lock()
+	if w.granted {
+		// 放弃等待的同时已获得准入，直接交给下一个运行
+		a.mu.Unlock()
+		a.release()
+		return nil, err
+	}
+	for i, q := range a.queue {
+		if q == w {
+			a.queue = append(a.queue[:i], a.queue[i+1:]...)
+			break
+		}
+	}
+	if errors.Is(err, ErrOverloaded) {
+		a.rejected++
+	}
+	a.mu.Unlock()
+	return nil, err
+}
+
+// releaser 返回只生效一次的释放函数
+func (a *AdmissionController) releaser() func() {
+	var once sync.Once
+	return func() { once.Do(a.release) }
+}
+
+// release 结束一个运行，有排队的运行时直接把名额交给最早到达的运行
+func (a *AdmissionController) release() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(a.queue) > 0 {
+		w := a.queue[0]
+		a.queue = a.queue[1:]
+		w.granted = true
+		a.admitted++
+		close(w.ready)
+		return
+	}
+	a.inFlight--
+}
+
+// WithAdmission 设置注册表启动运行前使用的准入控制器，
+// 执行选项中未指定 Admission 的运行都受其限制；被拒绝的运行不会写入 Store
+func WithAdmission(a *AdmissionController) RegistryOption {
+	return func(r *Registry) {
+		r.admission = a
+	}
+}
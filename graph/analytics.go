@@ -0,0 +1,220 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// AnalyticsQuery 定义聚合统计的范围
+type AnalyticsQuery struct {
+	Since  time.Time     // 只统计开始时间不早于该时间的运行，零值表示不限制
+	Until  time.Time     // 只统计开始时间早于该时间的运行，零值表示不限制
+	Bucket time.Duration // 趋势统计的时间粒度，为0时按天统计
+}
+
+// TaskStats 是单个任务在统计范围内的聚合结果，被跳过的执行不计入
+type TaskStats struct {
+	TaskID      string
+	Runs        int
+	Failures    int
+	FailureRate float64
+	// Flaky 是经过失败的尝试后才完成的次数，FlakyRate 是其在执行次数中的占比
+	Flaky     int
+	FlakyRate float64
+	P50       time.Duration
+	P95       time.Duration
+	Max       time.Duration
+	// Cost 是任务在统计范围内上报的成本之和，复用之前执行结果的不计入
+	Cost CostSummary
+}
+
+// TrendBucket 是一个时间段内的聚合结果
+type TrendBucket struct {
+	Start            time.Time
+	Runs             int
+	Failures         int
+	FailureRate      float64
+	P50              time.Duration // 运行耗时的中位数
+	P95              time.Duration
+	TaskFailureRates map[string]float64 // 任务ID -> 该时间段内的失败率
+	Cost             CostSummary        // 该时间段内运行的成本之和
+}
+
+// WorkflowAnalytics 是工作流执行历史的聚合统计
+type WorkflowAnalytics struct {
+	Namespace   string
+	Workflow    string
+	Runs        int
+	Failures    int
+	FailureRate float64
+	P50         time.Duration
+	P95         time.Duration
+	Tasks       []TaskStats   // 按任务ID排序
+	Trend       []TrendBucket // 按时间升序，没有运行的时间段不出现
+	// Cost 是统计范围内运行的成本之和，CostByTag 按任务标签汇总，见 ExecutionReport.CostByTag
+	Cost      CostSummary
+	CostByTag map[string]CostSummary
+}
+
+// taskSamples 收集单个任务的执行样本
+type taskSamples struct {
+	durations []time.Duration
+	failures  int
+	flaky     int
+	cost      CostSummary
+}
+
+func (s *taskSamples) add(tr *TaskReport) {
+	if !tr.Reused {
+		s.cost.add(tr.Cost, tr.Costs)
+	}
+	s.durations = append(s.durations, tr.Duration)
+	if tr.Status == TaskStatusFailed {
+		s.failures++
+	}
+	if tr.Status == TaskStatusCompleted && tr.Attempts > 1 {
+		s.flaky++
+	}
+}
+
+// Analyze 统计 Store 中工作流已结束运行的耗时分位数、失败率、成本和趋势
+func Analyze(ctx context.Context, store Store, namespace, workflow string, q AnalyticsQuery) (*WorkflowAnalytics, error) {
+	records, err := store.ListRuns(ctx, namespace, RunFilter{Workflow: workflow})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list runs: %v", err)
+	}
+	bucket := q.Bucket
+	if bucket <= 0 {
+		bucket = 24 * time.Hour
+	}
+
+	a := &WorkflowAnalytics{Namespace: namespace, Workflow: workflow}
+	var runDurations []time.Duration
+	tasks := make(map[string]*taskSamples)
+
+	type trendSamples struct {
+		durations []time.Duration
+		failures  int
+		cost      CostSummary
+		tasks     map[string]*taskSamples
+	}
+	trend := make(map[time.Time]*trendSamples)
+
+	for _, rec := range records {
+		if rec.Status == RunStatusRunning ||
+			(!q.Since.IsZero() && rec.StartTime.Before(q.Since)) ||
+			(!q.Until.IsZero() && !rec.StartTime.Before(q.Until)) {
+			continue
+		}
+		duration := rec.EndTime.Sub(rec.StartTime)
+		failed := rec.Status == RunStatusFailed
+
+		a.Runs++
+		runDurations = append(runDurations, duration)
+		start := rec.StartTime.Truncate(bucket)
+		ts, ok := trend[start]
+		if !ok {
+			ts = &trendSamples{tasks: make(map[string]*taskSamples)}
+			trend[start] = ts
+		}
+		ts.durations = append(ts.durations, duration)
+		if failed {
+			a.Failures++
+			ts.failures++
+		}
+
+		if rec.Report == nil {
+			continue
+		}
+		a.Cost.add(rec.Report.Cost, rec.Report.Costs)
+		ts.cost.add(rec.Report.Cost, rec.Report.Costs)
+		for tag, c := range rec.Report.CostByTag {
+			if a.CostByTag == nil {
+				a.CostByTag = make(map[string]CostSummary)
+			}
+			s := a.CostByTag[tag]
+			s.add(c.Cost, c.Costs)
+			a.CostByTag[tag] = s
+		}
+		for id, tr := range rec.Report.Tasks {
+			if tr.Status != TaskStatusCompleted && tr.Status != TaskStatusFailed {
+				continue
+			}
+			if tasks[id] == nil {
+				tasks[id] = &taskSamples{}
+			}
+			tasks[id].add(tr)
+			if ts.tasks[id] == nil {
+				ts.tasks[id] = &taskSamples{}
+			}
+			ts.tasks[id].add(tr)
+		}
+	}
+
+	a.FailureRate = rate(a.Failures, a.Runs)
+	a.P50, a.P95 = percentile(runDurations, 50), percentile(runDurations, 95)
+	for id, s := range tasks {
+		a.Tasks = append(a.Tasks, TaskStats{
+			TaskID:      id,
+			Runs:        len(s.durations),
+			Failures:    s.failures,
+			FailureRate: rate(s.failures, len(s.durations)),
+			Flaky:       s.flaky,
+			FlakyRate:   rate(s.flaky, len(s.durations)),
+			P50:         percentile(s.durations, 50),
+			P95:         percentile(s.durations, 95),
+			Max:         percentile(s.durations, 100),
+			Cost:        s.cost,
+		})
+	}
+	sort.Slice(a.Tasks, func(i, j int) bool { return a.Tasks[i].TaskID < a.Tasks[j].TaskID })
+
+	for start, ts := range trend {
+		b := TrendBucket{
+			Start:            start,
+			Runs:             len(ts.durations),
+			Failures:         ts.failures,
+			FailureRate:      rate(ts.failures, len(ts.durations)),
+			P50:              percentile(ts.durations, 50),
+			P95:              percentile(ts.durations, 95),
+			TaskFailureRates: make(map[string]float64, len(ts.tasks)),
+			Cost:             ts.cost,
+		}
+		for id, s := range ts.tasks {
+			b.TaskFailureRates[id] = rate(s.failures, len(s.durations))
+		}
+		a.Trend = append(a.Trend, b)
+	}
+	sort.Slice(a.Trend, func(i, j int) bool { return a.Trend[i].Start.Before(a.Trend[j].Start) })
+	return a, nil
+}
+
+// Analytics 统计命名空间内工作流的执行历史，注册表未配置 Store 时返回错误
+func (n *NamespaceRegistry) Analytics(ctx context.Context, workflow string, q AnalyticsQuery) (*WorkflowAnalytics, error) {
+	if n.registry.store == nil {
+		return nil, fmt.Errorf("registry has no store configured")
+	}
+	return Analyze(ctx, n.registry.store, n.namespace, workflow, q)
+}
+
+// percentile 按最近秩法计算分位数，会对 samples 原地排序
+func percentile(samples []time.Duration, p int) time.Duration {
+	if len(samples) == 0 {
+		return 0
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+	rank := (p*len(samples) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return samples[rank-1]
+}
+
+func rate(failures, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(failures) / float64(total)
+}
@@ -0,0 +1,319 @@
+package graph
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+)
+
+// ASLOptions 是导出 Amazon States Language 状态机的选项
+type ASLOptions struct {
+	Comment string
+	// Resource 是执行任务的 Task 状态资源，通常是 Lambda 函数的 ARN；
+	// Resources 中指定了任务ID时优先使用
+	Resource  string
+	Resources map[string]string
+	// RetryInterval 是重试间隔的秒数，为0时为1秒；重试按2倍退避
+	RetryInterval int
+}
+
+// ASLStateMachine 是 Amazon States Language 状态机定义
+type ASLStateMachine struct {
+	Comment string              `json:"Comment,omitempty"`
+	StartAt string              `json:"StartAt"`
+	States  map[string]ASLState `json:"States"`
+}
+
+// ASLState 是状态机中的一个状态，只包含导出时用到的字段
+type ASLState struct {
+	Type           string                   `json:"Type"`
+	Comment        string                   `json:"Comment,omitempty"`
+	Resource       string                   `json:"Resource,omitempty"`
+	Parameters     map[string]interface{}   `json:"Parameters,omitempty"`
+	Result         json.RawMessage          `json:"Result,omitempty"`
+	ResultSelector map[string]interface{}   `json:"ResultSelector,omitempty"`
+	ResultPath     string                   `json:"ResultPath,omitempty"`
+	OutputPath     string                   `json:"OutputPath,omitempty"`
+	TimeoutSeconds int                      `json:"TimeoutSeconds,omitempty"`
+	Retry          []ASLRetrier             `json:"Retry,omitempty"`
+	Catch          []ASLCatcher             `json:"Catch,omitempty"`
+	Choices        []map[string]interface{} `json:"Choices,omitempty"`
+	Default        string                   `json:"Default,omitempty"`
+	Branches       []ASLStateMachine        `json:"Branches,omitempty"`
+	Next           string                   `json:"Next,omitempty"`
+	End            bool                     `json:"End,omitempty"`
+}
+
+// ASLRetrier 是 Task 状态的重试规则
+type ASLRetrier struct {
+	ErrorEquals     []string `json:"ErrorEquals"`
+	IntervalSeconds int      `json:"IntervalSeconds"`
+	MaxAttempts     int      `json:"MaxAttempts"`
+	BackoffRate     float64  `json:"BackoffRate"`
+}
+
+// ASLCatcher 是 Task 状态的错误捕获规则
+type ASLCatcher struct {
+	ErrorEquals []string `json:"ErrorEquals"`
+	ResultPath  string   `json:"ResultPath,omitempty"`
+	Next        string   `json:"Next"`
+}
+
+// aslKeyPattern 是可以直接用于 JSONPath 的字段名
+var aslKeyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ExportASL 把任务图导出为 Amazon States Language 状态机，便于在 AWS Step Functions 中执行：
+// 同一层的任务导出为 Parallel 状态的分支，每层在前一层结束后开始，与引擎的按层调度一致；
+// 定义文件中以 compare 求值器声明的条件导出为 Choice 状态，条件不满足时任务的结果为 null。
+//
+// 状态机的输入为 {"params": {...}}，每个 Task 状态收到 {"task_id", "results", "params"}，
+// results 以任务ID为键（任务ID中 JSONPath 不支持的字符替换为 '_'），返回值写入 $.results；
+// 状态机的输出为全部任务的结果。可选任务失败时结果为 null，其余任务按 Retries 重试后失败则整个执行失败。
+// 代码中设置的 Condition 和 ConditionWithResults 无法导出，任务有这类条件时返回错误
+func (tg *TaskGraph) ExportASL(opts ASLOptions) (*ASLStateMachine, error) {
+	steps, err := tg.Steps()
+	if err != nil {
+		return nil, err
+	}
+	keys := make(map[string]string, len(steps))
+	used := make(map[string]string, len(steps))
+	var layers [][]Step
+	for _, s := range steps {
+		key := aslKey(s.TaskID)
+		if other, ok := used[key]; ok {
+			return nil, fmt.Errorf("tasks %s and %s map to the same ASL result key %s", other, s.TaskID, key)
+		}
+		used[key], keys[s.TaskID] = s.TaskID, key
+		for len(layers) <= s.Layer {
+			layers = append(layers, nil)
+		}
+		layers[s.Layer] = append(layers[s.Layer], s)
+	}
+
+	sm := &ASLStateMachine{Comment: opts.Comment, StartAt: "init", States: map[string]ASLState{}}
+	sm.States["init"] = ASLState{Type: "Pass", Result: json.RawMessage("{}"), ResultPath: "$.results", Next: "done"}
+	var done []string // 已结束的任务的结果键，用于合并 Parallel 分支的输出
+	prev := "init"
+	for i, layer := range layers {
+		// 每层先指向 done，添加下一层时再改为指向下一层的入口
+		var name string
+		if len(layer) == 1 {
+			if err := tg.aslStep(sm.States, layer[0], keys, opts, "done"); err != nil {
+				return nil, err
+			}
+			name = aslEntry(sm.States, layer[0].TaskID)
+		} else {
+			name = fmt.Sprintf("layer %d", i)
+			parallel := ASLState{Type: "Parallel", ResultSelector: map[string]interface{}{}, ResultPath: "$.results", Next: "done"}
+			for _, key := range done {
+				parallel.ResultSelector[key+".$"] = "$[0].results." + key
+			}
+			for b, s := range layer {
+				branch := ASLStateMachine{States: map[string]ASLState{}}
+				if err := tg.aslStep(branch.States, s, keys, opts, ""); err != nil {
+					return nil, err
+				}
+				branch.StartAt = aslEntry(branch.States, s.TaskID)
+				parallel.Branches = append(parallel.Branches, branch)
+				parallel.ResultSelector[keys[s.TaskID]+".$"] = fmt.Sprintf("$[%d].results.%s", b, keys[s.TaskID])
+			}
+			sm.States[name] = parallel
+		}
+		aslLink(sm.States, prev, name)
+		prev = name
+		if len(layer) == 1 {
+			// 单个任务的层由 Task 状态或跳过状态结束
+			prev = "run " + layer[0].TaskID
+		}
+		for _, s := range layer {
+			done = append(done, keys[s.TaskID])
+		}
+	}
+	sm.States["done"] = ASLState{Type: "Pass", OutputPath: "$.results", End: true}
+	return sm, nil
+}
+
+// aslStep 添加执行单个任务的状态：可选的 Choice、Task 和结果为 null 的 Pass，next 为空时以 End 结束
+func (tg *TaskGraph) aslStep(states map[string]ASLState, s Step, keys map[string]string, opts ASLOptions, next string) error {
+	task, err := tg.graph.Vertex(s.TaskID)
+	if err != nil {
+		return fmt.Errorf("task %s not found", s.TaskID)
+	}
+	resource := opts.Resources[s.TaskID]
+	if resource == "" {
+		resource = opts.Resource
+	}
+	if resource == "" {
+		return fmt.Errorf("no ASL resource for task %s", s.TaskID)
+	}
+	key := keys[s.TaskID]
+	skip := "skip " + s.TaskID
+
+	state := ASLState{
+		Type:     "Task",
+		Resource: resource,
+		Parameters: map[string]interface{}{
+			"task_id":   s.TaskID,
+			"results.$": "$.results",
+			"params.$":  "$.params",
+		},
+		ResultPath: "$.results." + key,
+	}
+	if s.Timeout > 0 {
+		state.TimeoutSeconds = int(math.Ceil(s.Timeout.Seconds()))
+	}
+	if s.Retries > 0 {
+		interval := opts.RetryInterval
+		if interval <= 0 {
+			interval = 1
+		}
+		state.Retry = []ASLRetrier{{ErrorEquals: []string{"States.ALL"}, IntervalSeconds: interval, MaxAttempts: s.Retries, BackoffRate: 2}}
+	}
+	needSkip := s.Optional
+	if s.Optional {
+		state.Catch = []ASLCatcher{{ErrorEquals: []string{"States.ALL"}, ResultPath: "$.errors." + key, Next: skip}}
+	}
+
+	switch {
+	case task.when != nil:
+		rule, err := aslChoice(task.when, keys)
+		if err != nil {
+			return fmt.Errorf("cannot export condition of task %s: %v", s.TaskID, err)
+		}
+		rule["Next"] = "run " + s.TaskID
+		states["check "+s.TaskID] = ASLState{Type: "Choice", Choices: []map[string]interface{}{rule}, Default: skip}
+		needSkip = true
+	case task.Condition != nil || task.ConditionWithResults != nil:
+		return fmt.Errorf("task %s has a condition set in code, only compare conditions from definitions can be exported", s.TaskID)
+	}
+
+	if next == "" {
+		state.End = true
+	} else {
+		state.Next = next
+	}
+	states["run "+s.TaskID] = state
+	if needSkip {
+		states[skip] = ASLState{Type: "Pass", Result: json.RawMessage("null"), ResultPath: "$.results." + key, Next: next, End: next == ""}
+	}
+	return nil
+}
+
+// aslEntry 返回执行任务的第一个状态
+func aslEntry(states map[string]ASLState, taskID string) string {
+	if _, ok := states["check "+taskID]; ok {
+		return "check " + taskID
+	}
+	return "run " + taskID
+}
+
+// aslLink 把 from 状态的下一个状态改为 to；from 是任务的 Task 状态时同时修改它的跳过状态
+func aslLink(states map[string]ASLState, from, to string) {
+	names := []string{from}
+	if taskID, ok := strings.CutPrefix(from, "run "); ok {
+		names = append(names, "skip "+taskID)
+	}
+	for _, name := range names {
+		if st, ok := states[name]; ok {
+			st.Next = to
+			states[name] = st
+		}
+	}
+}
+
+// aslKey 把任务ID转换为可以用于 JSONPath 的字段名
+func aslKey(taskID string) string {
+	if aslKeyPattern.MatchString(taskID) {
+		return taskID
+	}
+	var b strings.Builder
+	for i, r := range taskID {
+		switch {
+		case r == '_', r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', i > 0 && r >= '0' && r <= '9':
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
+
+// aslChoice 把 compare 条件转换为 Choice 规则，引用的值不存在时与 compare 求值器一样只有 != 成立
+func aslChoice(spec *ConditionSpec, keys map[string]string) (map[string]interface{}, error) {
+	if spec.Evaluator != "" && spec.Evaluator != ConditionCompare {
+		return nil, fmt.Errorf("evaluator %s is not supported, only %s conditions can be exported", spec.Evaluator, ConditionCompare)
+	}
+	path, op, want, err := parseCompare(spec.Expr)
+	if err != nil {
+		return nil, err
+	}
+	variable := "$.params"
+	if path[0] != "params" {
+		// 定义文件中的任务以依赖任务的ID为输入名称，inputs 与 results 引用相同的结果
+		key, ok := keys[path[1]]
+		if !ok {
+			return nil, fmt.Errorf("task %s not found", path[1])
+		}
+		path = append([]string{"results", key}, path[2:]...)
+		variable = "$"
+	} else {
+		path = path[1:]
+	}
+	for _, p := range path {
+		if !aslKeyPattern.MatchString(p) {
+			return nil, fmt.Errorf("field %q cannot be used in a JSONPath", p)
+		}
+		variable += "." + p
+	}
+	present := map[string]interface{}{"Variable": variable, "IsPresent": true}
+
+	var rule map[string]interface{}
+	switch op {
+	case "":
+		// 真值：存在且不为 null、false、0 或空字符串
+		return map[string]interface{}{"And": []interface{}{
+			present,
+			map[string]interface{}{"Variable": variable, "IsNull": false},
+			map[string]interface{}{"Not": map[string]interface{}{"Variable": variable, "BooleanEquals": false}},
+			map[string]interface{}{"Not": map[string]interface{}{"Variable": variable, "NumericEquals": 0}},
+			map[string]interface{}{"Not": map[string]interface{}{"Variable": variable, "StringEquals": ""}},
+		}}, nil
+	case "==", "!=":
+		switch w := want.(type) {
+		case nil:
+			rule = map[string]interface{}{"Variable": variable, "IsNull": true}
+		case bool:
+			rule = map[string]interface{}{"Variable": variable, "BooleanEquals": w}
+		case string:
+			rule = map[string]interface{}{"Variable": variable, "StringEquals": w}
+		case float64:
+			rule = map[string]interface{}{"Variable": variable, "NumericEquals": w}
+		default:
+			return nil, fmt.Errorf("cannot compare with %T in ASL", want)
+		}
+	default:
+		names := map[string]string{">": "GreaterThan", ">=": "GreaterThanEquals", "<": "LessThan", "<=": "LessThanEquals"}
+		switch w := want.(type) {
+		case float64:
+			rule = map[string]interface{}{"Variable": variable, "Numeric" + names[op]: w}
+		case string:
+			rule = map[string]interface{}{"Variable": variable, "String" + names[op]: w}
+		default:
+			return nil, fmt.Errorf("cannot order by %T in ASL", want)
+		}
+	}
+	if op == "!=" {
+		return map[string]interface{}{"Or": []interface{}{
+			map[string]interface{}{"Variable": variable, "IsPresent": false},
+			map[string]interface{}{"Not": rule},
+		}}, nil
+	}
+	return map[string]interface{}{"And": []interface{}{present, rule}}, nil
+}
+
+// JSON 返回缩进的状态机定义，可直接作为 CreateStateMachine 的 definition
+func (sm *ASLStateMachine) JSON() ([]byte, error) {
+	return json.MarshalIndent(sm, "", "  ")
+}
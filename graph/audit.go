@@ -0,0 +1,130 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// AuditAction 表示审计记录的动作
+type AuditAction string
+
+const (
+	AuditRunTriggered        AuditAction = "run.triggered"
+	AuditRunRedriven         AuditAction = "run.redriven"
+	AuditRunRetried          AuditAction = "run.retried"
+	AuditRunStatus           AuditAction = "run.status"
+	AuditTaskStatus          AuditAction = "task.status"
+	AuditDeadLetterDiscarded AuditAction = "deadletter.discarded"
+)
+
+// AuditEntry 是一条审计记录，写入后不可修改
+type AuditEntry struct {
+	Seq       int64 // 命名空间内单调递增的序号，由 AuditStore 分配
+	Time      time.Time
+	Namespace string
+	RunID     string
+	Workflow  string
+	Actor     string // 执行操作的调用方，由 ContextWithActor 写入；引擎内部的状态变化为空
+	Action    AuditAction
+	TaskID    string // 任务状态变化时的任务ID
+	From      string // 状态变化前的状态
+	To        string // 状态变化后的状态
+	Detail    string
+}
+
+// AuditFilter 定义查询审计记录的条件，零值字段不参与过滤
+type AuditFilter struct {
+	RunID  string
+	Actor  string
+	Action AuditAction
+	Since  time.Time // 只返回该时间及之后的记录
+	Limit  int       // 按序号升序返回的最大条数，为0时不限制
+}
+
+// AuditStore 是支持审计日志的 Store，审计记录只能追加。
+// Registry 使用的 Store 实现了该接口时，运行的触发、状态变化和运维操作都会被记录
+type AuditStore interface {
+	AppendAudit(ctx context.Context, entry *AuditEntry) error
+	ListAudit(ctx context.Context, namespace string, filter AuditFilter) ([]*AuditEntry, error)
+}
+
+type actorKey struct{}
+
+// ContextWithActor 返回携带调用方标识的上下文，通过该上下文发起的操作会在审计记录中记为该调用方
+func ContextWithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFrom 返回上下文中的调用方标识，不存在时返回空字符串
+func ActorFrom(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey{}).(string)
+	return actor
+}
+
+// AppendAudit 追加一条审计记录并分配序号
+func (s *MemoryStore) AppendAudit(ctx context.Context, entry *AuditEntry) error {
+	if entry.Namespace == "" {
+		return fmt.Errorf("audit entry requires namespace")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.audit == nil {
+		s.audit = make(map[string][]*AuditEntry)
+	}
+	c := *entry
+	c.Seq = int64(len(s.audit[entry.Namespace]) + 1)
+	if c.Time.IsZero() {
+		c.Time = time.Now()
+	}
+	s.audit[entry.Namespace] = append(s.audit[entry.Namespace], &c)
+	entry.Seq = c.Seq
+	return nil
+}
+
+// ListAudit 按序号升序返回命名空间内符合条件的审计记录
+func (s *MemoryStore) ListAudit(ctx context.Context, namespace string, filter AuditFilter) ([]*AuditEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var entries []*AuditEntry
+	for _, e := range s.audit[namespace] {
+		if (filter.RunID != "" && e.RunID != filter.RunID) ||
+			(filter.Actor != "" && e.Actor != filter.Actor) ||
+			(filter.Action != "" && e.Action != filter.Action) ||
+			e.Time.Before(filter.Since) {
+			continue
+		}
+		c := *e
+		entries = append(entries, &c)
+		if filter.Limit > 0 && len(entries) == filter.Limit {
+			break
+		}
+	}
+	return entries, nil
+}
+
+// audit 在 Store 支持审计时追加记录，未配置审计时忽略
+func (n *NamespaceRegistry) audit(ctx context.Context, entry AuditEntry) error {
+	as, ok := n.registry.store.(AuditStore)
+	if !ok {
+		return nil
+	}
+	entry.Namespace = n.namespace
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	if err := as.AppendAudit(ctx, &entry); err != nil {
+		return fmt.Errorf("failed to append audit entry: %v", err)
+	}
+	return nil
+}
+
+// Audit 返回命名空间内符合条件的审计记录，Store 不支持审计时返回错误
+func (n *NamespaceRegistry) Audit(ctx context.Context, filter AuditFilter) ([]*AuditEntry, error) {
+	as, ok := n.registry.store.(AuditStore)
+	if !ok {
+		return nil, fmt.Errorf("registry store does not support audit logs")
+	}
+	return as.ListAudit(ctx, n.namespace, filter)
+}
@@ -0,0 +1,139 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrBudgetExhausted 表示执行超出了预算
+var ErrBudgetExhausted = errors.New("budget exhausted")
+
+// BudgetMode 定义预算耗尽后的处理方式
+type BudgetMode int
+
+const (
+	// BudgetAbort 预算耗尽后中止执行并返回 ErrBudgetExhausted
+	BudgetAbort BudgetMode = iota
+	// BudgetDegrade 预算耗尽后跳过可选任务，其余任务继续执行
+	BudgetDegrade
+)
+
+// Budget 定义单次执行的资源预算，各项为0时表示不限制
+type Budget struct {
+	MaxTasks    int           // 最多启动的任务数
+	MaxDuration time.Duration // 最长执行时间
+	MaxCost     float64       // 任务通过 ReportCost 上报的成本之和上限
+	Mode        BudgetMode
+}
+
+// WithBudget 为本次执行设置预算，预算耗尽时中止执行
+func WithBudget(maxTasks int, maxDuration time.Duration, maxCost float64) ExecuteOption {
+	return func(o *ExecuteOptions) {
+		o.Budget = &Budget{MaxTasks: maxTasks, MaxDuration: maxDuration, MaxCost: maxCost}
+	}
+}
+
+// budgetTracker 在执行过程中并发安全地统计预算使用情况
+type budgetTracker struct {
+	mu      sync.Mutex
+	budget  *Budget
+	start   time.Time
+	started int
+	cost    float64
+}
+
+func newBudgetTracker(budget *Budget) *budgetTracker {
+	return &budgetTracker{budget: budget, start: time.Now()}
+}
+
+// exhausted 返回预算耗尽的原因，未耗尽时返回 nil；调用方需持有锁
+func (b *budgetTracker) exhausted() error {
+	switch {
+	case b.budget.MaxTasks > 0 && b.started >= b.budget.MaxTasks:
+		return fmt.Errorf("%w: started %d tasks, limit %d", ErrBudgetExhausted, b.started, b.budget.MaxTasks)
+	case b.budget.MaxDuration > 0 && time.Since(b.start) >= b.budget.MaxDuration:
+		return fmt.Errorf("%w: exceeded max duration %v", ErrBudgetExhausted, b.budget.MaxDuration)
+	case b.budget.MaxCost > 0 && b.cost >= b.budget.MaxCost:
+		return fmt.Errorf("%w: cost %.2f reached limit %.2f", ErrBudgetExhausted, b.cost, b.budget.MaxCost)
+	}
+	return nil
+}
+
+// admit 判断任务能否启动并占用一个任务名额。
+// 返回 skip 表示在降级模式下应跳过该可选任务；返回错误表示应中止执行
+func (b *budgetTracker) admit(task *Task) (skip bool, err error) {
+	if b == nil {
+		return false, nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if reason := b.exhausted(); reason != nil {
+		if b.budget.Mode == BudgetAbort {
+			return false, reason
+		}
+		if task.optional() {
+			return true, nil
+		}
+	}
+	b.started++
+	return false, nil
+}
+
+// addCost 累加任务上报的成本
+func (b *budgetTracker) addCost(cost float64) {
+	if b == nil || cost == 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.cost += cost
+}
+
+type costKey struct{}
+
+// taskCost 记录单个任务上报的成本
+type taskCost struct {
+	mu    sync.Mutex
+	value float64
+	units map[string]float64 // 单位 -> 通过 ReportCostIn 上报的成本
+}
+
+func withTaskCost(ctx context.Context, c *taskCost) context.Context {
+	return context.WithValue(ctx, costKey{}, c)
+}
+
+// ReportCost 为当前任务累加成本（如API调用额度、费用估算），
+// 计入执行预算并写入 ExecutionReport；在任务上下文之外调用时忽略
+func ReportCost(ctx context.Context, cost float64) {
+	c, ok := ctx.Value(costKey{}).(*taskCost)
+	if !ok {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.value += cost
+}
+
+func (c *taskCost) total() float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.value
+}
+
+// byUnit 返回按单位上报的成本的副本，没有时返回 nil
+func (c *taskCost) byUnit() map[string]float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.units) == 0 {
+		return nil
+	}
+	units := make(map[string]float64, len(c.units))
+	for unit, v := range c.units {
+		units[unit] = v
+	}
+	return units
+}
@@ -0,0 +1,102 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// 运行的上下文被取消后，各调度方式都不再启动下游任务，并返回 context.Canceled
+func TestCancelStopsDownstreamTasks(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		strategy Strategy
+	}{
+		{"layered", StrategyLayered},
+		{"small", ""},
+		{"work-stealing", StrategyWorkStealing},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			var started atomic.Int32
+			tg := NewTaskGraph()
+			root := &Task{ID: "root", Execute: func(ctx context.Context, inputs map[string]interface{}) (interface{}, error) {
+				cancel()
+				return 1, nil
+			}}
+			if err := tg.AddTask(root); err != nil {
+				t.Fatal(err)
+			}
+			parent := root
+			for i := 0; i < 4; i++ {
+				task := &Task{ID: fmt.Sprintf("task%d", i), Depends: []*Task{parent}, Execute: func(ctx context.Context, inputs map[string]interface{}) (interface{}, error) {
+					started.Add(1)
+					return 1, nil
+				}}
+				if err := tg.AddTask(task); err != nil {
+					t.Fatal(err)
+				}
+				if i%2 == 1 {
+					parent = task
+				}
+			}
+
+			report, err := tg.ExecuteWithReport(ctx, ExecuteOptions{Strategy: tc.strategy, WorkerCount: 4})
+			if !errors.Is(err, context.Canceled) {
+				t.Fatalf("expected context.Canceled, got %v", err)
+			}
+			if n := started.Load(); n != 0 {
+				t.Fatalf("%d downstream tasks started after cancellation", n)
+			}
+			for i := 0; i < 4; i++ {
+				id := fmt.Sprintf("task%d", i)
+				if tr := report.Tasks[id]; tr == nil || tr.Status != TaskStatusPending {
+					t.Fatalf("task %s should stay pending, report: %+v", id, tr)
+				}
+			}
+		})
+	}
+}
+
+// 等待互斥锁时截止时间到达，返回的错误仍能识别为 context.DeadlineExceeded
+func TestAcquireErrorKeepsContextCause(t *testing.T) {
+	held := make(chan struct{})
+	done := make(chan struct{})
+	holder := NewTaskGraph()
+	if err := holder.AddTask(&Task{ID: "holder", Mutex: "acquire-cause", Execute: func(ctx context.Context, inputs map[string]interface{}) (interface{}, error) {
+		close(held)
+		<-done
+		return nil, nil
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	finished := make(chan error, 1)
+	go func() {
+		_, err := holder.Execute(context.Background(), ExecuteOptions{})
+		finished <- err
+	}()
+	<-held
+	defer func() {
+		close(done)
+		if err := <-finished; err != nil {
+			t.Error(err)
+		}
+	}()
+
+	waiter := NewTaskGraph()
+	if err := waiter.AddTask(&Task{ID: "waiter", Mutex: "acquire-cause", Execute: func(ctx context.Context, inputs map[string]interface{}) (interface{}, error) {
+		return nil, nil
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := waiter.Execute(ctx, ExecuteOptions{})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected error wrapping context.DeadlineExceeded, got %v", err)
+	}
+}
@@ -0,0 +1,248 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+)
+
+// ErrCheckpointNotFound 表示任务还没有提交过检查点
+var ErrCheckpointNotFound = errors.New("checkpoint not found")
+
+// Checkpoint 是长任务提交的处理进度，同一运行中重新执行该任务时（如 RetryFailed 或 Engine.Resume）
+// 可以从这里继续，而不是从头开始。任务成功完成后检查点会被删除
+type Checkpoint struct {
+	RunID   string
+	TaskID  string
+	Chunk   int   // 已提交的块数
+	Records int64 // 已处理的记录数
+	// Offset 是下一条记录在源文件中的字节偏移，格式不支持按偏移恢复时为 -1
+	Offset int64
+	// State 是任务自定义的累积状态，如已写出的文件或游标，需要可以编码为 JSON
+	State map[string]interface{}
+	Time  time.Time
+}
+
+// CheckpointStore 持久化任务的检查点。运行记录的 Store 同时实现该接口时，
+// 注册表执行的运行自动使用它，也可以通过 ExecuteOptions.Checkpoints 指定
+type CheckpointStore interface {
+	SaveCheckpoint(ctx context.Context, cp *Checkpoint) error
+	// GetCheckpoint 在没有检查点时返回包装了 ErrCheckpointNotFound 的错误
+	GetCheckpoint(ctx context.Context, runID, taskID string) (*Checkpoint, error)
+	DeleteCheckpoint(ctx context.Context, runID, taskID string) error
+}
+
+// checkpointKey 在内存存储中标识一个检查点
+type checkpointKey struct {
+	runID  string
+	taskID string
+}
+
+// SaveCheckpoint 实现 CheckpointStore
+func (s *MemoryStore) SaveCheckpoint(ctx context.Context, cp *Checkpoint) error {
+	if cp.RunID == "" || cp.TaskID == "" {
+		return fmt.Errorf("checkpoint requires run id and task id")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.checkpoints == nil {
+		s.checkpoints = make(map[checkpointKey]*Checkpoint)
+	}
+	c := *cp
+	c.State = copyState(cp.State)
+	s.checkpoints[checkpointKey{cp.RunID, cp.TaskID}] = &c
+	return nil
+}
+
+// GetCheckpoint 实现 CheckpointStore
+func (s *MemoryStore) GetCheckpoint(ctx context.Context, runID, taskID string) (*Checkpoint, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	cp, ok := s.checkpoints[checkpointKey{runID, taskID}]
+	if !ok {
+		return nil, fmt.Errorf("%w: task %s of run %s", ErrCheckpointNotFound, taskID, runID)
+	}
+	c := *cp
+	c.State = copyState(cp.State)
+	return &c, nil
+}
+
+// DeleteCheckpoint 实现 CheckpointStore，检查点不存在时不返回错误
+func (s *MemoryStore) DeleteCheckpoint(ctx context.Context, runID, taskID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.checkpoints, checkpointKey{runID, taskID})
+	return nil
+}
+
+func copyState(state map[string]interface{}) map[string]interface{} {
+	if state == nil {
+		return nil
+	}
+	c := make(map[string]interface{}, len(state))
+	for k, v := range state {
+		c[k] = v
+	}
+	return c
+}
+
+// Checkpointer 读写当前任务的检查点，通过 CheckpointerFrom 获取
+type Checkpointer struct {
+	store  CheckpointStore
+	runID  string
+	taskID string
+}
+
+type checkpointerKey struct{}
+
+func withCheckpointer(ctx context.Context, c *Checkpointer) context.Context {
+	return context.WithValue(ctx, checkpointerKey{}, c)
+}
+
+// CheckpointerFrom 返回当前任务的检查点读写器；执行时没有设置 CheckpointStore 时返回 nil，
+// nil 的 Checkpointer 上 Load 返回 nil，Commit 不做任何事
+func CheckpointerFrom(ctx context.Context) *Checkpointer {
+	c, _ := ctx.Value(checkpointerKey{}).(*Checkpointer)
+	return c
+}
+
+// Load 返回最后提交的检查点，没有检查点时返回 nil
+func (c *Checkpointer) Load(ctx context.Context) (*Checkpoint, error) {
+	if c == nil {
+		return nil, nil
+	}
+	cp, err := c.store.GetCheckpoint(ctx, c.runID, c.taskID)
+	if errors.Is(err, ErrCheckpointNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load checkpoint of task %s: %v", c.taskID, err)
+	}
+	return cp, nil
+}
+
+// Commit 保存进度，cp 的 RunID、TaskID 和 Time 由 Commit 填写
+func (c *Checkpointer) Commit(ctx context.Context, cp Checkpoint) error {
+	if c == nil {
+		return nil
+	}
+	cp.RunID, cp.TaskID, cp.Time = c.runID, c.taskID, time.Now()
+	if err := c.store.SaveCheckpoint(ctx, &cp); err != nil {
+		return fmt.Errorf("failed to commit checkpoint of task %s: %v", c.taskID, err)
+	}
+	return nil
+}
+
+// clear 在任务成功后删除检查点，失败只记录日志
+func (c *Checkpointer) clear(ctx context.Context, logger *slog.Logger) {
+	if err := c.store.DeleteCheckpoint(context.WithoutCancel(ctx), c.runID, c.taskID); err != nil && !errors.Is(err, ErrCheckpointNotFound) {
+		logger.Warn("failed to delete checkpoint", slog.String("task_id", c.taskID), slog.Any("error", err))
+	}
+}
+
+// ChunkOptions 配置 ProcessRecordChunks
+type ChunkOptions struct {
+	Size    int    // 每块的记录数，默认10000
+	Format  string // 为空时按扩展名推断
+	Options map[string]interface{}
+}
+
+// RecordChunk 是交给处理函数的一块记录
+type RecordChunk struct {
+	Index   int   // 块的序号，从0开始；恢复后继续递增，可用于生成幂等的输出文件名
+	Start   int64 // 第一条记录在文件中的序号
+	Records []map[string]interface{}
+	// State 是上一块提交的累积状态，处理函数可以修改，随本块一起提交
+	State map[string]interface{}
+}
+
+// ProcessRecordChunks 分块读取记录文件，每块由 fn 处理后提交一个检查点。
+// 任务失败后在同一运行中重新执行时从最后提交的块之后继续：格式支持按偏移读取（CSV、JSONL）时直接定位，
+// 否则跳过已处理的记录。fn 的副作用需要以块为单位幂等，因为提交检查点之前的崩溃会使该块重新处理。
+// 没有 CheckpointStore 时只分块处理，不能恢复。返回最后的检查点，块数、记录数也会写入任务属性
+func ProcessRecordChunks(ctx context.Context, path string, opts ChunkOptions, fn func(ctx context.Context, chunk *RecordChunk) error) (*Checkpoint, error) {
+	if opts.Size <= 0 {
+		opts.Size = 10000
+	}
+	checkpoints := CheckpointerFrom(ctx)
+	progress, err := checkpoints.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var r RecordReader
+	if progress != nil {
+		Annotate(ctx, "resumed_from_chunk", progress.Chunk)
+		LoggerFrom(ctx).Info("resuming from checkpoint", slog.String("path", path), slog.Int("chunk", progress.Chunk), slog.Int64("records", progress.Records))
+		if progress.Offset >= 0 {
+			var ok bool
+			if r, ok, err = openRecordsAt(path, opts.Format, opts.Options, progress.Offset); err != nil {
+				return nil, err
+			}
+			if ok {
+				LoggerFrom(ctx).Debug("seeking to checkpoint offset", slog.Int64("offset", progress.Offset))
+			}
+		}
+	} else {
+		progress = &Checkpoint{}
+	}
+	skip := int64(0)
+	if r == nil {
+		if r, err = OpenRecords(path, opts.Format, opts.Options); err != nil {
+			return nil, err
+		}
+		skip = progress.Records
+	}
+	defer r.Close()
+	cursor, _ := r.(RecordCursor)
+
+	for {
+		chunk := &RecordChunk{Index: progress.Chunk, Start: progress.Records, State: progress.State}
+		for len(chunk.Records) < opts.Size {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			record, err := r.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to read chunk %d of %s: %v", chunk.Index, path, err)
+			}
+			if skip > 0 {
+				skip--
+				continue
+			}
+			chunk.Records = append(chunk.Records, record)
+		}
+		if len(chunk.Records) == 0 {
+			break
+		}
+		if chunk.State == nil {
+			chunk.State = make(map[string]interface{})
+		}
+		if err := fn(ctx, chunk); err != nil {
+			return nil, fmt.Errorf("chunk %d of %s failed: %v", chunk.Index, path, err)
+		}
+		progress = &Checkpoint{
+			Chunk:   chunk.Index + 1,
+			Records: chunk.Start + int64(len(chunk.Records)),
+			Offset:  -1,
+			State:   chunk.State,
+		}
+		if cursor != nil {
+			progress.Offset = cursor.Offset()
+		}
+		if err := checkpoints.Commit(ctx, *progress); err != nil {
+			return nil, err
+		}
+		Annotate(ctx, "chunks", progress.Chunk)
+		Annotate(ctx, "records", progress.Records)
+		if len(chunk.Records) < opts.Size {
+			break
+		}
+	}
+	return progress, nil
+}
@@ -0,0 +1,82 @@
+package graph
+
+import (
+	"fmt"
+)
+
+// Clone 复制任务图及其全部任务，新任务图中的任务状态重置为 pending，
+// 修改副本中的任务不会影响原任务图
+func (tg *TaskGraph) Clone() (*TaskGraph, error) {
+	return tg.CloneWith(nil)
+}
+
+// CloneWith 复制任务图，并用 overrides 中的任务替换同ID的任务，
+// 适合从同一个模板为不同租户或环境实例化工作流（如沙箱与生产环境的支付任务）。
+// 替换任务的 Depends 为空时沿用原任务的依赖，否则按依赖的ID在副本中重新关联
+func (tg *TaskGraph) CloneWith(overrides map[string]*Task) (*TaskGraph, error) {
+	for id, override := range overrides {
+		if _, err := tg.graph.Vertex(id); err != nil {
+			return nil, fmt.Errorf("override task %s not found", id)
+		}
+		if override.ID != "" && override.ID != id {
+			return nil, fmt.Errorf("override for task %s has mismatched id %s", id, override.ID)
+		}
+	}
+
+	order, err := tg.topologicalOrder()
+	if err != nil {
+		return nil, fmt.Errorf("failed to sort tasks: %v", err)
+	}
+
+	clone := NewTaskGraph()
+	clone.inferDeps = tg.inferDeps
+	clones := make(map[string]*Task, len(order))
+	for _, id := range order {
+		original, err := tg.graph.Vertex(id)
+		if err != nil {
+			return nil, fmt.Errorf("task %s not found", id)
+		}
+
+		source := original
+		if override, ok := overrides[id]; ok {
+			source = override
+		}
+		deps := source.Depends
+		if deps == nil {
+			deps = original.Depends
+		}
+
+		task := source.copy()
+		task.ID = id
+		task.Depends = make([]*Task, 0, len(deps))
+		for _, dep := range deps {
+			depClone, ok := clones[dep.ID]
+			if !ok {
+				return nil, fmt.Errorf("dependency %s of task %s not found", dep.ID, id)
+			}
+			task.Depends = append(task.Depends, depClone)
+		}
+
+		if err := clone.AddTask(task); err != nil {
+			return nil, err
+		}
+		clones[id] = task
+	}
+	return clone, nil
+}
+
+// copy 返回任务的副本，切片和映射字段被复制，状态重置为 pending
+func (t *Task) copy() *Task {
+	c := *t
+	c.Status = TaskStatusPending
+	if t.Tags != nil {
+		c.Tags = append([]string(nil), t.Tags...)
+	}
+	if t.Inputs != nil {
+		c.Inputs = make(map[string]InputBinding, len(t.Inputs))
+		for name, binding := range t.Inputs {
+			c.Inputs[name] = binding
+		}
+	}
+	return &c
+}
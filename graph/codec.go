@@ -0,0 +1,523 @@
+package graph
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// 内置编码的名称
+const (
+	CodecJSON    = "json"
+	CodecGob     = "gob"
+	CodecMsgpack = "msgpack"
+)
+
+// Codec 定义持久化和传输任务输入、输出时的编码。
+// 为空的编码名称视为 CodecJSON；protobuf 等其他编码通过 RegisterCodec 注册
+type Codec interface {
+	Name() string
+	Encode(v interface{}) ([]byte, error)
+	Decode(data []byte) (interface{}, error)
+}
+
+var (
+	codecsMu sync.RWMutex
+	codecs   = map[string]Codec{
+		CodecJSON:    jsonCodec{},
+		CodecGob:     gobCodec{},
+		CodecMsgpack: msgpackCodec{},
+	}
+)
+
+// RegisterCodec 注册全局可用的编码；同名编码已存在时返回错误
+func RegisterCodec(c Codec) error {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+	if c.Name() == "" {
+		return fmt.Errorf("codec name is required")
+	}
+	if _, ok := codecs[c.Name()]; ok {
+		return fmt.Errorf("codec %s already registered", c.Name())
+	}
+	codecs[c.Name()] = c
+	return nil
+}
+
+// Codecs 返回全局注册的编码名称（按名称排序）
+func Codecs() []string {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	names := make([]string, 0, len(codecs))
+	for name := range codecs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LookupCodec 返回全局注册的编码，name 为空时返回 JSON 编码
+func LookupCodec(name string) (Codec, bool) {
+	if name == "" {
+		name = CodecJSON
+	}
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	c, ok := codecs[name]
+	return c, ok
+}
+
+// Payload 是编码后的任务输入或输出，对应 proto 中的 workflow.v1.Payload
+type Payload struct {
+	Codec string `json:"codec"`
+	Data  []byte `json:"data"`
+}
+
+// EncodePayload 使用名为 codec 的编码（为空时为 JSON）编码 v
+func EncodePayload(codec string, v interface{}) (Payload, error) {
+	c, ok := LookupCodec(codec)
+	if !ok {
+		return Payload{}, fmt.Errorf("unknown codec %s", codec)
+	}
+	data, err := c.Encode(v)
+	if err != nil {
+		return Payload{}, fmt.Errorf("%s encode failed: %v", c.Name(), err)
+	}
+	return Payload{Codec: c.Name(), Data: data}, nil
+}
+
+// DecodePayload 使用 Payload 记录的编码解码
+func DecodePayload(p Payload) (interface{}, error) {
+	c, ok := LookupCodec(p.Codec)
+	if !ok {
+		return nil, fmt.Errorf("unknown codec %s", p.Codec)
+	}
+	v, err := c.Decode(p.Data)
+	if err != nil {
+		return nil, fmt.Errorf("%s decode failed: %v", c.Name(), err)
+	}
+	return v, nil
+}
+
+// jsonCodec 解码时把不含小数点和指数的数字还原为 int64（超出范围时为 float64），
+// 避免整数经 JSON 往返后变为 float64
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string {
+	return CodecJSON
+}
+
+func (jsonCodec) Encode(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Decode(data []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return restoreNumbers(v), nil
+}
+
+func restoreNumbers(v interface{}) interface{} {
+	switch v := v.(type) {
+	case json.Number:
+		s := string(v)
+		if !strings.ContainsAny(s, ".eE") {
+			if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+				return n
+			}
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		for k, e := range v {
+			v[k] = restoreNumbers(e)
+		}
+	case []interface{}:
+		for i, e := range v {
+			v[i] = restoreNumbers(e)
+		}
+	}
+	return v
+}
+
+// gobCodec 保留 Go 的具体类型；自定义类型需先通过 gob.Register 注册
+type gobCodec struct{}
+
+func init() {
+	gob.Register(map[string]interface{}{})
+	gob.Register([]interface{}{})
+}
+
+func (gobCodec) Name() string {
+	return CodecGob
+}
+
+func (gobCodec) Encode(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobCodec) Decode(data []byte) (interface{}, error) {
+	var v interface{}
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// msgpackCodec 实现 MessagePack 编码。整数解码为 int64（超出 int64 的无符号数为 uint64），
+// float32 和 float64 保持原精度，二进制数据解码为 []byte；
+// 结构体按导出字段编码为映射表（字段名取 json 标签），解码为 map[string]interface{}；
+// 实现了 encoding.TextMarshaler 的类型（如 time.Time）编码为字符串
+type msgpackCodec struct{}
+
+func (msgpackCodec) Name() string {
+	return CodecMsgpack
+}
+
+func (msgpackCodec) Encode(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := msgpackEncode(&buf, reflect.ValueOf(v)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (msgpackCodec) Decode(data []byte) (interface{}, error) {
+	d := &msgpackDecoder{data: data}
+	v, err := d.decode()
+	if err != nil {
+		return nil, err
+	}
+	if d.pos != len(data) {
+		return nil, fmt.Errorf("%d trailing bytes", len(data)-d.pos)
+	}
+	return v, nil
+}
+
+func msgpackEncode(buf *bytes.Buffer, v reflect.Value) error {
+	if !v.IsValid() {
+		buf.WriteByte(0xc0)
+		return nil
+	}
+	// time.Time 等实现了 TextMarshaler 的类型编码为字符串
+	if m, ok := v.Interface().(encoding.TextMarshaler); ok && v.Kind() != reflect.Pointer && v.Kind() != reflect.Interface {
+		text, err := m.MarshalText()
+		if err != nil {
+			return err
+		}
+		msgpackHeader(buf, len(text), 0xa0, 31, 0xda)
+		buf.Write(text)
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			buf.WriteByte(0xc0)
+			return nil
+		}
+		return msgpackEncode(buf, v.Elem())
+	case reflect.Bool:
+		if v.Bool() {
+			buf.WriteByte(0xc3)
+		} else {
+			buf.WriteByte(0xc2)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n := v.Int()
+		switch {
+		case n >= 0:
+			msgpackUint(buf, uint64(n))
+		case n >= -32:
+			buf.WriteByte(byte(n))
+		default:
+			buf.WriteByte(0xd3)
+			binary.Write(buf, binary.BigEndian, n)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		msgpackUint(buf, v.Uint())
+	case reflect.Float32:
+		buf.WriteByte(0xca)
+		binary.Write(buf, binary.BigEndian, math.Float32bits(float32(v.Float())))
+	case reflect.Float64:
+		buf.WriteByte(0xcb)
+		binary.Write(buf, binary.BigEndian, math.Float64bits(v.Float()))
+	case reflect.String:
+		msgpackHeader(buf, v.Len(), 0xa0, 31, 0xda)
+		buf.WriteString(v.String())
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			buf.WriteByte(0xc0)
+			return nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			msgpackHeader(buf, v.Len(), 0, -1, 0xc5)
+			for i := 0; i < v.Len(); i++ {
+				buf.WriteByte(byte(v.Index(i).Uint()))
+			}
+			return nil
+		}
+		msgpackHeader(buf, v.Len(), 0x90, 15, 0xdc)
+		for i := 0; i < v.Len(); i++ {
+			if err := msgpackEncode(buf, v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if v.IsNil() {
+			buf.WriteByte(0xc0)
+			return nil
+		}
+		msgpackHeader(buf, v.Len(), 0x80, 15, 0xde)
+		// 按键排序，相同的值编码结果相同
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j]) })
+		for _, k := range keys {
+			if err := msgpackEncode(buf, k); err != nil {
+				return err
+			}
+			if err := msgpackEncode(buf, v.MapIndex(k)); err != nil {
+				return err
+			}
+		}
+	case reflect.Struct:
+		names, fields := msgpackFields(v)
+		msgpackHeader(buf, len(names), 0x80, 15, 0xde)
+		for i, name := range names {
+			msgpackHeader(buf, len(name), 0xa0, 31, 0xda)
+			buf.WriteString(name)
+			if err := msgpackEncode(buf, fields[i]); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// msgpackFields 返回结构体的导出字段及其名称，忽略 json 标签为 "-" 的字段
+func msgpackFields(v reflect.Value) ([]string, []reflect.Value) {
+	var names []string
+	var fields []reflect.Value
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name := f.Name
+		if tag, _, _ := strings.Cut(f.Tag.Get("json"), ","); tag == "-" {
+			continue
+		} else if tag != "" {
+			name = tag
+		}
+		names = append(names, name)
+		fields = append(fields, v.Field(i))
+	}
+	return names, fields
+}
+
+func msgpackUint(buf *bytes.Buffer, n uint64) {
+	switch {
+	case n <= 0x7f:
+		buf.WriteByte(byte(n))
+	case n <= math.MaxUint8:
+		buf.WriteByte(0xcc)
+		buf.WriteByte(byte(n))
+	case n <= math.MaxUint16:
+		buf.WriteByte(0xcd)
+		binary.Write(buf, binary.BigEndian, uint16(n))
+	case n <= math.MaxUint32:
+		buf.WriteByte(0xce)
+		binary.Write(buf, binary.BigEndian, uint32(n))
+	default:
+		buf.WriteByte(0xcf)
+		binary.Write(buf, binary.BigEndian, n)
+	}
+}
+
+// msgpackHeader 写出字符串、二进制、数组或映射表的头部：长度不超过 fixMax 时使用 fix 格式，
+// 否则依次使用 16 位（first）和 32 位（first+1）长度；字符串和二进制还有 8 位长度格式
+func msgpackHeader(buf *bytes.Buffer, n int, fix byte, fixMax int, first byte) {
+	switch {
+	case n <= fixMax:
+		buf.WriteByte(fix | byte(n))
+	case first == 0xc5 && n <= math.MaxUint8:
+		buf.WriteByte(0xc4)
+		buf.WriteByte(byte(n))
+	case first == 0xda && n <= math.MaxUint8:
+		buf.WriteByte(0xd9)
+		buf.WriteByte(byte(n))
+	case n <= math.MaxUint16:
+		buf.WriteByte(first)
+		binary.Write(buf, binary.BigEndian, uint16(n))
+	default:
+		buf.WriteByte(first + 1)
+		binary.Write(buf, binary.BigEndian, uint32(n))
+	}
+}
+
+type msgpackDecoder struct {
+	data []byte
+	pos  int
+}
+
+func (d *msgpackDecoder) read(n int) ([]byte, error) {
+	if n < 0 || d.pos+n > len(d.data) {
+		return nil, fmt.Errorf("unexpected end of data")
+	}
+	b := d.data[d.pos : d.pos+n]
+	d.pos += n
+	return b, nil
+}
+
+// uint 读取 n 字节的大端无符号整数
+func (d *msgpackDecoder) uint(n int) (uint64, error) {
+	b, err := d.read(n)
+	if err != nil {
+		return 0, err
+	}
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v, nil
+}
+
+func (d *msgpackDecoder) decode() (interface{}, error) {
+	b, err := d.read(1)
+	if err != nil {
+		return nil, err
+	}
+	c := b[0]
+	switch {
+	case c <= 0x7f:
+		return int64(c), nil
+	case c >= 0xe0:
+		return int64(int8(c)), nil
+	case c&0xe0 == 0xa0:
+		return d.str(int(c & 0x1f))
+	case c&0xf0 == 0x90:
+		return d.array(int(c & 0x0f))
+	case c&0xf0 == 0x80:
+		return d.mapping(int(c & 0x0f))
+	}
+
+	// 其余格式的长度或值跟在类型字节之后
+	sizes := map[byte]int{
+		0xc4: 1, 0xc5: 2, 0xc6: 4, 0xca: 4, 0xcb: 8,
+		0xcc: 1, 0xcd: 2, 0xce: 4, 0xcf: 8, 0xd0: 1, 0xd1: 2, 0xd2: 4, 0xd3: 8,
+		0xd9: 1, 0xda: 2, 0xdb: 4, 0xdc: 2, 0xdd: 4, 0xde: 2, 0xdf: 4,
+	}
+	switch c {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	}
+	size, ok := sizes[c]
+	if !ok {
+		return nil, fmt.Errorf("unsupported format 0x%02x", c)
+	}
+	n, err := d.uint(size)
+	if err != nil {
+		return nil, err
+	}
+	switch c {
+	case 0xc4, 0xc5, 0xc6:
+		raw, err := d.read(int(n))
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte(nil), raw...), nil
+	case 0xca:
+		return math.Float32frombits(uint32(n)), nil
+	case 0xcb:
+		return math.Float64frombits(n), nil
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		if n > math.MaxInt64 {
+			return n, nil
+		}
+		return int64(n), nil
+	case 0xd0:
+		return int64(int8(n)), nil
+	case 0xd1:
+		return int64(int16(n)), nil
+	case 0xd2:
+		return int64(int32(n)), nil
+	case 0xd3:
+		return int64(n), nil
+	case 0xd9, 0xda, 0xdb:
+		return d.str(int(n))
+	case 0xdc, 0xdd:
+		return d.array(int(n))
+	default:
+		return d.mapping(int(n))
+	}
+}
+
+func (d *msgpackDecoder) str(n int) (interface{}, error) {
+	b, err := d.read(n)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func (d *msgpackDecoder) array(n int) (interface{}, error) {
+	if n > len(d.data)-d.pos {
+		return nil, fmt.Errorf("unexpected end of data")
+	}
+	arr := make([]interface{}, n)
+	for i := range arr {
+		v, err := d.decode()
+		if err != nil {
+			return nil, err
+		}
+		arr[i] = v
+	}
+	return arr, nil
+}
+
+// mapping 解码映射表，非字符串的键按 fmt.Sprint 转换为字符串
+func (d *msgpackDecoder) mapping(n int) (interface{}, error) {
+	if n > len(d.data)-d.pos {
+		return nil, fmt.Errorf("unexpected end of data")
+	}
+	m := make(map[string]interface{}, n)
+	for i := 0; i < n; i++ {
+		k, err := d.decode()
+		if err != nil {
+			return nil, err
+		}
+		v, err := d.decode()
+		if err != nil {
+			return nil, err
+		}
+		key, ok := k.(string)
+		if !ok {
+			key = fmt.Sprint(k)
+		}
+		m[key] = v
+	}
+	return m, nil
+}
@@ -0,0 +1,203 @@
+package graph
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ConditionCompare 是内置的比较条件求值器名称
+const ConditionCompare = "compare"
+
+// ConditionFunc 是编译后的条件，返回错误时视为条件不满足
+type ConditionFunc func(inputs map[string]interface{}, results ResultView) (bool, error)
+
+// ConditionEvaluator 是可插拔的条件求值器，如 "cel"、"jq"。
+// 定义文件中任务的 when 以 Name 选择求值器，表达式在定义加载时编译
+type ConditionEvaluator interface {
+	Name() string
+	Compile(expr string) (ConditionFunc, error)
+}
+
+var (
+	conditionEvaluatorsMu sync.RWMutex
+	conditionEvaluators   = map[string]ConditionEvaluator{
+		ConditionCompare: compareEvaluator{},
+	}
+)
+
+// RegisterConditionEvaluator 注册全局可用的条件求值器；同名求值器已存在时返回错误
+func RegisterConditionEvaluator(e ConditionEvaluator) error {
+	conditionEvaluatorsMu.Lock()
+	defer conditionEvaluatorsMu.Unlock()
+	if e.Name() == "" {
+		return fmt.Errorf("condition evaluator name is required")
+	}
+	if _, ok := conditionEvaluators[e.Name()]; ok {
+		return fmt.Errorf("condition evaluator %s already registered", e.Name())
+	}
+	conditionEvaluators[e.Name()] = e
+	return nil
+}
+
+// ConditionEvaluators 返回全局注册的条件求值器名称（按名称排序）
+func ConditionEvaluators() []string {
+	conditionEvaluatorsMu.RLock()
+	defer conditionEvaluatorsMu.RUnlock()
+	names := make([]string, 0, len(conditionEvaluators))
+	for name := range conditionEvaluators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LookupConditionEvaluator 返回全局注册的条件求值器
+func LookupConditionEvaluator(name string) (ConditionEvaluator, bool) {
+	conditionEvaluatorsMu.RLock()
+	defer conditionEvaluatorsMu.RUnlock()
+	e, ok := conditionEvaluators[name]
+	return e, ok
+}
+
+// compareOps 按匹配顺序排列，较长的运算符在前
+var compareOps = []string{"==", "!=", ">=", "<=", ">", "<"}
+
+// compareEvaluator 实现 "<引用> <运算符> <JSON 字面量>" 形式的条件，如
+// `inputs.fetch.count > 10`、`params.env == "prod"`、`results.check.ok`。
+// 引用以 inputs、results 或 params 开头，后接以点分隔的字段路径；只有引用时判断其是否为真值。
+// 引用的值不存在时只有 != 成立
+type compareEvaluator struct{}
+
+func (compareEvaluator) Name() string {
+	return ConditionCompare
+}
+
+func (compareEvaluator) Compile(expr string) (ConditionFunc, error) {
+	path, op, want, err := parseCompare(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(inputs map[string]interface{}, results ResultView) (bool, error) {
+		var root interface{}
+		var ok bool
+		switch path[0] {
+		case "inputs":
+			root, ok = inputs[path[1]]
+		case "results":
+			root, ok = results.Result(path[1])
+		case "params":
+			root, ok = results.Param(path[1])
+		}
+		value, found := lookupPath(root, path[2:])
+		if !ok || !found {
+			return op == "!=", nil
+		}
+		if op == "" {
+			return truthy(value), nil
+		}
+		return compareValues(value, op, want)
+	}, nil
+}
+
+// parseCompare 解析比较条件，返回引用的路径、运算符（只有引用时为空）和解码后的字面量
+func parseCompare(expr string) (path []string, op string, want interface{}, err error) {
+	// 取最靠前的运算符，避免匹配到字面量中的字符
+	ref, literal, at := strings.TrimSpace(expr), "", len(expr)
+	for _, candidate := range compareOps {
+		if i := strings.Index(expr, candidate); i >= 0 && i < at {
+			ref, op, literal, at = strings.TrimSpace(expr[:i]), candidate, strings.TrimSpace(expr[i+len(candidate):]), i
+		}
+	}
+
+	path = strings.Split(ref, ".")
+	if len(path) < 2 || (path[0] != "inputs" && path[0] != "results" && path[0] != "params") {
+		return nil, "", nil, fmt.Errorf("invalid reference %q, expected inputs.<name>, results.<task> or params.<key>", ref)
+	}
+	if op != "" {
+		if err := json.Unmarshal([]byte(literal), &want); err != nil {
+			return nil, "", nil, fmt.Errorf("invalid literal %q: %v", literal, err)
+		}
+	}
+	return path, op, want, nil
+}
+
+// lookupPath 按字段路径读取嵌套的 map 值
+func lookupPath(value interface{}, path []string) (interface{}, bool) {
+	for _, key := range path {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if value, ok = m[key]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+func truthy(v interface{}) bool {
+	switch v := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case string:
+		return v != ""
+	}
+	if f, ok := toFloat(v); ok {
+		return f != 0
+	}
+	return true
+}
+
+func compareValues(got interface{}, op string, want interface{}) (bool, error) {
+	gf, gok := toFloat(got)
+	wf, wok := toFloat(want)
+	switch op {
+	case "==", "!=":
+		equal := reflect.DeepEqual(got, want)
+		if gok && wok {
+			equal = gf == wf
+		}
+		return equal == (op == "=="), nil
+	}
+	if !gok || !wok {
+		gs, gIsString := got.(string)
+		ws, wIsString := want.(string)
+		if !gIsString || !wIsString {
+			return false, fmt.Errorf("cannot compare %T %s %T", got, op, want)
+		}
+		gf, wf = float64(strings.Compare(gs, ws)), 0
+	}
+	switch op {
+	case ">":
+		return gf > wf, nil
+	case ">=":
+		return gf >= wf, nil
+	case "<":
+		return gf < wf, nil
+	default:
+		return gf <= wf, nil
+	}
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	}
+	return 0, false
+}
@@ -0,0 +1,152 @@
+package graph
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Contract 声明任务输出的结构和样例，以及任务期望从上游收到的输入的结构，
+// 用于在CI中检查上下游任务之间的数据契约（见 CheckContracts），不影响执行
+type Contract struct {
+	// Output 是任务输出的 JSON Schema，支持的子集同 ValidateConfig
+	Output map[string]interface{} `json:"output,omitempty"`
+	// Samples 是任务的样例输出，应覆盖下游需要处理的各种形态
+	Samples []interface{} `json:"samples,omitempty"`
+	// Inputs 是输入名称 -> 期望的 JSON Schema；任务没有声明 Inputs 时以依赖任务的ID作为输入名称
+	Inputs map[string]map[string]interface{} `json:"inputs,omitempty"`
+}
+
+// ContractCheck 是一项契约检查的结果：生产者的一个样例输出经其编码往返后，
+// 是否符合生产者声明的 Output（Consumer 为空时），或消费者为对应输入声明的结构
+type ContractCheck struct {
+	Producer string
+	Consumer string
+	Input    string
+	Sample   int   // 样例在 Producer 的 Contract.Samples 中的下标，生产者没有样例时为 -1
+	Err      error // 为 nil 时检查通过
+}
+
+// Name 返回检查的名称，如 "extract->load/orders/sample0"，可作为测试名称
+func (c ContractCheck) Name() string {
+	switch {
+	case c.Consumer == "":
+		return fmt.Sprintf("%s/sample%d", c.Producer, c.Sample)
+	case c.Producer == "":
+		return fmt.Sprintf("%s/%s", c.Consumer, c.Input)
+	case c.Sample < 0:
+		return fmt.Sprintf("%s->%s/%s", c.Producer, c.Consumer, c.Input)
+	}
+	return fmt.Sprintf("%s->%s/%s/sample%d", c.Producer, c.Consumer, c.Input, c.Sample)
+}
+
+// CheckContracts 按任务声明的 Contract 生成并执行契约检查，结果按生产者、消费者、输入和样例排序：
+// 每个样例输出先以生产者的 Codec（为空时为 JSON）编码再解码，检查其符合生产者的 Output，
+// 再取出消费者绑定的部分（InputBinding.Key）检查其符合消费者为该输入声明的结构。
+// 消费者为某个输入声明了结构而生产者没有样例、或声明的输入不存在时，检查失败
+func (tg *TaskGraph) CheckContracts() ([]ContractCheck, error) {
+	tasks, _, err := tg.snapshot()
+	if err != nil {
+		return nil, err
+	}
+
+	var checks []ContractCheck
+	decoded := make(map[string][]decodedSample) // 生产者 -> 编码往返后的样例
+	for id, task := range tasks {
+		if task.Contract == nil {
+			continue
+		}
+		for i, sample := range task.Contract.Samples {
+			value, err := roundTrip(task.Codec, sample)
+			decoded[id] = append(decoded[id], decodedSample{value: value, err: err})
+			if err == nil && task.Contract.Output != nil {
+				err = validateSchema("output", task.Contract.Output, value)
+			}
+			checks = append(checks, ContractCheck{Producer: id, Sample: i, Err: err})
+		}
+	}
+
+	for id, task := range tasks {
+		if task.Contract == nil {
+			continue
+		}
+		bindings := task.inputBindings()
+		for name, schema := range task.Contract.Inputs {
+			binding, ok := bindings[name]
+			if !ok {
+				checks = append(checks, ContractCheck{Consumer: id, Input: name, Sample: -1,
+					Err: fmt.Errorf("task %s has no input or dependency named %s", id, name)})
+				continue
+			}
+			producer := tasks[binding.TaskID]
+			if producer.Contract == nil || len(producer.Contract.Samples) == 0 {
+				checks = append(checks, ContractCheck{Producer: binding.TaskID, Consumer: id, Input: name, Sample: -1,
+					Err: fmt.Errorf("task %s declares no sample outputs", binding.TaskID)})
+				continue
+			}
+			for i, sample := range decoded[binding.TaskID] {
+				check := ContractCheck{Producer: binding.TaskID, Consumer: id, Input: name, Sample: i, Err: sample.err}
+				if sample.err == nil {
+					check.Err = checkInput(name, binding.Key, schema, sample.value)
+				}
+				checks = append(checks, check)
+			}
+		}
+	}
+
+	sort.Slice(checks, func(i, j int) bool {
+		a, b := checks[i], checks[j]
+		if a.Producer != b.Producer {
+			return a.Producer < b.Producer
+		}
+		if a.Consumer != b.Consumer {
+			return a.Consumer < b.Consumer
+		}
+		if a.Input != b.Input {
+			return a.Input < b.Input
+		}
+		return a.Sample < b.Sample
+	})
+	return checks, nil
+}
+
+// decodedSample 是编码往返后的样例输出，err 为编码或解码失败的原因
+type decodedSample struct {
+	value interface{}
+	err   error
+}
+
+// inputBindings 返回任务的输入名称 -> 绑定，没有声明 Inputs 时每个依赖任务以其ID作为输入名称
+func (t *Task) inputBindings() map[string]InputBinding {
+	if len(t.Inputs) > 0 {
+		return t.Inputs
+	}
+	bindings := make(map[string]InputBinding, len(t.Depends))
+	for _, dep := range t.Depends {
+		bindings[dep.ID] = From(dep.ID)
+	}
+	return bindings
+}
+
+// roundTrip 以名为 codec 的编码编码再解码 v，得到下游从持久化或传输的结果中读到的值
+func roundTrip(codec string, v interface{}) (interface{}, error) {
+	payload, err := EncodePayload(codec, v)
+	if err != nil {
+		return nil, err
+	}
+	return DecodePayload(payload)
+}
+
+// checkInput 检查绑定到输出 key（为空时为整个输出）的输入是否符合结构
+func checkInput(name, key string, schema map[string]interface{}, output interface{}) error {
+	value := output
+	if key != "" {
+		outputs, ok := output.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("input %s is bound to output %s, but the sample is %T", name, key, output)
+		}
+		if value, ok = outputs[key]; !ok {
+			return fmt.Errorf("input %s is bound to output %s, which the sample does not contain", name, key)
+		}
+	}
+	return validateSchema(name, schema, value)
+}
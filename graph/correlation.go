@@ -0,0 +1,67 @@
+package graph
+
+import (
+	"context"
+	"net/http"
+)
+
+// 关联ID在HTTP请求中使用的请求头
+const (
+	HeaderCorrelationID = "X-Correlation-ID"
+	HeaderRunID         = "X-Run-ID"
+)
+
+type runIDKey struct{}
+
+type correlationIDKey struct{}
+
+// ExecuteOption 以函数形式修改执行选项
+type ExecuteOption func(*ExecuteOptions)
+
+// WithRunID 指定本次执行的 run_id，不指定时自动生成
+func WithRunID(runID string) ExecuteOption {
+	return func(o *ExecuteOptions) {
+		o.RunID = runID
+	}
+}
+
+// WithCorrelationID 指定本次执行的关联ID，用于端到端追踪
+func WithCorrelationID(correlationID string) ExecuteOption {
+	return func(o *ExecuteOptions) {
+		o.CorrelationID = correlationID
+	}
+}
+
+// ContextWithCorrelationID 返回携带关联ID的上下文，
+// 通常在入口处（如HTTP handler）从上游请求中提取后写入
+func ContextWithCorrelationID(ctx context.Context, correlationID string) context.Context {
+	return context.WithValue(ctx, correlationIDKey{}, correlationID)
+}
+
+// CorrelationIDFrom 返回上下文中的关联ID，不存在时返回空字符串
+func CorrelationIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(correlationIDKey{}).(string)
+	return id
+}
+
+// RunIDFrom 返回任务上下文中的 run_id，不存在时返回空字符串
+func RunIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(runIDKey{}).(string)
+	return id
+}
+
+// withRunID 将 run_id 写入上下文
+func withRunID(ctx context.Context, runID string) context.Context {
+	return context.WithValue(ctx, runIDKey{}, runID)
+}
+
+// InjectCorrelationHeaders 将上下文中的关联ID和 run_id 写入出站HTTP请求头，
+// 供调用外部服务的任务使用
+func InjectCorrelationHeaders(ctx context.Context, req *http.Request) {
+	if id := CorrelationIDFrom(ctx); id != "" {
+		req.Header.Set(HeaderCorrelationID, id)
+	}
+	if id := RunIDFrom(ctx); id != "" {
+		req.Header.Set(HeaderRunID, id)
+	}
+}
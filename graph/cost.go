@@ -0,0 +1,61 @@
+package graph
+
+import "context"
+
+// ReportCostIn 为当前任务按单位累加成本，如 ReportCostIn(ctx, "usd", 0.12) 或 ReportCostIn(ctx, "credits", 30)，
+// 写入 TaskReport.Costs 并在 ExecutionReport 和 Analyze 中汇总。按单位上报的成本不计入 Budget.MaxCost，
+// unit 为空时同 ReportCost；在任务上下文之外调用时忽略
+func ReportCostIn(ctx context.Context, unit string, amount float64) {
+	if unit == "" {
+		ReportCost(ctx, amount)
+		return
+	}
+	c, ok := ctx.Value(costKey{}).(*taskCost)
+	if !ok || amount == 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.units == nil {
+		c.units = make(map[string]float64)
+	}
+	c.units[unit] += amount
+}
+
+// CostSummary 是一组任务上报的成本之和
+type CostSummary struct {
+	Cost  float64            // 通过 ReportCost 上报的成本之和
+	Costs map[string]float64 // 单位 -> 通过 ReportCostIn 上报的成本之和
+}
+
+// add 累加一个任务或一次运行的成本
+func (s *CostSummary) add(cost float64, costs map[string]float64) {
+	s.Cost += cost
+	for unit, v := range costs {
+		if s.Costs == nil {
+			s.Costs = make(map[string]float64)
+		}
+		s.Costs[unit] += v
+	}
+}
+
+// summarizeCosts 汇总本次执行的任务的成本，tags 为任务ID -> 标签
+func (r *ExecutionReport) summarizeCosts(tags map[string][]string) {
+	var total CostSummary
+	var byTag map[string]CostSummary
+	for id, tr := range r.Tasks {
+		if tr.Reused || (tr.Cost == 0 && len(tr.Costs) == 0) {
+			continue
+		}
+		total.add(tr.Cost, tr.Costs)
+		for _, tag := range tags[id] {
+			if byTag == nil {
+				byTag = make(map[string]CostSummary)
+			}
+			s := byTag[tag]
+			s.add(tr.Cost, tr.Costs)
+			byTag[tag] = s
+		}
+	}
+	r.Cost, r.Costs, r.CostByTag = total.Cost, total.Costs, byTag
+}
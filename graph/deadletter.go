@@ -0,0 +1,243 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// DeadLetter 记录一次失败的运行（任务重试已用尽），保留重新执行所需的全部信息
+type DeadLetter struct {
+	Namespace       string
+	RunID           string
+	Workflow        string
+	WorkflowVersion int
+	Params          map[string]interface{}
+	Results         map[string]interface{} // 失败前已完成任务的结果
+	Sensitive       map[string][]string    // 结果中的敏感字段，见 ExecutionReport.Sensitive
+	Codecs          map[string]string      // 结果的编码，见 ExecutionReport.Codecs
+	Error           string
+	TaskErrors      map[string]string // 失败任务的ID -> 错误信息
+	FailedAt        time.Time
+	Redrives        int    // 该运行由死信重新执行的次数
+	RedriveOf       string // 重新执行时对应的原始 run_id
+}
+
+// DeadLetterStore 是支持死信队列的 Store。
+// Registry 使用的 Store 实现了该接口时，失败的运行会自动进入死信队列
+type DeadLetterStore interface {
+	SaveDeadLetter(ctx context.Context, dl *DeadLetter) error
+	GetDeadLetter(ctx context.Context, namespace, runID string) (*DeadLetter, error)
+	ListDeadLetters(ctx context.Context, namespace string, workflow string) ([]*DeadLetter, error)
+	DeleteDeadLetter(ctx context.Context, namespace, runID string) error
+}
+
+// newDeadLetter 根据失败运行的记录构造死信
+func newDeadLetter(record *RunRecord, origin *DeadLetter) *DeadLetter {
+	dl := &DeadLetter{
+		Namespace:       record.Namespace,
+		RunID:           record.RunID,
+		Workflow:        record.Workflow,
+		WorkflowVersion: record.WorkflowVersion,
+		Params:          record.Params,
+		Error:           record.Error,
+		FailedAt:        record.EndTime,
+	}
+	if origin != nil {
+		dl.Redrives = origin.Redrives + 1
+		dl.RedriveOf = origin.RunID
+		if origin.RedriveOf != "" {
+			dl.RedriveOf = origin.RedriveOf
+		}
+	}
+	if record.Report != nil {
+		dl.Results = record.Report.Results
+		dl.Sensitive = record.Report.Sensitive
+		dl.Codecs = record.Report.Codecs
+		for id, tr := range record.Report.Tasks {
+			if tr.Status == TaskStatusFailed && tr.Error != nil {
+				if dl.TaskErrors == nil {
+					dl.TaskErrors = make(map[string]string)
+				}
+				dl.TaskErrors[id] = tr.Error.Error()
+			}
+		}
+	}
+	return dl
+}
+
+// deadLetterKey 在内存存储中标识一条死信
+type deadLetterKey struct {
+	namespace string
+	runID     string
+}
+
+// SaveDeadLetter 保存死信
+func (s *MemoryStore) SaveDeadLetter(ctx context.Context, dl *DeadLetter) error {
+	if dl.Namespace == "" || dl.RunID == "" {
+		return fmt.Errorf("dead letter requires namespace and run id")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.deadLetters == nil {
+		s.deadLetters = make(map[deadLetterKey]*DeadLetter)
+	}
+	c := *dl
+	s.deadLetters[deadLetterKey{dl.Namespace, dl.RunID}] = &c
+	return nil
+}
+
+// GetDeadLetter 返回命名空间内的死信
+func (s *MemoryStore) GetDeadLetter(ctx context.Context, namespace, runID string) (*DeadLetter, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	dl, ok := s.deadLetters[deadLetterKey{namespace, runID}]
+	if !ok {
+		return nil, fmt.Errorf("dead letter %s not found in namespace %s", runID, namespace)
+	}
+	c := *dl
+	return &c, nil
+}
+
+// ListDeadLetters 按失败时间倒序返回命名空间内的死信，workflow 不为空时只返回该工作流的死信
+func (s *MemoryStore) ListDeadLetters(ctx context.Context, namespace string, workflow string) ([]*DeadLetter, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var dls []*DeadLetter
+	for key, dl := range s.deadLetters {
+		if key.namespace != namespace || (workflow != "" && dl.Workflow != workflow) {
+			continue
+		}
+		c := *dl
+		dls = append(dls, &c)
+	}
+	sort.Slice(dls, func(i, j int) bool {
+		return dls[i].FailedAt.After(dls[j].FailedAt)
+	})
+	return dls, nil
+}
+
+// DeleteDeadLetter 删除死信，死信不存在时返回错误
+func (s *MemoryStore) DeleteDeadLetter(ctx context.Context, namespace, runID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := deadLetterKey{namespace, runID}
+	if _, ok := s.deadLetters[key]; !ok {
+		return fmt.Errorf("dead letter %s not found in namespace %s", runID, namespace)
+	}
+	delete(s.deadLetters, key)
+	return nil
+}
+
+// deadLetters 返回注册表 Store 的死信接口，Store 不支持死信时返回错误
+func (n *NamespaceRegistry) deadLetters() (DeadLetterStore, error) {
+	dls, ok := n.registry.store.(DeadLetterStore)
+	if !ok {
+		return nil, fmt.Errorf("registry store does not support dead letters")
+	}
+	return dls, nil
+}
+
+// DeadLetters 返回命名空间内的死信，workflow 不为空时只返回该工作流的死信
+func (n *NamespaceRegistry) DeadLetters(ctx context.Context, workflow string) ([]*DeadLetter, error) {
+	dls, err := n.deadLetters()
+	if err != nil {
+		return nil, err
+	}
+	return dls.ListDeadLetters(ctx, n.namespace, workflow)
+}
+
+// DeadLetter 返回指定运行的死信
+func (n *NamespaceRegistry) DeadLetter(ctx context.Context, runID string) (*DeadLetter, error) {
+	dls, err := n.deadLetters()
+	if err != nil {
+		return nil, err
+	}
+	return dls.GetDeadLetter(ctx, n.namespace, runID)
+}
+
+// DiscardDeadLetter 删除死信而不重新执行
+func (n *NamespaceRegistry) DiscardDeadLetter(ctx context.Context, runID string) error {
+	dls, err := n.deadLetters()
+	if err != nil {
+		return err
+	}
+	dl, err := dls.GetDeadLetter(ctx, n.namespace, runID)
+	if err != nil {
+		return err
+	}
+	// 审计记录写入成功后才删除死信，审计失败时死信保留
+	if err := n.audit(ctx, AuditEntry{
+		RunID:    runID,
+		Workflow: dl.Workflow,
+		Actor:    ActorFrom(ctx),
+		Action:   AuditDeadLetterDiscarded,
+		Detail:   dl.Error,
+	}); err != nil {
+		return err
+	}
+	return dls.DeleteDeadLetter(ctx, n.namespace, runID)
+}
+
+// Redrive 使用死信记录的工作流版本重新执行一次运行，params 不为空时替换原来的参数。
+// 死信在新运行记录保存之后才被删除，新运行再次失败时会以新的 run_id 重新进入死信队列
+func (n *NamespaceRegistry) Redrive(ctx context.Context, runID string, params map[string]interface{}, opts ExecuteOptions, extra ...ExecuteOption) (*ExecutionReport, error) {
+	dls, err := n.deadLetters()
+	if err != nil {
+		return nil, err
+	}
+	dl, err := dls.GetDeadLetter(ctx, n.namespace, runID)
+	if err != nil {
+		return nil, err
+	}
+	def, err := n.Get(dl.Workflow, dl.WorkflowVersion)
+	if err != nil {
+		return nil, fmt.Errorf("failed to redrive %s: %v", runID, err)
+	}
+
+	opts.Params = dl.Params
+	if params != nil {
+		opts.Params = params
+		// 以修正后的参数重新执行时仍代表原运行的逻辑执行时间
+		if _, ok := params[ParamLogicalTime]; !ok && opts.LogicalTime.IsZero() {
+			if s, ok := dl.Params[ParamLogicalTime].(string); ok {
+				opts.LogicalTime, _ = time.Parse(time.RFC3339, s)
+			}
+		}
+	}
+	return n.run(ctx, def, opts, dl, extra...)
+}
+
+// claimDeadLetter 在重新执行的运行记录保存后删除原死信。删除失败时把运行记录标记为失败，
+// 失败的记录不再进入死信队列，避免同一死信被并发重新执行两次
+func (n *NamespaceRegistry) claimDeadLetter(ctx context.Context, record *RunRecord, origin *DeadLetter) error {
+	dls, err := n.deadLetters()
+	if err != nil {
+		return err
+	}
+	derr := dls.DeleteDeadLetter(ctx, n.namespace, origin.RunID)
+	if derr == nil {
+		return nil
+	}
+	err = fmt.Errorf("failed to redrive %s: %v", origin.RunID, derr)
+	saveCtx := context.WithoutCancel(ctx)
+	record.EndTime = time.Now()
+	record.Status = RunStatusFailed
+	record.Error = err.Error()
+	if serr := n.registry.store.SaveRun(saveCtx, record); serr != nil {
+		err = fmt.Errorf("%w (failed to save run %s: %v)", err, record.RunID, serr)
+	}
+	if aerr := n.audit(saveCtx, AuditEntry{
+		RunID:    record.RunID,
+		Workflow: record.Workflow,
+		Action:   AuditRunStatus,
+		From:     string(RunStatusRunning),
+		To:       string(RunStatusFailed),
+		Detail:   record.Error,
+	}); aerr != nil {
+		err = fmt.Errorf("%w (failed to audit run %s: %v)", err, record.RunID, aerr)
+	}
+	return err
+}
@@ -0,0 +1,64 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+)
+
+// 重新执行在新运行被记录之前失败时死信保留，新运行开始后死信才被删除
+func TestRedriveKeepsDeadLetterUntilRunRecorded(t *testing.T) {
+	var fail atomic.Bool
+	fail.Store(true)
+	tg := NewTaskGraph()
+	if err := tg.AddTask(&Task{ID: "flaky", Execute: func(ctx context.Context, inputs map[string]interface{}) (interface{}, error) {
+		if fail.Load() {
+			return nil, errors.New("boom")
+		}
+		return "ok", nil
+	}}); err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewRegistry(WithStore(NewMemoryStore()))
+	if _, err := r.Register("flaky", tg); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if _, err := r.Start(ctx, "flaky", ExecuteOptions{RunID: "first"}); err == nil {
+		t.Fatal("expected first run to fail")
+	}
+	ns := r.Namespace(DefaultNamespace)
+	if _, err := ns.DeadLetter(ctx, "first"); err != nil {
+		t.Fatal(err)
+	}
+
+	admission, err := NewAdmissionController(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	release, err := admission.Admit(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ns.Redrive(ctx, "first", nil, ExecuteOptions{Admission: admission}); !errors.Is(err, ErrOverloaded) {
+		t.Fatalf("expected redrive to be rejected by admission, got %v", err)
+	}
+	if _, err := ns.DeadLetter(ctx, "first"); err != nil {
+		t.Fatalf("dead letter lost after rejected redrive: %v", err)
+	}
+	release()
+
+	fail.Store(false)
+	report, err := ns.Redrive(ctx, "first", nil, ExecuteOptions{Admission: admission})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Tasks["flaky"].Status != TaskStatusCompleted {
+		t.Fatalf("expected redriven task to complete, got %s", report.Tasks["flaky"].Status)
+	}
+	if _, err := ns.DeadLetter(ctx, "first"); err == nil {
+		t.Fatal("expected dead letter to be deleted after successful redrive")
+	}
+}
@@ -0,0 +1,64 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrDeadlineBudget 表示任务启动时分配到的截止时间预算不足 DeadlineBudget.Reserve
+var ErrDeadlineBudget = errors.New("deadline budget exhausted")
+
+// DeadlineBudget 把运行的截止时间（ctx 的 Deadline）沿关键路径分配给任务。
+// 每个任务的截止时间是运行截止时间减去其下游最长路径的预期耗时（来自 DurationHints），
+// 为后续任务留出时间；任务启动时分到的时间不足 Reserve 时不再启动：
+// 可选任务被跳过，其余任务失败并返回包装了 ErrDeadlineBudget 的错误，
+// 避免后期任务只剩几毫秒时才开始执行。ctx 没有截止时间时不生效
+type DeadlineBudget struct {
+	Reserve time.Duration // 任务启动时至少需要的时间
+}
+
+// taskDeadlines 保存单次执行中每个任务的截止时间
+type taskDeadlines struct {
+	reserve  time.Duration
+	deadline time.Time
+	tail     map[string]time.Duration // 任务下游最长路径的预期耗时，不包括任务本身
+}
+
+// newTaskDeadlines 根据运行截止时间和预期耗时计算任务截止时间，不需要分配时返回 nil
+func (tg *TaskGraph) newTaskDeadlines(ctx context.Context, budget *DeadlineBudget, hints DurationHints) (*taskDeadlines, error) {
+	deadline, ok := ctx.Deadline()
+	if budget == nil || !ok {
+		return nil, nil
+	}
+	order, err := tg.topologicalOrder()
+	if err != nil {
+		return nil, fmt.Errorf("failed to sort tasks: %v", err)
+	}
+	adjacency, err := tg.graph.AdjacencyMap()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get adjacency map: %v", err)
+	}
+
+	tail := make(map[string]time.Duration, len(order))
+	for i := len(order) - 1; i >= 0; i-- {
+		id := order[i]
+		for next := range adjacency[id] {
+			if d := hints[next] + tail[next]; d > tail[id] {
+				tail[id] = d
+			}
+		}
+	}
+	return &taskDeadlines{reserve: budget.Reserve, deadline: deadline, tail: tail}, nil
+}
+
+// admit 返回任务的截止时间；分到的时间不足 Reserve 时返回包装了 ErrDeadlineBudget 的错误
+func (d *taskDeadlines) admit(task *Task) (time.Time, error) {
+	deadline := d.deadline.Add(-d.tail[task.ID])
+	if left := time.Until(deadline); left < d.reserve {
+		return deadline, fmt.Errorf("%w: %v left for task %s, %v reserved for downstream tasks, need %v",
+			ErrDeadlineBudget, left.Round(time.Millisecond), task.ID, d.tail[task.ID], d.reserve)
+	}
+	return deadline, nil
+}
@@ -0,0 +1,232 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrDuplicateRun 表示同一工作流已有相同去重键的运行正在执行或在去重窗口内成功完成
+var ErrDuplicateRun = errors.New("duplicate run")
+
+// DedupePolicy 决定重复的触发如何处理
+type DedupePolicy string
+
+const (
+	// DedupeCoalesce 把重复的触发合并到已有的运行：等待执行中的运行结束，返回它的报告和错误，
+	// 报告的 RunID 是已有运行的 run_id
+	DedupeCoalesce DedupePolicy = "coalesce"
+	// DedupeReject 拒绝重复的触发，返回包装了 ErrDuplicateRun 的 *DuplicateRunError
+	DedupeReject DedupePolicy = "reject"
+)
+
+// Dedupe 按业务键对运行去重，如以订单号为键，重复投递的事件不会使同一订单被处理两次。
+// 同一注册表内的判断是原子的；配置了 Store 时还会查询其中的运行记录，
+// 多个进程共享 Store 时可以发现其他进程的运行，但同时到达的触发仍可能都开始执行
+type Dedupe struct {
+	Key string
+	// Window 是成功完成的运行在结束后继续用于去重的时长，为0时只对执行中的运行去重；
+	// 失败的运行结束后立即不再参与去重，重新触发会开始新的运行
+	Window time.Duration
+	Policy DedupePolicy // 为空时为 DedupeCoalesce
+}
+
+// WithDedupe 以业务键 key 对本次执行去重，见 Dedupe
+func WithDedupe(key string, window time.Duration, policy DedupePolicy) ExecuteOption {
+	return func(o *ExecuteOptions) {
+		o.Dedupe = &Dedupe{Key: key, Window: window, Policy: policy}
+	}
+}
+
+// DuplicateRunError 是 DedupeReject 拒绝触发时返回的错误
+type DuplicateRunError struct {
+	Key    string
+	RunID  string // 已有运行的 run_id
+	Status RunStatus
+}
+
+func (e *DuplicateRunError) Error() string {
+	return fmt.Sprintf("%v: run %s with dedupe key %s is %s", ErrDuplicateRun, e.RunID, e.Key, e.Status)
+}
+
+func (e *DuplicateRunError) Unwrap() error {
+	return ErrDuplicateRun
+}
+
+// dedupeKey 在注册表中标识一个去重键
+type dedupeKey struct {
+	namespace string
+	workflow  string
+	key       string
+}
+
+// dedupeRun 是按去重键登记的运行，done 关闭后 report、err 和 end 可读
+type dedupeRun struct {
+	runID  string
+	done   chan struct{}
+	report *ExecutionReport
+	err    error
+	end    time.Time
+}
+
+// status 返回登记的运行的状态，只用于执行中或成功完成的运行
+func (r *dedupeRun) status() RunStatus {
+	select {
+	case <-r.done:
+		return RunStatusCompleted
+	default:
+		return RunStatusRunning
+	}
+}
+
+// dedupePollInterval 是等待其他进程中执行的重复运行结束时查询 Store 的间隔
+const dedupePollInterval = time.Second
+
+// runDeduped 登记去重键后执行运行；已有重复的运行时按策略合并或拒绝
+func (n *NamespaceRegistry) runDeduped(ctx context.Context, def *WorkflowDefinition, opts ExecuteOptions, origin *DeadLetter) (*ExecutionReport, error) {
+	d := *opts.Dedupe
+	if d.Policy == "" {
+		d.Policy = DedupeCoalesce
+	}
+	if d.Policy != DedupeCoalesce && d.Policy != DedupeReject {
+		return nil, fmt.Errorf("unknown dedupe policy %q", d.Policy)
+	}
+	r := n.registry
+	key := dedupeKey{namespace: n.namespace, workflow: def.Name, key: d.Key}
+
+	// 先在注册表内查找，再查询 Store；查询 Store 期间不持有锁，之后重新检查注册表
+	if existing := n.activeDedupe(key, d.Window); existing != nil {
+		return n.duplicate(ctx, d, existing)
+	}
+	rec, err := n.storedDuplicate(ctx, def.Name, d)
+	if err != nil {
+		return nil, err
+	}
+	if rec != nil {
+		return n.storedDuplicateResult(ctx, d, rec)
+	}
+
+	r.mu.Lock()
+	if existing := n.activeDedupeLocked(key, d.Window); existing != nil {
+		r.mu.Unlock()
+		return n.duplicate(ctx, d, existing)
+	}
+	run := &dedupeRun{runID: opts.RunID, done: make(chan struct{})}
+	r.dedupe[key] = run
+	r.mu.Unlock()
+
+	opts.Dedupe = nil
+	opts.dedupeKey = d.Key
+	run.report, run.err = n.run(ctx, def, opts, origin)
+	run.end = time.Now()
+	close(run.done)
+
+	// 失败的运行立即释放去重键，成功的运行在窗口结束后释放
+	release := func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if r.dedupe[key] == run {
+			delete(r.dedupe, key)
+		}
+	}
+	if run.err != nil || d.Window <= 0 {
+		release()
+	} else {
+		time.AfterFunc(d.Window, release)
+	}
+	return run.report, run.err
+}
+
+// activeDedupe 返回去重键对应的执行中或在 window 内成功完成的运行
+func (n *NamespaceRegistry) activeDedupe(key dedupeKey, window time.Duration) *dedupeRun {
+	n.registry.mu.RLock()
+	defer n.registry.mu.RUnlock()
+	return n.activeDedupeLocked(key, window)
+}
+
+// activeDedupeLocked 同 activeDedupe，需持有注册表的锁
+func (n *NamespaceRegistry) activeDedupeLocked(key dedupeKey, window time.Duration) *dedupeRun {
+	run := n.registry.dedupe[key]
+	if run == nil {
+		return nil
+	}
+	select {
+	case <-run.done:
+		if run.err != nil || time.Since(run.end) >= window {
+			return nil
+		}
+	default:
+	}
+	return run
+}
+
+// duplicate 按策略处理注册表内的重复运行
+func (n *NamespaceRegistry) duplicate(ctx context.Context, d Dedupe, existing *dedupeRun) (*ExecutionReport, error) {
+	if d.Policy == DedupeReject {
+		return nil, &DuplicateRunError{Key: d.Key, RunID: existing.runID, Status: existing.status()}
+	}
+	select {
+	case <-existing.done:
+		return existing.report, existing.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// storedDuplicate 在 Store 中查找去重键对应的执行中或在窗口内成功完成的运行，没有 Store 时返回 nil
+func (n *NamespaceRegistry) storedDuplicate(ctx context.Context, workflow string, d Dedupe) (*RunRecord, error) {
+	if n.registry.store == nil {
+		return nil, nil
+	}
+	records, err := n.registry.store.ListRuns(ctx, n.namespace, RunFilter{Workflow: workflow, DedupeKey: d.Key})
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up runs with dedupe key %s: %v", d.Key, err)
+	}
+	for _, rec := range records {
+		switch {
+		case rec.Status == RunStatusRunning:
+			return rec, nil
+		case rec.Status == RunStatusCompleted && time.Since(rec.EndTime) < d.Window:
+			return rec, nil
+		}
+	}
+	return nil, nil
+}
+
+// storedDuplicateResult 按策略处理 Store 中的重复运行，合并时轮询直到它结束
+func (n *NamespaceRegistry) storedDuplicateResult(ctx context.Context, d Dedupe, rec *RunRecord) (*ExecutionReport, error) {
+	if d.Policy == DedupeReject {
+		return nil, &DuplicateRunError{Key: d.Key, RunID: rec.RunID, Status: rec.Status}
+	}
+	ticker := time.NewTicker(dedupePollInterval)
+	defer ticker.Stop()
+	for rec.Status == RunStatusRunning {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+		var err error
+		if rec, err = n.GetRun(ctx, rec.RunID); err != nil {
+			return nil, err
+		}
+	}
+	if rec.Status == RunStatusFailed {
+		return rec.Report, fmt.Errorf("run %s failed: %s", rec.RunID, rec.Error)
+	}
+	return rec.Report, nil
+}
+
+// FindDuplicate 返回工作流中去重键 key 对应的执行中或在 window 内成功完成的运行，没有时 runID 为空。
+// 用于在异步开始运行之前告知调用方已有的 run_id，开始运行时仍以 ExecuteOptions.Dedupe 为准
+func (n *NamespaceRegistry) FindDuplicate(ctx context.Context, workflow, key string, window time.Duration) (runID string, status RunStatus, err error) {
+	if existing := n.activeDedupe(dedupeKey{namespace: n.namespace, workflow: workflow, key: key}, window); existing != nil {
+		return existing.runID, existing.status(), nil
+	}
+	rec, err := n.storedDuplicate(ctx, workflow, Dedupe{Key: key, Window: window})
+	if err != nil || rec == nil {
+		return "", "", err
+	}
+	return rec.RunID, rec.Status, nil
+}
@@ -0,0 +1,474 @@
+package graph
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefinitionSpec 是声明式的工作流定义，一个文件描述一个工作流
+type DefinitionSpec struct {
+	Namespace string     `json:"namespace,omitempty"` // 为空时使用默认命名空间
+	Name      string     `json:"name"`
+	Tasks     []TaskSpec `json:"tasks"`
+}
+
+// TaskSpec 是工作流定义中的任务，Handler 引用通过 WithHandler 注册的处理函数，
+// Type 引用任务类型插件，两者必须且只能设置一个
+type TaskSpec struct {
+	ID        string                 `json:"id"`
+	Handler   string                 `json:"handler,omitempty"`
+	Type      string                 `json:"type,omitempty"`
+	Depends   []string               `json:"depends,omitempty"`
+	When      *ConditionSpec         `json:"when,omitempty"`
+	Params    map[string]interface{} `json:"params,omitempty"`  // 原样传给处理函数，或作为任务类型的配置
+	Timeout   string                 `json:"timeout,omitempty"` // 如 "2s"
+	Retries   int                    `json:"retries,omitempty"`
+	Tags      []string               `json:"tags,omitempty"`
+	Executor  string                 `json:"executor,omitempty"`
+	Mutex     string                 `json:"mutex,omitempty"` // 互斥组，见 Task.Mutex
+	RateLimit *RateLimit             `json:"rate_limit,omitempty"`
+	Optional  bool                   `json:"optional,omitempty"`
+	Sensitive []string               `json:"sensitive,omitempty"`
+	Version   string                 `json:"version,omitempty"`
+	Codec     string                 `json:"codec,omitempty"`
+	Contract  *Contract              `json:"contract,omitempty"` // 数据契约，见 Task.Contract
+}
+
+// ConditionSpec 是任务的执行条件，Evaluator 为空时使用内置的 compare 求值器
+type ConditionSpec struct {
+	Evaluator string `json:"evaluator,omitempty"`
+	Expr      string `json:"expr"`
+}
+
+// TaskHandler 是定义文件中任务引用的处理函数，params 为任务定义中的 Params
+type TaskHandler func(ctx context.Context, inputs map[string]interface{}, params map[string]interface{}) (interface{}, error)
+
+// DefinitionDecoder 将定义文件的内容解码到 DefinitionSpec，如 yaml.Unmarshal
+type DefinitionDecoder func(data []byte, v interface{}) error
+
+// DefinitionLoader 从定义文件构建任务图并注册到注册表。
+// 定义在完整校验通过后才注册为新版本，执行中的运行继续使用原来的版本
+type DefinitionLoader struct {
+	registry *Registry
+	handlers map[string]TaskHandler
+	// types 和 evaluators 优先于全局注册的任务类型和条件求值器
+	types      map[string]TaskType
+	evaluators map[string]ConditionEvaluator
+	decoders   map[string]DefinitionDecoder // 按文件扩展名
+	logger     *slog.Logger
+}
+
+// DefinitionOption 定义加载器的构造选项
+type DefinitionOption func(*DefinitionLoader)
+
+// WithHandler 注册定义文件中可以引用的处理函数
+func WithHandler(name string, handler TaskHandler) DefinitionOption {
+	return func(l *DefinitionLoader) {
+		l.handlers[name] = handler
+	}
+}
+
+// WithTaskType 设置只对该加载器可用的任务类型，同名时优先于 RegisterTaskType 注册的类型
+func WithTaskType(t TaskType) DefinitionOption {
+	return func(l *DefinitionLoader) {
+		l.types[t.Name()] = t
+	}
+}
+
+// WithConditionEvaluator 设置只对该加载器可用的条件求值器，同名时优先于 RegisterConditionEvaluator 注册的求值器
+func WithConditionEvaluator(e ConditionEvaluator) DefinitionOption {
+	return func(l *DefinitionLoader) {
+		l.evaluators[e.Name()] = e
+	}
+}
+
+// WithDefinitionDecoder 注册文件扩展名（如 ".yaml"）对应的解码器，".json" 已内置
+func WithDefinitionDecoder(ext string, decode DefinitionDecoder) DefinitionOption {
+	return func(l *DefinitionLoader) {
+		l.decoders[strings.ToLower(ext)] = decode
+	}
+}
+
+// WithDefinitionLogger 设置 Watch 报告重新加载结果使用的日志器
+func WithDefinitionLogger(logger *slog.Logger) DefinitionOption {
+	return func(l *DefinitionLoader) {
+		l.logger = logger
+	}
+}
+
+// NewDefinitionLoader 创建向 registry 注册工作流的定义加载器
+func NewDefinitionLoader(registry *Registry, opts ...DefinitionOption) *DefinitionLoader {
+	l := &DefinitionLoader{
+		registry:   registry,
+		handlers:   make(map[string]TaskHandler),
+		types:      make(map[string]TaskType),
+		evaluators: make(map[string]ConditionEvaluator),
+		decoders:   map[string]DefinitionDecoder{".json": json.Unmarshal},
+		logger:     slog.Default(),
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// Build 校验定义并构建任务图，不会注册。任务的 Version 包含整个任务定义的哈希，
+// 因此修改参数、超时等配置也会产生新的工作流版本
+func (l *DefinitionLoader) Build(spec *DefinitionSpec) (*TaskGraph, error) {
+	if spec.Name == "" {
+		return nil, fmt.Errorf("workflow name is required")
+	}
+	if len(spec.Tasks) == 0 {
+		return nil, fmt.Errorf("workflow %s has no tasks", spec.Name)
+	}
+
+	specs := make(map[string]TaskSpec, len(spec.Tasks))
+	for _, ts := range spec.Tasks {
+		if ts.ID == "" {
+			return nil, fmt.Errorf("workflow %s has a task without id", spec.Name)
+		}
+		if _, ok := specs[ts.ID]; ok {
+			return nil, fmt.Errorf("task %s is defined more than once", ts.ID)
+		}
+		if (ts.Handler == "") == (ts.Type == "") {
+			return nil, fmt.Errorf("task %s must set exactly one of handler and type", ts.ID)
+		}
+		if _, ok := l.handlers[ts.Handler]; ts.Handler != "" && !ok {
+			return nil, fmt.Errorf("task %s uses unknown handler %q", ts.ID, ts.Handler)
+		}
+		if _, ok := l.taskType(ts.Type); ts.Type != "" && !ok {
+			return nil, fmt.Errorf("task %s uses unknown task type %q", ts.ID, ts.Type)
+		}
+		if _, ok := LookupCodec(ts.Codec); !ok {
+			return nil, fmt.Errorf("task %s uses unknown codec %q", ts.ID, ts.Codec)
+		}
+		specs[ts.ID] = ts
+	}
+	for _, ts := range spec.Tasks {
+		for _, dep := range ts.Depends {
+			if _, ok := specs[dep]; !ok {
+				return nil, fmt.Errorf("task %s depends on unknown task %s", ts.ID, dep)
+			}
+		}
+	}
+
+	// 依赖必须先于任务加入图中，按定义顺序反复挑选依赖已就绪的任务
+	tg := NewTaskGraph()
+	added := make(map[string]*Task, len(specs))
+	for len(added) < len(specs) {
+		progressed := false
+		for _, ts := range spec.Tasks {
+			if added[ts.ID] != nil || !depsAdded(ts.Depends, added) {
+				continue
+			}
+			task, err := l.buildTask(ts, added)
+			if err != nil {
+				return nil, err
+			}
+			if err := tg.AddTask(task); err != nil {
+				return nil, err
+			}
+			added[ts.ID] = task
+			progressed = true
+		}
+		if !progressed {
+			return nil, fmt.Errorf("workflow %s has a dependency cycle", spec.Name)
+		}
+	}
+	return tg, nil
+}
+
+func depsAdded(deps []string, added map[string]*Task) bool {
+	for _, dep := range deps {
+		if added[dep] == nil {
+			return false
+		}
+	}
+	return true
+}
+
+func (l *DefinitionLoader) buildTask(ts TaskSpec, added map[string]*Task) (*Task, error) {
+	var timeout time.Duration
+	if ts.Timeout != "" {
+		d, err := time.ParseDuration(ts.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timeout for task %s: %v", ts.ID, err)
+		}
+		timeout = d
+	}
+	if timeout < 0 || ts.Retries < 0 {
+		return nil, fmt.Errorf("timeout and retries of task %s must not be negative", ts.ID)
+	}
+	raw, err := json.Marshal(ts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode task %s: %v", ts.ID, err)
+	}
+	sum := sha256.Sum256(raw)
+
+	execute, err := l.execute(ts)
+	if err != nil {
+		return nil, err
+	}
+	task := &Task{
+		ID:        ts.ID,
+		Execute:   execute,
+		Version:   hex.EncodeToString(sum[:8]),
+		Tags:      ts.Tags,
+		Timeout:   timeout,
+		Retries:   ts.Retries,
+		Executor:  ts.Executor,
+		Mutex:     ts.Mutex,
+		RateLimit: ts.RateLimit,
+		Optional:  ts.Optional,
+		Sensitive: ts.Sensitive,
+		Codec:     ts.Codec,
+		Contract:  ts.Contract,
+	}
+	if ts.When != nil {
+		condition, err := l.condition(ts.When)
+		if err != nil {
+			return nil, fmt.Errorf("invalid condition for task %s: %v", ts.ID, err)
+		}
+		task.ConditionWithResults = func(inputs map[string]interface{}, results ResultView) bool {
+			ok, err := condition(inputs, results)
+			return err == nil && ok
+		}
+		task.when = ts.When
+	}
+	if ts.Version != "" {
+		task.Version = ts.Version + "+" + task.Version
+	}
+	for _, dep := range ts.Depends {
+		task.Depends = append(task.Depends, added[dep])
+	}
+	return task, nil
+}
+
+// execute 根据任务定义的处理函数或任务类型创建执行函数
+func (l *DefinitionLoader) execute(ts TaskSpec) (func(ctx context.Context, inputs map[string]interface{}) (interface{}, error), error) {
+	params := ts.Params
+	if ts.Handler != "" {
+		handler := l.handlers[ts.Handler]
+		return func(ctx context.Context, inputs map[string]interface{}) (interface{}, error) {
+			return handler(ctx, inputs, params)
+		}, nil
+	}
+
+	t, _ := l.taskType(ts.Type)
+	if params == nil {
+		params = make(map[string]interface{})
+	}
+	if err := ValidateConfig(t.ConfigSchema(), params); err != nil {
+		return nil, fmt.Errorf("invalid config for task %s of type %s: %v", ts.ID, ts.Type, err)
+	}
+	execute, err := t.New(params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create task %s of type %s: %v", ts.ID, ts.Type, err)
+	}
+	return execute, nil
+}
+
+// condition 用选定的求值器编译条件表达式
+func (l *DefinitionLoader) condition(spec *ConditionSpec) (ConditionFunc, error) {
+	name := spec.Evaluator
+	if name == "" {
+		name = ConditionCompare
+	}
+	e, ok := l.evaluators[name]
+	if !ok {
+		if e, ok = LookupConditionEvaluator(name); !ok {
+			return nil, fmt.Errorf("unknown condition evaluator %q", name)
+		}
+	}
+	return e.Compile(spec.Expr)
+}
+
+func (l *DefinitionLoader) taskType(name string) (TaskType, bool) {
+	if t, ok := l.types[name]; ok {
+		return t, true
+	}
+	return LookupTaskType(name)
+}
+
+// Parse 按扩展名解码定义文件
+func (l *DefinitionLoader) Parse(path string) (*DefinitionSpec, error) {
+	decode, ok := l.decoders[strings.ToLower(filepath.Ext(path))]
+	if !ok {
+		return nil, fmt.Errorf("no decoder for definition file %s", path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read definition: %v", err)
+	}
+	var spec DefinitionSpec
+	if err := decode(data, &spec); err != nil {
+		return nil, fmt.Errorf("failed to parse definition %s: %v", path, err)
+	}
+	return &spec, nil
+}
+
+// LoadFile 解析、校验并注册定义文件；定义未变化时返回已有的最新版本
+func (l *DefinitionLoader) LoadFile(path string, opts ...RegisterOption) (*WorkflowDefinition, error) {
+	spec, err := l.Parse(path)
+	if err != nil {
+		return nil, err
+	}
+	tg, err := l.Build(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid definition %s: %v", path, err)
+	}
+	return l.registry.Namespace(spec.Namespace).Register(spec.Name, tg, opts...)
+}
+
+// LoadDir 加载目录下所有可解码的定义文件（不含子目录），按文件名顺序注册；
+// 任一文件无效或两个文件定义了同一工作流时不注册任何文件
+func (l *DefinitionLoader) LoadDir(dir string, opts ...RegisterOption) ([]*WorkflowDefinition, error) {
+	paths, err := l.definitionFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	type built struct {
+		spec  *DefinitionSpec
+		graph *TaskGraph
+	}
+	all := make([]built, 0, len(paths))
+	owners := make(map[workflowKey]string)
+	for _, path := range paths {
+		spec, err := l.Parse(path)
+		if err != nil {
+			return nil, err
+		}
+		key := l.registry.Namespace(spec.Namespace).key(spec.Name)
+		if owner, ok := owners[key]; ok {
+			return nil, fmt.Errorf("workflow %s is defined in both %s and %s", spec.Name, owner, path)
+		}
+		owners[key] = path
+		tg, err := l.Build(spec)
+		if err != nil {
+			return nil, fmt.Errorf("invalid definition %s: %v", path, err)
+		}
+		all = append(all, built{spec: spec, graph: tg})
+	}
+
+	defs := make([]*WorkflowDefinition, 0, len(all))
+	for _, b := range all {
+		def, err := l.registry.Namespace(b.spec.Namespace).Register(b.spec.Name, b.graph, opts...)
+		if err != nil {
+			return nil, err
+		}
+		defs = append(defs, def)
+	}
+	return defs, nil
+}
+
+// DefinitionFiles 返回 LoadDir 会加载的文件，即目录下有对应解码器的文件（按文件名排序）
+func (l *DefinitionLoader) DefinitionFiles(dir string) ([]string, error) {
+	return l.definitionFiles(dir)
+}
+
+// definitionFiles 返回目录下有对应解码器的文件（按文件名排序）
+func (l *DefinitionLoader) definitionFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read definitions dir: %v", err)
+	}
+	var paths []string
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		if _, ok := l.decoders[strings.ToLower(filepath.Ext(entry.Name()))]; ok {
+			paths = append(paths, filepath.Join(dir, entry.Name()))
+		}
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// definitionFile 是 Watch 记录的文件状态
+type definitionFile struct {
+	modTime time.Time
+	size    int64
+}
+
+// Watch 每隔 interval 检查目录，重新加载新增或修改过的定义文件，直到 ctx 结束。
+// 无效的定义只记录日志，已注册的版本保持不变；删除文件不会注销工作流。
+// 调用前应先用 LoadDir 完成首次加载，Watch 只处理之后发生的变化
+func (l *DefinitionLoader) Watch(ctx context.Context, dir string, interval time.Duration) error {
+	if interval <= 0 {
+		return fmt.Errorf("watch interval must be positive")
+	}
+	seen, err := l.scan(dir)
+	if err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		current, err := l.scan(dir)
+		if err != nil {
+			l.logger.Warn("failed to scan definitions", slog.String("dir", dir), slog.Any("error", err))
+			continue
+		}
+		for _, path := range sortedPaths(current) {
+			if prev, ok := seen[path]; ok && prev == current[path] {
+				continue
+			}
+			def, err := l.LoadFile(path)
+			if err != nil {
+				l.logger.Warn("definition reload rejected", slog.String("file", path), slog.Any("error", err))
+				continue
+			}
+			l.logger.Info("definition reloaded", slog.String("file", path), slog.String("namespace", def.Namespace),
+				slog.String("workflow", def.Name), slog.Int("version", def.Version))
+		}
+		for path := range seen {
+			if _, ok := current[path]; !ok {
+				l.logger.Info("definition file removed, registered versions are kept", slog.String("file", path))
+			}
+		}
+		seen = current
+	}
+}
+
+func (l *DefinitionLoader) scan(dir string) (map[string]definitionFile, error) {
+	paths, err := l.definitionFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]definitionFile, len(paths))
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue // 扫描期间被删除
+		}
+		files[path] = definitionFile{modTime: info.ModTime(), size: info.Size()}
+	}
+	return files, nil
+}
+
+func sortedPaths(files map[string]definitionFile) []string {
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
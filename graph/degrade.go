@@ -0,0 +1,31 @@
+package graph
+
+import (
+	"context"
+	"time"
+)
+
+// 可降级任务的标签
+const (
+	TagOptional   = "optional"
+	TagEnrichment = "enrichment"
+)
+
+// optional 判断任务是否可选：设置了 Optional 或带有 optional/enrichment 标签。
+// 可选任务失败不会导致执行失败，并可在降级时被跳过
+func (t *Task) optional() bool {
+	return t.Optional || t.HasTag(TagOptional) || t.HasTag(TagEnrichment)
+}
+
+// degraded 判断可选任务是否因超出软延迟预算而应跳过
+func (run *runContext) degraded(task *Task) bool {
+	return !run.softDeadline.IsZero() && task.optional() && !time.Now().Before(run.softDeadline)
+}
+
+// withSoftDeadline 为可选任务设置软延迟截止时间，超时后任务按可选任务失败处理
+func (run *runContext) withSoftDeadline(ctx context.Context, task *Task) (context.Context, context.CancelFunc) {
+	if run.softDeadline.IsZero() || !task.optional() {
+		return ctx, func() {}
+	}
+	return context.WithDeadline(ctx, run.softDeadline)
+}
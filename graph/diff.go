@@ -0,0 +1,166 @@
+package graph
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Edge 表示一条依赖边，From 为上游任务，To 为下游任务
+type Edge struct {
+	From string
+	To   string
+}
+
+// TaskChange 表示同一任务在两个任务图之间某项设置的变化
+type TaskChange struct {
+	TaskID string
+	Field  string
+	Old    string
+	New    string
+}
+
+// GraphDiff 表示两个任务图之间的结构差异
+type GraphDiff struct {
+	AddedTasks   []string
+	RemovedTasks []string
+	AddedEdges   []Edge
+	RemovedEdges []Edge
+	ChangedTasks []TaskChange
+}
+
+// Empty 判断两个任务图是否没有差异
+func (d *GraphDiff) Empty() bool {
+	return len(d.AddedTasks) == 0 && len(d.RemovedTasks) == 0 &&
+		len(d.AddedEdges) == 0 && len(d.RemovedEdges) == 0 &&
+		len(d.ChangedTasks) == 0
+}
+
+// String 以适合在CI中审阅的文本格式输出差异
+func (d *GraphDiff) String() string {
+	var b strings.Builder
+	for _, id := range d.AddedTasks {
+		fmt.Fprintf(&b, "+ task %s\n", id)
+	}
+	for _, id := range d.RemovedTasks {
+		fmt.Fprintf(&b, "- task %s\n", id)
+	}
+	for _, e := range d.AddedEdges {
+		fmt.Fprintf(&b, "+ edge %s -> %s\n", e.From, e.To)
+	}
+	for _, e := range d.RemovedEdges {
+		fmt.Fprintf(&b, "- edge %s -> %s\n", e.From, e.To)
+	}
+	for _, c := range d.ChangedTasks {
+		fmt.Fprintf(&b, "~ task %s %s: %q -> %q\n", c.TaskID, c.Field, c.Old, c.New)
+	}
+	return b.String()
+}
+
+// Diff 比较两个任务图，报告从 a 到 b 新增/删除的任务和依赖边，以及任务设置的变化
+func Diff(a, b *TaskGraph) (*GraphDiff, error) {
+	aTasks, aEdges, err := a.snapshot()
+	if err != nil {
+		return nil, err
+	}
+	bTasks, bEdges, err := b.snapshot()
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &GraphDiff{}
+	for id, bTask := range bTasks {
+		aTask, ok := aTasks[id]
+		if !ok {
+			diff.AddedTasks = append(diff.AddedTasks, id)
+			continue
+		}
+		diff.ChangedTasks = append(diff.ChangedTasks, diffSettings(id, aTask, bTask)...)
+	}
+	for id := range aTasks {
+		if _, ok := bTasks[id]; !ok {
+			diff.RemovedTasks = append(diff.RemovedTasks, id)
+		}
+	}
+	for e := range bEdges {
+		if !aEdges[e] {
+			diff.AddedEdges = append(diff.AddedEdges, e)
+		}
+	}
+	for e := range aEdges {
+		if !bEdges[e] {
+			diff.RemovedEdges = append(diff.RemovedEdges, e)
+		}
+	}
+
+	sort.Strings(diff.AddedTasks)
+	sort.Strings(diff.RemovedTasks)
+	sortEdges(diff.AddedEdges)
+	sortEdges(diff.RemovedEdges)
+	sort.Slice(diff.ChangedTasks, func(i, j int) bool {
+		if diff.ChangedTasks[i].TaskID != diff.ChangedTasks[j].TaskID {
+			return diff.ChangedTasks[i].TaskID < diff.ChangedTasks[j].TaskID
+		}
+		return diff.ChangedTasks[i].Field < diff.ChangedTasks[j].Field
+	})
+	return diff, nil
+}
+
+// snapshot 返回任务图中的全部任务和依赖边
+func (tg *TaskGraph) snapshot() (map[string]*Task, map[Edge]bool, error) {
+	adjacency, err := tg.graph.AdjacencyMap()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get adjacency map: %v", err)
+	}
+
+	tasks := make(map[string]*Task, len(adjacency))
+	edges := make(map[Edge]bool)
+	for id, targets := range adjacency {
+		task, err := tg.graph.Vertex(id)
+		if err != nil {
+			return nil, nil, fmt.Errorf("task %s not found", id)
+		}
+		tasks[id] = task
+		for to := range targets {
+			edges[Edge{From: id, To: to}] = true
+		}
+	}
+	return tasks, edges, nil
+}
+
+// taskSettings 返回参与比较的任务设置
+func taskSettings(task *Task) map[string]string {
+	return map[string]string{
+		"version":   task.Version,
+		"condition": fmt.Sprintf("%t", task.Condition != nil || task.ConditionWithResults != nil),
+		"tags":      strings.Join(task.Tags, ","),
+		"timeout":   task.Timeout.String(),
+		"retries":   fmt.Sprintf("%d", task.Retries),
+		"inputs":    task.inputsString(),
+		"optional":  fmt.Sprintf("%t", task.Optional),
+		"executor":  task.Executor,
+		"verify":    fmt.Sprintf("%t", task.Verify != nil),
+		"precheck":  fmt.Sprintf("%t", task.Precheck != nil),
+	}
+}
+
+// diffSettings 比较同一任务的两个版本的设置
+func diffSettings(id string, a, b *Task) []TaskChange {
+	aSettings, bSettings := taskSettings(a), taskSettings(b)
+	var changes []TaskChange
+	for field, newValue := range bSettings {
+		if oldValue := aSettings[field]; oldValue != newValue {
+			changes = append(changes, TaskChange{TaskID: id, Field: field, Old: oldValue, New: newValue})
+		}
+	}
+	return changes
+}
+
+func sortEdges(edges []Edge) {
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].From != edges[j].From {
+			return edges[i].From < edges[j].From
+		}
+		return edges[i].To < edges[j].To
+	})
+}
@@ -0,0 +1,367 @@
+package graph
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Redacted 是被脱敏字段的替代值
+const Redacted = "[REDACTED]"
+
+// KMS 是密钥管理服务，负责加密和解密每条记录的数据密钥（信封加密）
+type KMS interface {
+	WrapKey(ctx context.Context, key []byte) ([]byte, error)
+	UnwrapKey(ctx context.Context, wrapped []byte) ([]byte, error)
+}
+
+// localKMS 使用本地主密钥加密数据密钥，适用于测试和密钥由部署环境注入的场景
+type localKMS struct {
+	aead cipher.AEAD
+}
+
+// NewLocalKMS 创建使用本地 AES 主密钥（16、24 或 32 字节）的 KMS
+func NewLocalKMS(masterKey []byte) (KMS, error) {
+	aead, err := newAEAD(masterKey)
+	if err != nil {
+		return nil, err
+	}
+	return &localKMS{aead: aead}, nil
+}
+
+func (k *localKMS) WrapKey(ctx context.Context, key []byte) ([]byte, error) {
+	return sealAEAD(k.aead, key, nil)
+}
+
+func (k *localKMS) UnwrapKey(ctx context.Context, wrapped []byte) ([]byte, error) {
+	return openAEAD(k.aead, wrapped, nil)
+}
+
+// RedactionRule 指定持久化前脱敏的结果字段：TaskID 为任务ID，
+// Path 为结果中以点分隔的字段路径，为空时脱敏整个结果。脱敏的值不可恢复
+type RedactionRule struct {
+	TaskID string
+	Path   string
+}
+
+// Envelope 是加密后的数据：数据密钥经 KMS 加密，数据用数据密钥以 AES-GCM 加密，
+// 并以命名空间和 run_id 作为附加数据，密文不能被挪用到其他记录
+type Envelope struct {
+	WrappedKey []byte `json:"wrapped_key"`
+	Ciphertext []byte `json:"ciphertext"`
+	// Format 为 envelopePayloads 时明文是任务ID到 Payload 的映射表，每个结果按其任务的编码编码；
+	// 为0时明文是整个结果映射表的 JSON 编码
+	Format int `json:"format,omitempty"`
+}
+
+// envelopeKey 是加密后结果映射表中唯一的键
+const envelopeKey = "$envelope"
+
+// envelopePayloads 是按任务编码结果的信封格式
+const envelopePayloads = 1
+
+// EncryptedStore 包装 Store，保存前对运行记录和死信中的任务结果脱敏并加密，读取时解密。
+// 被包装的 Store 必须同时实现 DeadLetterStore、AuditStore 和 PrunableStore。
+// 每个任务的结果按 ExecutionReport.Codecs 中的编码（默认 JSON，整数解码为 int64）编码后整体加密；
+// 通过它读取记录的调用方（如 REST 服务）得到的是解密后的结果；Retainer 从被包装的 Store 读取要归档的记录，
+// 归档中的结果保持加密，可以通过 OpenResults 解密
+type EncryptedStore struct {
+	store interface {
+		PrunableStore
+		DeadLetterStore
+		AuditStore
+	}
+	kms   KMS
+	rules map[string][]string // 任务ID -> 脱敏路径
+}
+
+// NewEncryptedStore 创建加密的 Store
+func NewEncryptedStore(store Store, kms KMS, rules ...RedactionRule) (*EncryptedStore, error) {
+	full, ok := store.(interface {
+		PrunableStore
+		DeadLetterStore
+		AuditStore
+	})
+	if !ok {
+		return nil, fmt.Errorf("encrypted store requires a store with dead letter, audit and prune support, got %T", store)
+	}
+	if kms == nil {
+		return nil, fmt.Errorf("kms is required")
+	}
+	s := &EncryptedStore{store: full, kms: kms, rules: make(map[string][]string)}
+	for _, rule := range rules {
+		s.rules[rule.TaskID] = append(s.rules[rule.TaskID], rule.Path)
+	}
+	return s, nil
+}
+
+// SaveRun 实现 Store
+func (s *EncryptedStore) SaveRun(ctx context.Context, record *RunRecord) error {
+	if record.Report == nil {
+		return s.store.SaveRun(ctx, record)
+	}
+	sealed, err := s.sealResults(ctx, record.Namespace, record.RunID, record.Report.Results, record.Report.Codecs)
+	if err != nil {
+		return err
+	}
+	rec, report := *record, *record.Report
+	report.Results = sealed
+	rec.Report = &report
+	return s.store.SaveRun(ctx, &rec)
+}
+
+// GetRun 实现 Store
+func (s *EncryptedStore) GetRun(ctx context.Context, namespace, runID string) (*RunRecord, error) {
+	rec, err := s.store.GetRun(ctx, namespace, runID)
+	if err != nil {
+		return nil, err
+	}
+	return s.openRecord(ctx, rec)
+}
+
+// ListRuns 实现 Store
+func (s *EncryptedStore) ListRuns(ctx context.Context, namespace string, filter RunFilter) ([]*RunRecord, error) {
+	records, err := s.store.ListRuns(ctx, namespace, filter)
+	if err != nil {
+		return nil, err
+	}
+	for i, rec := range records {
+		if records[i], err = s.openRecord(ctx, rec); err != nil {
+			return nil, err
+		}
+	}
+	return records, nil
+}
+
+func (s *EncryptedStore) openRecord(ctx context.Context, rec *RunRecord) (*RunRecord, error) {
+	if rec.Report == nil {
+		return rec, nil
+	}
+	results, err := s.openResults(ctx, rec.Namespace, rec.RunID, rec.Report.Results)
+	if err != nil {
+		return nil, err
+	}
+	report := *rec.Report
+	report.Results = results
+	rec.Report = &report
+	return rec, nil
+}
+
+// SaveDeadLetter 实现 DeadLetterStore
+func (s *EncryptedStore) SaveDeadLetter(ctx context.Context, dl *DeadLetter) error {
+	sealed, err := s.sealResults(ctx, dl.Namespace, dl.RunID, dl.Results, dl.Codecs)
+	if err != nil {
+		return err
+	}
+	c := *dl
+	c.Results = sealed
+	return s.store.SaveDeadLetter(ctx, &c)
+}
+
+// GetDeadLetter 实现 DeadLetterStore
+func (s *EncryptedStore) GetDeadLetter(ctx context.Context, namespace, runID string) (*DeadLetter, error) {
+	dl, err := s.store.GetDeadLetter(ctx, namespace, runID)
+	if err != nil {
+		return nil, err
+	}
+	return s.openDeadLetter(ctx, dl)
+}
+
+// ListDeadLetters 实现 DeadLetterStore
+func (s *EncryptedStore) ListDeadLetters(ctx context.Context, namespace string, workflow string) ([]*DeadLetter, error) {
+	dls, err := s.store.ListDeadLetters(ctx, namespace, workflow)
+	if err != nil {
+		return nil, err
+	}
+	for i, dl := range dls {
+		if dls[i], err = s.openDeadLetter(ctx, dl); err != nil {
+			return nil, err
+		}
+	}
+	return dls, nil
+}
+
+func (s *EncryptedStore) openDeadLetter(ctx context.Context, dl *DeadLetter) (*DeadLetter, error) {
+	results, err := s.openResults(ctx, dl.Namespace, dl.RunID, dl.Results)
+	if err != nil {
+		return nil, err
+	}
+	c := *dl
+	c.Results = results
+	return &c, nil
+}
+
+// DeleteDeadLetter 实现 DeadLetterStore
+func (s *EncryptedStore) DeleteDeadLetter(ctx context.Context, namespace, runID string) error {
+	return s.store.DeleteDeadLetter(ctx, namespace, runID)
+}
+
+// AppendAudit 实现 AuditStore，审计记录不包含任务结果，不加密
+func (s *EncryptedStore) AppendAudit(ctx context.Context, entry *AuditEntry) error {
+	return s.store.AppendAudit(ctx, entry)
+}
+
+// ListAudit 实现 AuditStore
+func (s *EncryptedStore) ListAudit(ctx context.Context, namespace string, filter AuditFilter) ([]*AuditEntry, error) {
+	return s.store.ListAudit(ctx, namespace, filter)
+}
+
+// Namespaces 实现 PrunableStore
+func (s *EncryptedStore) Namespaces(ctx context.Context) ([]string, error) {
+	return s.store.Namespaces(ctx)
+}
+
+// DeleteRuns 实现 PrunableStore
+func (s *EncryptedStore) DeleteRuns(ctx context.Context, namespace string, runIDs []string) error {
+	return s.store.DeleteRuns(ctx, namespace, runIDs)
+}
+
+// sealResults 按规则脱敏后以 codecs 中各任务的编码编码结果，再加密整个结果映射表，结果为空时不加密
+func (s *EncryptedStore) sealResults(ctx context.Context, namespace, runID string, results map[string]interface{}, codecs map[string]string) (map[string]interface{}, error) {
+	if len(results) == 0 {
+		return results, nil
+	}
+	payloads := make(map[string]Payload, len(results))
+	for taskID, v := range s.redact(results) {
+		p, err := EncodePayload(codecs[taskID], v)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode result of task %s in run %s: %v", taskID, runID, err)
+		}
+		payloads[taskID] = p
+	}
+	plaintext, err := json.Marshal(payloads)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode results of run %s: %v", runID, err)
+	}
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate data key: %v", err)
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	ciphertext, err := sealAEAD(aead, plaintext, []byte(namespace+"/"+runID))
+	if err != nil {
+		return nil, err
+	}
+	wrapped, err := s.kms.WrapKey(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to wrap data key for run %s: %v", runID, err)
+	}
+	return map[string]interface{}{envelopeKey: &Envelope{WrappedKey: wrapped, Ciphertext: ciphertext, Format: envelopePayloads}}, nil
+}
+
+// OpenResults 解密从归档中读出的运行结果，namespace 和 runID 为归档行中运行的命名空间和 run_id；
+// 未加密的结果原样返回
+func (s *EncryptedStore) OpenResults(ctx context.Context, namespace, runID string, results map[string]interface{}) (map[string]interface{}, error) {
+	return s.openResults(ctx, namespace, runID, results)
+}
+
+// openResults 解密 sealResults 的结果，未加密的结果原样返回
+func (s *EncryptedStore) openResults(ctx context.Context, namespace, runID string, results map[string]interface{}) (map[string]interface{}, error) {
+	raw, ok := results[envelopeKey]
+	if !ok || len(results) != 1 {
+		return results, nil
+	}
+	env, ok := raw.(*Envelope)
+	if !ok {
+		// 经 JSON 持久化的 Store 读回的是普通映射表
+		data, err := json.Marshal(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid envelope in run %s: %v", runID, err)
+		}
+		env = &Envelope{}
+		if err := json.Unmarshal(data, env); err != nil {
+			return nil, fmt.Errorf("invalid envelope in run %s: %v", runID, err)
+		}
+	}
+
+	key, err := s.kms.UnwrapKey(ctx, env.WrappedKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key for run %s: %v", runID, err)
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := openAEAD(aead, env.Ciphertext, []byte(namespace+"/"+runID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt results of run %s: %v", runID, err)
+	}
+	var opened map[string]interface{}
+	if env.Format != envelopePayloads {
+		if err := json.Unmarshal(plaintext, &opened); err != nil {
+			return nil, fmt.Errorf("failed to decode results of run %s: %v", runID, err)
+		}
+		return opened, nil
+	}
+	var payloads map[string]Payload
+	if err := json.Unmarshal(plaintext, &payloads); err != nil {
+		return nil, fmt.Errorf("failed to decode results of run %s: %v", runID, err)
+	}
+	opened = make(map[string]interface{}, len(payloads))
+	for taskID, p := range payloads {
+		if opened[taskID], err = DecodePayload(p); err != nil {
+			return nil, fmt.Errorf("failed to decode result of task %s in run %s: %v", taskID, runID, err)
+		}
+	}
+	return opened, nil
+}
+
+// redact 返回按规则脱敏后的结果副本，不修改原结果
+func (s *EncryptedStore) redact(results map[string]interface{}) map[string]interface{} {
+	return RedactResults(results, s.rules)
+}
+
+// redactPath 将 value 中 path 对应的字段替换为 Redacted，沿途的映射表会被复制
+func redactPath(value interface{}, path string) interface{} {
+	if path == "" {
+		return Redacted
+	}
+	m, ok := value.(map[string]interface{})
+	if !ok {
+		return value
+	}
+	key, rest, _ := strings.Cut(path, ".")
+	child, ok := m[key]
+	if !ok {
+		return value
+	}
+	c := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	c[key] = redactPath(child, rest)
+	return c
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key: %v", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// sealAEAD 加密并把随机 nonce 放在密文之前
+func sealAEAD(aead cipher.AEAD, plaintext, additional []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %v", err)
+	}
+	return aead.Seal(nonce, nonce, plaintext, additional), nil
+}
+
+func openAEAD(aead cipher.AEAD, ciphertext, additional []byte) ([]byte, error) {
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+	nonce, data := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+	return aead.Open(nil, nonce, data, additional)
+}
@@ -0,0 +1,280 @@
+package graph
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// 事件日志中的事件类型
+const (
+	EventRunStarted     = "run.started"
+	EventTaskTransition = "task.transition"
+	EventTaskAttempt    = "task.attempt"
+	EventRunFinished    = "run.finished"
+)
+
+// TaskEvent 是事件日志中的一行，记录运行的开始和结束、任务的状态变化和每次尝试；
+// 不包含任务结果。字段与 proto 中的 workflow.v1.TaskEvent 对应
+type TaskEvent struct {
+	Seq           int64      `json:"seq"` // 同一 EventLog 内单调递增的序号
+	Time          time.Time  `json:"time"`
+	Type          string     `json:"type"`
+	RunID         string     `json:"run_id"`
+	CorrelationID string     `json:"correlation_id,omitempty"`
+	TaskID        string     `json:"task_id,omitempty"`
+	From          TaskStatus `json:"from,omitempty"`
+	To            TaskStatus `json:"to,omitempty"` // 任务或运行（completed/failed）的新状态
+	// 以下字段描述任务报告或尝试在事件发生时的状态
+	Attempt    int           `json:"attempt,omitempty"`
+	StartTime  *time.Time    `json:"start_time,omitempty"`
+	EndTime    *time.Time    `json:"end_time,omitempty"`
+	QueueWait  time.Duration `json:"queue_wait,omitempty"`
+	Duration   time.Duration `json:"duration,omitempty"`
+	Worker     string        `json:"worker,omitempty"`
+	SkipReason string        `json:"skip_reason,omitempty"`
+	Reused     bool          `json:"reused,omitempty"`
+	Error      string        `json:"error,omitempty"`
+	// Fingerprint 是运行结束事件中任务图的 Fingerprint
+	Fingerprint string `json:"fingerprint,omitempty"`
+}
+
+// EventLog 把任务事件以 JSON Lines 格式写入 io.Writer，可同时供多个运行使用。
+// 写入失败时记录一次警告并丢弃之后的事件，不影响运行
+type EventLog struct {
+	mu     sync.Mutex
+	w      *bufio.Writer
+	closer io.Closer // 为空时 Close 只刷新缓冲
+	seq    int64
+	err    error
+	logger *slog.Logger
+}
+
+// NewEventLog 创建写入 w 的事件日志，logger 为空时使用 slog.Default()
+func NewEventLog(w io.Writer, logger *slog.Logger) *EventLog {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &EventLog{w: bufio.NewWriter(w), logger: logger}
+}
+
+// OpenEventLog 以追加方式打开 path 作为事件日志，Close 时关闭文件
+func OpenEventLog(path string, logger *slog.Logger) (*EventLog, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open event log %s: %v", path, err)
+	}
+	l := NewEventLog(f, logger)
+	l.closer = f
+	return l, nil
+}
+
+// append 分配序号并写入一行事件，运行结束时刷新缓冲以便日志管道及时读取
+func (l *EventLog) append(e TaskEvent) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.err != nil {
+		return
+	}
+	l.seq++
+	e.Seq = l.seq
+	data, err := json.Marshal(e)
+	if err == nil {
+		data = append(data, '\n')
+		_, err = l.w.Write(data)
+	}
+	if err == nil && (e.Type == EventRunFinished || e.Type == EventRunStarted) {
+		err = l.w.Flush()
+	}
+	if err != nil {
+		l.err = err
+		l.logger.Warn("failed to write event log, dropping further events", slog.Any("error", err))
+	}
+}
+
+// Close 刷新缓冲的事件，由 OpenEventLog 打开时同时关闭文件
+func (l *EventLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	err := l.err
+	if err == nil {
+		err = l.w.Flush()
+	}
+	if l.closer != nil {
+		if cerr := l.closer.Close(); err == nil {
+			err = cerr
+		}
+		l.closer = nil
+	}
+	return err
+}
+
+// optionalTime 在 t 为零值时返回 nil，使事件中省略未发生的时间
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// eventTime 返回事件中的时间，未设置时为零值
+func eventTime(t *time.Time) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return *t
+}
+
+// taskEvent 根据任务报告创建事件
+func taskEvent(runID, correlationID, typ string, tr *TaskReport) TaskEvent {
+	e := TaskEvent{
+		Time:          time.Now(),
+		Type:          typ,
+		RunID:         runID,
+		CorrelationID: correlationID,
+		TaskID:        tr.ID,
+		To:            tr.Status,
+		Attempt:       tr.Attempts,
+		StartTime:     optionalTime(tr.StartTime),
+		EndTime:       optionalTime(tr.EndTime),
+		QueueWait:     tr.QueueWait,
+		Duration:      tr.Duration,
+		SkipReason:    tr.SkipReason,
+		Reused:        tr.Reused,
+	}
+	if tr.Error != nil {
+		e.Error = tr.Error.Error()
+	}
+	return e
+}
+
+// attach 把运行的事件写入日志：在 hooks 之后追加状态变化和尝试的事件，并写入运行开始事件
+func (l *EventLog) attach(run *runContext) {
+	l.append(TaskEvent{Time: run.report.report.StartTime, Type: EventRunStarted, RunID: run.runID, CorrelationID: run.correlationID})
+	onTransition := run.report.onTransition
+	run.report.onTransition = func(tr TaskReport, from TaskStatus) {
+		if onTransition != nil {
+			onTransition(tr, from)
+		}
+		e := taskEvent(run.runID, run.correlationID, EventTaskTransition, &tr)
+		e.From = from
+		l.append(e)
+	}
+	onAttempt := run.report.onAttempt
+	run.report.onAttempt = func(taskID string, attempt TaskAttempt) {
+		if onAttempt != nil {
+			onAttempt(taskID, attempt)
+		}
+		e := TaskEvent{
+			Time:          time.Now(),
+			Type:          EventTaskAttempt,
+			RunID:         run.runID,
+			CorrelationID: run.correlationID,
+			TaskID:        taskID,
+			Attempt:       attempt.Attempt,
+			StartTime:     optionalTime(attempt.StartTime),
+			EndTime:       optionalTime(attempt.EndTime),
+			Duration:      attempt.Duration,
+			Worker:        attempt.Worker,
+		}
+		if attempt.Error != nil {
+			e.Error = attempt.Error.Error()
+		}
+		l.append(e)
+	}
+	run.report.onFinish = func(report *ExecutionReport) {
+		e := TaskEvent{
+			Time:          report.EndTime,
+			Type:          EventRunFinished,
+			RunID:         run.runID,
+			CorrelationID: run.correlationID,
+			To:            TaskStatusCompleted,
+			StartTime:     optionalTime(report.StartTime),
+			EndTime:       optionalTime(report.EndTime),
+			Duration:      report.Duration,
+			Fingerprint:   report.Fingerprint,
+		}
+		if report.Error != nil {
+			e.To = TaskStatusFailed
+			e.Error = report.Error.Error()
+		}
+		l.append(e)
+	}
+}
+
+// ReadEvents 读取 EventLog 写入的 JSON Lines 事件
+func ReadEvents(r io.Reader) ([]TaskEvent, error) {
+	var events []TaskEvent
+	dec := json.NewDecoder(r)
+	for {
+		var e TaskEvent
+		if err := dec.Decode(&e); err != nil {
+			if err == io.EOF {
+				return events, nil
+			}
+			return events, fmt.Errorf("failed to read event %d: %v", len(events)+1, err)
+		}
+		events = append(events, e)
+	}
+}
+
+// ReplayReport 根据事件重建运行 runID 的执行报告，不包含任务结果；
+// 任务和运行的错误还原为只含消息的错误。运行没有结束事件时报告的 EndTime 为零值
+func ReplayReport(events []TaskEvent, runID string) (*ExecutionReport, error) {
+	var report *ExecutionReport
+	task := func(id string) *TaskReport {
+		tr, ok := report.Tasks[id]
+		if !ok {
+			tr = &TaskReport{ID: id, Status: TaskStatusPending}
+			report.Tasks[id] = tr
+		}
+		return tr
+	}
+	for _, e := range events {
+		if e.RunID != runID {
+			continue
+		}
+		if report == nil {
+			if e.Type != EventRunStarted {
+				return nil, fmt.Errorf("event log of run %s does not start with %s", runID, EventRunStarted)
+			}
+			report = &ExecutionReport{RunID: runID, CorrelationID: e.CorrelationID, StartTime: e.Time, Tasks: make(map[string]*TaskReport)}
+			continue
+		}
+		switch e.Type {
+		case EventTaskTransition:
+			tr := task(e.TaskID)
+			tr.Status = e.To
+			tr.StartTime, tr.EndTime = eventTime(e.StartTime), eventTime(e.EndTime)
+			tr.QueueWait, tr.Duration = e.QueueWait, e.Duration
+			tr.Attempts, tr.SkipReason, tr.Reused = e.Attempt, e.SkipReason, e.Reused
+			tr.Error = nil
+			if e.Error != "" {
+				tr.Error = errors.New(e.Error)
+			}
+		case EventTaskAttempt:
+			tr := task(e.TaskID)
+			a := TaskAttempt{Attempt: e.Attempt, StartTime: eventTime(e.StartTime), EndTime: eventTime(e.EndTime), Duration: e.Duration, Worker: e.Worker}
+			if e.Error != "" {
+				a.Error = errors.New(e.Error)
+			}
+			tr.History = append(tr.History, a)
+		case EventRunFinished:
+			report.EndTime, report.Duration = eventTime(e.EndTime), e.Duration
+			report.Fingerprint = e.Fingerprint
+			if e.Error != "" {
+				report.Error = errors.New(e.Error)
+			}
+		}
+	}
+	if report == nil {
+		return nil, fmt.Errorf("run %s not found in event log", runID)
+	}
+	return report, nil
+}
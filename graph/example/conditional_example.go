@@ -4,10 +4,12 @@ import (
 	"context"
 	"fmt"
 
+	"workflow/engine"
 	"workflow/graph"
 )
 
-func RunConditionalExample() {
+// RunConditionalExample 注册并通过引擎执行条件任务示例
+func RunConditionalExample(ctx context.Context, e *engine.Engine) {
 	taskGraph := graph.NewTaskGraph()
 
 	// 任务1：获取用户信息
@@ -19,7 +21,6 @@ func RunConditionalExample() {
 				"vip_level": 2, // VIP等级
 			}, nil
 		},
-		Status: graph.TaskStatusPending,
 	}
 
 	// 任务2：获取用户订单
@@ -36,7 +37,6 @@ func RunConditionalExample() {
 				{"order_id": 2, "user_id": userID, "amount": 200},
 			}, nil
 		},
-		Status: graph.TaskStatusPending,
 	}
 
 	// 任务3：获取VIP特权信息（只有VIP等级大于1才执行）
@@ -55,7 +55,6 @@ func RunConditionalExample() {
 			vipLevel := userData["vip_level"].(int)
 			return vipLevel > 1
 		},
-		Status: graph.TaskStatusPending,
 	}
 
 	// 任务4：计算订单优惠（依赖于订单信息和VIP特权信息）
@@ -83,7 +82,6 @@ func RunConditionalExample() {
 			_, hasPrivileges := inputs["get_vip_privileges"]
 			return hasPrivileges
 		},
-		Status: graph.TaskStatusPending,
 	}
 
 	// 添加所有任务到图中
@@ -112,16 +110,23 @@ func RunConditionalExample() {
 	}
 	fmt.Printf("Execution Order: %v\n", order)
 
-	// 执行任务图
-	ctx := context.Background()
+	// 通过引擎执行任务图
+	if _, err := e.Registry().Register("conditional_example", taskGraph); err != nil {
+		fmt.Printf("Failed to register workflow: %v\n", err)
+		return
+	}
 	opts := graph.ExecuteOptions{
 		WorkerCount: 3,
 	}
-	result, err := taskGraph.Execute(ctx, opts)
+	report, err := e.Start(ctx, graph.DefaultNamespace, "conditional_example", opts)
 	if err != nil {
 		fmt.Printf("Execution failed: %v\n", err)
 		return
 	}
 
-	fmt.Printf("Final result: %+v\n", result)
+	// 任务状态按运行记录在报告中
+	for _, taskID := range order {
+		fmt.Printf("Task %s: %s\n", taskID, report.Tasks[taskID].Status)
+	}
+	fmt.Printf("Final result: %+v\n", report.Results)
 }
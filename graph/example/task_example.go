@@ -5,10 +5,12 @@ import (
 	"fmt"
 	"time"
 
+	"workflow/engine"
 	"workflow/graph"
 )
 
-func RunTaskExample() {
+// RunTaskExample 注册并通过引擎执行用户信息汇总示例
+func RunTaskExample(ctx context.Context, e *engine.Engine) {
 	// 创建任务图
 	taskGraph := graph.NewTaskGraph()
 
@@ -16,14 +18,14 @@ func RunTaskExample() {
 	getUserTask := &graph.Task{
 		ID: "get_user",
 		Execute: func(ctx context.Context, inputs map[string]interface{}) (interface{}, error) {
-			// 模拟RPC调用获取用户信息
+			// 模拟RPC调用获取用户信息，日志自动携带 run_id/task_id/attempt
+			graph.LoggerFrom(ctx).Info("fetching user info")
 			time.Sleep(100 * time.Millisecond)
 			return map[string]interface{}{
 				"user_id": 123,
 				"name":    "John Doe",
 			}, nil
 		},
-		Status: graph.TaskStatusPending,
 	}
 
 	// 任务2：获取用户订单
@@ -42,7 +44,6 @@ func RunTaskExample() {
 				{"order_id": 2, "user_id": userID, "amount": 200},
 			}, nil
 		},
-		Status: graph.TaskStatusPending,
 	}
 
 	// 任务3：获取用户积分
@@ -61,7 +62,6 @@ func RunTaskExample() {
 				"points":  1000,
 			}, nil
 		},
-		Status: graph.TaskStatusPending,
 	}
 
 	// 任务4：汇总用户信息
@@ -77,7 +77,6 @@ func RunTaskExample() {
 				"points":       points["points"],
 			}, nil
 		},
-		Status: graph.TaskStatusPending,
 	}
 
 	// 添加所有任务到图中
@@ -106,17 +105,24 @@ func RunTaskExample() {
 	}
 	fmt.Printf("Execution Order: %v\n", order)
 
-	// 执行任务图
-	ctx := context.Background()
+	// 通过引擎执行任务图，进程关闭时执行中的运行会被排空或保存为检查点
+	if _, err := e.Registry().Register("task_example", taskGraph); err != nil {
+		fmt.Printf("Failed to register workflow: %v\n", err)
+		return
+	}
 	opts := graph.ExecuteOptions{
 		WorkerCount: 3, // 设置工作池大小
 	}
 
-	data, err := taskGraph.Execute(ctx, opts)
+	report, err := e.Start(ctx, graph.DefaultNamespace, "task_example", opts)
 	if err != nil {
 		fmt.Printf("Execution failed: %v\n", err)
 		return
 	}
 
-	fmt.Printf("Final result: %+v\n", data)
+	// 任务状态按运行记录在报告中
+	for _, taskID := range order {
+		fmt.Printf("Task %s: %s\n", taskID, report.Tasks[taskID].Status)
+	}
+	fmt.Printf("Final result: %+v\n", report.Results)
 }
@@ -0,0 +1,173 @@
+package graph
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Explanation 说明任务在一次执行中为什么在那个时间开始，或为什么没有执行
+type Explanation struct {
+	TaskID       string
+	Status       TaskStatus
+	Layer        int
+	ReadyAfter   time.Duration // 就绪时间相对运行开始的偏移，任务未就绪时为0
+	StartAfter   time.Duration // 开始执行时间相对运行开始的偏移，任务未执行时为0
+	QueueWait    time.Duration
+	Dependencies []DependencyState // 直接依赖的状态，按ID排序
+	// LastDependency 是最后结束的直接依赖，即决定任务就绪时间的依赖；没有依赖时为空
+	LastDependency string
+	// BarrierWait 是最后一个依赖结束到任务就绪之间的时间，按层执行时即等待同层其他任务的时间
+	BarrierWait time.Duration
+	Reasons     []string // 按因果顺序排列的说明
+}
+
+// DependencyState 是依赖任务在执行报告中的状态
+type DependencyState struct {
+	ID       string
+	Status   TaskStatus
+	EndAfter time.Duration // 结束时间相对运行开始的偏移，未结束时为0
+}
+
+// String 以多行文本输出说明
+func (e *Explanation) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "task %s (layer %d): %s\n", e.TaskID, e.Layer, e.Status)
+	for _, r := range e.Reasons {
+		fmt.Fprintf(&b, "  - %s\n", r)
+	}
+	return b.String()
+}
+
+// Explain 根据本次执行的报告说明任务的执行时机，见 TaskGraph.Explain
+func (r *Run) Explain(taskID string) (*Explanation, error) {
+	if r.Report == nil {
+		return nil, fmt.Errorf("run has no report to explain")
+	}
+	return r.Graph.Explain(r.Report, taskID)
+}
+
+// Explain 根据执行报告说明任务为什么在那个时间开始或为什么没有执行：所在层和就绪时间、
+// 最后结束的依赖、等待层屏障和名额的时间，以及跳过任务的条件或预算原因
+func (tg *TaskGraph) Explain(report *ExecutionReport, taskID string) (*Explanation, error) {
+	task, err := tg.graph.Vertex(taskID)
+	if err != nil {
+		return nil, fmt.Errorf("task %s not found", taskID)
+	}
+	tr, ok := report.Tasks[taskID]
+	if !ok {
+		return nil, fmt.Errorf("task %s not found in report", taskID)
+	}
+	deps, err := tg.GetDependencies(taskID)
+	if err != nil {
+		return nil, err
+	}
+	offset := func(t time.Time) time.Duration {
+		if t.IsZero() {
+			return 0
+		}
+		return t.Sub(report.StartTime)
+	}
+
+	e := &Explanation{
+		TaskID:     taskID,
+		Status:     tr.Status,
+		Layer:      tg.taskLayers[taskID],
+		ReadyAfter: offset(tr.ReadyTime),
+		StartAfter: offset(tr.StartTime),
+		QueueWait:  tr.QueueWait,
+	}
+	var last time.Time
+	var unfinished []string
+	for _, id := range deps {
+		ds := DependencyState{ID: id, Status: TaskStatusPending}
+		if dr, ok := report.Tasks[id]; ok {
+			ds.Status = dr.Status
+			ds.EndAfter = offset(dr.EndTime)
+			if dr.EndTime.After(last) {
+				last = dr.EndTime
+				e.LastDependency = id
+			}
+		}
+		if !ds.Status.terminal() {
+			unfinished = append(unfinished, id)
+		}
+		e.Dependencies = append(e.Dependencies, ds)
+	}
+	if !tr.ReadyTime.IsZero() && !last.IsZero() && tr.ReadyTime.After(last) {
+		e.BarrierWait = tr.ReadyTime.Sub(last)
+	}
+
+	add := func(format string, args ...interface{}) {
+		e.Reasons = append(e.Reasons, fmt.Sprintf(format, args...))
+	}
+	round := func(d time.Duration) time.Duration { return d.Round(time.Microsecond) }
+
+	if tr.Reused {
+		add("result was reused from the previous run, the task did not execute")
+		return e, nil
+	}
+	if tr.ReadyTime.IsZero() {
+		switch {
+		case len(unfinished) > 0:
+			add("never became ready: dependencies %s did not finish", strings.Join(unfinished, ", "))
+		case report.Error != nil:
+			add("never became ready: the run stopped before the task was scheduled: %v", report.Error)
+		default:
+			add("never became ready")
+		}
+		for _, ds := range e.Dependencies {
+			if ds.Status == TaskStatusFailed {
+				add("dependency %s failed", ds.ID)
+			}
+		}
+		return e, nil
+	}
+
+	if e.LastDependency == "" {
+		add("ready at +%v: the task has no dependencies", round(e.ReadyAfter))
+	} else {
+		add("ready at +%v after its last dependency %s finished at +%v", round(e.ReadyAfter), e.LastDependency, round(offset(last)))
+	}
+	if e.BarrierWait >= time.Millisecond {
+		add("waited %v at the layer barrier for other tasks in layer %d", round(e.BarrierWait), e.Layer-1)
+	}
+	for _, ds := range e.Dependencies {
+		if ds.Status == TaskStatusSkipped || ds.Status == TaskStatusFailed {
+			add("dependency %s was %s, its result is missing from the inputs", ds.ID, ds.Status)
+		}
+	}
+
+	if tr.Status == TaskStatusSkipped {
+		switch tr.SkipReason {
+		case SkipReasonCondition:
+			if task.Condition != nil || task.ConditionWithResults != nil {
+				add("skipped: its condition evaluated to false")
+			} else {
+				add("skipped by condition")
+			}
+		case SkipReasonBudget:
+			add("skipped: the run budget was exhausted")
+		case SkipReasonDegraded:
+			add("skipped: the soft latency budget was exceeded and the task is optional")
+		case SkipReasonDeadline:
+			add("skipped: not enough of the deadline budget was left after waiting for a worker")
+		default:
+			add("skipped: %s", tr.SkipReason)
+		}
+		return e, nil
+	}
+
+	if tr.StartTime.IsZero() {
+		add("became ready but never started: the run ended while it was waiting for a worker slot")
+		return e, nil
+	}
+	if tr.QueueWait >= time.Millisecond {
+		add("waited %v for executor and global worker slots", round(tr.QueueWait))
+	}
+	add("started at +%v and ran for %v over %d attempt(s)", round(e.StartAfter), round(tr.Duration), tr.Attempts)
+	if tr.Error != nil {
+		add("failed: %v", tr.Error)
+	}
+	return e, nil
+}
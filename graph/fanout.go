@@ -0,0 +1,213 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// ErrFanOutThreshold 表示扇出中失败项的占比超过了 FanOutPolicy 允许的范围
+var ErrFanOutThreshold = errors.New("fan-out failure threshold exceeded")
+
+// FanOutPolicy 定义扇出的失败处理策略
+type FanOutPolicy struct {
+	// MaxFailureRatio 是允许失败的项目占比（0~1），失败占比超过该值时整个扇出任务失败，
+	// 否则失败项的错误汇总到 FanOutResult 中；为0时任意一项失败都会使扇出任务失败
+	MaxFailureRatio float64
+}
+
+// exceeded 判断失败数是否超出策略允许的范围
+func (p FanOutPolicy) exceeded(failures, total int) bool {
+	if failures == 0 {
+		return false
+	}
+	return float64(failures) > p.MaxFailureRatio*float64(total)
+}
+
+// FanOut 描述在运行时按输入动态展开的一组同构子任务
+type FanOut struct {
+	// Items 根据任务输入生成需要处理的项目
+	Items func(ctx context.Context, inputs map[string]interface{}) ([]interface{}, error)
+	// Each 处理单个项目
+	Each func(ctx context.Context, item interface{}) (interface{}, error)
+	// Concurrency 是同时处理的项目数，为0时所有项目同时处理
+	Concurrency int
+	Policy      FanOutPolicy
+}
+
+// ItemError 记录扇出中单个项目的失败
+type ItemError struct {
+	Index int
+	Item  interface{}
+	Err   error
+}
+
+func (e *ItemError) Error() string {
+	return fmt.Sprintf("item %d: %v", e.Index, e.Err)
+}
+
+func (e *ItemError) Unwrap() error {
+	return e.Err
+}
+
+// FanOutResult 是扇出任务的结果
+type FanOutResult struct {
+	Total   int
+	Results []interface{} // 按项目顺序排列，失败项为 nil
+	Errors  []ItemError   // 按项目顺序排列的失败项
+}
+
+// Failed 判断指定下标的项目是否失败
+func (r *FanOutResult) Failed(index int) bool {
+	for _, e := range r.Errors {
+		if e.Index == index {
+			return true
+		}
+	}
+	return false
+}
+
+// NewFanOutTask 创建执行扇出的任务：每次执行时调用 Items 展开项目，并发调用 Each，
+// 结果为 *FanOutResult。失败占比超过 Policy 时任务失败，报告中该任务的 Error 包装了 ErrFanOutThreshold；
+// 失败数和总数会通过 Annotate 写入报告的 fanout_failed 和 fanout_total 属性
+func NewFanOutTask(id string, fo FanOut) *Task {
+	return &Task{
+		ID: id,
+		Execute: func(ctx context.Context, inputs map[string]interface{}) (interface{}, error) {
+			result, err := fo.run(ctx, inputs)
+			if err != nil {
+				return nil, err
+			}
+			return result, nil
+		},
+	}
+}
+
+// run 展开并处理所有项目
+func (fo FanOut) run(ctx context.Context, inputs map[string]interface{}) (*FanOutResult, error) {
+	items, err := fo.Items(ctx, inputs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to expand items: %v", err)
+	}
+
+	result := &FanOutResult{Total: len(items), Results: make([]interface{}, len(items))}
+	errs := make([]error, len(items))
+	concurrency := fo.Concurrency
+	if concurrency <= 0 || concurrency > len(items) {
+		concurrency = len(items)
+	}
+
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				if err := ctx.Err(); err != nil {
+					errs[i] = err
+					continue
+				}
+				result.Results[i], errs[i] = fo.Each(ctx, items[i])
+			}
+		}()
+	}
+	for i := range items {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			result.Results[i] = nil
+			result.Errors = append(result.Errors, ItemError{Index: i, Item: items[i], Err: err})
+		}
+	}
+	Annotate(ctx, "fanout_total", result.Total)
+	Annotate(ctx, "fanout_failed", len(result.Errors))
+
+	if err := ctx.Err(); err != nil {
+		return result, err
+	}
+	if fo.Policy.exceeded(len(result.Errors), result.Total) {
+		return result, fmt.Errorf("%w: %d of %d items failed, first: %v",
+			ErrFanOutThreshold, len(result.Errors), result.Total, &result.Errors[0])
+	}
+	return result, nil
+}
+
+// FanInItem 是汇总时的一个成功项目
+type FanInItem struct {
+	Source string // 产生该项目的输入名称（依赖任务ID或命名输入）
+	Index  int    // 项目在上游扇出中的下标
+	Value  interface{}
+}
+
+// FanInError 是汇总时的一个失败项目
+type FanInError struct {
+	Source string
+	ItemError
+}
+
+// FanIn 汇总一个汇聚任务所有上游扇出的成功结果和失败项目
+type FanIn struct {
+	Total   int
+	Results []FanInItem  // 按输入名称和项目下标排序
+	Errors  []FanInError // 按输入名称和项目下标排序
+}
+
+// GatherFanOut 从汇聚任务的 inputs 中收集所有 *FanOutResult 类型的输入，
+// 让汇聚任务同时拿到成功结果和逐项错误，从而在结果中报告失败项而不是让整个执行失败。
+// 非扇出结果的输入会被忽略
+func GatherFanOut(inputs map[string]interface{}) *FanIn {
+	sources := make([]string, 0, len(inputs))
+	for name := range inputs {
+		sources = append(sources, name)
+	}
+	sort.Strings(sources)
+
+	fi := &FanIn{}
+	for _, source := range sources {
+		r, ok := inputs[source].(*FanOutResult)
+		if !ok || r == nil {
+			continue
+		}
+		fi.Total += r.Total
+		failed := make(map[int]bool, len(r.Errors))
+		for _, e := range r.Errors {
+			failed[e.Index] = true
+			fi.Errors = append(fi.Errors, FanInError{Source: source, ItemError: e})
+		}
+		for i, v := range r.Results {
+			if !failed[i] {
+				fi.Results = append(fi.Results, FanInItem{Source: source, Index: i, Value: v})
+			}
+		}
+	}
+	return fi
+}
+
+// Values 返回所有成功项目的结果
+func (f *FanIn) Values() []interface{} {
+	values := make([]interface{}, len(f.Results))
+	for i, item := range f.Results {
+		values[i] = item.Value
+	}
+	return values
+}
+
+// Err 将所有失败项目合并为一个错误，没有失败项时返回 nil
+func (f *FanIn) Err() error {
+	if len(f.Errors) == 0 {
+		return nil
+	}
+	errs := make([]error, len(f.Errors))
+	for i := range f.Errors {
+		e := &f.Errors[i]
+		errs[i] = fmt.Errorf("%s: %w", e.Source, &e.ItemError)
+	}
+	return errors.Join(errs...)
+}
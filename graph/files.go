@@ -0,0 +1,413 @@
+package graph
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 文件任务类型的名称
+const (
+	TaskTypeFileRead  = "file_read"
+	TaskTypeFileWrite = "file_write"
+	TaskTypeFileGlob  = "file_glob"
+)
+
+// maxFileRead 是 file_read 默认读取的上限
+const maxFileRead = 32 << 20
+
+// FileTaskTypes 返回读写文件、读写记录文件和按 glob 列出文件的任务类型，通过 WithTaskType 或 RegisterTaskType 注册。
+// root 不为空时所有路径都相对于 root 解析，解析后不在 root 之内的路径会被拒绝（按路径字面检查，不解析符号链接）；
+// 为空时路径相对于当前目录。这些类型不会默认注册，以免定义文件在未经允许时读写任意文件。配置：
+//
+//	file_read     {"path": "in/{{.inputs.name}}.json", "format": "text|json|lines", "max_bytes": 1048576}
+//	file_write    {"path": "out/{{.run_id}}.json", "content": "{{json .inputs}}", "append": false, "mode": "0644"}
+//	file_glob     {"pattern": "in/**/*.csv"}
+//	record_read   {"path": "in/users.csv", "format": "csv", "options": {"delimiter": ";"}, "limit": 100000}
+//	record_write  {"path": "out/users.jsonl", "input": "transform"}
+//
+// path、content 和 pattern 都是 PayloadTemplate；file_write 的 content 为空时写入任务输入的 JSON，
+// 不追加时先写入临时文件再重命名，读取方不会看到写了一半的文件。
+// record_read 和 record_write 的 format 为空时按扩展名推断，见 OpenRecords 和 RecordFormat
+func FileTaskTypes(root string) []TaskType {
+	return []TaskType{
+		fileReadTaskType{root: root}, fileWriteTaskType{root: root}, fileGlobTaskType{root: root},
+		recordReadTaskType{root: root}, recordWriteTaskType{root: root},
+	}
+}
+
+// resolvePath 把 p 解析到 root 之内
+func resolvePath(root, p string) (string, error) {
+	if root == "" {
+		return filepath.Clean(p), nil
+	}
+	full := filepath.Join(root, p)
+	rel, err := filepath.Rel(root, full)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("path %s is outside of %s", p, root)
+	}
+	return full, nil
+}
+
+// renderPath 渲染路径模板并解析到 root 之内
+func renderPath(ctx context.Context, root string, t *PayloadTemplate, inputs map[string]interface{}) (string, error) {
+	p, err := t.Render(ctx, inputs)
+	if err != nil {
+		return "", err
+	}
+	if len(p) == 0 {
+		return "", fmt.Errorf("path template %q rendered an empty path", t.String())
+	}
+	return resolvePath(root, string(p))
+}
+
+// Glob 返回匹配 pattern 的文件（不含目录），按路径排序。除 filepath.Match 的语法外，
+// 单独一段 "**" 匹配零或多级目录，如 "logs/**/*.gz"
+func Glob(pattern string) ([]string, error) {
+	var matches []string
+	if !strings.Contains(pattern, "**") {
+		paths, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid glob pattern %q: %v", pattern, err)
+		}
+		for _, p := range paths {
+			if info, err := os.Stat(p); err == nil && !info.IsDir() {
+				matches = append(matches, p)
+			}
+		}
+		return matches, nil
+	}
+
+	// 从第一段含通配符的目录之前开始遍历
+	segments := strings.Split(filepath.ToSlash(filepath.Clean(pattern)), "/")
+	static := 0
+	for static < len(segments)-1 && !strings.ContainsAny(segments[static], "*?[\\") {
+		static++
+	}
+	base := strings.Join(segments[:static], "/")
+	if base == "" {
+		base = "."
+		if strings.HasPrefix(pattern, "/") {
+			base = "/"
+		}
+	}
+	rest := segments[static:]
+	for _, seg := range rest {
+		if _, err := filepath.Match(seg, ""); err != nil {
+			return nil, fmt.Errorf("invalid glob pattern %q: %v", pattern, err)
+		}
+	}
+	err := filepath.WalkDir(filepath.FromSlash(base), func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if p == filepath.FromSlash(base) && os.IsNotExist(err) {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(filepath.FromSlash(base), p)
+		if err != nil {
+			return err
+		}
+		if matchSegments(rest, strings.Split(filepath.ToSlash(rel), "/")) {
+			matches = append(matches, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk %s: %v", base, err)
+	}
+	sort.Strings(matches)
+	return matches, nil
+}
+
+// matchSegments 逐段匹配路径，"**" 匹配零或多段
+func matchSegments(pattern, path []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(path); i++ {
+				if matchSegments(pattern[1:], path[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(path) == 0 {
+			return false
+		}
+		if ok, _ := filepath.Match(pattern[0], path[0]); !ok {
+			return false
+		}
+		pattern, path = pattern[1:], path[1:]
+	}
+	return len(path) == 0
+}
+
+// GlobItems 返回 FanOut 的 Items：每次执行时列出 root 下匹配 pattern 的文件，每个文件路径（string）是一个项目，
+// 用于"处理目录下的每个文件"：
+//
+//	NewFanOutTask("load", FanOut{Items: GlobItems("/data", "incoming/*.csv"), Each: loadFile, Concurrency: 4})
+func GlobItems(root, pattern string) func(ctx context.Context, inputs map[string]interface{}) ([]interface{}, error) {
+	return func(ctx context.Context, inputs map[string]interface{}) ([]interface{}, error) {
+		full, err := resolvePath(root, pattern)
+		if err != nil {
+			return nil, err
+		}
+		paths, err := Glob(full)
+		if err != nil {
+			return nil, err
+		}
+		items := make([]interface{}, len(paths))
+		for i, p := range paths {
+			items[i] = p
+		}
+		return items, nil
+	}
+}
+
+type fileReadTaskType struct {
+	root string
+}
+
+func (fileReadTaskType) Name() string {
+	return TaskTypeFileRead
+}
+
+func (fileReadTaskType) ConfigSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type":     "object",
+		"required": []string{"path"},
+		"properties": map[string]interface{}{
+			"path":      map[string]interface{}{"type": "string"},
+			"format":    map[string]interface{}{"type": "string"},
+			"max_bytes": map[string]interface{}{"type": "integer"},
+		},
+		"additionalProperties": false,
+	}
+}
+
+func (t fileReadTaskType) New(config map[string]interface{}) (func(ctx context.Context, inputs map[string]interface{}) (interface{}, error), error) {
+	path, err := ParsePayloadTemplate(config["path"].(string))
+	if err != nil {
+		return nil, fmt.Errorf("invalid path template: %v", err)
+	}
+	format, _ := config["format"].(string)
+	switch format {
+	case "":
+		format = "text"
+	case "text", "json", "lines":
+	default:
+		return nil, fmt.Errorf("unknown file format %q", format)
+	}
+	limit := int64(maxFileRead)
+	if n, ok := toFloat(config["max_bytes"]); ok && n > 0 {
+		limit = int64(n)
+	}
+	return func(ctx context.Context, inputs map[string]interface{}) (interface{}, error) {
+		p, err := renderPath(ctx, t.root, path, inputs)
+		if err != nil {
+			return nil, err
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		data, err := io.ReadAll(io.LimitReader(f, limit+1))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %v", p, err)
+		}
+		if int64(len(data)) > limit {
+			return nil, fmt.Errorf("file %s exceeds %d bytes", p, limit)
+		}
+		switch format {
+		case "json":
+			var v interface{}
+			if err := json.Unmarshal(data, &v); err != nil {
+				return nil, fmt.Errorf("failed to decode %s: %v", p, err)
+			}
+			return v, nil
+		case "lines":
+			lines := []interface{}{}
+			sc := bufio.NewScanner(bytes.NewReader(data))
+			sc.Buffer(nil, len(data)+1)
+			for sc.Scan() {
+				lines = append(lines, sc.Text())
+			}
+			return lines, sc.Err()
+		}
+		return string(data), nil
+	}, nil
+}
+
+type fileWriteTaskType struct {
+	root string
+}
+
+func (fileWriteTaskType) Name() string {
+	return TaskTypeFileWrite
+}
+
+func (fileWriteTaskType) ConfigSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type":     "object",
+		"required": []string{"path"},
+		"properties": map[string]interface{}{
+			"path":    map[string]interface{}{"type": "string"},
+			"content": map[string]interface{}{"type": "string"},
+			"append":  map[string]interface{}{"type": "boolean"},
+			"mode":    map[string]interface{}{"type": "string"},
+		},
+		"additionalProperties": false,
+	}
+}
+
+func (t fileWriteTaskType) New(config map[string]interface{}) (func(ctx context.Context, inputs map[string]interface{}) (interface{}, error), error) {
+	path, err := ParsePayloadTemplate(config["path"].(string))
+	if err != nil {
+		return nil, fmt.Errorf("invalid path template: %v", err)
+	}
+	var content *PayloadTemplate
+	if text, ok := config["content"].(string); ok {
+		if content, err = ParsePayloadTemplate(text); err != nil {
+			return nil, err
+		}
+	}
+	appendMode, _ := config["append"].(bool)
+	mode := os.FileMode(0o644)
+	if s, ok := config["mode"].(string); ok {
+		m, err := strconv.ParseUint(s, 8, 32)
+		if err != nil || m > 0o777 {
+			return nil, fmt.Errorf("invalid file mode %q", s)
+		}
+		mode = os.FileMode(m)
+	}
+	return func(ctx context.Context, inputs map[string]interface{}) (interface{}, error) {
+		p, err := renderPath(ctx, t.root, path, inputs)
+		if err != nil {
+			return nil, err
+		}
+		data, err := RenderPayload(ctx, content, inputs)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create directory for %s: %v", p, err)
+		}
+		if appendMode {
+			err = appendFile(p, data, mode)
+		} else {
+			err = writeFileAtomic(p, data, mode)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"path": p, "bytes": len(data)}, nil
+	}, nil
+}
+
+func appendFile(p string, data []byte, mode os.FileMode) error {
+	f, err := os.OpenFile(p, os.O_WRONLY|os.O_APPEND|os.O_CREATE, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write %s: %v", p, err)
+	}
+	return f.Close()
+}
+
+// writeFileAtomic 写入同目录下的临时文件后重命名
+func writeFileAtomic(p string, data []byte, mode os.FileMode) error {
+	f, err := os.CreateTemp(filepath.Dir(p), "."+filepath.Base(p)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file for %s: %v", p, err)
+	}
+	tmp := f.Name()
+	defer os.Remove(tmp)
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write %s: %v", p, err)
+	}
+	if err := f.Chmod(mode); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to set mode of %s: %v", p, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %v", p, err)
+	}
+	if err := os.Rename(tmp, p); err != nil {
+		return fmt.Errorf("failed to write %s: %v", p, err)
+	}
+	return nil
+}
+
+type fileGlobTaskType struct {
+	root string
+}
+
+func (fileGlobTaskType) Name() string {
+	return TaskTypeFileGlob
+}
+
+func (fileGlobTaskType) ConfigSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type":     "object",
+		"required": []string{"pattern"},
+		"properties": map[string]interface{}{
+			"pattern": map[string]interface{}{"type": "string"},
+		},
+		"additionalProperties": false,
+	}
+}
+
+// New 创建列出匹配文件的任务函数，结果为路径列表；下游可以用 ItemsFromInput 对每个文件扇出
+func (t fileGlobTaskType) New(config map[string]interface{}) (func(ctx context.Context, inputs map[string]interface{}) (interface{}, error), error) {
+	pattern, err := ParsePayloadTemplate(config["pattern"].(string))
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern template: %v", err)
+	}
+	return func(ctx context.Context, inputs map[string]interface{}) (interface{}, error) {
+		p, err := renderPath(ctx, t.root, pattern, inputs)
+		if err != nil {
+			return nil, err
+		}
+		paths, err := Glob(p)
+		if err != nil {
+			return nil, err
+		}
+		files := make([]interface{}, len(paths))
+		for i, f := range paths {
+			files[i] = f
+		}
+		return files, nil
+	}, nil
+}
+
+// ItemsFromInput 返回 FanOut 的 Items：把名为 name 的输入（如 file_glob 任务的结果）展开为项目，
+// 使定义文件中列出的文件可以由 Go 中的扇出任务逐个处理
+func ItemsFromInput(name string) func(ctx context.Context, inputs map[string]interface{}) ([]interface{}, error) {
+	return func(ctx context.Context, inputs map[string]interface{}) ([]interface{}, error) {
+		v, ok := inputs[name]
+		if !ok {
+			return nil, fmt.Errorf("input %s not found", name)
+		}
+		items, ok := v.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("input %s is %T, not a list", name, v)
+		}
+		return items, nil
+	}
+}
@@ -0,0 +1,37 @@
+package graph
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+)
+
+// Fingerprint 返回任务图结构的稳定哈希，覆盖任务ID、任务版本和依赖边，
+// 与任务的添加顺序无关；可随执行结果一起保存，用于发现定义已变更的情况
+func (tg *TaskGraph) Fingerprint() (string, error) {
+	adjacency, err := tg.graph.AdjacencyMap()
+	if err != nil {
+		return "", fmt.Errorf("failed to get adjacency map: %v", err)
+	}
+
+	ids := make([]string, 0, len(adjacency))
+	for id := range adjacency {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	h := sha256.New()
+	for _, id := range ids {
+		task, err := tg.graph.Vertex(id)
+		if err != nil {
+			return "", fmt.Errorf("task %s not found", id)
+		}
+		fmt.Fprintf(h, "task\x00%s\x00%s\n", id, task.Version)
+		for _, dep := range sortedKeys(adjacency[id]) {
+			fmt.Fprintf(h, "edge\x00%s\x00%s\n", id, dep)
+		}
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,123 @@
+package graph
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// GitSource 从 git 仓库的分支同步工作流定义文件并通过 DefinitionLoader 注册，
+// 注册的版本以提交 SHA 作为 Revision，随每次运行记录下来。需要 PATH 中有 git 命令
+type GitSource struct {
+	Repo   string // 仓库地址，任何 git clone 支持的形式
+	Branch string // 为空时使用 main
+	Path   string // 定义文件在仓库中的目录，为空时为仓库根目录
+	Dir    string // 本地工作副本目录，为空时在首次同步时创建临时目录
+
+	loader *DefinitionLoader
+	mu     sync.Mutex
+	synced string // 最近一次成功注册的提交
+}
+
+// NewGitSource 创建通过 loader 注册定义的 git 定义源
+func NewGitSource(loader *DefinitionLoader, repo, branch, path string) *GitSource {
+	return &GitSource{Repo: repo, Branch: branch, Path: path, loader: loader}
+}
+
+// Revision 返回最近一次成功同步的提交 SHA
+func (s *GitSource) Revision() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.synced
+}
+
+// Sync 拉取分支的最新提交并加载其中的定义文件。提交未变化时不做任何事；
+// 任一定义无效时整个提交都不注册，下次同步时重试
+func (s *GitSource) Sync(ctx context.Context) ([]*WorkflowDefinition, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	branch := s.Branch
+	if branch == "" {
+		branch = "main"
+	}
+	if s.Dir == "" {
+		dir, err := os.MkdirTemp("", "workflow-definitions-")
+		if err != nil {
+			return nil, fmt.Errorf("failed to create checkout dir: %v", err)
+		}
+		s.Dir = dir
+	}
+
+	if _, err := os.Stat(filepath.Join(s.Dir, ".git")); err != nil {
+		if _, err := s.git(ctx, "", "clone", "--depth", "1", "--single-branch", "--branch", branch, s.Repo, s.Dir); err != nil {
+			return nil, err
+		}
+	} else {
+		if _, err := s.git(ctx, s.Dir, "fetch", "--depth", "1", "origin", branch); err != nil {
+			return nil, err
+		}
+		if _, err := s.git(ctx, s.Dir, "reset", "--hard", "FETCH_HEAD"); err != nil {
+			return nil, err
+		}
+	}
+	revision, err := s.git(ctx, s.Dir, "rev-parse", "HEAD")
+	if err != nil {
+		return nil, err
+	}
+	if revision == s.synced {
+		return nil, nil
+	}
+
+	defs, err := s.loader.LoadDir(filepath.Join(s.Dir, s.Path), WithRevision(revision))
+	if err != nil {
+		return nil, fmt.Errorf("definitions at %s@%s: %v", s.Repo, revision, err)
+	}
+	s.synced = revision
+	return defs, nil
+}
+
+// Run 按 interval 周期性同步，直到 ctx 结束；同步失败只记录日志，已注册的版本保持不变
+func (s *GitSource) Run(ctx context.Context, interval time.Duration) error {
+	if interval <= 0 {
+		return fmt.Errorf("sync interval must be positive")
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		defs, err := s.Sync(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				s.loader.logger.Warn("definition sync failed", slog.String("repo", s.Repo), slog.Any("error", err))
+			}
+			continue
+		}
+		if defs != nil {
+			s.loader.logger.Info("definitions synced", slog.String("repo", s.Repo), slog.String("revision", s.Revision()), slog.Int("workflows", len(defs)))
+		}
+	}
+}
+
+// git 执行 git 命令并返回去掉首尾空白的标准输出
+func (s *GitSource) git(ctx context.Context, dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("git %s failed: %v: %s", args[0], err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return string(bytes.TrimSpace(stdout.Bytes())), nil
+}
@@ -0,0 +1,75 @@
+package graph
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// DurationHints 是任务的预期耗时（任务ID -> 耗时），通常由执行历史统计得出。
+// 执行时设置 ExecuteOptions.DurationHints 后，就绪任务中预期耗时最长的优先调度
+type DurationHints map[string]time.Duration
+
+// HintsFromAnalytics 以执行历史中各任务耗时的中位数作为预期耗时
+func HintsFromAnalytics(a *WorkflowAnalytics) DurationHints {
+	hints := make(DurationHints, len(a.Tasks))
+	for _, t := range a.Tasks {
+		hints[t.TaskID] = t.P50
+	}
+	return hints
+}
+
+// order 按预期耗时排序任务ID，longestFirst 为 false 时按升序排列；
+// 耗时相同或没有记录的任务按ID排序，保证调度顺序稳定
+func (h DurationHints) order(ids []string, longestFirst bool) {
+	sort.SliceStable(ids, func(i, j int) bool {
+		di, dj := h[ids[i]], h[ids[j]]
+		if di != dj {
+			return (di > dj) == longestFirst
+		}
+		return ids[i] < ids[j]
+	})
+}
+
+// Estimate 根据预期耗时估算任务图在并发不受限时的总耗时，即关键路径的长度，
+// 同时返回关键路径上的任务ID（按执行顺序）；没有预期耗时的任务按0计算
+func (tg *TaskGraph) Estimate(hints DurationHints) (time.Duration, []string, error) {
+	order, err := tg.topologicalOrder()
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to sort tasks: %v", err)
+	}
+	predecessors, err := tg.graph.PredecessorMap()
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to get predecessors: %v", err)
+	}
+
+	// finish[id] 是任务最早的结束时间，via[id] 是决定该时间的前驱任务
+	finish := make(map[string]time.Duration, len(order))
+	via := make(map[string]string, len(order))
+	var last string
+	for _, id := range order {
+		var start time.Duration
+		for _, dep := range sortedKeys(predecessors[id]) {
+			if via[id] == "" || finish[dep] > start {
+				start = finish[dep]
+				via[id] = dep
+			}
+		}
+		finish[id] = start + hints[id]
+		if last == "" || finish[id] > finish[last] {
+			last = id
+		}
+	}
+	if last == "" {
+		return 0, nil, nil
+	}
+
+	var path []string
+	for id := last; id != ""; id = via[id] {
+		path = append(path, id)
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return finish[last], path, nil
+}
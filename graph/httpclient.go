@@ -0,0 +1,150 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// HTTPClientOptions 定义 NewHTTPClient 创建的客户端，零值字段使用默认值
+type HTTPClientOptions struct {
+	Timeout      time.Duration     // 单次请求（含重试）的超时，默认30秒；任务上下文的截止时间同样生效
+	MaxRetries   int               // 幂等请求在网络错误、429 和 5xx 时的重试次数，默认2次；为负数时不重试
+	RetryBackoff time.Duration     // 第一次重试前的等待时间，之后每次翻倍，默认200毫秒
+	UserAgent    string            // 为空时为 "workflow-engine"
+	Transport    http.RoundTripper // 底层传输，为空时使用 http.DefaultTransport
+	// RateLimit 设置时每次请求（包括重试）前等待取得该配额的令牌，见 WaitRate
+	RateLimit *RateLimit
+}
+
+// NewHTTPClient 创建供任务调用 REST 接口的客户端：带超时、对幂等请求自动重试（遵守 Retry-After），
+// 在请求头中传递关联ID和 run_id，并用任务日志器记录每次请求。请求需使用任务的上下文创建，
+// 如 http.NewRequestWithContext(ctx, ...)
+func NewHTTPClient(opts HTTPClientOptions) *http.Client {
+	if opts.Timeout <= 0 {
+		opts.Timeout = 30 * time.Second
+	}
+	if opts.MaxRetries == 0 {
+		opts.MaxRetries = 2
+	}
+	if opts.RetryBackoff <= 0 {
+		opts.RetryBackoff = 200 * time.Millisecond
+	}
+	if opts.UserAgent == "" {
+		opts.UserAgent = "workflow-engine"
+	}
+	if opts.Transport == nil {
+		opts.Transport = http.DefaultTransport
+	}
+	return &http.Client{
+		Timeout:   opts.Timeout,
+		Transport: &taskTransport{opts: opts},
+	}
+}
+
+var defaultHTTPClient = NewHTTPClient(HTTPClientOptions{})
+
+// HTTPClient 返回任务上下文中注册的 *http.Client 服务，没有注册时返回按默认选项创建的共享客户端
+func HTTPClient(ctx context.Context) *http.Client {
+	if client, ok := Lookup[*http.Client](ServicesFrom(ctx)); ok {
+		return client
+	}
+	return defaultHTTPClient
+}
+
+// taskTransport 为任务的出站请求添加追踪请求头、日志和重试
+type taskTransport struct {
+	opts HTTPClientOptions
+}
+
+func (t *taskTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	req = req.Clone(ctx)
+	InjectCorrelationHeaders(ctx, req)
+	if req.Header.Get("User-Agent") == "" {
+		req.Header.Set("User-Agent", t.opts.UserAgent)
+	}
+
+	retries := t.opts.MaxRetries
+	if retries < 0 || !idempotent(req) {
+		retries = 0
+	}
+	logger := LoggerFrom(ctx)
+	backoff := t.opts.RetryBackoff
+	for attempt := 1; ; attempt++ {
+		if t.opts.RateLimit != nil {
+			if err := WaitRate(ctx, *t.opts.RateLimit); err != nil {
+				return nil, err
+			}
+		}
+		start := time.Now()
+		resp, err := t.opts.Transport.RoundTrip(req)
+		attrs := []any{slog.String("method", req.Method), slog.String("url", req.URL.Redacted()),
+			slog.Int("attempt", attempt), slog.Duration("duration", time.Since(start))}
+		if err != nil {
+			logger.Debug("http request failed", append(attrs, slog.Any("error", err))...)
+		} else {
+			logger.Debug("http request", append(attrs, slog.Int("status", resp.StatusCode))...)
+		}
+
+		if attempt > retries || !retryable(resp, err) {
+			return resp, err
+		}
+		wait := backoff
+		if resp != nil {
+			if after := retryAfter(resp); after > 0 {
+				wait = after
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		if req.Body != nil && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, fmt.Errorf("failed to rewind request body for retry: %v", err)
+			}
+			req.Body = body
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
+
+// idempotent 判断请求能否安全重试；带不可重放请求体的请求不重试
+func idempotent(req *http.Request) bool {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return req.Header.Get("Idempotency-Key") != ""
+}
+
+func retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+}
+
+// retryAfter 解析以秒为单位的 Retry-After 响应头
+func retryAfter(resp *http.Response) time.Duration {
+	seconds, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || seconds <= 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
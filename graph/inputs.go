@@ -0,0 +1,144 @@
+package graph
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Outputs 表示任务返回的一组命名输出，下游可以通过 From(id).Output(key) 只绑定其中一项
+type Outputs map[string]interface{}
+
+// InputBinding 将任务的一个命名输入绑定到上游任务的输出
+type InputBinding struct {
+	TaskID string
+	Key    string // 上游返回 Outputs 时绑定的输出名称，为空时绑定整个结果
+
+	defaultValue interface{}
+	hasDefault   bool
+}
+
+// From 创建绑定到指定上游任务结果的输入
+func From(taskID string) InputBinding {
+	return InputBinding{TaskID: taskID}
+}
+
+// Output 将输入绑定到上游任务的指定命名输出
+func (b InputBinding) Output(key string) InputBinding {
+	b.Key = key
+	return b
+}
+
+// Default 设置上游任务被跳过、可选任务失败或缺少对应输出时使用的默认值
+func (b InputBinding) Default(value interface{}) InputBinding {
+	b.defaultValue = value
+	b.hasDefault = true
+	return b
+}
+
+// resolve 从结果中取出绑定的值，取不到时使用默认值
+func (b InputBinding) resolve(results resultSource) (interface{}, bool) {
+	if value, ok := b.lookup(results); ok {
+		return value, true
+	}
+	return b.defaultValue, b.hasDefault
+}
+
+// lookup 从结果中取出绑定的值
+func (b InputBinding) lookup(results resultSource) (interface{}, bool) {
+	result, ok := results.get(b.TaskID)
+	if !ok || b.Key == "" {
+		return result, ok
+	}
+	outputs, ok := result.(Outputs)
+	if !ok {
+		return nil, false
+	}
+	value, ok := outputs[b.Key]
+	return value, ok
+}
+
+func (b InputBinding) String() string {
+	if b.Key == "" {
+		return b.TaskID
+	}
+	return b.TaskID + "." + b.Key
+}
+
+// inputsString 以稳定的顺序描述任务的命名输入绑定
+func (t *Task) inputsString() string {
+	names := make([]string, 0, len(t.Inputs))
+	for name := range t.Inputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		parts = append(parts, name+"="+t.Inputs[name].String())
+	}
+	return strings.Join(parts, ",")
+}
+
+// validateInputs 检查命名输入绑定的上游任务都在依赖列表中
+func (t *Task) validateInputs() error {
+	for name, binding := range t.Inputs {
+		if !t.dependsOn(binding.TaskID) {
+			return fmt.Errorf("input %s of task %s is bound to %s, which is not a dependency", name, t.ID, binding.TaskID)
+		}
+	}
+	return nil
+}
+
+// dependsOn 判断任务是否直接依赖指定任务
+func (t *Task) dependsOn(taskID string) bool {
+	for _, dep := range t.Depends {
+		if dep.ID == taskID {
+			return true
+		}
+	}
+	return false
+}
+
+// collectInputs 收集任务的输入：声明了 Inputs 时按名称绑定，否则以依赖任务的ID为键；
+// pool 不为空时从池中取出输入映射表，任务结束后需调用 releaseInputs 归还
+func (t *Task) collectInputs(results resultSource, pool *sync.Pool) map[string]interface{} {
+	var inputs map[string]interface{}
+	if pool != nil {
+		inputs = pool.Get().(map[string]interface{})
+	} else {
+		inputs = make(map[string]interface{}, len(t.Inputs)+len(t.Depends))
+	}
+
+	if len(t.Inputs) > 0 {
+		for name, binding := range t.Inputs {
+			if value, ok := binding.resolve(results); ok {
+				inputs[name] = value
+			}
+		}
+		return inputs
+	}
+
+	for _, dep := range t.Depends {
+		if result, ok := results.get(dep.ID); ok {
+			inputs[dep.ID] = result
+		}
+	}
+	return inputs
+}
+
+// sharedInputPool 在所有执行之间复用输入映射表
+var sharedInputPool = sync.Pool{
+	New: func() interface{} {
+		return make(map[string]interface{}, 8)
+	},
+}
+
+// releaseInputs 清空输入映射表并归还到池中
+func releaseInputs(pool *sync.Pool, inputs map[string]interface{}) {
+	if pool == nil {
+		return
+	}
+	clear(inputs)
+	pool.Put(inputs)
+}
@@ -0,0 +1,127 @@
+package graph
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime/debug"
+	"strconv"
+)
+
+// Isolation 定义任务的执行隔离选项，用于不可信或容易崩溃的任务代码
+type Isolation struct {
+	// Recover 为 true 时在独立的 goroutine 中执行任务并捕获 panic，
+	// panic 会转换为任务错误；上下文结束时不再等待仍未返回的任务
+	Recover bool
+
+	// MemoryLimit 是任务内存使用的提示值（字节）。进程内执行时无法强制限制，
+	// 在子进程执行时通过 GOMEMLIMIT 传递给子进程，在 WASM 中执行时限制模块内存
+	MemoryLimit int64
+
+	// Subprocess 设置后任务在独立的子进程中执行，此时忽略 Task.Execute
+	Subprocess *Subprocess
+
+	// WASM 设置后任务在 WASM 沙箱中执行，此时忽略 Task.Execute；不能与 Subprocess 同时设置
+	WASM *WASMModule
+}
+
+// Subprocess 描述以子进程方式执行的任务：
+// 输入以 JSON 对象写入子进程的标准输入，子进程在标准输出中写出 JSON 格式的结果
+type Subprocess struct {
+	Path string
+	Args []string
+	Env  []string // 追加到当前进程环境变量之后
+	Dir  string
+}
+
+// invoke 按隔离选项执行任务的一次尝试
+func (t *Task) invoke(ctx context.Context, inputs map[string]interface{}) (interface{}, error) {
+	execute := t.Execute
+	if t.TwoPhase != nil {
+		execute = t.TwoPhase.Prepare
+	}
+	iso := t.Isolation
+	if iso == nil {
+		return execute(ctx, inputs)
+	}
+
+	switch {
+	case iso.Subprocess != nil && iso.WASM != nil:
+		return nil, fmt.Errorf("task %s sets both subprocess and wasm isolation", t.ID)
+	case iso.Subprocess != nil:
+		execute = func(ctx context.Context, inputs map[string]interface{}) (interface{}, error) {
+			return iso.Subprocess.run(ctx, inputs, iso.MemoryLimit)
+		}
+	case iso.WASM != nil:
+		execute = func(ctx context.Context, inputs map[string]interface{}) (interface{}, error) {
+			return iso.WASM.run(ctx, inputs, iso.MemoryLimit)
+		}
+	}
+	if iso.Recover {
+		return executeRecovered(ctx, execute, inputs)
+	}
+	return execute(ctx, inputs)
+}
+
+// executeRecovered 在独立的 goroutine 中执行任务并将 panic 转换为错误
+func executeRecovered(ctx context.Context, execute func(context.Context, map[string]interface{}) (interface{}, error), inputs map[string]interface{}) (interface{}, error) {
+	type outcome struct {
+		result interface{}
+		err    error
+	}
+	done := make(chan outcome, 1)
+
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- outcome{err: fmt.Errorf("task panicked: %v\n%s", r, debug.Stack())}
+			}
+		}()
+		result, err := execute(ctx, inputs)
+		done <- outcome{result: result, err: err}
+	}()
+
+	select {
+	case o := <-done:
+		return o.result, o.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// run 启动子进程执行任务
+func (s *Subprocess) run(ctx context.Context, inputs map[string]interface{}, memoryLimit int64) (interface{}, error) {
+	payload, err := json.Marshal(inputs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode inputs: %v", err)
+	}
+
+	cmd := exec.CommandContext(ctx, s.Path, s.Args...)
+	cmd.Dir = s.Dir
+	cmd.Env = append(os.Environ(), s.Env...)
+	if memoryLimit > 0 {
+		cmd.Env = append(cmd.Env, "GOMEMLIMIT="+strconv.FormatInt(memoryLimit, 10))
+	}
+	cmd.Stdin = bytes.NewReader(payload)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err = cmd.Run()
+	usageFrom(ctx).addProcess(cmd.ProcessState)
+	if err != nil {
+		return nil, fmt.Errorf("subprocess %s failed: %v: %s", s.Path, err, bytes.TrimSpace(stderr.Bytes()))
+	}
+
+	if len(bytes.TrimSpace(stdout.Bytes())) == 0 {
+		return nil, nil
+	}
+	var result interface{}
+	if err := json.Unmarshal(stdout.Bytes(), &result); err != nil {
+		return nil, fmt.Errorf("failed to decode subprocess output: %v", err)
+	}
+	return result, nil
+}
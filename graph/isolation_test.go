@@ -0,0 +1,82 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// 可恢复隔离的任务超时后仍可能读取输入，复用输入映射表时它的输入不能被清空或归还到池中给其他任务复用
+func TestRecoveredTaskInputsNotPooled(t *testing.T) {
+	started := make(chan map[string]interface{}, 1)
+	resume := make(chan struct{})
+	seen := make(chan map[string]interface{}, 1)
+	source := &Task{ID: "source", Execute: func(ctx context.Context, inputs map[string]interface{}) (interface{}, error) {
+		return 1, nil
+	}}
+	slow := &Task{
+		ID:        "slow",
+		Depends:   []*Task{source},
+		Optional:  true,
+		Timeout:   5 * time.Millisecond,
+		Isolation: &Isolation{Recover: true},
+		Execute: func(ctx context.Context, inputs map[string]interface{}) (interface{}, error) {
+			started <- inputs
+			// 忽略上下文，在任务被放弃后再读取输入
+			<-resume
+			seen <- map[string]interface{}{"source": inputs["source"], "len": len(inputs)}
+			return nil, nil
+		},
+	}
+	tg := NewTaskGraph()
+	for _, task := range []*Task{source, slow} {
+		if err := tg.AddTask(task); err != nil {
+			t.Fatal(err)
+		}
+	}
+	report, err := tg.ExecuteWithReport(context.Background(), ExecuteOptions{WorkerCount: 2, ReuseInputs: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status := report.Tasks["slow"].Status; status != TaskStatusFailed {
+		t.Fatalf("slow task should be abandoned after its timeout, got %s", status)
+	}
+	abandoned := <-started
+
+	// 放弃的任务仍在运行时，其他执行从池中取出并填充输入映射表
+	other := NewTaskGraph()
+	root := &Task{ID: "root", Execute: func(ctx context.Context, inputs map[string]interface{}) (interface{}, error) {
+		return 1, nil
+	}}
+	if err := other.AddTask(root); err != nil {
+		t.Fatal(err)
+	}
+	reused := make(chan map[string]interface{}, 50*20)
+	for i := 0; i < 50; i++ {
+		task := &Task{ID: fmt.Sprintf("leaf%d", i), Depends: []*Task{root}, Execute: func(ctx context.Context, inputs map[string]interface{}) (interface{}, error) {
+			reused <- inputs
+			return nil, nil
+		}}
+		if err := other.AddTask(task); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 20; i++ {
+		if _, err := other.ExecuteWithReport(context.Background(), ExecuteOptions{WorkerCount: 8, ReuseInputs: true}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(reused)
+	for inputs := range reused {
+		if reflect.ValueOf(inputs).UnsafePointer() == reflect.ValueOf(abandoned).UnsafePointer() {
+			t.Fatal("the abandoned task's input map was handed to another task")
+		}
+	}
+
+	close(resume)
+	if got, want := <-seen, map[string]interface{}{"source": 1, "len": 1}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("the abandoned task's inputs changed after it was abandoned: got %v, want %v", got, want)
+	}
+}
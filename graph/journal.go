@@ -0,0 +1,154 @@
+package graph
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// ErrEffectNotFound 表示副作用还没有记录在日志中
+var ErrEffectNotFound = errors.New("effect not found")
+
+// Effect 是副作用日志中的一条记录：任务在某次运行中已经完成的一个副作用及其结果
+type Effect struct {
+	RunID  string
+	TaskID string
+	Key    string
+	// Result 是副作用函数的返回值，如支付流水号，需要可以编码为 JSON
+	Result interface{}
+	Time   time.Time
+}
+
+// EffectStore 持久化副作用日志。运行记录的 Store 同时实现该接口时，
+// 注册表执行的运行自动使用它，也可以通过 ExecuteOptions.Effects 指定
+type EffectStore interface {
+	SaveEffect(ctx context.Context, effect *Effect) error
+	// GetEffect 在副作用没有记录时返回包装了 ErrEffectNotFound 的错误
+	GetEffect(ctx context.Context, runID, taskID, key string) (*Effect, error)
+}
+
+// effectKey 在内存存储中标识一个副作用
+type effectKey struct {
+	runID  string
+	taskID string
+	key    string
+}
+
+// SaveEffect 实现 EffectStore
+func (s *MemoryStore) SaveEffect(ctx context.Context, effect *Effect) error {
+	if effect.RunID == "" || effect.TaskID == "" || effect.Key == "" {
+		return fmt.Errorf("effect requires run id, task id and key")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.effects == nil {
+		s.effects = make(map[effectKey]*Effect)
+	}
+	e := *effect
+	s.effects[effectKey{effect.RunID, effect.TaskID, effect.Key}] = &e
+	return nil
+}
+
+// GetEffect 实现 EffectStore
+func (s *MemoryStore) GetEffect(ctx context.Context, runID, taskID, key string) (*Effect, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	e, ok := s.effects[effectKey{runID, taskID, key}]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s of task %s in run %s", ErrEffectNotFound, key, taskID, runID)
+	}
+	c := *e
+	return &c, nil
+}
+
+// Journal 是当前任务的副作用日志，通过 JournalFrom 获取。任务在同一运行中被重试或重新执行时
+// （重试、RetryFailed、Engine.Resume），Once 跳过已经完成的副作用，直接返回当时记录的结果
+type Journal struct {
+	store  EffectStore
+	runID  string
+	taskID string
+	// mu 保护 locks 和 skipped，locks 使同一进程内并发调用同一个键的 Once 依次执行
+	mu      sync.Mutex
+	locks   map[string]*sync.Mutex
+	skipped int
+}
+
+type journalKey struct{}
+
+func withJournal(ctx context.Context, j *Journal) context.Context {
+	return context.WithValue(ctx, journalKey{}, j)
+}
+
+// JournalFrom 返回当前任务的副作用日志；执行时没有设置 EffectStore 时返回 nil，
+// nil 的 Journal 上 Once 每次都执行 fn
+func JournalFrom(ctx context.Context) *Journal {
+	j, _ := ctx.Value(journalKey{}).(*Journal)
+	return j
+}
+
+// Once 在 key 对应的副作用没有完成过时执行 fn 并记录其结果，已完成时直接返回记录的结果。
+// key 在任务内唯一，如 "charge" 或 "notify:" + 用户ID。fn 失败时不记录，之后的尝试会再次执行。
+// fn 成功但记录写入失败（或进程在两者之间退出）时副作用可能再执行一次，
+// 因此 fn 调用的外部服务支持幂等键时应传入 IdempotencyKey(key)，使这种情况也只生效一次
+func (j *Journal) Once(ctx context.Context, key string, fn func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	if j == nil {
+		return fn(ctx)
+	}
+	lock := j.lock(key)
+	lock.Lock()
+	defer lock.Unlock()
+
+	effect, err := j.store.GetEffect(ctx, j.runID, j.taskID, key)
+	switch {
+	case err == nil:
+		LoggerFrom(ctx).Info("skipping effect already performed", slog.String("effect", key), slog.Time("performed_at", effect.Time))
+		j.mu.Lock()
+		j.skipped++
+		skipped := j.skipped
+		j.mu.Unlock()
+		Annotate(ctx, "effects_skipped", skipped)
+		return effect.Result, nil
+	case !errors.Is(err, ErrEffectNotFound):
+		return nil, fmt.Errorf("failed to read effect %s of task %s: %v", key, j.taskID, err)
+	}
+
+	result, err := fn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	effect = &Effect{RunID: j.runID, TaskID: j.taskID, Key: key, Result: result, Time: time.Now()}
+	// 副作用已经发生，即使任务被取消也要尽量记录
+	if err := j.store.SaveEffect(context.WithoutCancel(ctx), effect); err != nil {
+		return nil, fmt.Errorf("effect %s of task %s performed but not recorded: %v", key, j.taskID, err)
+	}
+	return result, nil
+}
+
+// IdempotencyKey 返回 key 对应的稳定幂等键，同一运行中同一任务的同一副作用总是相同，
+// 可以作为支付、消息等外部服务的 Idempotency-Key。nil 的 Journal 返回空字符串，表示没有幂等保证
+func (j *Journal) IdempotencyKey(key string) string {
+	if j == nil {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(j.runID + "\x00" + j.taskID + "\x00" + key))
+	return hex.EncodeToString(sum[:16])
+}
+
+func (j *Journal) lock(key string) *sync.Mutex {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.locks == nil {
+		j.locks = make(map[string]*sync.Mutex)
+	}
+	l, ok := j.locks[key]
+	if !ok {
+		l = &sync.Mutex{}
+		j.locks[key] = l
+	}
+	return l
+}
@@ -0,0 +1,91 @@
+package graph
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// TaskLineage 记录任务输出的来源：任务实现的版本、消费的上游输出及其摘要，以及自身输出的摘要。
+// 通过 ExecuteOptions.Lineage 开启
+type TaskLineage struct {
+	Version      string         // 执行时任务的 Version
+	Inputs       []LineageInput // 按上游任务ID和输入名称排序
+	OutputDigest string         // 输出 JSON 编码的 SHA-256 摘要，形如 "sha256:<hex>"
+}
+
+// LineageInput 是任务消费的一个上游输出
+type LineageInput struct {
+	TaskID string // 上游任务ID
+	Name   string // 命名输入的名称，未使用命名输入时为空
+	Key    string // 绑定的上游输出名称，为空时为整个输出
+	Digest string // 上游输出的摘要，上游被跳过或失败（可选任务）时为空
+}
+
+// ProvenanceStep 是追溯结果来源时经过的一个任务
+type ProvenanceStep struct {
+	TaskID  string
+	Lineage TaskLineage
+}
+
+// Provenance 返回产生 taskID 输出的全部任务（包括其自身）及其血缘，按任务ID排序；
+// 与报告的 Fingerprint 一起可以确定结果由哪些输入和代码版本产生
+func (r *ExecutionReport) Provenance(taskID string) ([]ProvenanceStep, error) {
+	if tr, ok := r.Tasks[taskID]; !ok || tr.Lineage == nil {
+		return nil, fmt.Errorf("no lineage recorded for task %s", taskID)
+	}
+	seen := map[string]bool{taskID: true}
+	queue := []string{taskID}
+	var steps []ProvenanceStep
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		tr := r.Tasks[id]
+		if tr == nil || tr.Lineage == nil {
+			continue
+		}
+		steps = append(steps, ProvenanceStep{TaskID: id, Lineage: *tr.Lineage})
+		for _, in := range tr.Lineage.Inputs {
+			if !seen[in.TaskID] {
+				seen[in.TaskID] = true
+				queue = append(queue, in.TaskID)
+			}
+		}
+	}
+	sort.Slice(steps, func(i, j int) bool { return steps[i].TaskID < steps[j].TaskID })
+	return steps, nil
+}
+
+// taskLineage 根据任务的依赖和上游的报告构造血缘，上游的 OutputDigest 在其完成时已记录
+func (run *runContext) taskLineage(task *Task, output interface{}) *TaskLineage {
+	l := &TaskLineage{Version: task.Version, OutputDigest: digestOutput(output)}
+	bound := make(map[string]bool, len(task.Inputs))
+	for name, binding := range task.Inputs {
+		bound[binding.TaskID] = true
+		l.Inputs = append(l.Inputs, LineageInput{TaskID: binding.TaskID, Name: name, Key: binding.Key, Digest: run.report.outputDigest(binding.TaskID)})
+	}
+	for _, dep := range task.Depends {
+		if !bound[dep.ID] && !isSentinel(dep.ID) {
+			l.Inputs = append(l.Inputs, LineageInput{TaskID: dep.ID, Digest: run.report.outputDigest(dep.ID)})
+		}
+	}
+	sort.Slice(l.Inputs, func(i, j int) bool {
+		if l.Inputs[i].TaskID != l.Inputs[j].TaskID {
+			return l.Inputs[i].TaskID < l.Inputs[j].TaskID
+		}
+		return l.Inputs[i].Name < l.Inputs[j].Name
+	})
+	return l
+}
+
+// digestOutput 计算输出的摘要，无法编码为 JSON 的输出按 Go 语法表示计算
+func digestOutput(output interface{}) string {
+	data, err := json.Marshal(output)
+	if err != nil {
+		data = []byte(fmt.Sprintf("%#v", output))
+	}
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
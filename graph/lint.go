@@ -0,0 +1,170 @@
+package graph
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/dominikbraun/graph"
+)
+
+// 内置的检查规则名称
+const (
+	LintRuleIsolatedTask   = "isolated-task"
+	LintRuleUnusedOutput   = "unused-output"
+	LintRuleNetworkTimeout = "network-missing-timeout"
+	LintRuleNetworkRetries = "network-missing-retries"
+	LintRuleExcessiveDepth = "excessive-depth"
+	// LintRuleConditionReference 标记条件（定义文件中的 when）读取了非依赖任务的结果或不存在的输入，
+	// 这样的条件在求值时结果可能尚未产生；只检查内置 compare 求值器的条件
+	LintRuleConditionReference = "condition-non-dependency"
+)
+
+// TagNetwork 标记会发起网络调用的任务
+const TagNetwork = "network"
+
+// LintOptions 定义检查选项
+type LintOptions struct {
+	MaxDepth int      // 允许的最大层数，为0时默认为10
+	Outputs  []string // 作为最终输出的任务，设置后没有下游且不在其中的任务会被标记
+}
+
+// LintIssue 表示检查发现的一个问题
+type LintIssue struct {
+	Rule    string
+	TaskID  string
+	Message string
+}
+
+func (i LintIssue) String() string {
+	if i.TaskID == "" {
+		return fmt.Sprintf("[%s] %s", i.Rule, i.Message)
+	}
+	return fmt.Sprintf("[%s] %s: %s", i.Rule, i.TaskID, i.Message)
+}
+
+// Lint 检查任务图中的常见问题：孤立的任务、无人使用的输出、缺少超时或重试的网络任务、
+// 层数过深以及条件引用了非依赖任务，结果按任务ID排序
+func (tg *TaskGraph) Lint(opts LintOptions) ([]LintIssue, error) {
+	if opts.MaxDepth <= 0 {
+		opts.MaxDepth = 10
+	}
+
+	tasks, _, err := tg.snapshot()
+	if err != nil {
+		return nil, err
+	}
+	predecessors, err := tg.graph.PredecessorMap()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get predecessors: %v", err)
+	}
+	adjacency, err := tg.graph.AdjacencyMap()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get adjacency map: %v", err)
+	}
+
+	outputs := make(map[string]bool, len(opts.Outputs))
+	for _, id := range opts.Outputs {
+		outputs[id] = true
+	}
+
+	var issues []LintIssue
+	for id, task := range tasks {
+		isolated := len(predecessors[id]) == 0 && len(adjacency[id]) == 0
+		if isolated && len(tasks) > 1 {
+			issues = append(issues, LintIssue{
+				Rule:    LintRuleIsolatedTask,
+				TaskID:  id,
+				Message: "task has no dependencies and no dependents",
+			})
+		}
+		if len(outputs) > 0 && len(adjacency[id]) == 0 && !outputs[id] {
+			issues = append(issues, LintIssue{
+				Rule:    LintRuleUnusedOutput,
+				TaskID:  id,
+				Message: "task output is not consumed by any task and is not a declared output",
+			})
+		}
+		if task.HasTag(TagNetwork) && task.Timeout <= 0 {
+			issues = append(issues, LintIssue{
+				Rule:    LintRuleNetworkTimeout,
+				TaskID:  id,
+				Message: "network task has no timeout",
+			})
+		}
+		if task.HasTag(TagNetwork) && task.Retries <= 0 {
+			issues = append(issues, LintIssue{
+				Rule:    LintRuleNetworkRetries,
+				TaskID:  id,
+				Message: "network task has no retries",
+			})
+		}
+		if depth := tg.taskLayers[id] + 1; depth > opts.MaxDepth {
+			issues = append(issues, LintIssue{
+				Rule:    LintRuleExcessiveDepth,
+				TaskID:  id,
+				Message: fmt.Sprintf("task is at depth %d, exceeding max depth %d", depth, opts.MaxDepth),
+			})
+		}
+		if message := conditionReference(task, tasks, predecessors); message != "" {
+			issues = append(issues, LintIssue{
+				Rule:    LintRuleConditionReference,
+				TaskID:  id,
+				Message: message,
+			})
+		}
+	}
+
+	sort.Slice(issues, func(i, j int) bool {
+		if issues[i].TaskID != issues[j].TaskID {
+			return issues[i].TaskID < issues[j].TaskID
+		}
+		return issues[i].Rule < issues[j].Rule
+	})
+	return issues, nil
+}
+
+// conditionReference 检查任务的声明式条件引用的结果和输入：results.<task> 必须是直接或间接依赖的任务，
+// inputs.<name> 必须是任务的输入。返回问题的描述，没有问题时返回空字符串
+func conditionReference(task *Task, tasks map[string]*Task, predecessors map[string]map[string]graph.Edge[string]) string {
+	if task.when == nil || (task.when.Evaluator != "" && task.when.Evaluator != ConditionCompare) {
+		return ""
+	}
+	path, _, _, err := parseCompare(task.when.Expr)
+	if err != nil {
+		return ""
+	}
+	switch name := path[1]; path[0] {
+	case "results":
+		if _, ok := tasks[name]; !ok {
+			return fmt.Sprintf("condition reads results of unknown task %s", name)
+		}
+		if !ancestor(predecessors, task.ID, name) {
+			return fmt.Sprintf("condition reads results of task %s, which is not a dependency", name)
+		}
+	case "inputs":
+		if _, ok := task.inputBindings()[name]; !ok {
+			return fmt.Sprintf("condition reads input %s, which is not a dependency or declared input", name)
+		}
+	}
+	return ""
+}
+
+// ancestor 判断 id 是否直接或间接依赖 other
+func ancestor(predecessors map[string]map[string]graph.Edge[string], id, other string) bool {
+	seen := map[string]bool{id: true}
+	stack := []string{id}
+	for len(stack) > 0 {
+		current := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		for dep := range predecessors[current] {
+			if dep == other {
+				return true
+			}
+			if !seen[dep] {
+				seen[dep] = true
+				stack = append(stack, dep)
+			}
+		}
+	}
+	return false
+}
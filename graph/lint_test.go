@@ -0,0 +1,48 @@
+package graph
+
+import (
+	"context"
+	"testing"
+)
+
+// 条件引用了非依赖任务的结果或不存在的输入时报告 condition-non-dependency
+func TestLintConditionReferences(t *testing.T) {
+	handler := func(ctx context.Context, inputs map[string]interface{}, params map[string]interface{}) (interface{}, error) {
+		return nil, nil
+	}
+	loader := NewDefinitionLoader(NewRegistry(), WithHandler("noop", handler))
+	tg, err := loader.Build(&DefinitionSpec{Name: "conditions", Tasks: []TaskSpec{
+		{ID: "extract", Handler: "noop"},
+		{ID: "validate", Handler: "noop", Depends: []string{"extract"}},
+		{ID: "load", Handler: "noop", Depends: []string{"validate"}, When: &ConditionSpec{Expr: "results.extract.count > 0"}},
+		{ID: "notify", Handler: "noop", Depends: []string{"extract"}, When: &ConditionSpec{Expr: "results.validate.ok"}},
+		{ID: "report", Handler: "noop", Depends: []string{"validate"}, When: &ConditionSpec{Expr: "inputs.extract.count > 0"}},
+		{ID: "publish", Handler: "noop", Depends: []string{"load"}, When: &ConditionSpec{Expr: `params.env == "prod"`}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	issues, err := tg.Lint(LintOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := make(map[string]string)
+	for _, issue := range issues {
+		if issue.Rule == LintRuleConditionReference {
+			got[issue.TaskID] = issue.Message
+		}
+	}
+	want := map[string]string{
+		"notify": "condition reads results of task validate, which is not a dependency",
+		"report": "condition reads input extract, which is not a dependency or declared input",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected issues for %v, got %v", want, got)
+	}
+	for id, message := range want {
+		if got[id] != message {
+			t.Errorf("task %s: expected %q, got %q", id, message, got[id])
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// ErrLockLost 表示任务持有的互斥锁已过期或被其他持有者获得，任务的上下文以此为原因被取消
+var ErrLockLost = errors.New("mutex lock lost")
+
+// LockStore 提供跨进程的互斥锁，用于 Task.Mutex。锁以租约的形式持有，持有者崩溃后在 ttl 到期时自动释放。
+// 运行记录的 Store 同时实现该接口时，注册表执行的运行自动使用它，也可以通过 WithLockStore 或 ExecuteOptions.Locks 指定；
+// 共享同一 LockStore（如 redis.NewLockStore）的所有进程之间互斥
+type LockStore interface {
+	// TryLock 在锁空闲或已过期时以 owner 持有锁 ttl 时长，返回是否获得；owner 已持有时视为获得并续期
+	TryLock(ctx context.Context, name, owner string, ttl time.Duration) (bool, error)
+	// RefreshLock 把 owner 持有的锁延长 ttl，锁已不属于 owner 时返回包装了 ErrLockLost 的错误
+	RefreshLock(ctx context.Context, name, owner string, ttl time.Duration) error
+	// Unlock 释放 owner 持有的锁，锁已不属于 owner 时不做任何事
+	Unlock(ctx context.Context, name, owner string) error
+}
+
+const (
+	// lockTTL 是互斥锁租约的时长，持有期间每 lockTTL/3 续期一次
+	lockTTL = 30 * time.Second
+	// lockPollMin 和 lockPollMax 是等待锁时重试间隔的范围，间隔每次加倍
+	lockPollMin = 50 * time.Millisecond
+	lockPollMax = time.Second
+)
+
+// processLocks 是执行未指定 LockStore 时使用的进程内锁，MemoryStore 的锁同样使用它
+var processLocks = &memoryLocks{locks: make(map[string]*lease)}
+
+// lockReleases 在本进程释放锁时唤醒等待同名锁的任务，不必等到下一次轮询；
+// 其他进程释放的锁仍通过轮询发现
+var lockReleases = struct {
+	sync.Mutex
+	waiters map[string]chan struct{}
+}{waiters: make(map[string]chan struct{})}
+
+// releaseSignal 返回锁 name 下一次在本进程释放时关闭的通道
+func releaseSignal(name string) <-chan struct{} {
+	lockReleases.Lock()
+	defer lockReleases.Unlock()
+	ch, ok := lockReleases.waiters[name]
+	if !ok {
+		ch = make(chan struct{})
+		lockReleases.waiters[name] = ch
+	}
+	return ch
+}
+
+// signalRelease 唤醒等待锁 name 的任务
+func signalRelease(name string) {
+	lockReleases.Lock()
+	defer lockReleases.Unlock()
+	if ch, ok := lockReleases.waiters[name]; ok {
+		close(ch)
+		delete(lockReleases.waiters, name)
+	}
+}
+
+// memoryLocks 是进程内的 LockStore
+type memoryLocks struct {
+	mu    sync.Mutex
+	locks map[string]*lease
+}
+
+// lease 是进程内的一个锁
+type lease struct {
+	owner   string
+	expires time.Time
+}
+
+// TryLock 实现 LockStore，与未指定 LockStore 的执行共享进程内的锁
+func (s *MemoryStore) TryLock(ctx context.Context, name, owner string, ttl time.Duration) (bool, error) {
+	return processLocks.TryLock(ctx, name, owner, ttl)
+}
+
+// RefreshLock 实现 LockStore
+func (s *MemoryStore) RefreshLock(ctx context.Context, name, owner string, ttl time.Duration) error {
+	return processLocks.RefreshLock(ctx, name, owner, ttl)
+}
+
+// Unlock 实现 LockStore
+func (s *MemoryStore) Unlock(ctx context.Context, name, owner string) error {
+	return processLocks.Unlock(ctx, name, owner)
+}
+
+// TryLock 实现 LockStore
+func (s *memoryLocks) TryLock(ctx context.Context, name, owner string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if l, ok := s.locks[name]; ok && l.owner != owner && now.Before(l.expires) {
+		return false, nil
+	}
+	s.locks[name] = &lease{owner: owner, expires: now.Add(ttl)}
+	return true, nil
+}
+
+// RefreshLock 实现 LockStore
+func (s *memoryLocks) RefreshLock(ctx context.Context, name, owner string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	l, ok := s.locks[name]
+	if !ok || l.owner != owner || !now.Before(l.expires) {
+		return fmt.Errorf("%w: %s is no longer held by %s", ErrLockLost, name, owner)
+	}
+	l.expires = now.Add(ttl)
+	return nil
+}
+
+// Unlock 实现 LockStore
+func (s *memoryLocks) Unlock(ctx context.Context, name, owner string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if l, ok := s.locks[name]; ok && l.owner == owner {
+		delete(s.locks, name)
+	}
+	return nil
+}
+
+// acquireMutex 等待并持有任务的互斥锁，返回的上下文在锁丢失时以 ErrLockLost 取消；
+// 任务未设置 Mutex 时直接返回。release 释放锁并停止续期
+func (run *runContext) acquireMutex(ctx context.Context, task *Task) (context.Context, func(), error) {
+	if task.Mutex == "" {
+		return ctx, func() {}, nil
+	}
+	owner := run.runID + "/" + task.ID
+	start := time.Now()
+	wait := lockPollMin
+	for {
+		// 先取得释放通知再尝试加锁，避免错过两者之间的释放
+		released := releaseSignal(task.Mutex)
+		ok, err := run.locks.TryLock(ctx, task.Mutex, owner, lockTTL)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to acquire mutex %s: %v", task.Mutex, err)
+		}
+		if ok {
+			break
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, nil, aborted(ctx)
+		case <-released:
+			timer.Stop()
+		case <-timer.C:
+		}
+		if wait *= 2; wait > lockPollMax {
+			wait = lockPollMax
+		}
+	}
+	if waited := time.Since(start); waited >= lockPollMin {
+		run.logger.Debug("acquired mutex", slog.String("task_id", task.ID), slog.String("mutex", task.Mutex), slog.Duration("waited", waited))
+	}
+
+	lockCtx, cancel := context.WithCancelCause(ctx)
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(lockTTL / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			if err := run.locks.RefreshLock(context.WithoutCancel(ctx), task.Mutex, owner, lockTTL); err != nil {
+				run.logger.Warn("failed to refresh mutex", slog.String("task_id", task.ID), slog.String("mutex", task.Mutex), slog.Any("error", err))
+				if errors.Is(err, ErrLockLost) {
+					cancel(fmt.Errorf("%w: %s", ErrLockLost, task.Mutex))
+					return
+				}
+			}
+		}
+	}()
+	release := func() {
+		close(done)
+		<-stopped
+		cancel(nil)
+		if err := run.locks.Unlock(context.WithoutCancel(ctx), task.Mutex, owner); err != nil {
+			run.logger.Warn("failed to release mutex", slog.String("task_id", task.ID), slog.String("mutex", task.Mutex), slog.Any("error", err))
+		}
+		signalRelease(task.Mutex)
+	}
+	return lockCtx, release, nil
+}
@@ -0,0 +1,61 @@
+package graph
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// 使用 MemoryStore 的注册表运行和直接执行的任务图共享进程内的锁，同名 Mutex 的任务不会同时执行
+func TestMutexSharedBetweenRegistryAndDirectExecution(t *testing.T) {
+	var holders, maxHolders atomic.Int32
+	newGraph := func() *TaskGraph {
+		tg := NewTaskGraph()
+		task := &Task{ID: "exclusive", Mutex: "shared-resource", Execute: func(ctx context.Context, inputs map[string]interface{}) (interface{}, error) {
+			n := holders.Add(1)
+			defer holders.Add(-1)
+			for {
+				current := maxHolders.Load()
+				if n <= current || maxHolders.CompareAndSwap(current, n) {
+					break
+				}
+			}
+			time.Sleep(20 * time.Millisecond)
+			return nil, nil
+		}}
+		if err := tg.AddTask(task); err != nil {
+			t.Fatal(err)
+		}
+		return tg
+	}
+
+	r := NewRegistry(WithStore(NewMemoryStore()))
+	if _, err := r.Register("locked", newGraph()); err != nil {
+		t.Fatal(err)
+	}
+	direct := newGraph()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if _, err := r.Start(context.Background(), "locked", ExecuteOptions{}); err != nil {
+				t.Error(err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if _, err := direct.Execute(context.Background(), ExecuteOptions{}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := maxHolders.Load(); n != 1 {
+		t.Fatalf("%d tasks held mutex shared-resource at the same time", n)
+	}
+}
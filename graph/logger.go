@@ -0,0 +1,42 @@
+package graph
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+)
+
+type loggerKey struct{}
+
+// LoggerFrom 返回执行引擎注入到任务上下文中的日志器，
+// 日志器已携带 run_id、task_id 和 attempt 字段；ctx 中没有日志器时返回 slog.Default()
+func LoggerFrom(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok && logger != nil {
+		return logger
+	}
+	return slog.Default()
+}
+
+// withLogger 将日志器写入上下文
+func withLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// taskLogger 为单次任务执行构造带上下文字段的日志器
+func taskLogger(base *slog.Logger, runID, taskID string, attempt int) *slog.Logger {
+	return base.With(
+		slog.String("run_id", runID),
+		slog.String("task_id", taskID),
+		slog.Int("attempt", attempt),
+	)
+}
+
+// NewRunID 生成一次执行的唯一标识
+func NewRunID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "run-unknown"
+	}
+	return hex.EncodeToString(b)
+}
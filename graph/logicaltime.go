@@ -0,0 +1,66 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// ParamLogicalTime 是运行参数中逻辑执行时间的键，值为 UTC 的 RFC3339 字符串（精确到秒），
+// 固定宽度，可以在条件中按字符串比较，如 `params.logical_time >= "2026-01-01T00:00:00Z"`
+const ParamLogicalTime = "logical_time"
+
+// WithLogicalTime 指定本次运行的逻辑执行时间，见 ExecuteOptions.LogicalTime
+func WithLogicalTime(t time.Time) ExecuteOption {
+	return func(o *ExecuteOptions) {
+		o.LogicalTime = t
+	}
+}
+
+type logicalTimeKey struct{}
+
+func withLogicalTime(ctx context.Context, t time.Time) context.Context {
+	return context.WithValue(ctx, logicalTimeKey{}, t)
+}
+
+// LogicalTimeFrom 返回任务上下文中运行的逻辑执行时间，不在运行中时返回零值
+func LogicalTimeFrom(ctx context.Context) time.Time {
+	t, _ := ctx.Value(logicalTimeKey{}).(time.Time)
+	return t
+}
+
+// FormatLogicalTime 把时间格式化为 logical_time 参数的形式
+func FormatLogicalTime(t time.Time) string {
+	return t.UTC().Truncate(time.Second).Format(time.RFC3339)
+}
+
+// applyLogicalTime 确定运行的逻辑执行时间并写入参数：依次取 opts.LogicalTime、参数中已有的 logical_time
+// （重试和恢复时沿用原运行的参数）和当前时间。参数映射表被替换为副本，不修改调用方的映射表
+func applyLogicalTime(opts *ExecuteOptions) error {
+	t := opts.LogicalTime
+	if t.IsZero() {
+		if v, ok := opts.Params[ParamLogicalTime]; ok {
+			s, _ := v.(string)
+			parsed, err := time.Parse(time.RFC3339, s)
+			if err != nil {
+				return fmt.Errorf("invalid %s param %v: expected an RFC3339 time", ParamLogicalTime, v)
+			}
+			t = parsed
+		}
+	}
+	if t.IsZero() {
+		t = time.Now()
+	}
+	opts.LogicalTime = t.UTC().Truncate(time.Second)
+	formatted := FormatLogicalTime(t)
+	if opts.Params[ParamLogicalTime] == formatted {
+		return nil
+	}
+	params := make(map[string]interface{}, len(opts.Params)+1)
+	for k, v := range opts.Params {
+		params[k] = v
+	}
+	params[ParamLogicalTime] = formatted
+	opts.Params = params
+	return nil
+}
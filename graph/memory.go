@@ -0,0 +1,128 @@
+package graph
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync/atomic"
+)
+
+// ErrResultMemoryLimit 表示运行持有的结果超出了 ExecuteOptions.MaxResultBytes
+var ErrResultMemoryLimit = errors.New("result memory limit exceeded")
+
+// resultBytesInFlight 是所有执行中的运行持有的结果字节数之和
+var resultBytesInFlight atomic.Int64
+
+// ResultBytesInFlight 返回所有开启了内存统计、正在执行的运行持有的任务结果的估算字节数之和，
+// 可作为指标导出
+func ResultBytesInFlight() int64 {
+	return resultBytesInFlight.Load()
+}
+
+// memoryAccount 统计一次运行中任务结果占用的估算字节数
+type memoryAccount struct {
+	limit int64 // 为0时不限制
+	total atomic.Int64
+}
+
+// newMemoryAccount 在开启统计或设置了上限时返回统计器，否则返回 nil
+func newMemoryAccount(enabled bool, limit int64) (*memoryAccount, error) {
+	if limit < 0 {
+		return nil, fmt.Errorf("max result bytes must not be negative, got %d", limit)
+	}
+	if !enabled && limit == 0 {
+		return nil, nil
+	}
+	return &memoryAccount{limit: limit}, nil
+}
+
+// add 记录任务结果的大小，超出上限时返回包装了 ErrResultMemoryLimit 的错误，此时结果不计入
+func (m *memoryAccount) add(taskID string, size int64) error {
+	total := m.total.Add(size)
+	if m.limit > 0 && total > m.limit {
+		m.total.Add(-size)
+		return fmt.Errorf("%w: result of task %s (%d bytes) would bring the run to %d bytes, limit %d",
+			ErrResultMemoryLimit, taskID, size, total, m.limit)
+	}
+	resultBytesInFlight.Add(size)
+	return nil
+}
+
+// release 在运行结束时从进程级统计中移除本运行的结果
+func (m *memoryAccount) release() {
+	resultBytesInFlight.Add(-m.total.Load())
+}
+
+// approxSize 估算值占用的内存字节数：按 64 位平台计算 Go 值的头部和引用的数据，
+// 共享的指针和映射表只计一次；溢出的结果只计句柄本身
+func approxSize(v interface{}) int64 {
+	if v == nil {
+		return 0
+	}
+	seen := make(map[uintptr]bool)
+	return sizeOf(reflect.ValueOf(v), seen)
+}
+
+func sizeOf(v reflect.Value, seen map[uintptr]bool) int64 {
+	switch v.Kind() {
+	case reflect.Invalid:
+		return 0
+	case reflect.Pointer:
+		if v.IsNil() || seen[v.Pointer()] {
+			return 8
+		}
+		seen[v.Pointer()] = true
+		return 8 + sizeOf(v.Elem(), seen)
+	case reflect.Interface:
+		if v.IsNil() {
+			return 16
+		}
+		return 16 + sizeOf(v.Elem(), seen)
+	case reflect.String:
+		return 16 + int64(v.Len())
+	case reflect.Slice:
+		if v.IsNil() || seen[v.Pointer()] {
+			return 24
+		}
+		seen[v.Pointer()] = true
+		return 24 + arraySize(v, seen)
+	case reflect.Array:
+		return arraySize(v, seen)
+	case reflect.Map:
+		if v.IsNil() || seen[v.Pointer()] {
+			return 8
+		}
+		seen[v.Pointer()] = true
+		// 映射表的桶和元数据大约为键值本身的一半
+		size := int64(48)
+		iter := v.MapRange()
+		for iter.Next() {
+			size += sizeOf(iter.Key(), seen) + sizeOf(iter.Value(), seen)
+		}
+		return size + size/2
+	case reflect.Struct:
+		var size int64
+		for i := 0; i < v.NumField(); i++ {
+			size += sizeOf(v.Field(i), seen)
+		}
+		return size
+	default:
+		return int64(v.Type().Size())
+	}
+}
+
+// arraySize 估算数组或切片元素占用的字节数，元素不含引用时直接按元素大小计算
+func arraySize(v reflect.Value, seen map[uintptr]bool) int64 {
+	elem := v.Type().Elem()
+	switch elem.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
+		return int64(v.Len()) * int64(elem.Size())
+	}
+	var size int64
+	for i := 0; i < v.Len(); i++ {
+		size += sizeOf(v.Index(i), seen)
+	}
+	return size
+}
@@ -0,0 +1,341 @@
+package graph
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// OpenLineage 事件类型
+const (
+	OpenLineageStart    = "START"
+	OpenLineageComplete = "COMPLETE"
+	OpenLineageFail     = "FAIL"
+	OpenLineageAbort    = "ABORT"
+)
+
+const (
+	openLineageSchemaURL = "https://openlineage.io/spec/2-0-2/OpenLineage.json#/$defs/RunEvent"
+	openLineageFacetURL  = "https://openlineage.io/spec/facets/1-0-0/"
+	// DefaultOpenLineageProducer 是事件和 facet 中 _producer 的默认值
+	DefaultOpenLineageProducer = "workflow-engine"
+)
+
+// OpenLineageEvent 是 OpenLineage 规范中的 RunEvent
+type OpenLineageEvent struct {
+	EventType string               `json:"eventType"`
+	EventTime time.Time            `json:"eventTime"`
+	Run       OpenLineageRun       `json:"run"`
+	Job       OpenLineageJob       `json:"job"`
+	Inputs    []OpenLineageDataset `json:"inputs"`
+	Outputs   []OpenLineageDataset `json:"outputs"`
+	Producer  string               `json:"producer"`
+	SchemaURL string               `json:"schemaURL"`
+}
+
+// OpenLineageRun 是事件所属的运行，RunID 为 UUID
+type OpenLineageRun struct {
+	RunID  string                 `json:"runId"`
+	Facets map[string]interface{} `json:"facets,omitempty"`
+}
+
+// OpenLineageJob 是事件所属的作业：工作流和其中的每个任务各是一个作业
+type OpenLineageJob struct {
+	Namespace string                 `json:"namespace"`
+	Name      string                 `json:"name"`
+	Facets    map[string]interface{} `json:"facets,omitempty"`
+}
+
+// OpenLineageDataset 是作业读写的数据集：每个任务的输出是一个数据集
+type OpenLineageDataset struct {
+	Namespace string                 `json:"namespace"`
+	Name      string                 `json:"name"`
+	Facets    map[string]interface{} `json:"facets,omitempty"`
+}
+
+// OpenLineageTransport 发送 OpenLineage 事件
+type OpenLineageTransport interface {
+	Emit(ctx context.Context, event *OpenLineageEvent) error
+}
+
+// openLineageHTTP 以 HTTP POST 发送事件，兼容 Marquez 的 /api/v1/lineage 接口
+type openLineageHTTP struct {
+	url    string
+	apiKey string
+	client *http.Client
+}
+
+// NewOpenLineageHTTPTransport 创建向 url（如 http://marquez:5000/api/v1/lineage）POST 事件的传输，
+// apiKey 不为空时以 Bearer 令牌发送；client 为空时使用10秒超时的客户端
+func NewOpenLineageHTTPTransport(url, apiKey string, client *http.Client) OpenLineageTransport {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &openLineageHTTP{url: url, apiKey: apiKey, client: client}
+}
+
+func (t *openLineageHTTP) Emit(ctx context.Context, event *OpenLineageEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode openlineage event: %v", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if t.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+t.apiKey)
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("openlineage endpoint returned %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// OpenLineageEmitter 把运行和任务的状态变化转换为 OpenLineage 事件，按产生顺序在后台发送。
+// 工作流是一个作业，其中的每个任务是名为 "<工作流>.<任务ID>" 的子作业，以 parent facet 关联到工作流的运行；
+// 每个任务的输出是名为 "<工作流>.<任务ID>" 的数据集，作为依赖它的任务的输入。
+// 运行开启 ExecuteOptions.Lineage 时数据集带有以输出摘要为版本的 version facet
+type OpenLineageEmitter struct {
+	transport OpenLineageTransport
+	namespace string
+	producer  string
+	logger    *slog.Logger
+
+	mu     sync.Mutex
+	closed bool
+	events chan *OpenLineageEvent
+	done   chan struct{}
+}
+
+// OpenLineageOption 定义 OpenLineageEmitter 的构造选项
+type OpenLineageOption func(*OpenLineageEmitter)
+
+// WithOpenLineageNamespace 设置作业和数据集的 OpenLineage 命名空间，未设置时使用工作流所在的命名空间
+func WithOpenLineageNamespace(namespace string) OpenLineageOption {
+	return func(e *OpenLineageEmitter) {
+		e.namespace = namespace
+	}
+}
+
+// WithOpenLineageProducer 设置事件的 producer，默认为 DefaultOpenLineageProducer
+func WithOpenLineageProducer(producer string) OpenLineageOption {
+	return func(e *OpenLineageEmitter) {
+		e.producer = producer
+	}
+}
+
+// WithOpenLineageLogger 设置记录发送失败的日志器，默认为 slog.Default()
+func WithOpenLineageLogger(logger *slog.Logger) OpenLineageOption {
+	return func(e *OpenLineageEmitter) {
+		e.logger = logger
+	}
+}
+
+// WithOpenLineageBuffer 设置等待发送的事件数上限，默认1024；队列满时丢弃新事件并记录日志，不阻塞任务执行
+func WithOpenLineageBuffer(size int) OpenLineageOption {
+	return func(e *OpenLineageEmitter) {
+		e.events = make(chan *OpenLineageEvent, size)
+	}
+}
+
+// NewOpenLineageEmitter 创建事件发送器并启动后台发送，不再使用时调用 Close
+func NewOpenLineageEmitter(transport OpenLineageTransport, opts ...OpenLineageOption) *OpenLineageEmitter {
+	e := &OpenLineageEmitter{
+		transport: transport,
+		producer:  DefaultOpenLineageProducer,
+		logger:    slog.Default(),
+		events:    make(chan *OpenLineageEvent, 1024),
+		done:      make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	go e.loop()
+	return e
+}
+
+// Close 停止接收新事件，等待已排队的事件发送完毕
+func (e *OpenLineageEmitter) Close() {
+	e.mu.Lock()
+	if !e.closed {
+		e.closed = true
+		close(e.events)
+	}
+	e.mu.Unlock()
+	<-e.done
+}
+
+func (e *OpenLineageEmitter) loop() {
+	defer close(e.done)
+	for event := range e.events {
+		if err := e.transport.Emit(context.Background(), event); err != nil {
+			e.logger.Warn("openlineage emit failed",
+				slog.String("job", event.Job.Name), slog.String("event_type", event.EventType), slog.Any("error", err))
+		}
+	}
+}
+
+func (e *OpenLineageEmitter) enqueue(event *OpenLineageEvent) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.closed {
+		return
+	}
+	select {
+	case e.events <- event:
+	default:
+		e.logger.Warn("openlineage queue full, dropping event",
+			slog.String("job", event.Job.Name), slog.String("event_type", event.EventType))
+	}
+}
+
+// openLineageRun 是一次运行的事件上下文
+type openLineageRun struct {
+	emitter   *OpenLineageEmitter
+	namespace string
+	workflow  string
+	runID     string
+	upstream  map[string][]string // 任务ID -> 直接依赖
+}
+
+// run 创建一次运行的事件上下文，任务图的依赖在运行开始时读取一次
+func (e *OpenLineageEmitter) run(namespace, workflow, runID string, tg *TaskGraph) *openLineageRun {
+	if e.namespace != "" {
+		namespace = e.namespace
+	}
+	r := &openLineageRun{emitter: e, namespace: namespace, workflow: workflow, runID: runID, upstream: make(map[string][]string)}
+	if predecessors, err := tg.graph.PredecessorMap(); err == nil {
+		for id, edges := range predecessors {
+			r.upstream[id] = sortedKeys(edges)
+		}
+	}
+	return r
+}
+
+// runEvent 发送工作流作业的事件
+func (r *openLineageRun) runEvent(eventType string, at time.Time, err error) {
+	event := r.event(eventType, at, r.workflow, openLineageUUID(r.namespace, r.runID), "DAG")
+	if err != nil {
+		event.Run.Facets["errorMessage"] = r.facet(map[string]interface{}{"message": err.Error(), "programmingLanguage": "go"})
+	}
+	r.emitter.enqueue(event)
+}
+
+// taskTransition 是 OnTaskTransition 钩子：开始执行时发送 START，结束时发送 COMPLETE、FAIL 或 ABORT；
+// 未执行就被跳过的任务和复用之前结果的任务不产生事件
+func (r *openLineageRun) taskTransition(tr TaskReport, from TaskStatus) {
+	var eventType string
+	at := tr.EndTime
+	switch {
+	case tr.Reused:
+		return
+	case tr.Status == TaskStatusRunning && from != TaskStatusRunning:
+		eventType, at = OpenLineageStart, tr.StartTime
+	case tr.Status == TaskStatusCompleted:
+		eventType = OpenLineageComplete
+	case tr.Status == TaskStatusFailed:
+		eventType = OpenLineageFail
+	case tr.Status == TaskStatusSkipped && from == TaskStatusRunning:
+		eventType = OpenLineageAbort
+	default:
+		return
+	}
+	if at.IsZero() {
+		at = time.Now()
+	}
+
+	event := r.event(eventType, at, r.workflow+"."+tr.ID, openLineageUUID(r.namespace, r.runID, tr.ID), "TASK")
+	event.Run.Facets["parent"] = r.facet(map[string]interface{}{
+		"run": map[string]interface{}{"runId": openLineageUUID(r.namespace, r.runID)},
+		"job": map[string]interface{}{"namespace": r.namespace, "name": r.workflow},
+	})
+	if tr.Error != nil {
+		event.Run.Facets["errorMessage"] = r.facet(map[string]interface{}{"message": tr.Error.Error(), "programmingLanguage": "go"})
+	}
+
+	digests := make(map[string]string)
+	inputs := r.upstream[tr.ID]
+	if tr.Lineage != nil {
+		inputs = inputs[:0:0]
+		for _, in := range tr.Lineage.Inputs {
+			if _, ok := digests[in.TaskID]; !ok {
+				inputs = append(inputs, in.TaskID)
+			}
+			digests[in.TaskID] = in.Digest
+		}
+	}
+	for _, id := range inputs {
+		if !isSentinel(id) {
+			event.Inputs = append(event.Inputs, r.dataset(id, digests[id]))
+		}
+	}
+	if eventType == OpenLineageStart || eventType == OpenLineageComplete {
+		digest := ""
+		if tr.Lineage != nil {
+			digest = tr.Lineage.OutputDigest
+		}
+		event.Outputs = append(event.Outputs, r.dataset(tr.ID, digest))
+	}
+	r.emitter.enqueue(event)
+}
+
+func (r *openLineageRun) event(eventType string, at time.Time, job, runID, jobType string) *OpenLineageEvent {
+	return &OpenLineageEvent{
+		EventType: eventType,
+		EventTime: at.UTC(),
+		Run: OpenLineageRun{RunID: runID, Facets: map[string]interface{}{
+			"workflow_run": r.facet(map[string]interface{}{"run_id": r.runID}),
+		}},
+		Job: OpenLineageJob{Namespace: r.namespace, Name: job, Facets: map[string]interface{}{
+			"jobType": r.facet(map[string]interface{}{"processingType": "BATCH", "integration": "WORKFLOW", "jobType": jobType}),
+		}},
+		Inputs:    []OpenLineageDataset{},
+		Outputs:   []OpenLineageDataset{},
+		Producer:  r.emitter.producer,
+		SchemaURL: openLineageSchemaURL,
+	}
+}
+
+// dataset 返回任务输出对应的数据集，digest 不为空时作为数据集的版本
+func (r *openLineageRun) dataset(taskID, digest string) OpenLineageDataset {
+	ds := OpenLineageDataset{Namespace: r.namespace, Name: r.workflow + "." + taskID}
+	if digest != "" {
+		ds.Facets = map[string]interface{}{"version": r.facet(map[string]interface{}{"datasetVersion": digest})}
+	}
+	return ds
+}
+
+// facet 为 facet 字段补充规范要求的 _producer 和 _schemaURL
+func (r *openLineageRun) facet(fields map[string]interface{}) map[string]interface{} {
+	fields["_producer"] = r.emitter.producer
+	fields["_schemaURL"] = openLineageFacetURL
+	return fields
+}
+
+// openLineageUUID 由命名空间、run_id 和任务ID确定性地生成 UUID，同一运行的重复事件使用相同的 runId
+func openLineageUUID(parts ...string) string {
+	h := sha256.New()
+	for _, p := range parts {
+		h.Write([]byte(p))
+		h.Write([]byte{0})
+	}
+	b := h.Sum(nil)[:16]
+	b[6] = (b[6] & 0x0f) | 0x50 // 版本5
+	b[8] = (b[8] & 0x3f) | 0x80 // RFC 4122 变体
+	s := hex.EncodeToString(b)
+	return s[0:8] + "-" + s[8:12] + "-" + s[12:16] + "-" + s[16:20] + "-" + s[20:32]
+}
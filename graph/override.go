@@ -0,0 +1,65 @@
+package graph
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// TaskOverride 是执行时对单个任务配置的覆盖，通过 WithTaskOptions 设置，
+// 用于在不修改任务图定义的情况下临时调整运行参数（如依赖服务降级时放宽超时）
+type TaskOverride struct {
+	timeout *time.Duration
+	retries *int
+}
+
+// TaskOption 修改单个任务的执行时配置
+type TaskOption func(*TaskOverride)
+
+// Timeout 覆盖任务单次执行的超时时间，为0时不限制
+func Timeout(d time.Duration) TaskOption {
+	return func(o *TaskOverride) {
+		o.timeout = &d
+	}
+}
+
+// Retries 覆盖任务执行失败后的重试次数，为0时不重试
+func Retries(n int) TaskOption {
+	return func(o *TaskOverride) {
+		o.retries = &n
+	}
+}
+
+// WithTaskOptions 在本次执行中覆盖指定任务的配置，如
+// WithTaskOptions("get_orders", Timeout(2*time.Second), Retries(3))；
+// 多次对同一任务设置时后设置的选项生效，任务不存在时执行返回错误
+func WithTaskOptions(taskID string, opts ...TaskOption) ExecuteOption {
+	return func(o *ExecuteOptions) {
+		if o.TaskOverrides == nil {
+			o.TaskOverrides = make(map[string]*TaskOverride)
+		}
+		override, ok := o.TaskOverrides[taskID]
+		if !ok {
+			override = &TaskOverride{}
+			o.TaskOverrides[taskID] = override
+		}
+		for _, opt := range opts {
+			opt(override)
+		}
+	}
+}
+
+// validateOverrides 检查覆盖的任务都存在于任务图中
+func validateOverrides(overrides map[string]*TaskOverride, tasks map[string]*Task) error {
+	var unknown []string
+	for id := range overrides {
+		if _, ok := tasks[id]; !ok {
+			unknown = append(unknown, id)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("task options set for unknown tasks: %v", unknown)
+	}
+	return nil
+}
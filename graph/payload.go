@@ -0,0 +1,68 @@
+package graph
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"text/template"
+)
+
+// PayloadTemplate 是调用外部服务的任务（如 Lambda、消息发布）使用的请求体模板，以 text/template 语法编写。
+// 模板中可以使用 .inputs（任务输入）、.run_id 和 .logical_time（运行的逻辑执行时间，time.Time），
+// 以及函数 json（编码为 JSON）和 default（值为空时使用默认值），如
+//
+//	{"user": {{json .inputs.fetch.user}}, "source": "{{.run_id}}", "date": "{{.logical_time.Format "2006-01-02"}}"}
+type PayloadTemplate struct {
+	text string
+	tmpl *template.Template
+}
+
+var payloadFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	"default": func(def, v interface{}) interface{} {
+		if v == nil || v == "" {
+			return def
+		}
+		return v
+	},
+}
+
+// ParsePayloadTemplate 解析请求体模板；引用不存在的输入时渲染失败，而不是输出 "<no value>"
+func ParsePayloadTemplate(text string) (*PayloadTemplate, error) {
+	tmpl, err := template.New("payload").Funcs(payloadFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid payload template: %v", err)
+	}
+	return &PayloadTemplate{text: text, tmpl: tmpl}, nil
+}
+
+// String 返回模板原文
+func (t *PayloadTemplate) String() string {
+	return t.text
+}
+
+// Render 使用任务输入渲染请求体
+func (t *PayloadTemplate) Render(ctx context.Context, inputs map[string]interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	data := map[string]interface{}{"inputs": inputs, "run_id": RunIDFrom(ctx), "logical_time": LogicalTimeFrom(ctx)}
+	if err := t.tmpl.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("failed to render payload: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// RenderPayload 渲染请求体：t 为空时把任务输入编码为 JSON
+func RenderPayload(ctx context.Context, t *PayloadTemplate, inputs map[string]interface{}) ([]byte, error) {
+	if t == nil {
+		b, err := json.Marshal(inputs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode inputs: %v", err)
+		}
+		return b, nil
+	}
+	return t.Render(ctx, inputs)
+}
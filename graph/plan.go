@@ -0,0 +1,225 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// smallGraphThreshold 是使用小图快速路径的最大任务数
+const smallGraphThreshold = 16
+
+// executionPlan 是任务图编译后的执行计划，在任务图变更前可被多次执行复用
+type executionPlan struct {
+	ids         []string // 按ID排序的任务ID，下标即任务序号
+	tasks       map[string]*Task
+	fingerprint string
+	sensitive   map[string][]string // 任务ID -> 敏感字段路径
+	tags        map[string][]string // 任务ID -> 标签，用于按标签汇总成本
+	twoPhase    bool                // 是否包含两阶段任务
+
+	// 以下字段仅在小图快速路径中使用
+	small   bool
+	order   []*Task  // 按任务序号排列的任务
+	depMask []uint32 // 每个任务依赖的任务序号位图
+}
+
+// codecs 返回各任务输出使用的编码名称，defaultCodec 为任务未设置 Codec 时使用的编码；
+// 所有任务都使用 JSON 时返回空映射表
+func (p *executionPlan) codecs(defaultCodec string) (map[string]string, error) {
+	var codecs map[string]string
+	for _, id := range p.ids {
+		name := p.tasks[id].Codec
+		if name == "" {
+			name = defaultCodec
+		}
+		if name == "" || name == CodecJSON {
+			continue
+		}
+		if _, ok := LookupCodec(name); !ok {
+			return nil, fmt.Errorf("unknown codec %s for task %s", name, id)
+		}
+		if codecs == nil {
+			codecs = make(map[string]string)
+		}
+		codecs[id] = name
+	}
+	return codecs, nil
+}
+
+// compile 返回任务图的执行计划，任务图未变更时复用上次编译的结果
+func (tg *TaskGraph) compile() (*executionPlan, error) {
+	tg.planMu.Lock()
+	defer tg.planMu.Unlock()
+	if tg.plan != nil {
+		return tg.plan, nil
+	}
+
+	if _, err := tg.topologicalOrder(); err != nil {
+		return nil, fmt.Errorf("failed to sort tasks: %v", err)
+	}
+	tasks, _, err := tg.snapshot()
+	if err != nil {
+		return nil, err
+	}
+	fingerprint, err := tg.Fingerprint()
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &executionPlan{
+		ids:         sortedTaskIDs(tasks),
+		tasks:       tasks,
+		fingerprint: fingerprint,
+		small:       len(tasks) <= smallGraphThreshold,
+	}
+	for id, task := range tasks {
+		if task.TwoPhase != nil {
+			plan.twoPhase = true
+		}
+		if len(task.Sensitive) > 0 {
+			if plan.sensitive == nil {
+				plan.sensitive = make(map[string][]string)
+			}
+			plan.sensitive[id] = task.Sensitive
+		}
+		if len(task.Tags) > 0 {
+			if plan.tags == nil {
+				plan.tags = make(map[string][]string)
+			}
+			plan.tags[id] = task.Tags
+		}
+	}
+	if plan.small {
+		ordinals := make(map[string]int, len(plan.ids))
+		for i, id := range plan.ids {
+			ordinals[id] = i
+		}
+		plan.order = make([]*Task, len(plan.ids))
+		plan.depMask = make([]uint32, len(plan.ids))
+		for i, id := range plan.ids {
+			plan.order[i] = tasks[id]
+			for _, dep := range tasks[id].Depends {
+				plan.depMask[i] |= 1 << ordinals[dep.ID]
+			}
+		}
+	}
+
+	tg.plan = plan
+	return plan, nil
+}
+
+// invalidatePlan 在任务图变更后丢弃已编译的执行计划
+func (tg *TaskGraph) invalidatePlan() {
+	tg.planMu.Lock()
+	defer tg.planMu.Unlock()
+	tg.plan = nil
+}
+
+// smallResults 是小图快速路径使用的结果集合，以数组保存并线性查找，不使用映射表
+type smallResults struct {
+	ids   []string
+	slots [smallGraphThreshold]atomic.Pointer[resultEntry]
+}
+
+func (r *smallResults) get(taskID string) (interface{}, bool) {
+	for i, id := range r.ids {
+		if id == taskID {
+			if entry := r.slots[i].Load(); entry != nil {
+				return entry.value, true
+			}
+			return nil, false
+		}
+	}
+	return nil, false
+}
+
+// toMap 在执行结束后生成结果映射表
+func (r *smallResults) toMap() map[string]interface{} {
+	results := make(map[string]interface{}, len(r.ids))
+	for i, id := range r.ids {
+		if entry := r.slots[i].Load(); entry != nil {
+			results[id] = entry.value
+		}
+	}
+	return results
+}
+
+// executeSmall 是小图的快速执行路径：用位图记录依赖和完成情况，
+// 任务的依赖全部结束后立即启动，调度过程中不分配映射表
+func (tg *TaskGraph) executeSmall(ctx context.Context, run *runContext, plan *executionPlan, results *smallResults) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		started  uint32
+		done     uint32
+		firstErr error
+		launch   func()
+		priority []int // 按预期耗时从长到短排列的任务序号，为空时按序号顺序启动
+	)
+	if run.hints != nil {
+		ids := append([]string(nil), plan.ids...)
+		run.hints.order(ids, true)
+		ordinals := make(map[string]int, len(plan.ids))
+		for i, id := range plan.ids {
+			ordinals[id] = i
+		}
+		priority = make([]int, len(ids))
+		for k, id := range ids {
+			priority[k] = ordinals[id]
+		}
+	}
+
+	execute := func(i int) {
+		defer wg.Done()
+		result, completed, err := tg.runTask(ctx, run, plan.order[i], results)
+		if completed {
+			results.slots[i].Store(&resultEntry{value: result})
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+				cancel()
+			}
+			return
+		}
+		done |= 1 << i
+		if firstErr == nil && ctx.Err() == nil {
+			launch()
+		}
+	}
+
+	// launch 启动所有依赖已结束且尚未启动的任务，调用方需持有锁
+	launch = func() {
+		for k := range plan.order {
+			i := k
+			if priority != nil {
+				i = priority[k]
+			}
+			bit := uint32(1) << i
+			if started&bit == 0 && plan.depMask[i]&^done == 0 {
+				started |= bit
+				wg.Add(1)
+				go execute(i)
+			}
+		}
+	}
+
+	mu.Lock()
+	launch()
+	mu.Unlock()
+	wg.Wait()
+
+	// 执行被外部取消且正在执行的任务都正常返回时，未启动的任务不会再启动
+	if firstErr == nil && done != 1<<len(plan.order)-1 && ctx.Err() != nil {
+		return aborted(ctx)
+	}
+	return firstErr
+}
@@ -0,0 +1,152 @@
+package graph
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"sync"
+)
+
+// DefaultExecutor 是未指定 Executor 的任务使用的执行器，并发数由 WorkerCount 决定
+const DefaultExecutor = ""
+
+// slotWaiter 是等待并发名额的任务
+type slotWaiter struct {
+	priority int64
+	seq      uint64
+	ready    chan struct{} // 获得名额时关闭
+	slot     int           // 获得的名额编号
+	index    int           // 在堆中的位置，出堆后为 -1
+}
+
+// waiterHeap 按优先级从高到低、同优先级按到达顺序排列等待者
+type waiterHeap []*slotWaiter
+
+func (h waiterHeap) Len() int { return len(h) }
+func (h waiterHeap) Less(i, j int) bool {
+	if h[i].priority != h[j].priority {
+		return h[i].priority > h[j].priority
+	}
+	return h[i].seq < h[j].seq
+}
+func (h waiterHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+func (h *waiterHeap) Push(x any) {
+	w := x.(*slotWaiter)
+	w.index = len(*h)
+	*h = append(*h, w)
+}
+func (h *waiterHeap) Pop() any {
+	old := *h
+	w := old[len(old)-1]
+	old[len(old)-1] = nil
+	w.index = -1
+	*h = old[:len(old)-1]
+	return w
+}
+
+// slotPool 是按优先级分配名额的信号量：名额释放时交给优先级最高的等待者。
+// 每个名额有固定编号（0 ~ capacity-1），用于在尝试记录中标识执行任务的工作者
+type slotPool struct {
+	mu      sync.Mutex
+	free    []int // 空闲的名额编号
+	seq     uint64
+	waiters waiterHeap
+}
+
+func newSlotPool(capacity int) *slotPool {
+	p := &slotPool{free: make([]int, capacity)}
+	// 倒序存放，使编号小的名额先被使用
+	for i := range p.free {
+		p.free[i] = capacity - 1 - i
+	}
+	return p
+}
+
+// acquire 占用一个名额并返回其编号，名额不足时按 priority 排队等待
+func (p *slotPool) acquire(ctx context.Context, priority int64) (int, error) {
+	p.mu.Lock()
+	if len(p.free) > 0 && len(p.waiters) == 0 {
+		slot := p.free[len(p.free)-1]
+		p.free = p.free[:len(p.free)-1]
+		p.mu.Unlock()
+		return slot, nil
+	}
+	p.seq++
+	w := &slotWaiter{priority: priority, seq: p.seq, ready: make(chan struct{})}
+	heap.Push(&p.waiters, w)
+	p.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return w.slot, nil
+	case <-ctx.Done():
+		p.mu.Lock()
+		granted := w.index < 0
+		if !granted {
+			heap.Remove(&p.waiters, w.index)
+		}
+		p.mu.Unlock()
+		// 取消的同时已获得名额时归还给下一个等待者
+		if granted {
+			p.release(w.slot)
+		}
+		return 0, ctx.Err()
+	}
+}
+
+// release 归还名额，有等待者时直接转交给优先级最高的等待者
+func (p *slotPool) release(slot int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.waiters) > 0 {
+		w := heap.Pop(&p.waiters).(*slotWaiter)
+		w.slot = slot
+		close(w.ready)
+		return
+	}
+	p.free = append(p.free, slot)
+}
+
+// workerPools 按执行器名称限制任务并发
+type workerPools map[string]*slotPool
+
+// newWorkerPools 根据默认并发数和命名执行器配置创建执行器池
+func newWorkerPools(workerCount int, executors map[string]int) workerPools {
+	pools := workerPools{DefaultExecutor: newSlotPool(workerCount)}
+	for name, concurrency := range executors {
+		if concurrency <= 0 {
+			concurrency = workerCount
+		}
+		pools[name] = newSlotPool(concurrency)
+	}
+	return pools
+}
+
+// validate 检查任务路由到的执行器都已配置
+func (p workerPools) validate(tasks map[string]*Task) error {
+	for _, task := range tasks {
+		if _, ok := p[task.Executor]; !ok {
+			return fmt.Errorf("task %s routed to unknown executor %q", task.ID, task.Executor)
+		}
+	}
+	return nil
+}
+
+// acquire 为任务占用所属执行器的一个并发名额，返回工作者标识（如 "default/0"、"gpu/1"）和释放函数；
+// 名额不足时 priority 高的任务先获得名额
+func (p workerPools) acquire(ctx context.Context, task *Task, priority int64) (string, func(), error) {
+	pool := p[task.Executor]
+	slot, err := pool.acquire(ctx, priority)
+	if err != nil {
+		return "", nil, err
+	}
+	executor := task.Executor
+	if executor == DefaultExecutor {
+		executor = "default"
+	}
+	return fmt.Sprintf("%s/%d", executor, slot), func() { pool.release(slot) }, nil
+}
@@ -0,0 +1,179 @@
+package graph
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/dominikbraun/graph"
+)
+
+// GetDependencies 返回任务的直接依赖（按ID排序）
+func (tg *TaskGraph) GetDependencies(taskID string) ([]string, error) {
+	switch taskID {
+	case StartNode:
+		return nil, nil
+	case EndNode:
+		return tg.Sinks()
+	}
+	predecessors, err := tg.graph.PredecessorMap()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get predecessors: %v", err)
+	}
+	edges, ok := predecessors[taskID]
+	if !ok {
+		return nil, fmt.Errorf("task %s not found", taskID)
+	}
+	return sortedKeys(edges), nil
+}
+
+// GetDependents 返回直接依赖该任务的下游任务（按ID排序）
+func (tg *TaskGraph) GetDependents(taskID string) ([]string, error) {
+	switch taskID {
+	case StartNode:
+		return tg.Sources()
+	case EndNode:
+		return nil, nil
+	}
+	adjacency, err := tg.graph.AdjacencyMap()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get adjacency map: %v", err)
+	}
+	edges, ok := adjacency[taskID]
+	if !ok {
+		return nil, fmt.Errorf("task %s not found", taskID)
+	}
+	return sortedKeys(edges), nil
+}
+
+// GetTransitiveDependencies 返回任务的全部直接和间接依赖（按ID排序）
+func (tg *TaskGraph) GetTransitiveDependencies(taskID string) ([]string, error) {
+	switch taskID {
+	case StartNode:
+		return nil, nil
+	case EndNode:
+		return tg.allTaskIDs()
+	}
+	predecessors, err := tg.graph.PredecessorMap()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get predecessors: %v", err)
+	}
+	return walk(predecessors, taskID)
+}
+
+// GetTransitiveDependents 返回直接和间接依赖该任务的全部下游任务（按ID排序），
+// 即该任务失败时会受到影响的任务集合
+func (tg *TaskGraph) GetTransitiveDependents(taskID string) ([]string, error) {
+	switch taskID {
+	case StartNode:
+		return tg.allTaskIDs()
+	case EndNode:
+		return nil, nil
+	}
+	adjacency, err := tg.graph.AdjacencyMap()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get adjacency map: %v", err)
+	}
+	return walk(adjacency, taskID)
+}
+
+// walk 沿邻接表进行广度优先遍历，返回起点之外所有可达的节点
+func walk(edges map[string]map[string]graph.Edge[string], start string) ([]string, error) {
+	if _, ok := edges[start]; !ok {
+		return nil, fmt.Errorf("task %s not found", start)
+	}
+
+	visited := map[string]bool{start: true}
+	queue := []string{start}
+	var reached []string
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for next := range edges[current] {
+			if visited[next] {
+				continue
+			}
+			visited[next] = true
+			reached = append(reached, next)
+			queue = append(queue, next)
+		}
+	}
+
+	sort.Strings(reached)
+	return reached, nil
+}
+
+// reachableSentinel 处理起点或终点为虚拟节点的可达性判断
+func (tg *TaskGraph) reachableSentinel(from, to string) (bool, error) {
+	for _, id := range []string{from, to} {
+		if !isSentinel(id) {
+			if _, err := tg.graph.Vertex(id); err != nil {
+				return false, fmt.Errorf("task %s not found", id)
+			}
+		}
+	}
+	if from == to {
+		return true, nil
+	}
+	return from == StartNode || to == EndNode, nil
+}
+
+// sortedIDs 对ID列表排序后返回
+func sortedIDs(ids []string) []string {
+	sort.Strings(ids)
+	return ids
+}
+
+// sortedKeys 返回边集合中的目标节点ID（按ID排序）
+func sortedKeys(edges map[string]graph.Edge[string]) []string {
+	keys := make([]string, 0, len(edges))
+	for k := range edges {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Ancestors 返回任务的全部祖先节点，等价于 GetTransitiveDependencies
+func (tg *TaskGraph) Ancestors(taskID string) ([]string, error) {
+	return tg.GetTransitiveDependencies(taskID)
+}
+
+// Descendants 返回任务的全部后代节点，等价于 GetTransitiveDependents
+func (tg *TaskGraph) Descendants(taskID string) ([]string, error) {
+	return tg.GetTransitiveDependents(taskID)
+}
+
+// Reachable 判断是否存在从 from 到 to 的有向路径，from 与 to 相同时返回 true；
+// 任意任务都可以从 StartNode 到达，并可以到达 EndNode
+func (tg *TaskGraph) Reachable(from, to string) (bool, error) {
+	if isSentinel(from) || isSentinel(to) {
+		return tg.reachableSentinel(from, to)
+	}
+	adjacency, err := tg.graph.AdjacencyMap()
+	if err != nil {
+		return false, fmt.Errorf("failed to get adjacency map: %v", err)
+	}
+	if _, ok := adjacency[from]; !ok {
+		return false, fmt.Errorf("task %s not found", from)
+	}
+	if _, ok := adjacency[to]; !ok {
+		return false, fmt.Errorf("task %s not found", to)
+	}
+
+	visited := map[string]bool{from: true}
+	stack := []string{from}
+	for len(stack) > 0 {
+		current := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if current == to {
+			return true, nil
+		}
+		for next := range adjacency[current] {
+			if !visited[next] {
+				visited[next] = true
+				stack = append(stack, next)
+			}
+		}
+	}
+	return false, nil
+}
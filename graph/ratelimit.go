@@ -0,0 +1,134 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"sync"
+	"time"
+)
+
+// RateLimit 是以令牌桶表示的调用配额，如第三方 API 每秒 100 次、突发 20 次的全局限制。
+// 同名的限制共享一个令牌桶，使用同一 RateStore 的所有进程合计不超过配额
+type RateLimit struct {
+	Name  string  `json:"name"`
+	Rate  float64 `json:"rate"`            // 每秒补充的令牌数
+	Burst int     `json:"burst,omitempty"` // 令牌桶容量，为0时为 Rate 向上取整（至少为1）
+}
+
+// burst 返回令牌桶的容量
+func (l RateLimit) burst() int {
+	if l.Burst > 0 {
+		return l.Burst
+	}
+	return int(math.Max(1, math.Ceil(l.Rate)))
+}
+
+func (l RateLimit) validate() error {
+	if l.Name == "" {
+		return fmt.Errorf("rate limit requires a name")
+	}
+	if l.Rate <= 0 || math.IsInf(l.Rate, 0) || math.IsNaN(l.Rate) {
+		return fmt.Errorf("rate of limit %s must be positive", l.Name)
+	}
+	if l.Burst < 0 {
+		return fmt.Errorf("burst of limit %s must not be negative", l.Name)
+	}
+	return nil
+}
+
+// RateStore 保存 RateLimit 的令牌桶。注册表可通过 WithRateStore 设置，
+// 运行记录的 Store 同时实现该接口时也会自动使用它，或通过 ExecuteOptions.Rates 指定；
+// 基于 Redis 的实现见 workflow/redis，共享同一 Redis 的所有工作进程合计遵守配额
+type RateStore interface {
+	// TakeToken 从名为 name、每秒补充 rate 个令牌、容量为 burst 的令牌桶取一个令牌。
+	// 取到时返回0，令牌不足时不取，返回预计可以取到令牌的等待时长
+	TakeToken(ctx context.Context, name string, rate float64, burst int) (time.Duration, error)
+}
+
+// processRates 是未指定 RateStore 时使用的进程内令牌桶，配额只在本进程内生效；MemoryStore 的令牌桶同样使用它
+var processRates = &memoryRates{buckets: make(map[string]*bucket)}
+
+// memoryRates 是进程内的 RateStore
+type memoryRates struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+// bucket 是进程内的一个令牌桶
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// TakeToken 实现 RateStore，与未指定 RateStore 的执行共享进程内的令牌桶
+func (s *MemoryStore) TakeToken(ctx context.Context, name string, rate float64, burst int) (time.Duration, error) {
+	return processRates.TakeToken(ctx, name, rate, burst)
+}
+
+// TakeToken 实现 RateStore
+func (s *memoryRates) TakeToken(ctx context.Context, name string, rate float64, burst int) (time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	b, ok := s.buckets[name]
+	if !ok {
+		b = &bucket{tokens: float64(burst), last: now}
+		s.buckets[name] = b
+	}
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = math.Min(float64(burst), b.tokens+elapsed.Seconds()*rate)
+		b.last = now
+	}
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0, nil
+	}
+	return time.Duration((1 - b.tokens) / rate * float64(time.Second)), nil
+}
+
+type rateStoreKey struct{}
+
+func withRateStore(ctx context.Context, store RateStore) context.Context {
+	return context.WithValue(ctx, rateStoreKey{}, store)
+}
+
+// rateStoreFrom 返回任务上下文中的 RateStore，不在任务中时返回进程内的令牌桶
+func rateStoreFrom(ctx context.Context) RateStore {
+	if store, ok := ctx.Value(rateStoreKey{}).(RateStore); ok {
+		return store
+	}
+	return processRates
+}
+
+// WaitRate 等待并取得配额 limit 的一个令牌，每次调用受配额限制的 API 前调用。
+// 在任务中使用执行的 RateStore，否则只在本进程内限流。Task.RateLimit 和
+// HTTPClientOptions.RateLimit 已自动调用它，任务内多次调用同一 API 时可直接使用
+func WaitRate(ctx context.Context, limit RateLimit) error {
+	if err := limit.validate(); err != nil {
+		return err
+	}
+	store := rateStoreFrom(ctx)
+	start := time.Now()
+	for {
+		wait, err := store.TakeToken(ctx, limit.Name, limit.Rate, limit.burst())
+		if err != nil {
+			return fmt.Errorf("failed to take token of rate limit %s: %v", limit.Name, err)
+		}
+		if wait <= 0 {
+			break
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+	if waited := time.Since(start); waited >= time.Millisecond {
+		LoggerFrom(ctx).Debug("waited for rate limit", slog.String("rate_limit", limit.Name), slog.Duration("waited", waited))
+	}
+	return nil
+}
@@ -0,0 +1,656 @@
+package graph
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// 记录读写任务类型的名称
+const (
+	TaskTypeRecordRead  = "record_read"
+	TaskTypeRecordWrite = "record_write"
+)
+
+// 记录格式的名称；parquet 没有内置实现，需要通过 RegisterRecordFormat 注册
+const (
+	RecordFormatCSV     = "csv"
+	RecordFormatJSONL   = "jsonl"
+	RecordFormatParquet = "parquet"
+)
+
+// RecordReader 逐条读取记录，读完时返回 io.EOF
+type RecordReader interface {
+	Read() (map[string]interface{}, error)
+	Close() error
+}
+
+// RecordCursor 是可以报告读取位置的 RecordReader：Offset 返回下一条记录在文件中的字节偏移，
+// 与 ResumableRecordFormat 配合，分块处理恢复时可以直接定位而不需要重新读取之前的记录
+type RecordCursor interface {
+	RecordReader
+	Offset() int64
+}
+
+// ResumableRecordFormat 是可以从字节偏移继续读取的 RecordFormat，offset 为 RecordCursor.Offset 的返回值
+type ResumableRecordFormat interface {
+	RecordFormat
+	NewReaderAt(r io.ReadSeeker, offset int64, options map[string]interface{}) (RecordReader, error)
+}
+
+// RecordWriter 逐条写入记录，Close 后写入的内容才完整
+type RecordWriter interface {
+	Write(record map[string]interface{}) error
+	Close() error
+}
+
+// RecordFormat 是一种记录文件格式。options 为任务配置中的 options，各格式自行解释。
+// 从文件读写时 r 和 w 是 *os.File，需要随机访问的格式（如 parquet 读取 footer）可以断言为 io.ReaderAt 和 io.Seeker
+type RecordFormat interface {
+	NewReader(r io.Reader, options map[string]interface{}) (RecordReader, error)
+	NewWriter(w io.Writer, options map[string]interface{}) (RecordWriter, error)
+}
+
+var (
+	recordFormatsMu sync.RWMutex
+	recordFormats   = map[string]RecordFormat{
+		RecordFormatCSV:   csvFormat{},
+		RecordFormatJSONL: jsonlFormat{},
+	}
+)
+
+// RegisterRecordFormat 注册记录格式，如基于 parquet 库的适配实现；同名格式已存在时返回错误
+func RegisterRecordFormat(name string, f RecordFormat) error {
+	recordFormatsMu.Lock()
+	defer recordFormatsMu.Unlock()
+	if name == "" {
+		return fmt.Errorf("record format name is required")
+	}
+	if _, ok := recordFormats[name]; ok {
+		return fmt.Errorf("record format %s already registered", name)
+	}
+	recordFormats[name] = f
+	return nil
+}
+
+// lookupRecordFormat 返回格式，format 为空时按文件扩展名推断
+func lookupRecordFormat(format, path string) (RecordFormat, string, error) {
+	if format == "" {
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".csv":
+			format = RecordFormatCSV
+		case ".jsonl", ".ndjson":
+			format = RecordFormatJSONL
+		case ".parquet":
+			format = RecordFormatParquet
+		default:
+			return nil, "", fmt.Errorf("cannot infer record format of %s", path)
+		}
+	}
+	recordFormatsMu.RLock()
+	defer recordFormatsMu.RUnlock()
+	f, ok := recordFormats[format]
+	if !ok {
+		return nil, "", fmt.Errorf("record format %s is not registered", format)
+	}
+	return f, format, nil
+}
+
+// OpenRecords 打开记录文件逐条读取，format 为空时按扩展名推断（.csv、.jsonl/.ndjson、.parquet）
+func OpenRecords(path, format string, options map[string]interface{}) (RecordReader, error) {
+	rf, _, err := lookupRecordFormat(format, path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	r, err := rf.NewReader(f, options)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	return &fileRecordReader{RecordReader: r, file: f}, nil
+}
+
+// openRecordsAt 从字节偏移 offset 打开记录文件，格式不支持按偏移读取时返回 ok 为 false
+func openRecordsAt(path, format string, options map[string]interface{}, offset int64) (r RecordReader, ok bool, err error) {
+	rf, _, err := lookupRecordFormat(format, path)
+	if err != nil {
+		return nil, false, err
+	}
+	resumable, ok := rf.(ResumableRecordFormat)
+	if !ok {
+		return nil, false, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, false, err
+	}
+	r, err = resumable.NewReaderAt(f, offset, options)
+	if err != nil {
+		f.Close()
+		return nil, false, fmt.Errorf("failed to read %s at offset %d: %v", path, offset, err)
+	}
+	return &fileRecordReader{RecordReader: r, file: f}, true, nil
+}
+
+type fileRecordReader struct {
+	RecordReader
+	file *os.File
+}
+
+// Offset 实现 RecordCursor，底层读取器不支持时返回 -1
+func (r *fileRecordReader) Offset() int64 {
+	if c, ok := r.RecordReader.(RecordCursor); ok {
+		return c.Offset()
+	}
+	return -1
+}
+
+func (r *fileRecordReader) Close() error {
+	err := r.RecordReader.Close()
+	if cerr := r.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// CreateRecords 创建记录文件逐条写入。内容先写入同目录下的临时文件，Close 成功后才重命名为 path，
+// 读取方不会看到写了一半的文件；Close 前出错时调用 Abort 丢弃临时文件
+func CreateRecords(path, format string, options map[string]interface{}) (*RecordFileWriter, error) {
+	rf, _, err := lookupRecordFormat(format, path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create directory for %s: %v", path, err)
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary file for %s: %v", path, err)
+	}
+	w, err := rf.NewWriter(f, options)
+	if err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+	return &RecordFileWriter{w: w, file: f, path: path}, nil
+}
+
+// RecordFileWriter 是 CreateRecords 返回的写入器
+type RecordFileWriter struct {
+	w     RecordWriter
+	file  *os.File
+	path  string
+	count int
+}
+
+// Write 写入一条记录
+func (w *RecordFileWriter) Write(record map[string]interface{}) error {
+	if err := w.w.Write(record); err != nil {
+		return fmt.Errorf("failed to write record %d to %s: %v", w.count, w.path, err)
+	}
+	w.count++
+	return nil
+}
+
+// Count 返回已写入的记录数
+func (w *RecordFileWriter) Count() int {
+	return w.count
+}
+
+// Close 完成写入并把临时文件重命名为目标文件
+func (w *RecordFileWriter) Close() error {
+	tmp := w.file.Name()
+	err := w.w.Close()
+	if cerr := w.file.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, w.path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write %s: %v", w.path, err)
+	}
+	return nil
+}
+
+// Abort 丢弃已写入的内容
+func (w *RecordFileWriter) Abort() {
+	w.w.Close()
+	w.file.Close()
+	os.Remove(w.file.Name())
+}
+
+// ReadRecords 逐条读取记录文件并调用 fn，不把整个文件读入内存；fn 返回错误或 ctx 取消时停止
+func ReadRecords(ctx context.Context, path, format string, options map[string]interface{}, fn func(record map[string]interface{}) error) error {
+	r, err := OpenRecords(path, format, options)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	for n := 0; ; n++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		record, err := r.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read record %d of %s: %v", n, path, err)
+		}
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+}
+
+// csvFormat 以首行作为列名，值都读作字符串。选项：
+//
+//	delimiter  分隔符，默认 ","
+//	columns    列名；读取时设置后把首行也作为数据，写入时决定列的顺序（默认为第一条记录的字段按名称排序）
+type csvFormat struct{}
+
+func csvOptions(options map[string]interface{}) (rune, []string, error) {
+	delim := ','
+	if s, ok := options["delimiter"].(string); ok {
+		r, size := utf8.DecodeRuneInString(s)
+		if size == 0 || size != len(s) {
+			return 0, nil, fmt.Errorf("csv delimiter must be a single character")
+		}
+		delim = r
+	}
+	var columns []string
+	if cols, ok := options["columns"].([]interface{}); ok {
+		for _, c := range cols {
+			columns = append(columns, fmt.Sprint(c))
+		}
+	}
+	return delim, columns, nil
+}
+
+func (csvFormat) NewReader(r io.Reader, options map[string]interface{}) (RecordReader, error) {
+	delim, columns, err := csvOptions(options)
+	if err != nil {
+		return nil, err
+	}
+	cr := newCSVReader(r, delim)
+	if columns == nil {
+		header, err := cr.Read()
+		if err == io.EOF {
+			return &csvReader{}, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read csv header: %v", err)
+		}
+		columns = append([]string(nil), header...)
+	}
+	cr.FieldsPerRecord = len(columns)
+	return &csvReader{r: cr, columns: columns}, nil
+}
+
+// NewReaderAt 实现 ResumableRecordFormat：先从文件开头读取表头，再从 offset 继续读取
+func (f csvFormat) NewReaderAt(r io.ReadSeeker, offset int64, options map[string]interface{}) (RecordReader, error) {
+	delim, columns, err := csvOptions(options)
+	if err != nil {
+		return nil, err
+	}
+	if columns == nil {
+		header, err := newCSVReader(r, delim).Read()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read csv header: %v", err)
+		}
+		columns = append([]string(nil), header...)
+	}
+	if _, err := r.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	cr := newCSVReader(r, delim)
+	cr.FieldsPerRecord = len(columns)
+	return &csvReader{r: cr, columns: columns, base: offset}, nil
+}
+
+func newCSVReader(r io.Reader, delim rune) *csv.Reader {
+	cr := csv.NewReader(bufio.NewReader(r))
+	cr.Comma = delim
+	cr.ReuseRecord = true
+	return cr
+}
+
+type csvReader struct {
+	r       *csv.Reader
+	columns []string
+	base    int64 // r 开始读取的位置
+}
+
+func (c *csvReader) Offset() int64 {
+	if c.r == nil {
+		return c.base
+	}
+	return c.base + c.r.InputOffset()
+}
+
+func (c *csvReader) Read() (map[string]interface{}, error) {
+	if c.r == nil {
+		return nil, io.EOF
+	}
+	row, err := c.r.Read()
+	if err != nil {
+		return nil, err
+	}
+	record := make(map[string]interface{}, len(row))
+	for i, v := range row {
+		record[c.columns[i]] = v
+	}
+	return record, nil
+}
+
+func (c *csvReader) Close() error {
+	return nil
+}
+
+func (csvFormat) NewWriter(w io.Writer, options map[string]interface{}) (RecordWriter, error) {
+	delim, columns, err := csvOptions(options)
+	if err != nil {
+		return nil, err
+	}
+	cw := csv.NewWriter(w)
+	cw.Comma = delim
+	return &csvWriter{w: cw, columns: columns}, nil
+}
+
+type csvWriter struct {
+	w       *csv.Writer
+	columns []string
+	header  bool
+}
+
+func (c *csvWriter) Write(record map[string]interface{}) error {
+	if c.columns == nil {
+		for k := range record {
+			c.columns = append(c.columns, k)
+		}
+		sort.Strings(c.columns)
+	}
+	if !c.header {
+		if err := c.w.Write(c.columns); err != nil {
+			return err
+		}
+		c.header = true
+	}
+	known := 0
+	row := make([]string, len(c.columns))
+	for i, col := range c.columns {
+		v, ok := record[col]
+		if !ok {
+			continue
+		}
+		known++
+		s, err := csvValue(v)
+		if err != nil {
+			return fmt.Errorf("field %s: %v", col, err)
+		}
+		row[i] = s
+	}
+	if known != len(record) {
+		for k := range record {
+			if !containsString(c.columns, k) {
+				return fmt.Errorf("field %s is not one of the csv columns", k)
+			}
+		}
+	}
+	return c.w.Write(row)
+}
+
+// csvValue 把字段值格式化为单元格：nil 为空，数字不使用科学计数法，对象和数组编码为 JSON
+func csvValue(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case bool, int, int64:
+		return fmt.Sprint(v), nil
+	}
+	b, err := json.Marshal(v)
+	return string(b), err
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *csvWriter) Close() error {
+	if !c.header && c.columns != nil {
+		// 没有记录时仍然写出表头
+		c.w.Write(c.columns)
+	}
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// jsonlFormat 每行一个 JSON 对象，空行会被跳过
+type jsonlFormat struct{}
+
+func (jsonlFormat) NewReader(r io.Reader, options map[string]interface{}) (RecordReader, error) {
+	return &jsonlReader{d: json.NewDecoder(bufio.NewReader(r))}, nil
+}
+
+// NewReaderAt 实现 ResumableRecordFormat
+func (jsonlFormat) NewReaderAt(r io.ReadSeeker, offset int64, options map[string]interface{}) (RecordReader, error) {
+	if _, err := r.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return &jsonlReader{d: json.NewDecoder(bufio.NewReader(r)), base: offset}, nil
+}
+
+type jsonlReader struct {
+	d    *json.Decoder
+	base int64
+}
+
+func (j *jsonlReader) Offset() int64 {
+	return j.base + j.d.InputOffset()
+}
+
+func (j *jsonlReader) Read() (map[string]interface{}, error) {
+	var record map[string]interface{}
+	if err := j.d.Decode(&record); err != nil {
+		return nil, err
+	}
+	if record == nil {
+		return nil, fmt.Errorf("record is not a json object")
+	}
+	return record, nil
+}
+
+func (j *jsonlReader) Close() error {
+	return nil
+}
+
+func (jsonlFormat) NewWriter(w io.Writer, options map[string]interface{}) (RecordWriter, error) {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	enc.SetEscapeHTML(false)
+	return &jsonlWriter{w: bw, enc: enc}, nil
+}
+
+type jsonlWriter struct {
+	w   *bufio.Writer
+	enc *json.Encoder
+}
+
+func (j *jsonlWriter) Write(record map[string]interface{}) error {
+	return j.enc.Encode(record)
+}
+
+func (j *jsonlWriter) Close() error {
+	return j.w.Flush()
+}
+
+type recordReadTaskType struct {
+	root string
+}
+
+func (recordReadTaskType) Name() string {
+	return TaskTypeRecordRead
+}
+
+func (recordReadTaskType) ConfigSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type":     "object",
+		"required": []string{"path"},
+		"properties": map[string]interface{}{
+			"path":    map[string]interface{}{"type": "string"},
+			"format":  map[string]interface{}{"type": "string"},
+			"options": map[string]interface{}{"type": "object"},
+			"limit":   map[string]interface{}{"type": "integer"},
+		},
+		"additionalProperties": false,
+	}
+}
+
+// New 创建读取记录文件的任务函数，结果为记录列表；limit 大于0时超过该条数会使任务失败，避免把过大的文件读入内存
+func (t recordReadTaskType) New(config map[string]interface{}) (func(ctx context.Context, inputs map[string]interface{}) (interface{}, error), error) {
+	path, err := ParsePayloadTemplate(config["path"].(string))
+	if err != nil {
+		return nil, fmt.Errorf("invalid path template: %v", err)
+	}
+	format, _ := config["format"].(string)
+	options, _ := config["options"].(map[string]interface{})
+	limit, _ := toFloat(config["limit"])
+	return func(ctx context.Context, inputs map[string]interface{}) (interface{}, error) {
+		p, err := renderPath(ctx, t.root, path, inputs)
+		if err != nil {
+			return nil, err
+		}
+		records := []interface{}{}
+		err = ReadRecords(ctx, p, format, options, func(record map[string]interface{}) error {
+			if limit > 0 && len(records) >= int(limit) {
+				return fmt.Errorf("%s has more than %d records", p, int(limit))
+			}
+			records = append(records, record)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		Annotate(ctx, "records", len(records))
+		return records, nil
+	}, nil
+}
+
+type recordWriteTaskType struct {
+	root string
+}
+
+func (recordWriteTaskType) Name() string {
+	return TaskTypeRecordWrite
+}
+
+func (recordWriteTaskType) ConfigSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type":     "object",
+		"required": []string{"path"},
+		"properties": map[string]interface{}{
+			"path":    map[string]interface{}{"type": "string"},
+			"format":  map[string]interface{}{"type": "string"},
+			"options": map[string]interface{}{"type": "object"},
+			"input":   map[string]interface{}{"type": "string"},
+		},
+		"additionalProperties": false,
+	}
+}
+
+// New 创建把记录列表写入文件的任务函数：记录取自名为 input 的输入，未设置时任务必须只有一个输入。
+// 输入可以是记录列表或 *FanOutResult（写入所有成功项），结果为 {"path": ..., "records": n}
+func (t recordWriteTaskType) New(config map[string]interface{}) (func(ctx context.Context, inputs map[string]interface{}) (interface{}, error), error) {
+	path, err := ParsePayloadTemplate(config["path"].(string))
+	if err != nil {
+		return nil, fmt.Errorf("invalid path template: %v", err)
+	}
+	format, _ := config["format"].(string)
+	options, _ := config["options"].(map[string]interface{})
+	input, _ := config["input"].(string)
+	return func(ctx context.Context, inputs map[string]interface{}) (interface{}, error) {
+		records, err := recordsInput(inputs, input)
+		if err != nil {
+			return nil, err
+		}
+		p, err := renderPath(ctx, t.root, path, inputs)
+		if err != nil {
+			return nil, err
+		}
+		w, err := CreateRecords(p, format, options)
+		if err != nil {
+			return nil, err
+		}
+		for i, v := range records {
+			record, ok := v.(map[string]interface{})
+			if !ok {
+				w.Abort()
+				return nil, fmt.Errorf("record %d is %T, not an object", i, v)
+			}
+			if err := w.Write(record); err != nil {
+				w.Abort()
+				return nil, err
+			}
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		Annotate(ctx, "records", w.Count())
+		return map[string]interface{}{"path": p, "records": w.Count()}, nil
+	}, nil
+}
+
+// recordsInput 取出要写入的记录
+func recordsInput(inputs map[string]interface{}, name string) ([]interface{}, error) {
+	if name == "" {
+		if len(inputs) != 1 {
+			return nil, fmt.Errorf("input must be set when the task has %d inputs", len(inputs))
+		}
+		for k := range inputs {
+			name = k
+		}
+	}
+	v, ok := inputs[name]
+	if !ok {
+		return nil, fmt.Errorf("input %s not found", name)
+	}
+	switch v := v.(type) {
+	case []interface{}:
+		return v, nil
+	case []map[string]interface{}:
+		records := make([]interface{}, len(v))
+		for i, r := range v {
+			records[i] = r
+		}
+		return records, nil
+	case *FanOutResult:
+		return GatherFanOut(map[string]interface{}{name: v}).Values(), nil
+	}
+	return nil, fmt.Errorf("input %s is %T, not a list of records", name, v)
+}
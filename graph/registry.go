@@ -0,0 +1,655 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+)
+
+// WorkflowDefinition 表示注册表中某个工作流的一个版本
+type WorkflowDefinition struct {
+	Namespace    string
+	Name         string
+	Version      int
+	Graph        *TaskGraph
+	Fingerprint  string
+	RegisteredAt time.Time
+	// Revision 是定义来源的版本，如从 git 同步时的提交 SHA，会记录在每次运行中
+	Revision string
+}
+
+// RegisterOption 定义注册工作流版本时的选项
+type RegisterOption func(*WorkflowDefinition)
+
+// WithRevision 记录定义来源的版本，如 git 提交 SHA
+func WithRevision(revision string) RegisterOption {
+	return func(def *WorkflowDefinition) {
+		def.Revision = revision
+	}
+}
+
+// workflowKey 在注册表中唯一标识一个工作流
+type workflowKey struct {
+	namespace string
+	name      string
+}
+
+// Registry 按命名空间、名称和版本管理工作流定义。
+// 新的执行总是使用最新版本，执行中的运行固定在其开始时的版本上，
+// 因此可以在长时间运行的工作流执行期间安全地发布新版本。
+// 不同命名空间的工作流和运行记录相互隔离，通过 Namespace 获取指定命名空间的视图
+type Registry struct {
+	mu            sync.RWMutex
+	workflows     map[workflowKey][]*WorkflowDefinition
+	inFlight      map[workflowKey]map[int]int // 每个版本正在执行的运行数
+	dedupe        map[dedupeKey]*dedupeRun    // 按去重键登记的执行中和最近成功的运行
+	store         Store
+	scheduler     *Scheduler // 为空时运行之间不共享名额
+	admission     *AdmissionController
+	services      *Services           // 运行未指定 Services 时使用
+	lineage       *OpenLineageEmitter // 为空时不发送 OpenLineage 事件
+	codec         string              // 运行未指定 Codec 时使用
+	spill         *SpillOptions       // 运行未指定 Spill 时使用
+	profileLabels bool                // 为 true 时所有运行设置 pprof 标签
+	eventLog      *EventLog           // 运行未指定 EventLog 时使用
+	rates         RateStore           // 运行未指定 Rates 时使用
+	locks         LockStore           // 运行未指定 Locks 时使用
+	// 运行未指定 MaxResultBytes 时使用，memoryAccounting 为 true 时所有运行开启内存统计
+	memoryAccounting bool
+	maxResultBytes   int64
+	// 运行未指定 WorkerCount 和 Executors 时使用的默认值
+	workerCount int
+	executors   map[string]int
+	// 运行未指定 DefaultTimeout 和 DefaultRetries 时使用的默认值
+	taskTimeout time.Duration
+	taskRetries int
+}
+
+// RegistryOption 定义注册表的构造选项
+type RegistryOption func(*Registry)
+
+// WithStore 设置保存运行记录的 Store
+func WithStore(store Store) RegistryOption {
+	return func(r *Registry) {
+		r.store = store
+	}
+}
+
+// WithServices 设置注册表执行工作流时注入任务上下文的服务集合，
+// 只作用于执行选项中未指定 Services 的运行
+func WithServices(services *Services) RegistryOption {
+	return func(r *Registry) {
+		r.services = services
+	}
+}
+
+// WithCodec 设置注册表执行工作流时任务输出默认的编码，只作用于执行选项中未指定 Codec 的运行
+func WithCodec(name string) RegistryOption {
+	return func(r *Registry) {
+		r.codec = name
+	}
+}
+
+// WithSpill 设置注册表执行工作流时大结果的溢出方式，只作用于执行选项中未指定 Spill 的运行
+func WithSpill(opts *SpillOptions) RegistryOption {
+	return func(r *Registry) {
+		r.spill = opts
+	}
+}
+
+// WithMemoryAccounting 为注册表执行的所有运行开启结果内存统计，maxResultBytes 大于0时作为
+// 执行选项中未指定 MaxResultBytes 的运行的上限
+func WithMemoryAccounting(maxResultBytes int64) RegistryOption {
+	return func(r *Registry) {
+		r.memoryAccounting = true
+		r.maxResultBytes = maxResultBytes
+	}
+}
+
+// WithEventLog 设置注册表执行的运行默认写入的事件日志
+func WithEventLog(log *EventLog) RegistryOption {
+	return func(r *Registry) {
+		r.eventLog = log
+	}
+}
+
+// WithRateStore 设置注册表执行的运行默认使用的令牌桶，多个进程使用同一 RateStore 时
+// Task.RateLimit 等配额由所有进程合计遵守；只作用于执行选项中未指定 Rates 的运行
+func WithRateStore(store RateStore) RegistryOption {
+	return func(r *Registry) {
+		r.rates = store
+	}
+}
+
+// WithLockStore 设置注册表执行的运行默认使用的互斥锁，多个进程使用同一 LockStore 时
+// 同名 Task.Mutex 的任务在所有进程之间互斥；只作用于执行选项中未指定 Locks 的运行，优先于 Store 实现的 LockStore
+func WithLockStore(store LockStore) RegistryOption {
+	return func(r *Registry) {
+		r.locks = store
+	}
+}
+
+// WithProfileLabels 为注册表执行的所有运行的任务设置 pprof 标签，见 ExecuteOptions.ProfileLabels
+func WithProfileLabels() RegistryOption {
+	return func(r *Registry) {
+		r.profileLabels = true
+	}
+}
+
+// WithOpenLineage 设置注册表执行工作流时发送 OpenLineage 事件的发送器
+func WithOpenLineage(emitter *OpenLineageEmitter) RegistryOption {
+	return func(r *Registry) {
+		r.lineage = emitter
+	}
+}
+
+// WithWorkerDefaults 设置注册表执行工作流时默认的并发数和命名执行器，
+// 只作用于执行选项中未指定 WorkerCount 或 Executors 的运行
+func WithWorkerDefaults(workerCount int, executors map[string]int) RegistryOption {
+	return func(r *Registry) {
+		r.workerCount = workerCount
+		r.executors = executors
+	}
+}
+
+// WithTaskDefaults 设置注册表执行工作流时任务默认的超时和重试次数，
+// 只作用于执行选项中未指定 DefaultTimeout 或 DefaultRetries 的运行
+func WithTaskDefaults(timeout time.Duration, retries int) RegistryOption {
+	return func(r *Registry) {
+		r.taskTimeout = timeout
+		r.taskRetries = retries
+	}
+}
+
+// NewRegistry 创建空的工作流注册表
+func NewRegistry(opts ...RegistryOption) *Registry {
+	r := &Registry{
+		workflows: make(map[workflowKey][]*WorkflowDefinition),
+		inFlight:  make(map[workflowKey]map[int]int),
+		dedupe:    make(map[dedupeKey]*dedupeRun),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Namespace 返回注册表在指定命名空间下的视图
+func (r *Registry) Namespace(namespace string) *NamespaceRegistry {
+	if namespace == "" {
+		namespace = DefaultNamespace
+	}
+	return &NamespaceRegistry{registry: r, namespace: namespace}
+}
+
+// Namespaces 返回所有已注册工作流的命名空间（按名称排序）
+func (r *Registry) Namespaces() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	seen := make(map[string]bool)
+	var namespaces []string
+	for key := range r.workflows {
+		if !seen[key.namespace] {
+			seen[key.namespace] = true
+			namespaces = append(namespaces, key.namespace)
+		}
+	}
+	sort.Strings(namespaces)
+	return namespaces
+}
+
+// Register 在默认命名空间中注册工作流的新版本
+func (r *Registry) Register(name string, tg *TaskGraph, opts ...RegisterOption) (*WorkflowDefinition, error) {
+	return r.Namespace(DefaultNamespace).Register(name, tg, opts...)
+}
+
+// Latest 返回默认命名空间中工作流的最新版本
+func (r *Registry) Latest(name string) (*WorkflowDefinition, error) {
+	return r.Namespace(DefaultNamespace).Latest(name)
+}
+
+// Get 返回默认命名空间中工作流的指定版本
+func (r *Registry) Get(name string, version int) (*WorkflowDefinition, error) {
+	return r.Namespace(DefaultNamespace).Get(name, version)
+}
+
+// Names 返回默认命名空间中所有已注册的工作流名称
+func (r *Registry) Names() []string {
+	return r.Namespace(DefaultNamespace).Names()
+}
+
+// Versions 返回默认命名空间中工作流仍保留的所有版本号
+func (r *Registry) Versions(name string) []int {
+	return r.Namespace(DefaultNamespace).Versions(name)
+}
+
+// InFlight 返回默认命名空间中工作流指定版本正在执行的运行数
+func (r *Registry) InFlight(name string, version int) int {
+	return r.Namespace(DefaultNamespace).InFlight(name, version)
+}
+
+// Prune 删除默认命名空间中工作流已没有执行中运行的旧版本
+func (r *Registry) Prune(name string) []int {
+	return r.Namespace(DefaultNamespace).Prune(name)
+}
+
+// Start 使用默认命名空间中工作流的最新版本开始一次执行
+func (r *Registry) Start(ctx context.Context, name string, opts ExecuteOptions, extra ...ExecuteOption) (*ExecutionReport, error) {
+	return r.Namespace(DefaultNamespace).Start(ctx, name, opts, extra...)
+}
+
+// StartVersion 使用默认命名空间中工作流的指定版本开始一次执行
+func (r *Registry) StartVersion(ctx context.Context, name string, version int, opts ExecuteOptions, extra ...ExecuteOption) (*ExecutionReport, error) {
+	return r.Namespace(DefaultNamespace).StartVersion(ctx, name, version, opts, extra...)
+}
+
+// NamespaceRegistry 是注册表在单个命名空间下的视图，只能访问该命名空间的工作流和运行记录
+type NamespaceRegistry struct {
+	registry  *Registry
+	namespace string
+}
+
+func (n *NamespaceRegistry) key(name string) workflowKey {
+	return workflowKey{namespace: n.namespace, name: name}
+}
+
+// Name 返回命名空间名称
+func (n *NamespaceRegistry) Name() string {
+	return n.namespace
+}
+
+// Register 注册工作流的新版本并返回该版本的定义；
+// 若任务图与最新版本的 Fingerprint 相同，则直接返回最新版本而不新增版本（也不更新其 Revision）
+func (n *NamespaceRegistry) Register(name string, tg *TaskGraph, opts ...RegisterOption) (*WorkflowDefinition, error) {
+	if name == "" {
+		return nil, fmt.Errorf("workflow name is required")
+	}
+	fingerprint, err := tg.Fingerprint()
+	if err != nil {
+		return nil, fmt.Errorf("failed to fingerprint workflow %s: %v", name, err)
+	}
+
+	r := n.registry
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := n.key(name)
+	versions := r.workflows[key]
+	if last := len(versions) - 1; last >= 0 && versions[last].Fingerprint == fingerprint {
+		return versions[last], nil
+	}
+
+	version := 1
+	if last := len(versions) - 1; last >= 0 {
+		version = versions[last].Version + 1
+	}
+	def := &WorkflowDefinition{
+		Namespace:    n.namespace,
+		Name:         name,
+		Version:      version,
+		Graph:        tg,
+		Fingerprint:  fingerprint,
+		RegisteredAt: time.Now(),
+	}
+	for _, opt := range opts {
+		opt(def)
+	}
+	r.workflows[key] = append(versions, def)
+	return def, nil
+}
+
+// Latest 返回工作流的最新版本
+func (n *NamespaceRegistry) Latest(name string) (*WorkflowDefinition, error) {
+	r := n.registry
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	versions := r.workflows[n.key(name)]
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("workflow %s not found in namespace %s", name, n.namespace)
+	}
+	return versions[len(versions)-1], nil
+}
+
+// Get 返回工作流的指定版本
+func (n *NamespaceRegistry) Get(name string, version int) (*WorkflowDefinition, error) {
+	r := n.registry
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, def := range r.workflows[n.key(name)] {
+		if def.Version == version {
+			return def, nil
+		}
+	}
+	return nil, fmt.Errorf("workflow %s version %d not found in namespace %s", name, version, n.namespace)
+}
+
+// Names 返回命名空间中所有已注册的工作流名称（按名称排序）
+func (n *NamespaceRegistry) Names() []string {
+	r := n.registry
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var names []string
+	for key := range r.workflows {
+		if key.namespace == n.namespace {
+			names = append(names, key.name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Versions 返回工作流仍保留的所有版本号（升序）
+func (n *NamespaceRegistry) Versions(name string) []int {
+	r := n.registry
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	defs := r.workflows[n.key(name)]
+	versions := make([]int, 0, len(defs))
+	for _, def := range defs {
+		versions = append(versions, def.Version)
+	}
+	return versions
+}
+
+// InFlight 返回工作流指定版本正在执行的运行数
+func (n *NamespaceRegistry) InFlight(name string, version int) int {
+	r := n.registry
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.inFlight[n.key(name)][version]
+}
+
+// Prune 删除除最新版本外、已没有执行中运行的旧版本，返回被删除的版本号
+func (n *NamespaceRegistry) Prune(name string) []int {
+	r := n.registry
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := n.key(name)
+	versions := r.workflows[key]
+	if len(versions) <= 1 {
+		return nil
+	}
+
+	var pruned []int
+	kept := make([]*WorkflowDefinition, 0, len(versions))
+	for i, def := range versions {
+		if i < len(versions)-1 && r.inFlight[key][def.Version] == 0 {
+			pruned = append(pruned, def.Version)
+			continue
+		}
+		kept = append(kept, def)
+	}
+	r.workflows[key] = kept
+	return pruned
+}
+
+// Start 使用工作流的最新版本开始一次执行，执行期间固定使用该版本
+func (n *NamespaceRegistry) Start(ctx context.Context, name string, opts ExecuteOptions, extra ...ExecuteOption) (*ExecutionReport, error) {
+	def, err := n.Latest(name)
+	if err != nil {
+		return nil, err
+	}
+	return n.run(ctx, def, opts, nil, extra...)
+}
+
+// StartVersion 使用工作流的指定版本开始一次执行
+func (n *NamespaceRegistry) StartVersion(ctx context.Context, name string, version int, opts ExecuteOptions, extra ...ExecuteOption) (*ExecutionReport, error) {
+	def, err := n.Get(name, version)
+	if err != nil {
+		return nil, err
+	}
+	return n.run(ctx, def, opts, nil, extra...)
+}
+
+// GetRun 返回命名空间内的运行记录，注册表未配置 Store 时返回错误
+func (n *NamespaceRegistry) GetRun(ctx context.Context, runID string) (*RunRecord, error) {
+	if n.registry.store == nil {
+		return nil, fmt.Errorf("registry has no store configured")
+	}
+	return n.registry.store.GetRun(ctx, n.namespace, runID)
+}
+
+// ListRuns 返回命名空间内符合条件的运行记录，注册表未配置 Store 时返回错误
+func (n *NamespaceRegistry) ListRuns(ctx context.Context, filter RunFilter) ([]*RunRecord, error) {
+	if n.registry.store == nil {
+		return nil, fmt.Errorf("registry has no store configured")
+	}
+	return n.registry.store.ListRuns(ctx, n.namespace, filter)
+}
+
+// run 执行指定版本的定义，并在执行期间登记为执行中；配置了 Store 时保存运行记录，
+// Store 支持死信时失败的运行进入死信队列。origin 是重新执行时的原始死信
+func (n *NamespaceRegistry) run(ctx context.Context, def *WorkflowDefinition, opts ExecuteOptions, origin *DeadLetter, extra ...ExecuteOption) (*ExecutionReport, error) {
+	r := n.registry
+	key := n.key(def.Name)
+	for _, opt := range extra {
+		opt(&opts)
+	}
+	if opts.RunID == "" {
+		opts.RunID = NewRunID()
+	}
+	if opts.Dedupe != nil && opts.Dedupe.Key != "" {
+		return n.runDeduped(ctx, def, opts, origin)
+	}
+
+	r.mu.Lock()
+	if r.inFlight[key] == nil {
+		r.inFlight[key] = make(map[int]int)
+	}
+	r.inFlight[key][def.Version]++
+	r.mu.Unlock()
+
+	defer func() {
+		r.mu.Lock()
+		r.inFlight[key][def.Version]--
+		r.mu.Unlock()
+	}()
+
+	if err := applyLogicalTime(&opts); err != nil {
+		return nil, fmt.Errorf("run %s of %s: %v", opts.RunID, def.Name, err)
+	}
+	if opts.Scheduler == nil {
+		opts.Scheduler = r.scheduler
+	}
+	if opts.WorkerCount <= 0 {
+		opts.WorkerCount = r.workerCount
+	}
+	if opts.Executors == nil {
+		opts.Executors = r.executors
+	}
+	if opts.Codec == "" {
+		opts.Codec = r.codec
+	}
+	if opts.Spill == nil {
+		opts.Spill = r.spill
+	}
+	if opts.EventLog == nil {
+		opts.EventLog = r.eventLog
+	}
+	if r.profileLabels {
+		opts.ProfileLabels = true
+	}
+	if r.memoryAccounting {
+		opts.MemoryAccounting = true
+		if opts.MaxResultBytes == 0 {
+			opts.MaxResultBytes = r.maxResultBytes
+		}
+	}
+	if opts.Services == nil {
+		opts.Services = r.services
+	}
+	if opts.DefaultTimeout <= 0 {
+		opts.DefaultTimeout = r.taskTimeout
+	}
+	if opts.DefaultRetries <= 0 {
+		opts.DefaultRetries = r.taskRetries
+	}
+	// 准入在写入运行记录之前进行，被拒绝的运行不留下记录
+	if opts.Admission == nil {
+		opts.Admission = r.admission
+	}
+	release, err := opts.Admission.Admit(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("run %s of %s not admitted: %w", opts.RunID, def.Name, err)
+	}
+	defer release()
+	opts.Admission = nil
+
+	record := &RunRecord{
+		Namespace:       n.namespace,
+		RunID:           opts.RunID,
+		Workflow:        def.Name,
+		WorkflowVersion: def.Version,
+		Revision:        def.Revision,
+		Status:          RunStatusRunning,
+		Params:          opts.Params,
+		LogicalTime:     opts.LogicalTime,
+		DedupeKey:       opts.dedupeKey,
+		StartTime:       time.Now(),
+	}
+	if r.store != nil {
+		if err := r.store.SaveRun(ctx, record); err != nil {
+			return nil, fmt.Errorf("failed to save run %s: %v", record.RunID, err)
+		}
+	}
+
+	// 记录触发和状态变化的审计日志，触发记录写入失败时不开始执行
+	trigger := AuditEntry{
+		RunID:    record.RunID,
+		Workflow: def.Name,
+		Actor:    ActorFrom(ctx),
+		Action:   AuditRunTriggered,
+		To:       string(RunStatusRunning),
+		Detail:   fmt.Sprintf("version %d", def.Version),
+	}
+	if origin != nil {
+		trigger.Action = AuditRunRedriven
+		trigger.Detail = fmt.Sprintf("version %d, redrive of %s", def.Version, origin.RunID)
+	} else if opts.reuse != nil {
+		trigger.Action = AuditRunRetried
+		trigger.Detail = fmt.Sprintf("version %d, retry of failed tasks", def.Version)
+	}
+	if err := n.audit(ctx, trigger); err != nil {
+		return nil, err
+	}
+	// 新运行已经记录后才删除原死信，之前的任何失败都不会丢失死信；
+	// 删除失败说明死信已被其他重新执行取走，本次运行直接以失败结束
+	if origin != nil {
+		if err := n.claimDeadLetter(ctx, record, origin); err != nil {
+			return nil, err
+		}
+	}
+	if _, ok := r.store.(AuditStore); ok {
+		auditCtx := context.WithoutCancel(ctx)
+		logger := opts.Logger
+		if logger == nil {
+			logger = slog.Default()
+		}
+		userHook := opts.OnTaskTransition
+		opts.OnTaskTransition = func(tr TaskReport, from TaskStatus) {
+			entry := AuditEntry{
+				RunID:    record.RunID,
+				Workflow: def.Name,
+				Action:   AuditTaskStatus,
+				TaskID:   tr.ID,
+				From:     string(from),
+				To:       string(tr.Status),
+				Detail:   tr.SkipReason,
+			}
+			if tr.Error != nil {
+				entry.Detail = tr.Error.Error()
+			}
+			if err := n.audit(auditCtx, entry); err != nil {
+				logger.Warn("audit failed", slog.String("run_id", record.RunID), slog.String("task_id", tr.ID), slog.Any("error", err))
+			}
+			if userHook != nil {
+				userHook(tr, from)
+			}
+		}
+	}
+
+	var lineage *openLineageRun
+	if r.lineage != nil {
+		lineage = r.lineage.run(n.namespace, def.Name, record.RunID, def.Graph)
+		userHook := opts.OnTaskTransition
+		opts.OnTaskTransition = func(tr TaskReport, from TaskStatus) {
+			lineage.taskTransition(tr, from)
+			if userHook != nil {
+				userHook(tr, from)
+			}
+		}
+		lineage.runEvent(OpenLineageStart, record.StartTime, nil)
+	}
+
+	if cs, ok := r.store.(CheckpointStore); ok && opts.Checkpoints == nil {
+		opts.Checkpoints = cs
+	}
+	if es, ok := r.store.(EffectStore); ok && opts.Effects == nil {
+		opts.Effects = es
+	}
+	if opts.Locks == nil {
+		opts.Locks = r.locks
+	}
+	if ls, ok := r.store.(LockStore); ok && opts.Locks == nil {
+		opts.Locks = ls
+	}
+	if opts.Rates == nil {
+		opts.Rates = r.rates
+	}
+	if rs, ok := r.store.(RateStore); ok && opts.Rates == nil {
+		opts.Rates = rs
+	}
+	report, err := def.Graph.ExecuteWithReport(ctx, opts)
+	if lineage != nil {
+		if err != nil {
+			lineage.runEvent(OpenLineageFail, time.Now(), err)
+		} else {
+			lineage.runEvent(OpenLineageComplete, time.Now(), nil)
+		}
+	}
+	if report != nil {
+		report.Namespace = n.namespace
+		report.Workflow = def.Name
+		report.WorkflowVersion = def.Version
+	}
+
+	if r.store != nil {
+		record.EndTime = time.Now()
+		record.Report = report
+		record.Status = RunStatusCompleted
+		if err != nil {
+			record.Status = RunStatusFailed
+			record.Error = err.Error()
+		}
+		// 使用独立的上下文保存最终状态，避免调用方取消后记录停留在 running
+		saveCtx := context.WithoutCancel(ctx)
+		if serr := r.store.SaveRun(saveCtx, record); serr != nil && err == nil {
+			err = fmt.Errorf("failed to save run %s: %v", record.RunID, serr)
+		}
+		if dls, ok := r.store.(DeadLetterStore); ok && record.Status == RunStatusFailed {
+			if derr := dls.SaveDeadLetter(saveCtx, newDeadLetter(record, origin)); derr != nil {
+				err = fmt.Errorf("%w (failed to save dead letter: %v)", err, derr)
+			}
+		}
+		if aerr := n.audit(saveCtx, AuditEntry{
+			RunID:    record.RunID,
+			Workflow: def.Name,
+			Action:   AuditRunStatus,
+			From:     string(RunStatusRunning),
+			To:       string(record.Status),
+			Detail:   record.Error,
+		}); aerr != nil && err == nil {
+			err = aerr
+		}
+	}
+	return report, err
+}
@@ -0,0 +1,289 @@
+package graph
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// 任务被跳过的原因
+const (
+	SkipReasonCondition = "condition"
+	SkipReasonBudget    = "budget"
+	SkipReasonDegraded  = "degraded"
+	SkipReasonDeadline  = "deadline"
+)
+
+// TaskAttempt 记录任务的一次执行尝试
+type TaskAttempt struct {
+	Attempt   int // 从1开始的尝试序号
+	StartTime time.Time
+	EndTime   time.Time
+	Duration  time.Duration
+	Worker    string // 执行该次尝试的工作者，格式为 "执行器/名额编号"
+	Error     error  // 尝试成功时为 nil
+}
+
+// TaskReport 记录单个任务的执行情况
+type TaskReport struct {
+	ID          string
+	Status      TaskStatus
+	ReadyTime   time.Time // 依赖全部结束、任务开始等待名额的时间
+	StartTime   time.Time
+	EndTime     time.Time
+	QueueWait   time.Duration      // 从就绪到开始执行等待执行器和全局名额的时间，即调度造成的延迟
+	Duration    time.Duration      // 从开始执行到结束的时间（包括重试），即处理函数的耗时
+	Attempts    int                // 实际执行次数（包括重试）
+	History     []TaskAttempt      // 按顺序排列的每次尝试，Precheck 失败或未执行时为空
+	Cost        float64            // 任务通过 ReportCost 上报的成本
+	Costs       map[string]float64 // 任务通过 ReportCostIn 按单位（如 "usd"）上报的成本
+	SkipReason  string             // 任务被跳过的原因，如 SkipReasonCondition
+	Reused      bool               // 结果复用自之前的执行（RetryFailed），本次没有执行
+	Error       error
+	Attributes  map[string]interface{} // 任务通过 Annotate 附加的自定义属性
+	Lineage     *TaskLineage           // 任务完成时的数据血缘，未开启 ExecuteOptions.Lineage 时为空
+	ResultBytes int64                  // 结果的估算字节数（溢出的结果只计句柄），未开启内存统计时为0
+	Usage       *ResourceUsage         // 任务的资源使用，未开启 ExecuteOptions.ResourceUsage 时为空
+}
+
+// ExecutionReport 记录一次任务图执行的整体情况
+type ExecutionReport struct {
+	RunID           string
+	CorrelationID   string
+	Fingerprint     string // 执行时任务图的 Fingerprint
+	Namespace       string // 通过 Registry 执行时的命名空间
+	Workflow        string // 通过 Registry 执行时的工作流名称
+	WorkflowVersion int    // 通过 Registry 执行时固定的工作流版本
+	StartTime       time.Time
+	EndTime         time.Time
+	Duration        time.Duration
+	Results         map[string]interface{}
+	Sensitive       map[string][]string // 任务ID -> 通过 Task.Sensitive 标记的敏感字段路径
+	Codecs          map[string]string   // 任务ID -> 输出的编码名称，未列出的任务使用 JSON
+	ResultBytes     int64               // 任务结果的估算字节数之和，未开启内存统计时为0
+	Tasks           map[string]*TaskReport
+	Error           error
+
+	// Cost 和 Costs 是本次执行的任务上报的成本之和，复用自之前执行的任务不计入
+	Cost  float64
+	Costs map[string]float64
+	// CostByTag 按任务标签汇总成本，带多个标签的任务计入每个标签，没有带标签的任务上报成本时为空
+	CostByTag map[string]CostSummary
+}
+
+// reportRecorder 在执行过程中并发安全地收集任务报告
+type reportRecorder struct {
+	mu        sync.Mutex
+	report    *ExecutionReport
+	onTaskEnd func(tr TaskReport) // 任务进入结束状态后调用，为空时不通知
+	// onTransition 在任务状态变化后调用，为空时不通知
+	onTransition func(tr TaskReport, from TaskStatus)
+	// onAttempt 在任务每次尝试结束后调用，为空时不通知
+	onAttempt func(taskID string, attempt TaskAttempt)
+	// onFinish 在 finish 生成最终报告后在锁外调用，为空时不通知
+	onFinish func(report *ExecutionReport)
+	// tags 是任务ID -> 标签，finish 时用于按标签汇总成本
+	tags map[string][]string
+}
+
+func newReportRecorder(runID, correlationID string) *reportRecorder {
+	return &reportRecorder{
+		report: &ExecutionReport{
+			RunID:         runID,
+			CorrelationID: correlationID,
+			StartTime:     time.Now(),
+			Tasks:         make(map[string]*TaskReport),
+		},
+	}
+}
+
+// update 在锁保护下修改指定任务的报告，报告不存在时先创建；
+// 状态变化时在锁外以报告副本调用 onTransition，进入结束状态时再调用 onTaskEnd
+func (r *reportRecorder) update(taskID string, fn func(tr *TaskReport)) {
+	r.mu.Lock()
+	tr, ok := r.report.Tasks[taskID]
+	if !ok {
+		tr = &TaskReport{ID: taskID, Status: TaskStatusPending}
+		r.report.Tasks[taskID] = tr
+	}
+	from := tr.Status
+	fn(tr)
+	if tr.Status == from {
+		r.mu.Unlock()
+		return
+	}
+	snapshot := *tr
+	r.mu.Unlock()
+
+	if r.onTransition != nil {
+		r.onTransition(snapshot, from)
+	}
+	if r.onTaskEnd != nil && snapshot.Status.terminal() {
+		r.onTaskEnd(snapshot)
+	}
+}
+
+// addAttempt 追加任务的一次尝试记录，并在锁外调用 onAttempt
+func (r *reportRecorder) addAttempt(taskID string, attempt TaskAttempt) {
+	r.mu.Lock()
+	tr, ok := r.report.Tasks[taskID]
+	if !ok {
+		tr = &TaskReport{ID: taskID, Status: TaskStatusPending}
+		r.report.Tasks[taskID] = tr
+	}
+	tr.History = append(tr.History, attempt)
+	r.mu.Unlock()
+
+	if r.onAttempt != nil {
+		r.onAttempt(taskID, attempt)
+	}
+}
+
+// status 返回任务当前的状态，未登记的任务为 pending
+func (r *reportRecorder) status(taskID string) TaskStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if tr, ok := r.report.Tasks[taskID]; ok {
+		return tr.Status
+	}
+	return TaskStatusPending
+}
+
+// outputDigest 返回已完成任务输出的摘要，没有记录血缘时返回空字符串
+func (r *reportRecorder) outputDigest(taskID string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if tr, ok := r.report.Tasks[taskID]; ok && tr.Lineage != nil {
+		return tr.Lineage.OutputDigest
+	}
+	return ""
+}
+
+// setFingerprint 记录执行时任务图的 Fingerprint
+func (r *reportRecorder) setFingerprint(fingerprint string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.report.Fingerprint = fingerprint
+}
+
+func (r *reportRecorder) setSensitive(sensitive map[string][]string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.report.Sensitive = sensitive
+}
+
+func (r *reportRecorder) setTags(tags map[string][]string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tags = tags
+}
+
+func (r *reportRecorder) setCodecs(codecs map[string]string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.report.Codecs = codecs
+}
+
+// codec 返回任务输出的编码名称，为空表示 JSON
+func (r *reportRecorder) codec(taskID string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.report.Codecs[taskID]
+}
+
+// finish 结束记录并返回最终报告
+func (r *reportRecorder) finish(results map[string]interface{}, err error) *ExecutionReport {
+	r.mu.Lock()
+	r.report.EndTime = time.Now()
+	r.report.Duration = r.report.EndTime.Sub(r.report.StartTime)
+	r.report.Results = results
+	r.report.Error = err
+	for _, tr := range r.report.Tasks {
+		r.report.ResultBytes += tr.ResultBytes
+	}
+	r.report.summarizeCosts(r.tags)
+	report := r.report
+	r.mu.Unlock()
+
+	if r.onFinish != nil {
+		r.onFinish(report)
+	}
+	return report
+}
+
+type annotationKey struct{}
+
+// annotations 保存单个任务执行期间附加的属性
+type annotations struct {
+	mu    sync.Mutex
+	attrs map[string]interface{}
+}
+
+func withAnnotations(ctx context.Context, a *annotations) context.Context {
+	return context.WithValue(ctx, annotationKey{}, a)
+}
+
+// Annotate 为当前任务附加自定义属性（如记录数、是否命中缓存），
+// 属性会写入 ExecutionReport 中对应任务的 Attributes；在任务上下文之外调用时忽略
+func Annotate(ctx context.Context, key string, value interface{}) {
+	a, ok := ctx.Value(annotationKey{}).(*annotations)
+	if !ok {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.attrs == nil {
+		a.attrs = make(map[string]interface{})
+	}
+	a.attrs[key] = value
+}
+
+// snapshot 返回属性的副本
+func (a *annotations) snapshot() map[string]interface{} {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(a.attrs) == 0 {
+		return nil
+	}
+	attrs := make(map[string]interface{}, len(a.attrs))
+	for k, v := range a.attrs {
+		attrs[k] = v
+	}
+	return attrs
+}
+
+// RedactedResults 返回把敏感字段替换为 Redacted 后的结果副本，用于展示、事件和日志，不修改 Results
+func (r *ExecutionReport) RedactedResults() map[string]interface{} {
+	return RedactResults(r.Results, r.Sensitive)
+}
+
+// RedactResults 返回把 sensitive 中标记的字段替换为 Redacted 后的结果副本
+func RedactResults(results map[string]interface{}, sensitive map[string][]string) map[string]interface{} {
+	if len(sensitive) == 0 || len(results) == 0 {
+		return results
+	}
+	out := make(map[string]interface{}, len(results))
+	for taskID, result := range results {
+		for _, path := range sensitive[taskID] {
+			result = redactPath(result, path)
+		}
+		out[taskID] = result
+	}
+	return out
+}
+
+// Secret 包装写入日志的敏感值，slog 输出时显示为 Redacted
+type Secret struct {
+	Value interface{}
+}
+
+// LogValue 实现 slog.LogValuer
+func (Secret) LogValue() slog.Value {
+	return slog.StringValue(Redacted)
+}
+
+// String 使 fmt 输出同样显示为 Redacted
+func (Secret) String() string {
+	return Redacted
+}
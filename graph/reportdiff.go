@@ -0,0 +1,178 @@
+package graph
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// TaskDelta 是同一任务在两次执行中的差异
+type TaskDelta struct {
+	ID               string
+	BaseStatus       TaskStatus // 任务不在基准报告中时为空
+	Status           TaskStatus // 任务不在对比报告中时为空
+	BaseDuration     time.Duration
+	Duration         time.Duration
+	BaseSkipReason   string
+	SkipReason       string
+	BaseAttempts     int
+	Attempts         int
+	NewlySkipped     bool // 基准中未被跳过，对比中被跳过
+	NoLongerSkipped  bool // 基准中被跳过，对比中未被跳过
+	StatusChanged    bool
+	DurationIncrease bool // 耗时增加超过 ReportDiff 的阈值
+	DurationDecrease bool // 耗时减少超过 ReportDiff 的阈值
+}
+
+// Delta 返回耗时的变化
+func (t TaskDelta) Delta() time.Duration {
+	return t.Duration - t.BaseDuration
+}
+
+// Change 返回耗时变化的比例，如 0.25 表示慢了25%；基准耗时为0时返回0
+func (t TaskDelta) Change() float64 {
+	if t.BaseDuration <= 0 {
+		return 0
+	}
+	return float64(t.Delta()) / float64(t.BaseDuration)
+}
+
+// ReportDiff 是两次执行报告的比较结果，用于验证性能改动和金丝雀运行
+type ReportDiff struct {
+	BaseRunID    string
+	RunID        string
+	BaseDuration time.Duration
+	Duration     time.Duration
+	// FingerprintChanged 为 true 时两次执行的任务图不同，任务的差异可能来自图的变更
+	FingerprintChanged bool
+	Tasks              []TaskDelta // 按任务ID排序
+}
+
+// CompareReports 比较基准报告 base 和对比报告 candidate 中每个任务的状态和耗时。
+// 耗时变化超过 threshold（如 0.1 表示10%）的任务标记为变快或变慢，threshold 为0时任何变化都会标记
+func CompareReports(base, candidate *ExecutionReport, threshold float64) *ReportDiff {
+	d := &ReportDiff{
+		BaseRunID:          base.RunID,
+		RunID:              candidate.RunID,
+		BaseDuration:       base.Duration,
+		Duration:           candidate.Duration,
+		FingerprintChanged: base.Fingerprint != candidate.Fingerprint,
+	}
+	ids := make(map[string]bool, len(base.Tasks))
+	for id := range base.Tasks {
+		ids[id] = true
+	}
+	for id := range candidate.Tasks {
+		ids[id] = true
+	}
+	for id := range ids {
+		t := TaskDelta{ID: id}
+		if tr, ok := base.Tasks[id]; ok {
+			t.BaseStatus, t.BaseDuration, t.BaseSkipReason, t.BaseAttempts = tr.Status, tr.Duration, tr.SkipReason, tr.Attempts
+		}
+		if tr, ok := candidate.Tasks[id]; ok {
+			t.Status, t.Duration, t.SkipReason, t.Attempts = tr.Status, tr.Duration, tr.SkipReason, tr.Attempts
+		}
+		t.StatusChanged = t.BaseStatus != t.Status
+		t.NewlySkipped = t.Status == TaskStatusSkipped && t.BaseStatus != TaskStatusSkipped && t.BaseStatus != ""
+		t.NoLongerSkipped = t.BaseStatus == TaskStatusSkipped && t.Status != TaskStatusSkipped && t.Status != ""
+		if t.BaseStatus == TaskStatusCompleted && t.Status == TaskStatusCompleted {
+			change := t.Change()
+			t.DurationIncrease = t.Delta() > 0 && change >= threshold
+			t.DurationDecrease = t.Delta() < 0 && -change >= threshold
+		}
+		d.Tasks = append(d.Tasks, t)
+	}
+	sort.Slice(d.Tasks, func(i, j int) bool { return d.Tasks[i].ID < d.Tasks[j].ID })
+	return d
+}
+
+// Regressions 返回变慢或变为失败的任务ID
+func (d *ReportDiff) Regressions() []string {
+	var ids []string
+	for _, t := range d.Tasks {
+		if t.DurationIncrease || (t.StatusChanged && t.Status == TaskStatusFailed) {
+			ids = append(ids, t.ID)
+		}
+	}
+	return ids
+}
+
+// String 以适合在CI中审阅的文本格式输出差异：状态变化、新被跳过的分支和耗时变化
+func (d *ReportDiff) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "run %s -> %s: %v -> %v (%s)\n", d.BaseRunID, d.RunID,
+		d.BaseDuration.Round(time.Millisecond), d.Duration.Round(time.Millisecond), percent(d.BaseDuration, d.Duration))
+	if d.FingerprintChanged {
+		b.WriteString("! task graph fingerprint changed\n")
+	}
+	for _, t := range d.Tasks {
+		switch {
+		case t.BaseStatus == "":
+			fmt.Fprintf(&b, "+ task %s %s\n", t.ID, t.Status)
+		case t.Status == "":
+			fmt.Fprintf(&b, "- task %s %s\n", t.ID, t.BaseStatus)
+		case t.NewlySkipped:
+			fmt.Fprintf(&b, "~ task %s %s -> skipped (%s)\n", t.ID, t.BaseStatus, t.SkipReason)
+		case t.StatusChanged:
+			fmt.Fprintf(&b, "~ task %s %s -> %s\n", t.ID, t.BaseStatus, t.Status)
+		case t.DurationIncrease || t.DurationDecrease:
+			fmt.Fprintf(&b, "~ task %s %v -> %v (%s)\n", t.ID,
+				t.BaseDuration.Round(time.Millisecond), t.Duration.Round(time.Millisecond), percent(t.BaseDuration, t.Duration))
+		}
+	}
+	return b.String()
+}
+
+// percent 格式化耗时变化的百分比
+func percent(base, d time.Duration) string {
+	if base <= 0 {
+		return "n/a"
+	}
+	return fmt.Sprintf("%+.1f%%", float64(d-base)/float64(base)*100)
+}
+
+var reportDiffHTML = template.Must(template.New("diff").Funcs(template.FuncMap{
+	"ms":      func(d time.Duration) string { return d.Round(time.Millisecond).String() },
+	"percent": percent,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Run {{.BaseRunID}} vs {{.RunID}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+th, td { padding: 4px 10px; border-bottom: 1px solid #ddd; text-align: left; }
+td.num { text-align: right; font-variant-numeric: tabular-nums; }
+tr.slower { background: #fde2e1; }
+tr.faster { background: #e3f6e3; }
+tr.status { background: #fff3cd; }
+</style>
+</head>
+<body>
+<h1>Run {{.BaseRunID}} vs {{.RunID}}</h1>
+<p>Total: {{ms .BaseDuration}} &rarr; {{ms .Duration}} ({{percent .BaseDuration .Duration}})</p>
+{{if .FingerprintChanged}}<p><strong>The task graph changed between the two runs.</strong></p>{{end}}
+<table>
+<tr><th>Task</th><th>Status</th><th>Base</th><th>Candidate</th><th>Change</th><th>Attempts</th></tr>
+{{range .Tasks}}<tr class="{{if or .StatusChanged .NewlySkipped}}status{{else if .DurationIncrease}}slower{{else if .DurationDecrease}}faster{{end}}">
+<td>{{.ID}}</td>
+<td>{{if .StatusChanged}}{{or .BaseStatus "-"}} &rarr; {{or .Status "-"}}{{else}}{{.Status}}{{end}}{{with .SkipReason}} ({{.}}){{end}}</td>
+<td class="num">{{ms .BaseDuration}}</td>
+<td class="num">{{ms .Duration}}</td>
+<td class="num">{{percent .BaseDuration .Duration}}</td>
+<td class="num">{{.BaseAttempts}} &rarr; {{.Attempts}}</td>
+</tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// WriteHTML 把差异输出为独立的 HTML 页面，变慢、变快和状态变化的任务以不同颜色标出
+func (d *ReportDiff) WriteHTML(w io.Writer) error {
+	return reportDiffHTML.Execute(w, d)
+}
@@ -0,0 +1,97 @@
+package graph
+
+import "sync/atomic"
+
+// ResultView 提供对已完成任务结果和工作流参数的只读访问
+type ResultView interface {
+	// Result 返回已完成任务的结果，任务未执行、被跳过或尚未完成时返回 false
+	Result(taskID string) (interface{}, bool)
+	// Param 返回执行时传入的工作流参数
+	Param(key string) (interface{}, bool)
+}
+
+// resultSource 是执行器内部读取已完成任务结果的方式
+type resultSource interface {
+	get(taskID string) (interface{}, bool)
+}
+
+// runResults 是单次执行中保存全部任务结果的集合
+type runResults interface {
+	resultSource
+	toMap() map[string]interface{}
+}
+
+// resultEntry 保存单个任务的结果
+type resultEntry struct {
+	value interface{}
+}
+
+// ordinalResults 是按任务序号预分配的结果集合，所有调度方式共用。
+// 每个槽位只会被对应任务写入一次，读写都是无锁的原子操作，
+// 避免了按层复制结果带来的分配和锁竞争
+type ordinalResults struct {
+	ordinals map[string]int // 执行期间只读
+	slots    []atomic.Pointer[resultEntry]
+}
+
+func newOrdinalResults(taskIDs []string) *ordinalResults {
+	r := &ordinalResults{
+		ordinals: make(map[string]int, len(taskIDs)),
+		slots:    make([]atomic.Pointer[resultEntry], len(taskIDs)),
+	}
+	for i, id := range taskIDs {
+		r.ordinals[id] = i
+	}
+	return r
+}
+
+func (r *ordinalResults) get(taskID string) (interface{}, bool) {
+	i, ok := r.ordinals[taskID]
+	if !ok {
+		return nil, false
+	}
+	entry := r.slots[i].Load()
+	if entry == nil {
+		return nil, false
+	}
+	return entry.value, true
+}
+
+func (r *ordinalResults) set(taskID string, result interface{}) {
+	if i, ok := r.ordinals[taskID]; ok {
+		r.slots[i].Store(&resultEntry{value: result})
+	}
+}
+
+// clear 移除任务的结果，用于被放弃的两阶段任务
+func (r *ordinalResults) clear(taskID string) {
+	if i, ok := r.ordinals[taskID]; ok {
+		r.slots[i].Store(nil)
+	}
+}
+
+// toMap 在执行结束后生成结果映射表
+func (r *ordinalResults) toMap() map[string]interface{} {
+	results := make(map[string]interface{}, len(r.slots))
+	for id, i := range r.ordinals {
+		if entry := r.slots[i].Load(); entry != nil {
+			results[id] = entry.value
+		}
+	}
+	return results
+}
+
+// resultView 基于已完成任务的结果实现 ResultView
+type resultView struct {
+	results resultSource
+	params  map[string]interface{}
+}
+
+func (v resultView) Result(taskID string) (interface{}, bool) {
+	return v.results.get(taskID)
+}
+
+func (v resultView) Param(key string) (interface{}, bool) {
+	value, ok := v.params[key]
+	return value, ok
+}
@@ -0,0 +1,296 @@
+package graph
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"path"
+	"time"
+)
+
+// RetentionPolicy 定义每个工作流保留多少运行记录，两个条件同时设置时满足任意一个即清理
+type RetentionPolicy struct {
+	KeepRuns int           // 保留最近的运行数，为0时不限制
+	MaxAge   time.Duration // 开始时间早于该时长的运行被清理，为0时不限制
+}
+
+// PrunableStore 是支持删除运行记录的 Store，保留策略只能作用于实现了该接口的 Store
+type PrunableStore interface {
+	Store
+	Namespaces(ctx context.Context) ([]string, error)
+	DeleteRuns(ctx context.Context, namespace string, runIDs []string) error
+}
+
+// Archiver 在运行记录被删除前将其归档，返回错误时本次不删除这些记录
+type Archiver interface {
+	Archive(ctx context.Context, namespace string, records []*RunRecord) error
+}
+
+// ObjectStore 是对象存储的最小接口，可由 S3、GCS 等客户端适配
+type ObjectStore interface {
+	PutObject(ctx context.Context, key string, data []byte) error
+}
+
+// ObjectArchiver 将归档的运行记录按 JSON Lines 格式写入对象存储，
+// 对象键形如 "<prefix>/<namespace>/2006/01/02/<unix纳秒>.jsonl"。
+// 结果中 Task.Sensitive 标记的字段替换为 Redacted；清理 EncryptedStore 时结果以加密的信封写入归档，读取时用 EncryptedStore.OpenResults 解密
+type ObjectArchiver struct {
+	Objects ObjectStore
+	Prefix  string
+}
+
+// archivedTask 是归档文件中的任务记录，错误保存为字符串
+type archivedTask struct {
+	Status     TaskStatus             `json:"status"`
+	StartTime  time.Time              `json:"start_time,omitempty"`
+	EndTime    time.Time              `json:"end_time,omitempty"`
+	DurationMS int64                  `json:"duration_ms"`
+	Attempts   int                    `json:"attempts"`
+	Cost       float64                `json:"cost,omitempty"`
+	Costs      map[string]float64     `json:"costs,omitempty"`
+	SkipReason string                 `json:"skip_reason,omitempty"`
+	Error      string                 `json:"error,omitempty"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+}
+
+// archivedRun 是归档文件中的一行
+type archivedRun struct {
+	Namespace       string                  `json:"namespace"`
+	RunID           string                  `json:"run_id"`
+	Workflow        string                  `json:"workflow"`
+	WorkflowVersion int                     `json:"workflow_version"`
+	Revision        string                  `json:"revision,omitempty"`
+	Status          RunStatus               `json:"status"`
+	Params          map[string]interface{}  `json:"params,omitempty"`
+	StartTime       time.Time               `json:"start_time"`
+	EndTime         time.Time               `json:"end_time,omitempty"`
+	Error           string                  `json:"error,omitempty"`
+	Fingerprint     string                  `json:"fingerprint,omitempty"`
+	Results         map[string]interface{}  `json:"results,omitempty"`
+	Tasks           map[string]archivedTask `json:"tasks,omitempty"`
+}
+
+// Archive 实现 Archiver
+func (a *ObjectArchiver) Archive(ctx context.Context, namespace string, records []*RunRecord) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, rec := range records {
+		row := archivedRun{
+			Namespace:       rec.Namespace,
+			RunID:           rec.RunID,
+			Workflow:        rec.Workflow,
+			WorkflowVersion: rec.WorkflowVersion,
+			Revision:        rec.Revision,
+			Status:          rec.Status,
+			Params:          rec.Params,
+			StartTime:       rec.StartTime,
+			EndTime:         rec.EndTime,
+			Error:           rec.Error,
+		}
+		if rec.Report != nil {
+			row.Fingerprint = rec.Report.Fingerprint
+			// 结果离开进程前脱敏 Task.Sensitive 标记的字段
+			row.Results = rec.Report.RedactedResults()
+			row.Tasks = make(map[string]archivedTask, len(rec.Report.Tasks))
+			for id, tr := range rec.Report.Tasks {
+				at := archivedTask{
+					Status:     tr.Status,
+					StartTime:  tr.StartTime,
+					EndTime:    tr.EndTime,
+					DurationMS: tr.Duration.Milliseconds(),
+					Attempts:   tr.Attempts,
+					Cost:       tr.Cost,
+					Costs:      tr.Costs,
+					SkipReason: tr.SkipReason,
+					Attributes: tr.Attributes,
+				}
+				if tr.Error != nil {
+					at.Error = tr.Error.Error()
+				}
+				row.Tasks[id] = at
+			}
+		}
+		if err := enc.Encode(row); err != nil {
+			return fmt.Errorf("failed to encode run %s: %v", rec.RunID, err)
+		}
+	}
+
+	now := time.Now().UTC()
+	key := path.Join(a.Prefix, namespace, now.Format("2006/01/02"), fmt.Sprintf("%d.jsonl", now.UnixNano()))
+	if err := a.Objects.PutObject(ctx, key, buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to write archive %s: %v", key, err)
+	}
+	return nil
+}
+
+// Retainer 按保留策略定期清理 Store 中的运行记录，执行中的运行不会被清理
+type Retainer struct {
+	store    PrunableStore
+	policy   RetentionPolicy
+	policies map[workflowKey]RetentionPolicy // 按工作流覆盖默认策略
+	archiver Archiver
+	logger   *slog.Logger
+}
+
+// RetainerOption 定义 Retainer 的构造选项
+type RetainerOption func(*Retainer)
+
+// WithWorkflowRetention 为指定命名空间下的工作流设置单独的保留策略
+func WithWorkflowRetention(namespace, workflow string, policy RetentionPolicy) RetainerOption {
+	return func(r *Retainer) {
+		r.policies[workflowKey{namespace: namespace, name: workflow}] = policy
+	}
+}
+
+// WithArchiver 设置在删除前归档运行记录的 Archiver
+func WithArchiver(archiver Archiver) RetainerOption {
+	return func(r *Retainer) {
+		r.archiver = archiver
+	}
+}
+
+// WithRetainerLogger 设置后台清理使用的日志器
+func WithRetainerLogger(logger *slog.Logger) RetainerOption {
+	return func(r *Retainer) {
+		r.logger = logger
+	}
+}
+
+// NewRetainer 创建使用默认策略 policy 的 Retainer，store 需要实现 PrunableStore
+func NewRetainer(store Store, policy RetentionPolicy, opts ...RetainerOption) (*Retainer, error) {
+	// EncryptedStore 读取时会解密结果，直接清理被包装的 Store，归档中的结果保持加密
+	if es, ok := store.(*EncryptedStore); ok {
+		store = es.store
+	}
+	ps, ok := store.(PrunableStore)
+	if !ok {
+		return nil, fmt.Errorf("store does not support deleting runs")
+	}
+	r := &Retainer{
+		store:    ps,
+		policy:   policy,
+		policies: make(map[workflowKey]RetentionPolicy),
+		logger:   slog.Default(),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r, nil
+}
+
+// Prune 对所有命名空间执行一次清理，返回删除的运行数
+func (r *Retainer) Prune(ctx context.Context) (int, error) {
+	namespaces, err := r.store.Namespaces(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list namespaces: %v", err)
+	}
+	total := 0
+	for _, ns := range namespaces {
+		n, err := r.pruneNamespace(ctx, ns)
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// pruneNamespace 清理单个命名空间，归档成功后才删除
+func (r *Retainer) pruneNamespace(ctx context.Context, namespace string) (int, error) {
+	// ListRuns 按开始时间倒序返回，每个工作流的前 KeepRuns 条会被保留
+	records, err := r.store.ListRuns(ctx, namespace, RunFilter{})
+	if err != nil {
+		return 0, fmt.Errorf("failed to list runs in namespace %s: %v", namespace, err)
+	}
+
+	now := time.Now()
+	seen := make(map[string]int)
+	var expired []*RunRecord
+	for _, rec := range records {
+		seen[rec.Workflow]++
+		if rec.Status == RunStatusRunning {
+			continue
+		}
+		policy, ok := r.policies[workflowKey{namespace: namespace, name: rec.Workflow}]
+		if !ok {
+			policy = r.policy
+		}
+		if (policy.KeepRuns > 0 && seen[rec.Workflow] > policy.KeepRuns) ||
+			(policy.MaxAge > 0 && now.Sub(rec.StartTime) > policy.MaxAge) {
+			expired = append(expired, rec)
+		}
+	}
+	if len(expired) == 0 {
+		return 0, nil
+	}
+
+	if r.archiver != nil {
+		if err := r.archiver.Archive(ctx, namespace, expired); err != nil {
+			return 0, fmt.Errorf("failed to archive runs in namespace %s: %v", namespace, err)
+		}
+	}
+	ids := make([]string, len(expired))
+	for i, rec := range expired {
+		ids[i] = rec.RunID
+	}
+	if err := r.store.DeleteRuns(ctx, namespace, ids); err != nil {
+		return 0, fmt.Errorf("failed to delete runs in namespace %s: %v", namespace, err)
+	}
+	return len(ids), nil
+}
+
+// Run 每隔 interval 执行一次清理，直到 ctx 取消；单次清理失败只记录日志
+func (r *Retainer) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		n, err := r.Prune(ctx)
+		if err != nil {
+			r.logger.Warn("run retention failed", slog.Int("pruned", n), slog.Any("error", err))
+		} else if n > 0 {
+			r.logger.Info("pruned runs", slog.Int("pruned", n))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Namespaces 返回存在运行记录的命名空间
+func (s *MemoryStore) Namespaces(ctx context.Context) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	namespaces := make([]string, 0, len(s.runs))
+	for ns := range s.runs {
+		namespaces = append(namespaces, ns)
+	}
+	return namespaces, nil
+}
+
+// DeleteRuns 删除命名空间内的运行记录，不存在的 run_id 被忽略
+func (s *MemoryStore) DeleteRuns(ctx context.Context, namespace string, runIDs []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, id := range runIDs {
+		delete(s.runs[namespace], id)
+		for key := range s.checkpoints {
+			if key.runID == id {
+				delete(s.checkpoints, key)
+			}
+		}
+		for key := range s.effects {
+			if key.runID == id {
+				delete(s.effects, key)
+			}
+		}
+	}
+	if len(s.runs[namespace]) == 0 {
+		delete(s.runs, namespace)
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package graph
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// memoryObjects 是记录写入对象的 ObjectStore
+type memoryObjects struct {
+	objects map[string][]byte
+}
+
+func (m *memoryObjects) PutObject(ctx context.Context, key string, data []byte) error {
+	m.objects[key] = data
+	return nil
+}
+
+// 清理 EncryptedStore 时归档的结果保持加密，可以用 OpenResults 解密
+func TestArchiveKeepsEncryptedResultsSealed(t *testing.T) {
+	ctx := context.Background()
+	kms, err := NewLocalKMS(make([]byte, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := NewEncryptedStore(NewMemoryStore(), kms)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now().Add(-time.Hour)
+	record := &RunRecord{
+		Namespace: DefaultNamespace,
+		RunID:     "run1",
+		Workflow:  "orders",
+		Status:    RunStatusCompleted,
+		StartTime: start,
+		EndTime:   start.Add(time.Second),
+		Report:    &ExecutionReport{Results: map[string]interface{}{"charge": "card-4242"}},
+	}
+	if err := store.SaveRun(ctx, record); err != nil {
+		t.Fatal(err)
+	}
+
+	objects := &memoryObjects{objects: make(map[string][]byte)}
+	retainer, err := NewRetainer(store, RetentionPolicy{MaxAge: time.Minute}, WithArchiver(&ObjectArchiver{Objects: objects}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := retainer.Prune(ctx); err != nil || n != 1 {
+		t.Fatalf("expected 1 pruned run, got %d, %v", n, err)
+	}
+	if len(objects.objects) != 1 {
+		t.Fatalf("expected 1 archive object, got %d", len(objects.objects))
+	}
+	for _, data := range objects.objects {
+		if bytes.Contains(data, []byte("card-4242")) {
+			t.Fatalf("archive contains the plaintext result: %s", data)
+		}
+		var row archivedRun
+		if err := json.Unmarshal(data, &row); err != nil {
+			t.Fatal(err)
+		}
+		results, err := store.OpenResults(ctx, row.Namespace, row.RunID, row.Results)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if results["charge"] != "card-4242" {
+			t.Fatalf("unexpected decrypted results: %v", results)
+		}
+	}
+}
+
+// 归档的结果中 Task.Sensitive 标记的字段被脱敏
+func TestArchiveRedactsSensitiveResults(t *testing.T) {
+	ctx := context.Background()
+	objects := &memoryObjects{objects: make(map[string][]byte)}
+	record := &RunRecord{
+		Namespace: DefaultNamespace,
+		RunID:     "run1",
+		Workflow:  "signup",
+		Status:    RunStatusCompleted,
+		Report: &ExecutionReport{
+			Results: map[string]interface{}{
+				"user": map[string]interface{}{"email": "a@example.com", "password": "hunter2"},
+			},
+			Sensitive: map[string][]string{"user": {"password"}},
+		},
+	}
+	if err := (&ObjectArchiver{Objects: objects}).Archive(ctx, DefaultNamespace, []*RunRecord{record}); err != nil {
+		t.Fatal(err)
+	}
+	for _, data := range objects.objects {
+		if bytes.Contains(data, []byte("hunter2")) {
+			t.Fatalf("archive contains a sensitive field: %s", data)
+		}
+		if !bytes.Contains(data, []byte("a@example.com")) {
+			t.Fatalf("archive lost a non-sensitive field: %s", data)
+		}
+	}
+	if record.Report.Results["user"].(map[string]interface{})["password"] != "hunter2" {
+		t.Fatal("archiving modified the record")
+	}
+}
@@ -0,0 +1,132 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// Run 表示任务图的一次执行，执行失败后可以只重新执行失败的任务
+type Run struct {
+	Graph   *TaskGraph
+	Options ExecuteOptions
+	Report  *ExecutionReport
+}
+
+// Run 执行任务图并返回可重试的 Run，执行失败时同样返回 Run 和错误
+func (tg *TaskGraph) Run(ctx context.Context, opts ExecuteOptions, extra ...ExecuteOption) (*Run, error) {
+	for _, opt := range extra {
+		opt(&opts)
+	}
+	if opts.RunID == "" {
+		opts.RunID = NewRunID()
+	}
+	report, err := tg.ExecuteWithReport(ctx, opts)
+	return &Run{Graph: tg, Options: opts, Report: report}, err
+}
+
+// NewRun 根据之前的执行报告（如 Store 中保存的运行记录）恢复 Run，用于之后调用 RetryFailed
+func NewRun(tg *TaskGraph, opts ExecuteOptions, report *ExecutionReport) *Run {
+	if report != nil {
+		opts.RunID = report.RunID
+	}
+	return &Run{Graph: tg, Options: opts, Report: report}
+}
+
+// Failed 返回报告中失败的任务ID（按ID排序）
+func (r *Run) Failed() []string {
+	var failed []string
+	if r.Report == nil {
+		return nil
+	}
+	for id, tr := range r.Report.Tasks {
+		if tr.Status == TaskStatusFailed {
+			failed = append(failed, id)
+		}
+	}
+	sort.Strings(failed)
+	return failed
+}
+
+// RetryFailed 只重新执行失败的任务及其尚未执行的下游任务，已完成任务的结果直接复用，
+// 被条件跳过的任务保持跳过；新的报告替换 r.Report，复用的任务在报告中标记为 Reused。
+// 任务图的 Fingerprint 与报告中的不同时返回错误，因为复用的结果可能已不再有效
+func (r *Run) RetryFailed(ctx context.Context) error {
+	if r.Report == nil {
+		return fmt.Errorf("run has no report to retry from")
+	}
+	if r.Report.Error == nil && len(r.Failed()) == 0 {
+		return nil
+	}
+	if fp, err := r.Graph.Fingerprint(); err != nil {
+		return err
+	} else if r.Report.Fingerprint != "" && fp != r.Report.Fingerprint {
+		return fmt.Errorf("cannot retry run %s: task graph changed since the run (fingerprint %s, was %s)", r.Report.RunID, fp, r.Report.Fingerprint)
+	}
+
+	opts := r.Options
+	opts.RunID = r.Report.RunID
+	opts.reuse = r.Report
+	report, err := r.Graph.ExecuteWithReport(ctx, opts)
+	if report != nil {
+		report.Namespace = r.Report.Namespace
+		report.Workflow = r.Report.Workflow
+		report.WorkflowVersion = r.Report.WorkflowVersion
+	}
+	r.Report = report
+	return err
+}
+
+// reused 返回之前执行中可复用的任务报告：已完成或被跳过的任务可复用，
+// 失败、未执行或执行中断的任务需要重新执行
+func reused(prev *ExecutionReport, taskID string) (*TaskReport, interface{}, bool) {
+	if prev == nil {
+		return nil, nil, false
+	}
+	tr, ok := prev.Tasks[taskID]
+	if !ok {
+		return nil, nil, false
+	}
+	switch tr.Status {
+	case TaskStatusCompleted:
+		result, ok := prev.Results[taskID]
+		return tr, result, ok
+	case TaskStatusSkipped:
+		return tr, nil, true
+	}
+	return nil, nil, false
+}
+
+// RetryFailed 重新执行命名空间内一次失败运行中失败的任务，复用其余已完成任务的结果，
+// 使用原运行的工作流版本、参数和 run_id。重试成功时删除该运行的死信
+func (n *NamespaceRegistry) RetryFailed(ctx context.Context, runID string, opts ExecuteOptions, extra ...ExecuteOption) (*ExecutionReport, error) {
+	rec, err := n.GetRun(ctx, runID)
+	if err != nil {
+		return nil, err
+	}
+	if rec.Status != RunStatusFailed {
+		return nil, fmt.Errorf("run %s is %s, only failed runs can be retried", runID, rec.Status)
+	}
+	if rec.Report == nil {
+		return nil, fmt.Errorf("run %s has no report to retry from", runID)
+	}
+	def, err := n.Get(rec.Workflow, rec.WorkflowVersion)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retry %s: %v", runID, err)
+	}
+	if rec.Report.Fingerprint != "" && def.Fingerprint != rec.Report.Fingerprint {
+		return nil, fmt.Errorf("cannot retry run %s: workflow %s version %d changed since the run", runID, def.Name, def.Version)
+	}
+
+	opts.Params = rec.Params
+	opts.reuse = rec.Report
+	extra = append(extra, WithRunID(runID))
+	report, err := n.run(ctx, def, opts, nil, extra...)
+	if err == nil {
+		if dls, ok := n.registry.store.(DeadLetterStore); ok {
+			// 运行可能已被重新执行或丢弃，死信不存在时忽略
+			_ = dls.DeleteDeadLetter(context.WithoutCancel(ctx), n.namespace, runID)
+		}
+	}
+	return report, err
+}
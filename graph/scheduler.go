@@ -0,0 +1,204 @@
+package graph
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Scheduler 在进程内所有并发执行的运行之间共享一组全局工作者名额。
+// WorkerCount 和 Executors 只限制单个运行的并发，大量请求同时触发执行时
+// 任务总数仍会成倍增长；多个运行使用同一个 Scheduler 时，同时执行的任务总数不超过其名额数。
+//
+// 名额不足时优先分配给 Priority 最高的运行，同一优先级的运行之间加权轮转：
+// 每个有等待任务的运行依次获得 SchedulerWeight 个名额，任务很多的大运行不会让任务少、
+// 对延迟敏感的小运行一直等待；同一运行内仍是预期耗时长的任务优先
+type Scheduler struct {
+	mu     sync.Mutex
+	slots  int
+	free   []int // 空闲的名额编号
+	seq    uint64
+	queues map[string]*runQueue // 有等待任务的运行
+	ring   []*runQueue          // 有等待任务的运行，按轮转顺序排列
+	next   int                  // 轮转中下一个获得名额的运行在 ring 中的位置
+
+	// 空闲名额不超过 reserved 个时，只有 Priority 不低于 minPriority 的运行能启动新任务
+	reserved    int
+	minPriority int
+}
+
+// runQueue 是单个运行中等待全局名额的任务
+type runQueue struct {
+	runID    string
+	priority int
+	weight   int
+	served   int // 本轮已获得的名额数
+	waiters  waiterHeap
+}
+
+// SchedulerOption 定义调度器的构造选项
+type SchedulerOption func(*Scheduler)
+
+// WithReservedSlots 为高优先级运行保留 slots 个名额：空闲名额不超过该数量时，
+// Priority 低于 minPriority 的运行尚未开始的任务被推迟，直到负载下降，
+// 使高优先级运行到达时总有名额可用
+func WithReservedSlots(slots, minPriority int) SchedulerOption {
+	return func(s *Scheduler) {
+		s.reserved = slots
+		s.minPriority = minPriority
+	}
+}
+
+// NewScheduler 创建拥有 slots 个全局名额的调度器
+func NewScheduler(slots int, opts ...SchedulerOption) (*Scheduler, error) {
+	if slots <= 0 {
+		return nil, fmt.Errorf("scheduler requires at least one slot, got %d", slots)
+	}
+	s := &Scheduler{slots: slots, free: make([]int, slots), queues: make(map[string]*runQueue)}
+	for i := range s.free {
+		s.free[i] = slots - 1 - i
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.reserved < 0 || s.reserved >= slots {
+		return nil, fmt.Errorf("reserved slots must be between 0 and %d, got %d", slots-1, s.reserved)
+	}
+	return s, nil
+}
+
+// SchedulerStats 是调度器某一时刻的名额使用情况
+type SchedulerStats struct {
+	Slots       int // 全局名额数
+	InUse       int // 正在执行的任务数
+	Waiting     int // 等待名额的任务数
+	WaitingRuns int // 有任务在等待名额的运行数
+}
+
+// Stats 返回当前的名额使用情况
+func (s *Scheduler) Stats() SchedulerStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats := SchedulerStats{Slots: s.slots, InUse: s.slots - len(s.free), WaitingRuns: len(s.ring)}
+	for _, q := range s.ring {
+		stats.Waiting += len(q.waiters)
+	}
+	return stats
+}
+
+// acquire 为运行中的任务占用一个全局名额，返回释放函数；未配置调度器时不限制
+func (s *Scheduler) acquire(ctx context.Context, run *runContext, priority int64) (func(), error) {
+	if s == nil {
+		return func() {}, nil
+	}
+
+	s.mu.Lock()
+	q, ok := s.queues[run.runID]
+	if !ok {
+		q = &runQueue{runID: run.runID, priority: run.priority, weight: max(run.weight, 1)}
+		s.queues[run.runID] = q
+		s.ring = append(s.ring, q)
+	}
+	s.seq++
+	w := &slotWaiter{priority: priority, seq: s.seq, ready: make(chan struct{})}
+	heap.Push(&q.waiters, w)
+	s.dispatch()
+	s.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return func() { s.release(w.slot) }, nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		granted := w.index < 0
+		if !granted {
+			heap.Remove(&q.waiters, w.index)
+			if len(q.waiters) == 0 {
+				s.removeQueue(q)
+			}
+		}
+		s.mu.Unlock()
+		// 取消的同时已获得名额时归还给下一个等待者
+		if granted {
+			s.release(w.slot)
+		}
+		return nil, ctx.Err()
+	}
+}
+
+// release 归还名额并分配给等待的任务
+func (s *Scheduler) release(slot int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.free = append(s.free, slot)
+	s.dispatch()
+}
+
+// dispatch 在持有锁时把空闲名额分配给等待的任务，直到没有空闲名额或可以启动的任务
+func (s *Scheduler) dispatch() {
+	for len(s.free) > 0 {
+		i := s.pick()
+		if i < 0 {
+			return
+		}
+		q := s.ring[i]
+		w := heap.Pop(&q.waiters).(*slotWaiter)
+		w.slot = s.free[len(s.free)-1]
+		s.free = s.free[:len(s.free)-1]
+		close(w.ready)
+
+		q.served++
+		s.next = i
+		switch {
+		case len(q.waiters) == 0:
+			s.removeQueue(q)
+		case q.served >= q.weight:
+			q.served = 0
+			s.next = i + 1
+		}
+	}
+}
+
+// pick 返回下一个获得名额的运行在 ring 中的位置：在可以启动任务的运行中选择优先级最高的，
+// 同一优先级从轮转位置开始选择；没有可以启动任务的运行时返回 -1
+func (s *Scheduler) pick() int {
+	pressured := len(s.free) <= s.reserved
+	best := -1
+	for k := range s.ring {
+		i := (s.next + k) % len(s.ring)
+		q := s.ring[i]
+		if pressured && q.priority < s.minPriority {
+			continue
+		}
+		if best < 0 || q.priority > s.ring[best].priority {
+			best = i
+		}
+	}
+	return best
+}
+
+// removeQueue 在运行不再有等待任务时将其移出轮转
+func (s *Scheduler) removeQueue(q *runQueue) {
+	delete(s.queues, q.runID)
+	for i, rq := range s.ring {
+		if rq == q {
+			s.ring = append(s.ring[:i], s.ring[i+1:]...)
+			if i < s.next {
+				s.next--
+			}
+			break
+		}
+	}
+	if s.next >= len(s.ring) {
+		s.next = 0
+	}
+}
+
+// WithScheduler 设置注册表执行工作流时使用的全局调度器，
+// 执行选项中未指定 Scheduler 的运行都共享该调度器的名额
+func WithScheduler(s *Scheduler) RegistryOption {
+	return func(r *Registry) {
+		r.scheduler = s
+	}
+}
@@ -0,0 +1,59 @@
+package graph
+
+import "fmt"
+
+// 虚拟的起始和结束节点ID。它们不会出现在任务图中，
+// 但可以在依赖查询中作为整个任务图的锚点使用：
+// StartNode 是所有源任务的依赖，EndNode 依赖所有汇任务
+const (
+	StartNode = "__start__"
+	EndNode   = "__end__"
+)
+
+// isSentinel 判断ID是否为虚拟节点
+func isSentinel(taskID string) bool {
+	return taskID == StartNode || taskID == EndNode
+}
+
+// Sources 返回没有依赖的任务（按ID排序），即 StartNode 的直接下游
+func (tg *TaskGraph) Sources() ([]string, error) {
+	predecessors, err := tg.graph.PredecessorMap()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get predecessors: %v", err)
+	}
+	var sources []string
+	for id, edges := range predecessors {
+		if len(edges) == 0 {
+			sources = append(sources, id)
+		}
+	}
+	return sortedIDs(sources), nil
+}
+
+// Sinks 返回没有下游的任务（按ID排序），即 EndNode 的直接依赖
+func (tg *TaskGraph) Sinks() ([]string, error) {
+	adjacency, err := tg.graph.AdjacencyMap()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get adjacency map: %v", err)
+	}
+	var sinks []string
+	for id, edges := range adjacency {
+		if len(edges) == 0 {
+			sinks = append(sinks, id)
+		}
+	}
+	return sortedIDs(sinks), nil
+}
+
+// allTaskIDs 返回全部任务ID（按ID排序）
+func (tg *TaskGraph) allTaskIDs() ([]string, error) {
+	adjacency, err := tg.graph.AdjacencyMap()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get adjacency map: %v", err)
+	}
+	ids := make([]string, 0, len(adjacency))
+	for id := range adjacency {
+		ids = append(ids, id)
+	}
+	return sortedIDs(ids), nil
+}
@@ -0,0 +1,66 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// Services 是按类型注册的共享依赖（如 HTTP 客户端、数据库连接池、功能开关），
+// 通过 ExecuteOptions.Services 或 Registry 注入到每个任务的上下文中，任务用 Service 获取。
+// 可以在运行过程中注册新的服务，并发安全
+type Services struct {
+	mu       sync.RWMutex
+	services map[reflect.Type]interface{}
+}
+
+// NewServices 创建空的服务集合
+func NewServices() *Services {
+	return &Services{services: make(map[reflect.Type]interface{})}
+}
+
+// Provide 以类型 T 注册服务，同一类型再次注册时替换原来的服务；
+// T 通常是接口或指针类型，如 Provide[*sql.DB](s, db)
+func Provide[T any](s *Services, service T) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.services[reflect.TypeFor[T]()] = service
+}
+
+// Lookup 返回集合中类型 T 的服务
+func Lookup[T any](s *Services) (T, bool) {
+	var zero T
+	if s == nil {
+		return zero, false
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	service, ok := s.services[reflect.TypeFor[T]()]
+	if !ok {
+		return zero, false
+	}
+	return service.(T), true
+}
+
+type servicesKey struct{}
+
+// ContextWithServices 返回携带服务集合的上下文，可用于在测试中直接调用任务函数
+func ContextWithServices(ctx context.Context, s *Services) context.Context {
+	return context.WithValue(ctx, servicesKey{}, s)
+}
+
+// ServicesFrom 返回上下文中的服务集合，没有时返回空
+func ServicesFrom(ctx context.Context) *Services {
+	s, _ := ctx.Value(servicesKey{}).(*Services)
+	return s
+}
+
+// Service 返回任务上下文中类型 T 的服务，未注册时返回错误
+func Service[T any](ctx context.Context) (T, error) {
+	service, ok := Lookup[T](ServicesFrom(ctx))
+	if !ok {
+		return service, fmt.Errorf("service %v is not registered", reflect.TypeFor[T]())
+	}
+	return service, nil
+}
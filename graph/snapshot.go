@@ -0,0 +1,61 @@
+package graph
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Snapshot 以稳定的文本格式输出任务图的结构：按ID排序的任务、每个任务的直接依赖和 Diff 比较的任务设置，
+// 取零值的设置省略。输出与任务的添加顺序无关，适合保存为 golden 文件在测试中比较（见 graphtest 包）：
+//
+//	task load
+//	  depends: extract, transform
+//	  retries: 3
+//	  timeout: 30s
+func (tg *TaskGraph) Snapshot() ([]byte, error) {
+	tasks, edges, err := tg.snapshot()
+	if err != nil {
+		return nil, err
+	}
+	depends := make(map[string][]string, len(tasks))
+	for e := range edges {
+		depends[e.To] = append(depends[e.To], e.From)
+	}
+
+	ids := make([]string, 0, len(tasks))
+	for id := range tasks {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var b strings.Builder
+	for _, id := range ids {
+		fmt.Fprintf(&b, "task %s\n", id)
+		if deps := depends[id]; len(deps) > 0 {
+			sort.Strings(deps)
+			fmt.Fprintf(&b, "  depends: %s\n", strings.Join(deps, ", "))
+		}
+		settings := taskSettings(tasks[id])
+		fields := make([]string, 0, len(settings))
+		for field, value := range settings {
+			if !zeroSetting(value) {
+				fields = append(fields, field)
+			}
+		}
+		sort.Strings(fields)
+		for _, field := range fields {
+			fmt.Fprintf(&b, "  %s: %s\n", field, settings[field])
+		}
+	}
+	return []byte(b.String()), nil
+}
+
+// zeroSetting 判断任务设置是否为零值
+func zeroSetting(value string) bool {
+	switch value {
+	case "", "0", "0s", "false":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,255 @@
+package graph
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ArtifactStore 保存溢出到内存之外的大结果，key 形如 "<run_id>/<任务ID>"
+type ArtifactStore interface {
+	Put(ctx context.Context, key string, r io.Reader) (int64, error)
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// FileArtifactStore 把结果保存为目录下的文件，key 中的 "/" 对应子目录
+type FileArtifactStore struct {
+	dir string
+}
+
+// NewFileArtifactStore 创建保存在 dir 下的 ArtifactStore，目录不存在时创建
+func NewFileArtifactStore(dir string) (*FileArtifactStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create artifact dir %s: %v", dir, err)
+	}
+	return &FileArtifactStore{dir: dir}, nil
+}
+
+// path 返回 key 对应的文件路径，拒绝指向目录之外的 key
+func (s *FileArtifactStore) path(key string) (string, error) {
+	p := filepath.Join(s.dir, filepath.FromSlash(key))
+	if rel, err := filepath.Rel(s.dir, p); err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return "", fmt.Errorf("invalid artifact key %q", key)
+	}
+	return p, nil
+}
+
+// Put 实现 ArtifactStore，先写入临时文件再重命名，读取方不会看到写了一半的文件
+func (s *FileArtifactStore) Put(ctx context.Context, key string, r io.Reader) (int64, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return 0, err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o700); err != nil {
+		return 0, fmt.Errorf("failed to create artifact dir: %v", err)
+	}
+	f, err := os.CreateTemp(filepath.Dir(p), ".spill-*")
+	if err != nil {
+		return 0, fmt.Errorf("failed to create artifact %s: %v", key, err)
+	}
+	n, err := io.Copy(f, r)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), p)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return 0, fmt.Errorf("failed to write artifact %s: %v", key, err)
+	}
+	return n, nil
+}
+
+// Open 实现 ArtifactStore
+func (s *FileArtifactStore) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(p)
+}
+
+// Delete 实现 ArtifactStore，key 不存在时不返回错误
+func (s *FileArtifactStore) Delete(ctx context.Context, key string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+var (
+	defaultArtifactsOnce  sync.Once
+	defaultArtifacts      ArtifactStore
+	defaultArtifactsError error
+)
+
+// defaultArtifactStore 返回系统临时目录下共享的 FileArtifactStore
+func defaultArtifactStore() (ArtifactStore, error) {
+	defaultArtifactsOnce.Do(func() {
+		defaultArtifacts, defaultArtifactsError = NewFileArtifactStore(filepath.Join(os.TempDir(), "workflow-artifacts"))
+	})
+	return defaultArtifacts, defaultArtifactsError
+}
+
+// SpillOptions 配置大结果的溢出：编码后超过 Threshold 字节的任务结果（返回 Outputs 时为单项输出）
+// 写入 Store，下游得到的输入和报告中的结果是 *Spilled 句柄，通过 Resolve 或 Spilled.Load 按需读取。
+// 溢出的数据在运行结束后保留（恢复和重试需要读取），由调用方通过 Spilled.Delete 或 Store 的生命周期清理
+type SpillOptions struct {
+	Threshold int64         // 必须大于0
+	Store     ArtifactStore // 为空时使用系统临时目录下的 workflow-artifacts 目录
+}
+
+// Spilled 是溢出到 ArtifactStore 的结果的句柄
+type Spilled struct {
+	Key   string // 在 ArtifactStore 中的键
+	Size  int64  // 编码后的字节数
+	Codec string // 编码名称，见 RegisterCodec
+
+	store ArtifactStore
+}
+
+func init() {
+	gob.Register(&Spilled{})
+}
+
+// Open 返回编码后的原始数据
+func (s *Spilled) Open(ctx context.Context) (io.ReadCloser, error) {
+	if s.store == nil {
+		return nil, fmt.Errorf("artifact %s is not attached to a store", s.Key)
+	}
+	return s.store.Open(ctx, s.Key)
+}
+
+// Load 读取并解码溢出的结果
+func (s *Spilled) Load(ctx context.Context) (interface{}, error) {
+	r, err := s.Open(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open artifact %s: %v", s.Key, err)
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read artifact %s: %v", s.Key, err)
+	}
+	v, err := DecodePayload(Payload{Codec: s.Codec, Data: data})
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode artifact %s: %v", s.Key, err)
+	}
+	return v, nil
+}
+
+// Delete 删除溢出的数据
+func (s *Spilled) Delete(ctx context.Context) error {
+	if s.store == nil {
+		return fmt.Errorf("artifact %s is not attached to a store", s.Key)
+	}
+	return s.store.Delete(ctx, s.Key)
+}
+
+// MarshalJSON 在报告、REST 接口和回调中把句柄表示为 {"$spilled": {"key", "size", "codec"}}
+func (s *Spilled) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"$spilled": map[string]interface{}{"key": s.Key, "size": s.Size, "codec": s.Codec},
+	})
+}
+
+// Resolve 返回 v 本身，v 是 *Spilled 时读取溢出的结果；任务可以用它读取可能被溢出的输入
+func Resolve(ctx context.Context, v interface{}) (interface{}, error) {
+	if s, ok := v.(*Spilled); ok {
+		return s.Load(ctx)
+	}
+	return v, nil
+}
+
+// spiller 在任务完成后溢出超过阈值的结果
+type spiller struct {
+	threshold int64
+	store     ArtifactStore
+}
+
+// newSpiller 校验溢出选项，未设置时返回 nil
+func newSpiller(opts *SpillOptions) (*spiller, error) {
+	if opts == nil {
+		return nil, nil
+	}
+	if opts.Threshold <= 0 {
+		return nil, fmt.Errorf("spill threshold must be positive, got %d", opts.Threshold)
+	}
+	s := &spiller{threshold: opts.Threshold, store: opts.Store}
+	if s.store == nil {
+		store, err := defaultArtifactStore()
+		if err != nil {
+			return nil, err
+		}
+		s.store = store
+	}
+	return s, nil
+}
+
+// spill 返回溢出后的结果；写入失败时保留原结果并记录警告，不影响任务结果
+func (run *runContext) spill(ctx context.Context, task *Task, result interface{}) interface{} {
+	codec := run.report.codec(task.ID)
+	if outputs, ok := result.(Outputs); ok {
+		var spilled Outputs
+		for key, value := range outputs {
+			v, ok := run.spillValue(ctx, run.runID+"/"+task.ID+"/"+key, codec, value)
+			if !ok {
+				continue
+			}
+			if spilled == nil {
+				spilled = make(Outputs, len(outputs))
+				for k, v := range outputs {
+					spilled[k] = v
+				}
+			}
+			spilled[key] = v
+		}
+		if spilled == nil {
+			return result
+		}
+		return spilled
+	}
+	if v, ok := run.spillValue(ctx, run.runID+"/"+task.ID, codec, result); ok {
+		return v
+	}
+	return result
+}
+
+// spillValue 在 value 编码后超过阈值时写入 ArtifactStore 并返回句柄
+func (run *runContext) spillValue(ctx context.Context, key, codec string, value interface{}) (*Spilled, bool) {
+	if value == nil {
+		return nil, false
+	}
+	if _, ok := value.(*Spilled); ok {
+		return nil, false
+	}
+	p, err := EncodePayload(codec, value)
+	if err != nil {
+		run.logger.Warn("failed to encode result for spillover", slog.String("key", key), slog.Any("error", err))
+		return nil, false
+	}
+	if int64(len(p.Data)) <= run.spiller.threshold {
+		return nil, false
+	}
+	n, err := run.spiller.store.Put(ctx, key, bytes.NewReader(p.Data))
+	if err != nil {
+		run.logger.Warn("failed to spill result, keeping it in memory", slog.String("key", key), slog.Any("error", err))
+		return nil, false
+	}
+	run.logger.Debug("result spilled", slog.String("key", key), slog.Int64("bytes", n))
+	return &Spilled{Key: key, Size: n, Codec: p.Codec, store: run.spiller.store}, true
+}
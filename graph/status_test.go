@@ -0,0 +1,50 @@
+package graph
+
+import (
+	"context"
+	"sync"
+	"testing"
+)
+
+// 注册表的多个运行并发执行同一任务图，任务状态按运行记录在报告中，不写入共享的任务
+func TestConcurrentRunsKeepStatusPerRun(t *testing.T) {
+	tg := NewTaskGraph()
+	first := &Task{ID: "first", Execute: func(ctx context.Context, inputs map[string]interface{}) (interface{}, error) {
+		return 1, nil
+	}}
+	second := &Task{ID: "second", Depends: []*Task{first}, Execute: func(ctx context.Context, inputs map[string]interface{}) (interface{}, error) {
+		return 2, nil
+	}}
+	for _, task := range []*Task{first, second} {
+		if err := tg.AddTask(task); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r := NewRegistry()
+	if _, err := r.Register("concurrent", tg); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			report, err := r.Start(context.Background(), "concurrent", ExecuteOptions{WorkerCount: 2})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			for _, id := range []string{"first", "second"} {
+				if status := report.Tasks[id].Status; status != TaskStatusCompleted {
+					t.Errorf("task %s is %s, expected completed", id, status)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if first.Status != "" || second.Status != "" {
+		t.Fatalf("shared tasks were mutated: %s, %s", first.Status, second.Status)
+	}
+}
@@ -0,0 +1,233 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// Strategy 定义任务的调度方式
+type Strategy string
+
+const (
+	// StrategyLayered 按层执行，每层的任务全部结束后才开始下一层
+	StrategyLayered Strategy = "layered"
+	// StrategyWorkStealing 任务的依赖全部结束后立即就绪，空闲的工作者从其他工作者的队列中窃取任务，
+	// 适合任务耗时差异较大的任务图；此模式下不会调用 OnLayerStart/OnLayerEnd
+	StrategyWorkStealing Strategy = "work-stealing"
+)
+
+// taskDeque 是单个工作者的任务队列：所有者从尾部取任务，窃取者从头部取任务
+type taskDeque struct {
+	mu    sync.Mutex
+	tasks []string
+}
+
+func (d *taskDeque) pushBack(taskID string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.tasks = append(d.tasks, taskID)
+}
+
+func (d *taskDeque) popBack() (string, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.tasks) == 0 {
+		return "", false
+	}
+	taskID := d.tasks[len(d.tasks)-1]
+	d.tasks = d.tasks[:len(d.tasks)-1]
+	return taskID, true
+}
+
+func (d *taskDeque) popFront() (string, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.tasks) == 0 {
+		return "", false
+	}
+	taskID := d.tasks[0]
+	d.tasks = d.tasks[1:]
+	return taskID, true
+}
+
+// stealScheduler 协调工作窃取调度中的就绪任务和结束条件
+type stealScheduler struct {
+	deques []*taskDeque
+
+	mu        sync.Mutex
+	cond      *sync.Cond
+	ready     int // 所有队列中尚未被领取的任务数
+	remaining int // 尚未结束的任务数
+	done      bool
+	err       error
+}
+
+func newStealScheduler(workers, total int) *stealScheduler {
+	s := &stealScheduler{
+		deques:    make([]*taskDeque, workers),
+		remaining: total,
+	}
+	for i := range s.deques {
+		s.deques[i] = &taskDeque{}
+	}
+	s.cond = sync.NewCond(&s.mu)
+	return s
+}
+
+// push 将就绪任务放入指定工作者的队列并唤醒一个空闲工作者
+func (s *stealScheduler) push(worker int, taskID string) {
+	s.deques[worker].pushBack(taskID)
+	s.mu.Lock()
+	s.ready++
+	s.mu.Unlock()
+	s.cond.Signal()
+}
+
+// next 为工作者领取下一个任务：优先取自己队列尾部的任务，否则从其他队列头部窃取；
+// 调度结束时返回 false
+func (s *stealScheduler) next(worker int) (string, bool) {
+	s.mu.Lock()
+	for s.ready == 0 && !s.done {
+		s.cond.Wait()
+	}
+	if s.done {
+		s.mu.Unlock()
+		return "", false
+	}
+	// 先预留一个任务名额，保证下面的扫描一定能取到任务
+	s.ready--
+	s.mu.Unlock()
+
+	for {
+		if taskID, ok := s.deques[worker].popBack(); ok {
+			return taskID, true
+		}
+		for i := 1; i < len(s.deques); i++ {
+			victim := (worker + i) % len(s.deques)
+			if taskID, ok := s.deques[victim].popFront(); ok {
+				return taskID, true
+			}
+		}
+	}
+}
+
+// finish 标记一个任务结束，所有任务结束后结束调度
+func (s *stealScheduler) finish() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.remaining--
+	if s.remaining == 0 {
+		s.done = true
+		s.cond.Broadcast()
+	}
+}
+
+// fail 记录第一个错误并结束调度
+func (s *stealScheduler) fail(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err == nil {
+		s.err = err
+	}
+	s.done = true
+	s.cond.Broadcast()
+}
+
+// executeWorkStealing 使用工作窃取调度执行任务图，结果写入 results
+func (tg *TaskGraph) executeWorkStealing(ctx context.Context, run *runContext, workers int, tasks map[string]*Task, results *ordinalResults) error {
+	predecessors, err := tg.graph.PredecessorMap()
+	if err != nil {
+		return fmt.Errorf("failed to get predecessors: %v", err)
+	}
+	adjacency, err := tg.graph.AdjacencyMap()
+	if err != nil {
+		return fmt.Errorf("failed to get adjacency map: %v", err)
+	}
+	if len(tasks) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// 记录每个任务尚未结束的依赖数
+	pending := make(map[string]*atomic.Int32, len(tasks))
+	for id := range tasks {
+		pending[id] = &atomic.Int32{}
+		pending[id].Store(int32(len(predecessors[id])))
+	}
+
+	// 工作者从队列尾部取任务，按预期耗时升序入队使最长的任务最先被取走
+	sched := newStealScheduler(workers, len(tasks))
+	var roots []string
+	for _, id := range sortedTaskIDs(tasks) {
+		if len(predecessors[id]) == 0 {
+			roots = append(roots, id)
+		}
+	}
+	if run.hints != nil {
+		run.hints.order(roots, false)
+	}
+	for w, id := range roots {
+		sched.push(w%workers, id)
+	}
+
+	// 上下文被取消时唤醒所有工作者
+	stop := context.AfterFunc(ctx, func() { sched.fail(ctx.Err()) })
+	defer stop()
+
+	var wg sync.WaitGroup
+	for worker := 0; worker < workers; worker++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for {
+				taskID, ok := sched.next(worker)
+				if !ok {
+					return
+				}
+				result, completed, err := tg.runTask(ctx, run, tasks[taskID], results)
+				if err != nil {
+					sched.fail(err)
+					cancel()
+					return
+				}
+				if completed {
+					results.set(taskID, result)
+				}
+				// 依赖全部结束的下游任务放入当前工作者的队列
+				successors := sortedKeys(adjacency[taskID])
+				if run.hints != nil {
+					run.hints.order(successors, false)
+				}
+				for _, next := range successors {
+					if pending[next].Add(-1) == 0 {
+						sched.push(worker, next)
+					}
+				}
+				sched.finish()
+			}
+		}(worker)
+	}
+	wg.Wait()
+
+	sched.mu.Lock()
+	defer sched.mu.Unlock()
+	if sched.err != nil && sched.remaining > 0 {
+		return sched.err
+	}
+	return nil
+}
+
+// sortedTaskIDs 返回按ID排序的任务ID
+func sortedTaskIDs(tasks map[string]*Task) []string {
+	ids := make([]string, 0, len(tasks))
+	for id := range tasks {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
@@ -0,0 +1,130 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Step 描述任务图中的一个任务及其调度属性，供外部编排器（如 Temporal）按依赖顺序调度任务
+type Step struct {
+	TaskID   string
+	Depends  []string // 直接依赖（按ID排序）
+	Layer    int
+	Timeout  time.Duration
+	Retries  int
+	Tags     []string
+	Optional bool
+}
+
+// Steps 按执行顺序返回任务图中的全部任务，每个任务都排在它的依赖之后
+func (tg *TaskGraph) Steps() ([]Step, error) {
+	order, err := tg.GetExecutionOrder()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get execution order: %v", err)
+	}
+	steps := make([]Step, 0, len(order))
+	for _, id := range order {
+		task, err := tg.graph.Vertex(id)
+		if err != nil {
+			return nil, fmt.Errorf("task %s not found", id)
+		}
+		deps, err := tg.GetDependencies(id)
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, Step{
+			TaskID:   id,
+			Depends:  deps,
+			Layer:    tg.taskLayers[id],
+			Timeout:  task.Timeout,
+			Retries:  task.Retries,
+			Tags:     task.Tags,
+			Optional: task.optional(),
+		})
+	}
+	return steps, nil
+}
+
+// StepInput 是在引擎之外单独执行一个任务时需要的状态
+type StepInput struct {
+	RunID   string                 // 外部编排器中的运行ID，用于日志和 RunIDFrom
+	Attempt int                    // 外部编排器的尝试次数，从1开始，为0时视为1
+	Results map[string]interface{} // 已完成任务的结果，至少包含该任务的依赖
+	Params  map[string]interface{} // 工作流参数
+}
+
+// ExecuteTask 在引擎之外执行任务图中的单个任务：与引擎一样收集输入、检查条件、
+// 执行 Precheck、应用 ContextFunc 和 Timeout，并校验结果。条件不满足时返回 executed 为 false。
+// 重试、名额和预算由调用方（外部编排器）负责，任务图本身不会被修改
+func (tg *TaskGraph) ExecuteTask(ctx context.Context, taskID string, in StepInput) (result interface{}, executed bool, err error) {
+	task, err := tg.graph.Vertex(taskID)
+	if err != nil {
+		return nil, false, fmt.Errorf("task %s not found", taskID)
+	}
+	results := mapResults(in.Results)
+	inputs := task.collectInputs(results, nil)
+	if !task.conditionMet(inputs, resultView{results: results, params: in.Params}) {
+		return nil, false, nil
+	}
+	if task.Precheck != nil {
+		if err := task.Precheck(inputs); err != nil {
+			return nil, false, fmt.Errorf("precheck failed: %v", err)
+		}
+	}
+
+	attempt := in.Attempt
+	if attempt <= 0 {
+		attempt = 1
+	}
+	if in.RunID != "" {
+		ctx = withRunID(ctx, in.RunID)
+	}
+	if s, ok := in.Params[ParamLogicalTime].(string); ok {
+		if t, err := time.Parse(time.RFC3339, s); err == nil {
+			ctx = withLogicalTime(ctx, t)
+		}
+	}
+	ctx = withLogger(ctx, taskLogger(LoggerFrom(ctx), in.RunID, taskID, attempt))
+	if task.ContextFunc != nil {
+		ctx = task.ContextFunc(ctx)
+	}
+	if task.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, task.Timeout)
+		defer cancel()
+	}
+	result, err = task.invoke(ctx, inputs)
+	if err == nil && task.Verify != nil {
+		if verr := task.Verify(result); verr != nil {
+			err = fmt.Errorf("verification failed: %v", verr)
+		}
+	}
+	if err == nil && task.TwoPhase != nil {
+		// 单独执行的两阶段任务没有同层的其他任务，准备后立即提交
+		err = commitStep(ctx, task.TwoPhase, result)
+	}
+	if err != nil {
+		return nil, true, fmt.Errorf("task %s failed: %w", taskID, err)
+	}
+	return result, true, nil
+}
+
+// commitStep 提交单独执行的两阶段任务，提交失败时放弃
+func commitStep(ctx context.Context, tp TwoPhase, prepared interface{}) error {
+	if err := tp.Commit(ctx, prepared); err != nil {
+		if aerr := tp.Abort(context.WithoutCancel(ctx), prepared); aerr != nil {
+			return fmt.Errorf("commit failed: %v (abort failed: %v)", err, aerr)
+		}
+		return fmt.Errorf("commit failed: %v", err)
+	}
+	return nil
+}
+
+// mapResults 以映射表提供已完成任务的结果
+type mapResults map[string]interface{}
+
+func (r mapResults) get(taskID string) (interface{}, bool) {
+	v, ok := r[taskID]
+	return v, ok
+}
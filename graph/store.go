@@ -0,0 +1,131 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultNamespace 是未指定命名空间时使用的命名空间
+const DefaultNamespace = "default"
+
+// RunStatus 表示一次运行的状态
+type RunStatus string
+
+const (
+	RunStatusRunning   RunStatus = "running"
+	RunStatusCompleted RunStatus = "completed"
+	RunStatusFailed    RunStatus = "failed"
+)
+
+// RunRecord 是保存在 Store 中的一次运行记录
+type RunRecord struct {
+	Namespace       string
+	RunID           string
+	Workflow        string
+	WorkflowVersion int
+	Revision        string // 工作流定义来源的版本，见 WorkflowDefinition.Revision
+	Status          RunStatus
+	Params          map[string]interface{}
+	LogicalTime     time.Time // 运行的逻辑执行时间，见 ExecuteOptions.LogicalTime
+	DedupeKey       string    // 运行的去重键，见 ExecuteOptions.Dedupe
+	StartTime       time.Time
+	EndTime         time.Time
+	Error           string
+	Report          *ExecutionReport // 运行结束后的执行报告
+}
+
+// RunFilter 定义查询运行记录的条件，零值字段不参与过滤
+type RunFilter struct {
+	Workflow  string
+	Status    RunStatus
+	DedupeKey string
+	Limit     int // 按开始时间倒序返回的最大条数，为0时不限制
+}
+
+// Store 持久化运行记录。所有读写都限定在命名空间内，
+// 一个命名空间无法读取或覆盖另一个命名空间的记录
+type Store interface {
+	SaveRun(ctx context.Context, record *RunRecord) error
+	GetRun(ctx context.Context, namespace, runID string) (*RunRecord, error)
+	ListRuns(ctx context.Context, namespace string, filter RunFilter) ([]*RunRecord, error)
+}
+
+// PingableStore 是可以检查连接状态的 Store，服务的就绪探针优先使用 Ping
+type PingableStore interface {
+	Store
+	Ping(ctx context.Context) error
+}
+
+// MemoryStore 是基于内存的 Store、DeadLetterStore、PrunableStore、AuditStore、CheckpointStore、EffectStore、LockStore 和 RateStore 实现，适用于测试和单进程部署；
+// 锁和令牌桶使用进程内共享的实例，与未指定 LockStore、RateStore 的执行互斥和合计限流
+type MemoryStore struct {
+	mu          sync.RWMutex
+	runs        map[string]map[string]*RunRecord // 命名空间 -> run_id -> 记录
+	deadLetters map[deadLetterKey]*DeadLetter
+	audit       map[string][]*AuditEntry // 命名空间 -> 按序号排列的审计记录
+	checkpoints map[checkpointKey]*Checkpoint
+	effects     map[effectKey]*Effect
+}
+
+// NewMemoryStore 创建空的内存存储
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{runs: make(map[string]map[string]*RunRecord)}
+}
+
+// SaveRun 保存或更新运行记录
+func (s *MemoryStore) SaveRun(ctx context.Context, record *RunRecord) error {
+	if record.Namespace == "" || record.RunID == "" {
+		return fmt.Errorf("run record requires namespace and run id")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.runs[record.Namespace] == nil {
+		s.runs[record.Namespace] = make(map[string]*RunRecord)
+	}
+	rec := *record
+	s.runs[record.Namespace][record.RunID] = &rec
+	return nil
+}
+
+// GetRun 返回命名空间内的运行记录
+func (s *MemoryStore) GetRun(ctx context.Context, namespace, runID string) (*RunRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	rec, ok := s.runs[namespace][runID]
+	if !ok {
+		return nil, fmt.Errorf("run %s not found in namespace %s", runID, namespace)
+	}
+	c := *rec
+	return &c, nil
+}
+
+// ListRuns 按开始时间倒序返回命名空间内符合条件的运行记录
+func (s *MemoryStore) ListRuns(ctx context.Context, namespace string, filter RunFilter) ([]*RunRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var records []*RunRecord
+	for _, rec := range s.runs[namespace] {
+		if filter.Workflow != "" && rec.Workflow != filter.Workflow {
+			continue
+		}
+		if filter.Status != "" && rec.Status != filter.Status {
+			continue
+		}
+		if filter.DedupeKey != "" && rec.DedupeKey != filter.DedupeKey {
+			continue
+		}
+		c := *rec
+		records = append(records, &c)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].StartTime.After(records[j].StartTime)
+	})
+	if filter.Limit > 0 && len(records) > filter.Limit {
+		records = records[:filter.Limit]
+	}
+	return records, nil
+}
@@ -2,8 +2,14 @@ package graph
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
+	"runtime/pprof"
+	"sort"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/dominikbraun/graph"
 	"golang.org/x/sync/errgroup"
@@ -18,8 +24,15 @@ const (
 	TaskStatusCompleted TaskStatus = "completed"
 	TaskStatusFailed    TaskStatus = "failed"
 	TaskStatusSkipped   TaskStatus = "skipped"
+	// TaskStatusPrepared 表示两阶段任务已准备，等待本层结束时提交（completed）或放弃（failed）
+	TaskStatusPrepared TaskStatus = "prepared"
 )
 
+// terminal 判断任务是否已处于结束状态
+func (s TaskStatus) terminal() bool {
+	return s == TaskStatusCompleted || s == TaskStatusFailed || s == TaskStatusSkipped
+}
+
 // TaskResult 表示任务执行的结果
 type TaskResult struct {
 	Data  interface{}
@@ -28,29 +41,174 @@ type TaskResult struct {
 
 // Task 表示一个可执行的任务
 type Task struct {
-	ID        string
-	Execute   func(ctx context.Context, inputs map[string]interface{}) (interface{}, error)
-	Depends   []*Task
+	ID      string
+	Execute func(ctx context.Context, inputs map[string]interface{}) (interface{}, error)
+	Depends []*Task
+	// Status 不随执行更新：同一任务图可能被多个运行并发执行，任务状态按运行记录在 ExecutionReport 中，
+	// 最近一次执行的状态可通过 TaskGraph.GetTaskStatus 读取
 	Status    TaskStatus
 	Condition func(inputs map[string]interface{}) bool
+
+	// ConditionWithResults 与 Condition 类似，但可以读取任意已完成任务的结果和工作流参数；
+	// 同时设置时两者都满足才会执行
+	ConditionWithResults func(inputs map[string]interface{}, results ResultView) bool
+
+	// Inputs 声明命名输入及其绑定的上游输出，设置后 Execute 和 Condition
+	// 收到的 inputs 以输入名称为键，而不是依赖任务的ID
+	Inputs map[string]InputBinding
+
+	Version string        // 任务实现的版本，变更后会改变任务图的 Fingerprint
+	Tags    []string      // 任务标签，如 "network"
+	Timeout time.Duration // 单次执行的超时时间，为0时不限制
+	Retries int           // 执行失败后的重试次数
+
+	// Executor 指定执行任务的命名执行器（如 "cpu"、"io"），
+	// 需要在 ExecuteOptions.Executors 中配置；为空时使用默认执行器
+	Executor string
+
+	// Precheck 在条件满足后、执行前校验输入，返回错误时任务直接失败且不重试；
+	// 与 Condition 不同，它表示输入契约被违反，而不是分支未被选中
+	Precheck func(inputs map[string]interface{}) error
+
+	// ContextFunc 在每次执行前修饰任务的上下文，可用于附加认证信息、租户ID或截止时间
+	ContextFunc func(ctx context.Context) context.Context
+
+	// Isolation 设置任务的执行隔离方式，为空时直接在工作 goroutine 中执行
+	Isolation *Isolation
+
+	// Verify 在 Execute 成功后校验输出，返回错误时本次执行视为失败并按 Retries 重试
+	Verify func(output interface{}) error
+
+	// Optional 为 true 时任务失败不会导致整个执行失败，
+	// 下游任务按照没有该输入处理（可通过 InputBinding.Default 提供默认值）；
+	// 带有 optional 或 enrichment 标签的任务同样视为可选
+	Optional bool
+
+	// Sensitive 标记输出中的敏感字段（以点分隔的路径，空字符串表示整个输出）。
+	// 下游任务和 Execute 的返回值仍是真实值，报告的 RedactedResults、REST 接口和回调中显示为 Redacted
+	Sensitive []string
+
+	// Codec 是持久化和传输输出时使用的编码名称（见 RegisterCodec），为空时使用 ExecuteOptions.Codec
+	Codec string
+
+	// Mutex 是任务的互斥组名称，同名的任务（包括其他运行和其他进程中的任务）不会同时执行，
+	// 跨进程互斥需要 ExecuteOptions.Locks，见 LockStore
+	Mutex string
+
+	// RateLimit 设置时任务的每次尝试（包括重试）开始前等待取得该配额的一个令牌，
+	// 同名配额在共享 RateStore 的所有进程之间合计限流，见 RateStore
+	RateLimit *RateLimit
+
+	// TwoPhase 设置时任务以两阶段提交执行，代替 Execute：同层的两阶段任务全部准备成功后才提交，
+	// 见 TwoPhase。包含两阶段任务的任务图按层执行
+	TwoPhase TwoPhase
+
+	// Contract 声明输出的结构和样例以及期望的输入结构，用于检查上下游之间的数据契约，见 CheckContracts
+	Contract *Contract
+
+	// when 是从定义文件构建时任务的声明式条件，导出为其他格式（如 ASL）时使用
+	when *ConditionSpec
+}
+
+// HasTag 判断任务是否带有指定标签
+func (t *Task) HasTag(tag string) bool {
+	for _, v := range t.Tags {
+		if v == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// conditionMet 判断任务的执行条件是否满足
+func (t *Task) conditionMet(inputs map[string]interface{}, results ResultView) bool {
+	if t.Condition != nil && !t.Condition(inputs) {
+		return false
+	}
+	if t.ConditionWithResults != nil && !t.ConditionWithResults(inputs, results) {
+		return false
+	}
+	return true
 }
 
 // TaskGraph 表示任务的DAG图
 type TaskGraph struct {
 	graph      graph.Graph[string, *Task]
 	taskLayers map[string]int // 存储任务的层级
+	inferDeps  bool           // 是否根据命名输入推断依赖
+
+	planMu sync.Mutex
+	plan   *executionPlan // 缓存的执行计划，任务图变更时清空
+
+	lastRun atomic.Pointer[reportRecorder] // 最近一次开始的执行的报告，供 GetTaskStatus 读取
+}
+
+// GraphOption 定义任务图的构造选项
+type GraphOption func(*TaskGraph)
+
+// WithDependencyInference 根据任务声明的 Inputs 自动补全 Depends，
+// 避免两者分别维护而产生不一致
+func WithDependencyInference() GraphOption {
+	return func(tg *TaskGraph) {
+		tg.inferDeps = true
+	}
 }
 
 // NewTaskGraph 创建新的任务图
-func NewTaskGraph() *TaskGraph {
-	return &TaskGraph{
+func NewTaskGraph(opts ...GraphOption) *TaskGraph {
+	tg := &TaskGraph{
 		graph:      graph.New(func(task *Task) string { return task.ID }, graph.Directed()),
 		taskLayers: make(map[string]int),
 	}
+	for _, opt := range opts {
+		opt(tg)
+	}
+	return tg
+}
+
+// inferDependencies 将命名输入引用但未在 Depends 中声明的上游任务加入依赖
+func (tg *TaskGraph) inferDependencies(task *Task) error {
+	names := make([]string, 0, len(task.Inputs))
+	for name := range task.Inputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		depID := task.Inputs[name].TaskID
+		if task.dependsOn(depID) {
+			continue
+		}
+		dep, err := tg.graph.Vertex(depID)
+		if err != nil {
+			return fmt.Errorf("input %s of task %s is bound to unknown task %s", name, task.ID, depID)
+		}
+		task.Depends = append(task.Depends, dep)
+	}
+	return nil
 }
 
 // AddTask 添加新任务到图中
 func (tg *TaskGraph) AddTask(task *Task) error {
+	if isSentinel(task.ID) {
+		return fmt.Errorf("failed to add task: %s is a reserved task id", task.ID)
+	}
+	defer tg.invalidatePlan()
+
+	if tg.inferDeps {
+		if err := tg.inferDependencies(task); err != nil {
+			return fmt.Errorf("failed to add task: %v", err)
+		}
+	}
+	if err := task.validateInputs(); err != nil {
+		return fmt.Errorf("failed to add task: %v", err)
+	}
+	if task.RateLimit != nil {
+		if err := task.RateLimit.validate(); err != nil {
+			return fmt.Errorf("failed to add task %s: %v", task.ID, err)
+		}
+	}
+
 	// 添加节点
 	if err := tg.graph.AddVertex(task); err != nil {
 		return fmt.Errorf("failed to add task: %v", err)
@@ -80,85 +238,652 @@ func (tg *TaskGraph) AddTask(task *Task) error {
 		tg.taskLayers[task.ID] = maxDepLayer + 1
 	}
 
-	// 检查是否有环
-	if _, err := graph.TopologicalSort(tg.graph); err != nil {
-		return fmt.Errorf("invalid task graph: %v", err)
-	}
-
+	// 新任务只有指向它的边，不会形成环，无需每次添加都做拓扑排序（编译执行计划时仍会检查）
 	return nil
 }
 
 // ExecuteOptions 定义执行选项
 type ExecuteOptions struct {
-	WorkerCount int
+	WorkerCount   int
+	Logger        *slog.Logger           // 任务日志器的基础日志器，为空时使用 slog.Default()
+	RunID         string                 // 本次执行的ID，为空时自动生成
+	CorrelationID string                 // 关联ID，为空时依次取 ctx 中的关联ID和 RunID
+	Params        map[string]interface{} // 工作流参数，可在 ConditionWithResults 中读取
+	// LogicalTime 是运行的逻辑执行时间，由调度器或补跑工具设置为该次运行所代表的时间，与实际开始时间无关；
+	// 为空时取参数中的 logical_time，都没有时为开始时间。它以 logical_time 写入参数，
+	// 任务通过 LogicalTimeFrom 读取，请求体模板中为 .logical_time
+	LogicalTime time.Time
+	Budget      *Budget   // 执行预算，为空时不限制
+	Services    *Services // 任务通过 Service 获取的共享依赖，为空时使用 ctx 中的服务集合
+	// Lineage 为 true 时为每个完成的任务记录数据血缘（TaskReport.Lineage），需要对每个输出计算摘要
+	Lineage bool
+	// Codec 是持久化和传输任务输出时默认使用的编码名称，为空时使用 JSON；任务可通过 Task.Codec 覆盖
+	Codec string
+	// Spill 设置后，编码后超过阈值的任务结果写入 ArtifactStore，下游和报告中得到 *Spilled 句柄；为空时不溢出
+	Spill *SpillOptions
+	// MemoryAccounting 为 true 时估算每个任务结果占用的内存，记录在报告的 ResultBytes 中，
+	// 并计入 ResultBytesInFlight；MaxResultBytes 大于0时同样开启统计，
+	// 运行持有的结果超过该值时产生结果的任务失败，错误包装 ErrResultMemoryLimit
+	MemoryAccounting bool
+	MaxResultBytes   int64
+	// ResourceUsage 为 true 时记录每个任务的 CPU 时间、分配字节数和 goroutine 变化（TaskReport.Usage）；
+	// 开启后任务执行期间独占所在的系统线程
+	ResourceUsage bool
+	// ProfileLabels 为 true 时在任务执行期间设置 runtime/pprof 标签 task_id 和 run_id，
+	// 任务启动的 goroutine 继承这些标签，CPU profile 可以按任务筛选
+	ProfileLabels bool
+	// Strategy 指定调度方式。为空时，不超过16个任务且未设置层级钩子的小图使用快速路径
+	// （依赖结束后立即启动，调度过程不分配映射表），其余任务图按层执行
+	Strategy Strategy
+
+	// ReuseInputs 为 true 时复用任务的输入映射表以降低高QPS场景下的GC压力。
+	// 开启后任务的 Execute、Condition 等函数不能在返回后继续持有 inputs，
+	// 也不能把 inputs 本身作为结果返回；设置了 Isolation.Recover 的任务不复用
+	ReuseInputs bool
+
+	// Executors 配置命名执行器及其并发数，使CPU密集型任务不会占满IO任务的并发名额；
+	// 默认执行器的并发数为 WorkerCount
+	Executors map[string]int
+
+	// SoftLatency 是软延迟预算：执行时间超过该值后跳过尚未开始的可选任务，
+	// 正在执行的可选任务被取消，只返回核心结果；为0时不启用降级
+	SoftLatency time.Duration
+
+	// DurationHints 是任务的预期耗时，设置后就绪任务按预期耗时从长到短启动，
+	// 等待并发名额时也是预期耗时长的任务优先；可通过 HintsFromAnalytics 从执行历史得出
+	DurationHints DurationHints
+
+	// DeadlineBudget 把 ctx 的截止时间沿关键路径分配给任务，依赖 DurationHints 估算下游耗时；
+	// 为空时任务共用运行的截止时间
+	DeadlineBudget *DeadlineBudget
+
+	// DefaultTimeout 和 DefaultRetries 是未设置 Timeout 或 Retries 的任务使用的默认值
+	DefaultTimeout time.Duration
+	DefaultRetries int
+	// TaskOverrides 按任务ID覆盖任务的超时和重试次数，优先于任务定义和默认值，通过 WithTaskOptions 设置
+	TaskOverrides map[string]*TaskOverride
+
+	// Admission 是运行开始前的准入控制器，同时执行的运行过多时执行直接返回包装了 ErrOverloaded 的错误；
+	// 为空时不限制
+	Admission *AdmissionController
+
+	// Scheduler 是多个运行共享的全局调度器，任务在占用执行器名额后还需占用一个全局名额；
+	// 为空时只受 WorkerCount 和 Executors 限制
+	Scheduler *Scheduler
+	// SchedulerWeight 是名额不足时本运行每轮可连续获得的全局名额数，为0时为1；
+	// 对延迟敏感的运行可以设置更大的权重
+	SchedulerWeight int
+	// Priority 是本运行在 Scheduler 中的优先级，名额不足时优先级高的运行的就绪任务先获得名额
+	Priority int
+
+	// OnLayerStart 在每一层任务开始执行前调用
+	OnLayerStart func(layer int, taskIDs []string)
+	// OnLayerEnd 在每一层任务全部结束后调用（包括失败的情况），duration 为该层耗时
+	OnLayerEnd func(layer int, taskIDs []string, duration time.Duration)
+	// OnTaskEnd 在任务完成、失败或被跳过后调用，可能被多个任务并发调用
+	OnTaskEnd func(tr TaskReport)
+	// OnTaskTransition 在任务状态每次变化后调用，from 为变化前的状态，可能被多个任务并发调用
+	OnTaskTransition func(tr TaskReport, from TaskStatus)
+	// OnTaskAttempt 在任务每次尝试（包括重试）结束后调用，可能被多个任务并发调用
+	OnTaskAttempt func(taskID string, attempt TaskAttempt)
+	// OnUtilization 在任务图执行结束后以利用率摘要调用，可直接打印 u.String()；
+	// 执行前的校验失败时不调用
+	OnUtilization func(u *Utilization)
+	// EventLog 设置后，运行的开始和结束、任务的每次状态变化和尝试以 JSON Lines 写入该日志
+	EventLog *EventLog
+	// Checkpoints 保存长任务的处理进度（见 CheckpointerFrom 和 ProcessRecordChunks），
+	// 同一运行中重新执行的任务从最后的检查点继续；为空时任务无法提交检查点
+	Checkpoints CheckpointStore
+	// Locks 提供 Task.Mutex 的互斥锁，为空时使用进程内的锁，只在本进程内互斥
+	Locks LockStore
+	// Rates 保存 Task.RateLimit、HTTPClientOptions.RateLimit 和 WaitRate 的令牌桶，
+	// 为空时使用进程内的令牌桶，配额只在本进程内生效
+	Rates RateStore
+	// Effects 记录任务通过 JournalFrom(ctx).Once 完成的副作用，同一运行中重试或重新执行的任务
+	// 跳过已完成的副作用；为空时 Once 每次都执行
+	Effects EffectStore
+
+	// Dedupe 设置后，同一工作流已有相同去重键的运行正在执行或刚成功完成时不再开始新的运行，
+	// 只对通过注册表执行的运行生效，见 Dedupe
+	Dedupe *Dedupe
+
+	// reuse 是 RetryFailed 时之前的执行报告，其中已完成和被跳过的任务不再执行
+	reuse *ExecutionReport
+	// dedupeKey 是注册表已登记的去重键，写入运行记录
+	dedupeKey string
+}
+
+// runContext 保存单次执行过程中共享的状态
+type runContext struct {
+	runID         string
+	correlationID string
+	logger        *slog.Logger
+	report        *reportRecorder
+	params        map[string]interface{}
+	budget        *budgetTracker
+	softDeadline  time.Time
+	pools         workerPools
+	inputPool     *sync.Pool       // 为空时不复用输入映射表
+	hints         DurationHints    // 为空时按任务ID顺序调度
+	reuse         *ExecutionReport // 为空时执行所有任务
+	scheduler     *Scheduler       // 为空时不限制全局并发
+	weight        int              // 在 scheduler 中的权重
+	priority      int              // 在 scheduler 中的优先级
+	deadlines     *taskDeadlines   // 为空时不分配截止时间预算
+	timeout       time.Duration    // 任务的默认超时
+	retries       int              // 任务的默认重试次数
+	overrides     map[string]*TaskOverride
+	lineage       bool            // 是否记录数据血缘
+	spiller       *spiller        // 为空时不溢出大结果
+	memory        *memoryAccount  // 为空时不统计结果内存
+	resourceUsage bool            // 是否记录任务的资源使用
+	profileLabels bool            // 是否设置 pprof 标签
+	checkpoints   CheckpointStore // 为空时不提供检查点
+	effects       EffectStore     // 为空时不记录副作用
+	twoPhase      *twoPhaseLayer  // 任务图没有两阶段任务时为空
+	locks         LockStore       // Task.Mutex 使用的锁
+	rates         RateStore       // 任务上下文中的令牌桶
+}
+
+// taskTimeout 返回任务单次执行的超时：执行时的覆盖优先，其次是任务定义，最后是执行选项中的默认值
+func (run *runContext) taskTimeout(task *Task) time.Duration {
+	if o := run.overrides[task.ID]; o != nil && o.timeout != nil {
+		return *o.timeout
+	}
+	if task.Timeout > 0 {
+		return task.Timeout
+	}
+	return run.timeout
 }
 
-// executeLayer 执行单层任务并返回结果
-func (tg *TaskGraph) executeLayer(ctx context.Context, layer []string, results map[string]interface{}) (map[string]interface{}, error) {
+// taskRetries 返回任务的重试次数，优先级与 taskTimeout 相同
+func (run *runContext) taskRetries(task *Task) int {
+	if o := run.overrides[task.ID]; o != nil && o.retries != nil {
+		return *o.retries
+	}
+	if task.Retries > 0 {
+		return task.Retries
+	}
+	return run.retries
+}
+
+// executeLayer 执行单层任务，结果直接写入 results
+func (tg *TaskGraph) executeLayer(ctx context.Context, run *runContext, layer []string, results *ordinalResults) error {
 	g, ctx := errgroup.WithContext(ctx)
-	layerResults := make(map[string]interface{})
-	var layerMu sync.Mutex
 
 	// 并行执行同一层的任务
 	for _, taskID := range layer {
+		// 已有任务失败或执行被取消时不再为剩余任务启动协程
+		if ctx.Err() != nil {
+			break
+		}
 		taskID := taskID
 		task, _ := tg.graph.Vertex(taskID)
 
 		g.Go(func() error {
-			// 收集任务的输入（来自依赖任务的结果）
-			inputs := make(map[string]interface{})
-			for _, dep := range task.Depends {
-				if result, ok := results[dep.ID]; ok {
-					inputs[dep.ID] = result
-				}
+			result, completed, err := tg.runTask(ctx, run, task, results)
+			if err != nil {
+				return err
 			}
-
-			// 检查条件是否满足
-			if task.Condition != nil && !task.Condition(inputs) {
-				task.Status = TaskStatusSkipped
-				return nil
+			if completed {
+				results.set(taskID, result)
 			}
+			return nil
+		})
+	}
 
-			// 更新任务状态并执行
-			task.Status = TaskStatusRunning
-			result, err := task.Execute(ctx, inputs)
-			if err != nil {
-				task.Status = TaskStatusFailed
-				return fmt.Errorf("task %s failed: %v", taskID, err)
+	// 等待当前层的所有任务完成
+	return g.Wait()
+}
+
+// runTask 执行单个任务：检查条件、预算和降级，占用执行器名额后执行并记录报告。
+// completed 为 true 时 result 是需要保存的任务结果；返回错误表示整个执行应当失败
+func (tg *TaskGraph) runTask(ctx context.Context, run *runContext, task *Task, results resultSource) (result interface{}, completed bool, err error) {
+	// 重试失败任务时直接复用之前已完成任务的结果
+	if prev, result, ok := reused(run.reuse, task.ID); ok {
+		run.report.update(task.ID, func(tr *TaskReport) {
+			*tr = *prev
+			tr.Reused = true
+		})
+		return result, prev.Status == TaskStatusCompleted, nil
+	}
+
+	// 执行已被取消时不再启动任务，任务在报告中保持 pending
+	if ctx.Err() != nil {
+		return nil, false, aborted(ctx)
+	}
+
+	// 记录就绪时间，被跳过或等待名额时被取消的任务同样可以说明执行时机
+	ready := time.Now()
+	run.report.update(task.ID, func(tr *TaskReport) { tr.ReadyTime = ready })
+
+	// 收集任务的输入（来自依赖任务的结果）。可恢复隔离的任务超时后可能仍在读取输入，
+	// 它的输入映射表不能归还到池中被其他任务复用
+	pool := run.inputPool
+	if task.Isolation != nil && task.Isolation.Recover {
+		pool = nil
+	}
+	inputs := task.collectInputs(results, pool)
+	defer releaseInputs(pool, inputs)
+
+	// 检查条件是否满足
+	if !task.conditionMet(inputs, resultView{results: results, params: run.params}) {
+		run.report.update(task.ID, func(tr *TaskReport) {
+			tr.Status = TaskStatusSkipped
+			tr.SkipReason = SkipReasonCondition
+		})
+		return nil, false, nil
+	}
+
+	// 检查预算，降级模式下预算耗尽时跳过可选任务
+	skip, err := run.budget.admit(task)
+	if err != nil {
+		return nil, false, err
+	}
+	if skip {
+		run.report.update(task.ID, func(tr *TaskReport) {
+			tr.Status = TaskStatusSkipped
+			tr.SkipReason = SkipReasonBudget
+		})
+		return nil, false, nil
+	}
+
+	// 超出软延迟预算时跳过可选任务
+	if run.degraded(task) {
+		run.report.update(task.ID, func(tr *TaskReport) {
+			tr.Status = TaskStatusSkipped
+			tr.SkipReason = SkipReasonDegraded
+		})
+		return nil, false, nil
+	}
+	taskCtx, cancel := run.withSoftDeadline(ctx, task)
+	defer cancel()
+
+	// 先持有互斥锁再占用名额，等待锁的任务不占用执行器
+	taskCtx, unlock, err := run.acquireMutex(taskCtx, task)
+	if err != nil {
+		return nil, false, fmt.Errorf("task %s failed: %w", task.ID, err)
+	}
+	defer unlock()
+
+	// 占用所属执行器的并发名额
+	// 名额不足时预期耗时长的任务优先
+	worker, release, err := run.pools.acquire(ctx, task, int64(run.hints[task.ID]))
+	if err != nil {
+		return nil, false, fmt.Errorf("task %s failed: %w", task.ID, err)
+	}
+	defer release()
+	// 先占用本运行的名额再占用全局名额，避免运行内排队的任务占着全局名额
+	releaseGlobal, err := run.scheduler.acquire(ctx, run, int64(run.hints[task.ID]))
+	if err != nil {
+		return nil, false, fmt.Errorf("task %s failed: %w", task.ID, err)
+	}
+	defer releaseGlobal()
+
+	// 等待名额之后再检查截止时间预算，排队消耗的时间也计入
+	if run.deadlines != nil {
+		deadline, err := run.deadlines.admit(task)
+		if err != nil {
+			if task.optional() {
+				run.report.update(task.ID, func(tr *TaskReport) {
+					tr.Status = TaskStatusSkipped
+					tr.SkipReason = SkipReasonDeadline
+				})
+				return nil, false, nil
 			}
+			run.report.update(task.ID, func(tr *TaskReport) {
+				tr.Status = TaskStatusFailed
+				tr.Error = err
+			})
+			return nil, false, fmt.Errorf("task %s failed: %w", task.ID, err)
+		}
+		var cancelDeadline context.CancelFunc
+		taskCtx, cancelDeadline = context.WithDeadline(taskCtx, deadline)
+		defer cancelDeadline()
+	}
 
-			task.Status = TaskStatusCompleted
-			// 加锁保护并发写入
-			layerMu.Lock()
-			layerResults[taskID] = result
-			layerMu.Unlock()
-			return nil
+	// 更新任务状态并执行
+	attrs := &annotations{}
+	cost := &taskCost{}
+	var usage *usageRecorder
+	if run.resourceUsage {
+		usage = &usageRecorder{}
+		taskCtx = withUsage(taskCtx, usage)
+	}
+	start := time.Now()
+	run.report.update(task.ID, func(tr *TaskReport) {
+		tr.Status = TaskStatusRunning
+		tr.StartTime = start
+		tr.QueueWait = start.Sub(ready)
+	})
+	result, attempts, err := tg.executeWithRetry(withTaskCost(taskCtx, cost), run, task, worker, inputs, attrs)
+	end := time.Now()
+	if err == nil && task.TwoPhase != nil && run.twoPhase != nil {
+		// 已准备的任务在本层结束时提交或放弃
+		run.twoPhase.add(task, result)
+	}
+	if err == nil && run.checkpoints != nil {
+		(&Checkpointer{store: run.checkpoints, runID: run.runID, taskID: task.ID}).clear(ctx, run.logger)
+	}
+	run.budget.addCost(cost.total())
+	if err != nil {
+		run.report.update(task.ID, func(tr *TaskReport) {
+			tr.Status = TaskStatusFailed
+			tr.EndTime = end
+			tr.Duration = end.Sub(start)
+			tr.Attempts = attempts
+			tr.Error = err
+			tr.Cost = cost.total()
+			tr.Costs = cost.byUnit()
+			tr.Attributes = attrs.snapshot()
+			tr.Usage = usage.snapshot()
 		})
+		if task.optional() {
+			run.logger.Warn("optional task failed", slog.String("task_id", task.ID), slog.Any("error", err))
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("task %s failed: %w", task.ID, err)
 	}
 
-	// 等待当前层的所有任务完成
-	if err := g.Wait(); err != nil {
-		return nil, err
+	var lineage *TaskLineage
+	if run.lineage {
+		lineage = run.taskLineage(task, result)
+	}
+	if run.spiller != nil {
+		result = run.spill(ctx, task, result)
+	}
+	var size int64
+	if run.memory != nil {
+		size = approxSize(result)
+		if err := run.memory.add(task.ID, size); err != nil {
+			run.report.update(task.ID, func(tr *TaskReport) {
+				tr.Status = TaskStatusFailed
+				tr.EndTime = end
+				tr.Duration = end.Sub(start)
+				tr.Attempts = attempts
+				tr.Error = err
+				tr.Cost = cost.total()
+				tr.Costs = cost.byUnit()
+				tr.Attributes = attrs.snapshot()
+				tr.Usage = usage.snapshot()
+			})
+			return nil, false, fmt.Errorf("task %s failed: %w", task.ID, err)
+		}
+	}
+	// 两阶段任务在提交后才完成，此前结束钩子和完成事件不会触发
+	status := TaskStatusCompleted
+	if task.TwoPhase != nil && run.twoPhase != nil {
+		status = TaskStatusPrepared
+	}
+	run.report.update(task.ID, func(tr *TaskReport) {
+		tr.Status = status
+		tr.EndTime = end
+		tr.Duration = end.Sub(start)
+		tr.Attempts = attempts
+		tr.Cost = cost.total()
+		tr.Costs = cost.byUnit()
+		tr.Attributes = attrs.snapshot()
+		tr.Lineage = lineage
+		tr.ResultBytes = size
+		tr.Usage = usage.snapshot()
+	})
+	return result, true, nil
+}
+
+// executeWithRetry 执行并校验任务，失败时按 Retries 重试，每次尝试单独应用 Timeout；
+// 每次尝试都会记录到报告的 History 中；
+// 返回最后一次尝试的结果、实际尝试次数和错误；Precheck 失败时尝试次数为0
+func (tg *TaskGraph) executeWithRetry(ctx context.Context, run *runContext, task *Task, worker string, inputs map[string]interface{}, attrs *annotations) (interface{}, int, error) {
+	if task.Precheck != nil {
+		if err := task.Precheck(inputs); err != nil {
+			return nil, 0, fmt.Errorf("precheck failed: %v", err)
+		}
 	}
 
-	return layerResults, nil
+	var (
+		result  interface{}
+		err     error
+		attempt int
+	)
+	timeout, retries := run.taskTimeout(task), run.taskRetries(task)
+	usage := usageFrom(ctx)
+	// 副作用日志在任务的所有尝试之间共享
+	var journal *Journal
+	if run.effects != nil {
+		journal = &Journal{store: run.effects, runID: run.runID, taskID: task.ID}
+	}
+	for attempt = 1; attempt <= retries+1; attempt++ {
+		// 注入携带上下文字段的日志器和任务属性收集器
+		attemptCtx := withLogger(ctx, taskLogger(run.logger, run.runID, task.ID, attempt))
+		attemptCtx = withAnnotations(attemptCtx, attrs)
+		attemptCtx = withRateStore(attemptCtx, run.rates)
+		if run.checkpoints != nil {
+			attemptCtx = withCheckpointer(attemptCtx, &Checkpointer{store: run.checkpoints, runID: run.runID, taskID: task.ID})
+		}
+		if journal != nil {
+			attemptCtx = withJournal(attemptCtx, journal)
+		}
+		if task.ContextFunc != nil {
+			attemptCtx = task.ContextFunc(attemptCtx)
+		}
+		// 等待配额的时间不计入尝试的超时和耗时
+		if task.RateLimit != nil {
+			if err = WaitRate(attemptCtx, *task.RateLimit); err != nil {
+				break
+			}
+		}
+		cancel := func() {}
+		if timeout > 0 {
+			attemptCtx, cancel = context.WithTimeout(attemptCtx, timeout)
+		}
+		start := time.Now()
+		usage.measure(func() {
+			if !run.profileLabels {
+				result, err = task.invoke(attemptCtx, inputs)
+				return
+			}
+			pprof.Do(attemptCtx, pprof.Labels("task_id", task.ID, "run_id", run.runID), func(ctx context.Context) {
+				result, err = task.invoke(ctx, inputs)
+			})
+		})
+		if err == nil && task.Verify != nil {
+			if verr := task.Verify(result); verr != nil {
+				err = fmt.Errorf("verification failed: %v", verr)
+			}
+		}
+		cancel()
+		end := time.Now()
+		run.report.addAttempt(task.ID, TaskAttempt{
+			Attempt:   attempt,
+			StartTime: start,
+			EndTime:   end,
+			Duration:  end.Sub(start),
+			Worker:    worker,
+			Error:     err,
+		})
+
+		// 成功或整体执行已被取消时不再重试
+		if err == nil || ctx.Err() != nil {
+			break
+		}
+	}
+	if attempt > retries+1 {
+		attempt = retries + 1
+	}
+	return result, attempt, err
 }
 
 // Execute 执行整个任务图
-func (tg *TaskGraph) Execute(ctx context.Context, opts ExecuteOptions) (map[string]interface{}, error) {
+func (tg *TaskGraph) Execute(ctx context.Context, opts ExecuteOptions, extra ...ExecuteOption) (map[string]interface{}, error) {
+	report, err := tg.ExecuteWithReport(ctx, opts, extra...)
+	if err != nil {
+		return nil, err
+	}
+	return report.Results, nil
+}
+
+// ExecuteWithReport 执行整个任务图并返回执行报告，执行失败时同样返回已收集到的报告
+func (tg *TaskGraph) ExecuteWithReport(ctx context.Context, opts ExecuteOptions, extra ...ExecuteOption) (*ExecutionReport, error) {
+	for _, opt := range extra {
+		opt(&opts)
+	}
+	release, err := opts.Admission.Admit(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
 	if opts.WorkerCount <= 0 {
 		opts.WorkerCount = 5
 	}
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+	if opts.RunID == "" {
+		opts.RunID = NewRunID()
+	}
+	if opts.CorrelationID == "" {
+		opts.CorrelationID = CorrelationIDFrom(ctx)
+	}
+	if opts.CorrelationID == "" {
+		opts.CorrelationID = opts.RunID
+	}
+	if err := applyLogicalTime(&opts); err != nil {
+		return nil, err
+	}
+	run := &runContext{
+		runID:         opts.RunID,
+		correlationID: opts.CorrelationID,
+		logger:        opts.Logger.With(slog.String("correlation_id", opts.CorrelationID)),
+		report:        newReportRecorder(opts.RunID, opts.CorrelationID),
+		params:        opts.Params,
+		pools:         newWorkerPools(opts.WorkerCount, opts.Executors),
+		hints:         opts.DurationHints,
+		reuse:         opts.reuse,
+		scheduler:     opts.Scheduler,
+		weight:        opts.SchedulerWeight,
+		priority:      opts.Priority,
+		timeout:       opts.DefaultTimeout,
+		retries:       opts.DefaultRetries,
+		overrides:     opts.TaskOverrides,
+		lineage:       opts.Lineage,
+		resourceUsage: opts.ResourceUsage,
+		profileLabels: opts.ProfileLabels,
+		checkpoints:   opts.Checkpoints,
+		effects:       opts.Effects,
+		locks:         opts.Locks,
+		rates:         opts.Rates,
+	}
+	if run.locks == nil {
+		run.locks = processLocks
+	}
+	if run.rates == nil {
+		run.rates = processRates
+	}
+	tg.lastRun.Store(run.report)
+	run.report.onTaskEnd = opts.OnTaskEnd
+	run.report.onTransition = opts.OnTaskTransition
+	run.report.onAttempt = opts.OnTaskAttempt
+	if opts.EventLog != nil {
+		opts.EventLog.attach(run)
+	}
+	if opts.ReuseInputs {
+		run.inputPool = &sharedInputPool
+	}
+	if opts.SoftLatency > 0 {
+		run.softDeadline = time.Now().Add(opts.SoftLatency)
+	}
+	if opts.Budget != nil {
+		run.budget = newBudgetTracker(opts.Budget)
+		if opts.Budget.Mode == BudgetAbort && opts.Budget.MaxDuration > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeoutCause(ctx, opts.Budget.MaxDuration,
+				fmt.Errorf("%w: exceeded max duration %v", ErrBudgetExhausted, opts.Budget.MaxDuration))
+			defer cancel()
+		}
+	}
+	ctx = withRunID(ContextWithCorrelationID(ctx, run.correlationID), run.runID)
+	ctx = withLogicalTime(ctx, opts.LogicalTime)
+	if opts.Services != nil {
+		ctx = ContextWithServices(ctx, opts.Services)
+	}
+	if run.deadlines, err = tg.newTaskDeadlines(ctx, opts.DeadlineBudget, opts.DurationHints); err != nil {
+		return run.report.finish(nil, err), err
+	}
 
-	// 获取执行顺序
-	_, err := graph.TopologicalSort(tg.graph)
+	// 获取编译后的执行计划
+	plan, err := tg.compile()
 	if err != nil {
-		return nil, fmt.Errorf("failed to sort tasks: %v", err)
+		return run.report.finish(nil, err), err
+	}
+	if err := run.pools.validate(plan.tasks); err != nil {
+		return run.report.finish(nil, err), err
+	}
+	if err := validateOverrides(run.overrides, plan.tasks); err != nil {
+		return run.report.finish(nil, err), err
+	}
+	run.report.setFingerprint(plan.fingerprint)
+	run.report.setSensitive(plan.sensitive)
+	run.report.setTags(plan.tags)
+	codecs, err := plan.codecs(opts.Codec)
+	if err != nil {
+		return run.report.finish(nil, err), err
+	}
+	run.report.setCodecs(codecs)
+	if run.spiller, err = newSpiller(opts.Spill); err != nil {
+		return run.report.finish(nil, err), err
+	}
+	if run.memory, err = newMemoryAccount(opts.MemoryAccounting, opts.MaxResultBytes); err != nil {
+		return run.report.finish(nil, err), err
+	}
+	if run.memory != nil {
+		defer run.memory.release()
 	}
 
-	// 创建结果映射表
-	results := make(map[string]interface{})
+	if plan.twoPhase {
+		run.twoPhase = &twoPhaseLayer{}
+	}
+
+	// 预先登记所有任务，未执行到的任务在报告中保持 pending
+	for _, taskID := range plan.ids {
+		run.report.update(taskID, func(tr *TaskReport) {})
+	}
 
+	var results runResults
+	switch {
+	case opts.Strategy == "" && plan.small && !plan.twoPhase && opts.OnLayerStart == nil && opts.OnLayerEnd == nil:
+		small := &smallResults{ids: plan.ids}
+		results = small
+		err = tg.executeSmall(ctx, run, plan, small)
+	case opts.Strategy == StrategyWorkStealing && plan.twoPhase:
+		err = fmt.Errorf("two-phase tasks require the layered strategy")
+		return run.report.finish(nil, err), err
+	case opts.Strategy == StrategyWorkStealing:
+		ordinal := newOrdinalResults(plan.ids)
+		results = ordinal
+		err = tg.executeWorkStealing(ctx, run, opts.WorkerCount, plan.tasks, ordinal)
+	case opts.Strategy == StrategyLayered || opts.Strategy == "":
+		ordinal := newOrdinalResults(plan.ids)
+		results = ordinal
+		err = tg.executeLayered(ctx, run, opts, ordinal)
+	default:
+		err = fmt.Errorf("unknown execution strategy %q", opts.Strategy)
+		return run.report.finish(nil, err), err
+	}
+	if err != nil {
+		// 因预算超时被取消时返回预算错误
+		if cause := context.Cause(ctx); errors.Is(cause, ErrBudgetExhausted) {
+			err = cause
+		}
+	}
+	report := run.report.finish(results.toMap(), err)
+	if opts.OnUtilization != nil {
+		opts.OnUtilization(tg.Utilization(report))
+	}
+	return report, err
+}
+
+// executeLayered 按层执行任务图，结果写入 results
+func (tg *TaskGraph) executeLayered(ctx context.Context, run *runContext, opts ExecuteOptions, results *ordinalResults) error {
 	// 找出最大层级
 	maxLayer := 0
 	for _, layer := range tg.taskLayers {
@@ -166,7 +891,6 @@ func (tg *TaskGraph) Execute(ctx context.Context, opts ExecuteOptions) (map[stri
 			maxLayer = layer
 		}
 	}
-	fmt.Println("task layers: ", tg.taskLayers)
 
 	// 按层级组织任务
 	layers := make([][]string, maxLayer+1)
@@ -174,35 +898,90 @@ func (tg *TaskGraph) Execute(ctx context.Context, opts ExecuteOptions) (map[stri
 		layers[layer] = append(layers[layer], taskID)
 	}
 
-	fmt.Println("layers: ", layers)
+	// 层内按预期耗时从长到短启动，使长任务先占用并发名额
+	if run.hints != nil {
+		for _, layer := range layers {
+			run.hints.order(layer, true)
+		}
+	}
 
 	// 按层次执行任务
-	for _, layer := range layers {
-		layerResults, err := tg.executeLayer(ctx, layer, results)
-		if err != nil {
-			return nil, err
+	for i, layer := range layers {
+		if ctx.Err() != nil {
+			return aborted(ctx)
 		}
-
-		// 合并当前层的结果
-		for k, v := range layerResults {
-			results[k] = v
+		if opts.OnLayerStart != nil {
+			opts.OnLayerStart(i, layer)
+		}
+		layerStart := time.Now()
+		err := tg.executeLayer(ctx, run, layer, results)
+		if run.twoPhase != nil {
+			err = run.resolveTwoPhase(ctx, results, err)
+		}
+		if opts.OnLayerEnd != nil {
+			opts.OnLayerEnd(i, layer, time.Since(layerStart))
+		}
+		if err != nil {
+			return err
 		}
 	}
 
-	return results, nil
+	return nil
+}
+
+// aborted 返回执行被取消时的错误，保留取消原因（如 ErrBudgetExhausted）用于 errors.Is 判断
+func aborted(ctx context.Context) error {
+	return fmt.Errorf("execution aborted: %w", context.Cause(ctx))
 }
 
 // GetExecutionOrder 获取任务的执行顺序
 func (tg *TaskGraph) GetExecutionOrder() ([]string, error) {
-	return graph.TopologicalSort(tg.graph)
+	return tg.topologicalOrder()
 }
 
-// GetTaskStatus 获取任务状态
-func (tg *TaskGraph) GetTaskStatus(taskID string) (TaskStatus, error) {
-	task, err := tg.graph.Vertex(taskID)
+// topologicalOrder 返回任务的一个拓扑序，图中有环时返回错误。
+// graph.TopologicalSort 每处理一个节点都要遍历所有节点的前驱，任务数上千时明显变慢，这里按入度线性计算
+func (tg *TaskGraph) topologicalOrder() ([]string, error) {
+	adjacency, err := tg.graph.AdjacencyMap()
 	if err != nil {
-		return "", fmt.Errorf("task %s not found", taskID)
+		return nil, err
 	}
+	indegree := make(map[string]int, len(adjacency))
+	for id, edges := range adjacency {
+		if _, ok := indegree[id]; !ok {
+			indegree[id] = 0
+		}
+		for target := range edges {
+			indegree[target]++
+		}
+	}
+	order := make([]string, 0, len(adjacency))
+	for id, n := range indegree {
+		if n == 0 {
+			order = append(order, id)
+		}
+	}
+	for i := 0; i < len(order); i++ {
+		for target := range adjacency[order[i]] {
+			if indegree[target]--; indegree[target] == 0 {
+				order = append(order, target)
+			}
+		}
+	}
+	if len(order) != len(adjacency) {
+		return nil, errors.New("topological sort cannot be computed on graph with cycles")
+	}
+	return order, nil
+}
 
-	return task.Status, nil
+// GetTaskStatus 获取任务在最近一次开始的执行中的状态，尚未执行过时为 pending；
+// 任务图被并发执行时需要某次执行中的状态应读取该次执行的 ExecutionReport
+func (tg *TaskGraph) GetTaskStatus(taskID string) (TaskStatus, error) {
+	if _, err := tg.graph.Vertex(taskID); err != nil {
+		return "", fmt.Errorf("task %s not found", taskID)
+	}
+	if run := tg.lastRun.Load(); run != nil {
+		return run.status(taskID), nil
+	}
+	return TaskStatusPending, nil
 }
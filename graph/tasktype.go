@@ -0,0 +1,196 @@
+package graph
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"sort"
+	"sync"
+)
+
+// TaskType 是可插拔的任务类型，如 "snowflake_query"。定义文件中 type 为 Name 的任务
+// 以其 params 作为配置，经 ConfigSchema 校验后由 New 创建执行函数
+type TaskType interface {
+	Name() string
+	// ConfigSchema 返回配置的 JSON Schema，支持 type、properties、required、additionalProperties 和 items；
+	// 为空时不校验
+	ConfigSchema() map[string]interface{}
+	// New 根据配置创建任务的执行函数，在定义加载时调用，返回错误时定义无效
+	New(config map[string]interface{}) (func(ctx context.Context, inputs map[string]interface{}) (interface{}, error), error)
+}
+
+var (
+	taskTypesMu sync.RWMutex
+	taskTypes   = map[string]TaskType{
+		TaskTypeWASM: wasmTaskType{},
+	}
+)
+
+// RegisterTaskType 注册全局可用的任务类型，通常在插件包的 init 中调用；同名类型已存在时返回错误
+func RegisterTaskType(t TaskType) error {
+	taskTypesMu.Lock()
+	defer taskTypesMu.Unlock()
+	if t.Name() == "" {
+		return fmt.Errorf("task type name is required")
+	}
+	if _, ok := taskTypes[t.Name()]; ok {
+		return fmt.Errorf("task type %s already registered", t.Name())
+	}
+	taskTypes[t.Name()] = t
+	return nil
+}
+
+// TaskTypes 返回全局注册的任务类型名称（按名称排序）
+func TaskTypes() []string {
+	taskTypesMu.RLock()
+	defer taskTypesMu.RUnlock()
+	names := make([]string, 0, len(taskTypes))
+	for name := range taskTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LookupTaskType 返回全局注册的任务类型
+func LookupTaskType(name string) (TaskType, bool) {
+	taskTypesMu.RLock()
+	defer taskTypesMu.RUnlock()
+	t, ok := taskTypes[name]
+	return t, ok
+}
+
+// ValidateConfig 按 JSON Schema 的子集校验配置：type、properties、required、additionalProperties 和 items
+func ValidateConfig(schema map[string]interface{}, config map[string]interface{}) error {
+	if schema == nil {
+		return nil
+	}
+	return validateSchema("config", schema, config)
+}
+
+func validateSchema(path string, schema map[string]interface{}, value interface{}) error {
+	if typ, ok := schema["type"].(string); ok && !schemaTypeMatches(typ, value) {
+		return fmt.Errorf("%s must be of type %s, got %T", path, typ, value)
+	}
+	if items, ok := schema["items"].(map[string]interface{}); ok {
+		if list, ok := value.([]interface{}); ok {
+			for i, item := range list {
+				if err := validateSchema(fmt.Sprintf("%s[%d]", path, i), items, item); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	obj, ok := value.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	if required, ok := schema["required"].([]interface{}); ok {
+		for _, key := range required {
+			if _, ok := obj[fmt.Sprint(key)]; !ok {
+				return fmt.Errorf("%s.%v is required", path, key)
+			}
+		}
+	}
+	if required, ok := schema["required"].([]string); ok {
+		for _, key := range required {
+			if _, ok := obj[key]; !ok {
+				return fmt.Errorf("%s.%s is required", path, key)
+			}
+		}
+	}
+	properties, _ := schema["properties"].(map[string]interface{})
+	for _, key := range sortedConfigKeys(obj) {
+		prop, ok := properties[key].(map[string]interface{})
+		if !ok {
+			if additional, ok := schema["additionalProperties"].(bool); ok && !additional {
+				return fmt.Errorf("%s.%s is not allowed", path, key)
+			}
+			continue
+		}
+		if err := validateSchema(path+"."+key, prop, obj[key]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func schemaTypeMatches(typ string, value interface{}) bool {
+	switch v := value.(type) {
+	case string:
+		return typ == "string"
+	case bool:
+		return typ == "boolean"
+	case float64:
+		return typ == "number" || (typ == "integer" && v == float64(int64(v)))
+	case int, int64:
+		return typ == "number" || typ == "integer"
+	case map[string]interface{}:
+		return typ == "object"
+	case []interface{}:
+		return typ == "array"
+	case nil:
+		return typ == "null"
+	}
+	return false
+}
+
+func sortedConfigKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// subprocessTaskType 是由外部可执行文件实现的任务类型
+type subprocessTaskType struct {
+	name    string
+	schema  map[string]interface{}
+	process Subprocess
+}
+
+// SubprocessTaskType 创建由外部可执行文件实现的任务类型，可用任何语言编写。
+// 插件以 "describe" 参数调用时在标准输出写出 {"name": ..., "config_schema": {...}}；
+// 以 "run" 参数调用时从标准输入读取 {"config": {...}, "inputs": {...}}，在标准输出写出 JSON 结果
+func SubprocessTaskType(ctx context.Context, path string, args ...string) (TaskType, error) {
+	cmd := exec.CommandContext(ctx, path, append(append([]string(nil), args...), "describe")...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("plugin %s describe failed: %v: %s", path, err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	var desc struct {
+		Name         string                 `json:"name"`
+		ConfigSchema map[string]interface{} `json:"config_schema"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &desc); err != nil {
+		return nil, fmt.Errorf("failed to decode plugin %s description: %v", path, err)
+	}
+	if desc.Name == "" {
+		return nil, fmt.Errorf("plugin %s did not report a task type name", path)
+	}
+	return &subprocessTaskType{
+		name:    desc.Name,
+		schema:  desc.ConfigSchema,
+		process: Subprocess{Path: path, Args: append(append([]string(nil), args...), "run")},
+	}, nil
+}
+
+func (t *subprocessTaskType) Name() string {
+	return t.name
+}
+
+func (t *subprocessTaskType) ConfigSchema() map[string]interface{} {
+	return t.schema
+}
+
+func (t *subprocessTaskType) New(config map[string]interface{}) (func(ctx context.Context, inputs map[string]interface{}) (interface{}, error), error) {
+	return func(ctx context.Context, inputs map[string]interface{}) (interface{}, error) {
+		return t.process.run(ctx, map[string]interface{}{"config": config, "inputs": inputs}, 0)
+	}, nil
+}
@@ -0,0 +1,195 @@
+package graph
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+)
+
+// TemplateParams 是实例化模板时传入的参数
+type TemplateParams map[string]interface{}
+
+// Template 是参数化的任务图模板。Build 通过 TemplateBuilder 添加任务，
+// 任务ID使用模板内的局部ID，实例化时会自动加上实例前缀
+type Template struct {
+	Name     string
+	Required []string       // 必填参数
+	Defaults TemplateParams // 参数默认值
+	Build    func(b *TemplateBuilder) error
+}
+
+// TemplateLibrary 管理已注册的模板
+type TemplateLibrary struct {
+	mu        sync.RWMutex
+	templates map[string]*Template
+}
+
+// NewTemplateLibrary 创建空的模板库
+func NewTemplateLibrary() *TemplateLibrary {
+	return &TemplateLibrary{templates: make(map[string]*Template)}
+}
+
+// Register 注册模板，同名模板已存在时返回错误
+func (l *TemplateLibrary) Register(t *Template) error {
+	if t.Name == "" || t.Build == nil {
+		return fmt.Errorf("template name and build func are required")
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.templates[t.Name]; ok {
+		return fmt.Errorf("template %s already registered", t.Name)
+	}
+	l.templates[t.Name] = t
+	return nil
+}
+
+// Names 返回已注册的模板名称（按名称排序）
+func (l *TemplateLibrary) Names() []string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	names := make([]string, 0, len(l.templates))
+	for name := range l.templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (l *TemplateLibrary) get(name string) (*Template, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	t, ok := l.templates[name]
+	if !ok {
+		return nil, fmt.Errorf("template %s not found", name)
+	}
+	return t, nil
+}
+
+// Instantiate 使用参数实例化模板，生成新的任务图；
+// instanceID 不为空时所有任务ID以 "instanceID." 为前缀，便于同一模板的多个实例共存
+func (l *TemplateLibrary) Instantiate(name, instanceID string, params TemplateParams, opts ...GraphOption) (*TaskGraph, error) {
+	tg := NewTaskGraph(opts...)
+	if _, err := l.instantiate(tg, name, instanceID, params, 0); err != nil {
+		return nil, err
+	}
+	return tg, nil
+}
+
+// maxTemplateDepth 限制模板嵌套层数，防止模板相互引用导致无限递归
+const maxTemplateDepth = 16
+
+func (l *TemplateLibrary) instantiate(tg *TaskGraph, name, prefix string, params TemplateParams, depth int) (*TemplateBuilder, error) {
+	if depth > maxTemplateDepth {
+		return nil, fmt.Errorf("template %s exceeds max nesting depth %d", name, maxTemplateDepth)
+	}
+	t, err := l.get(name)
+	if err != nil {
+		return nil, err
+	}
+
+	merged := make(TemplateParams, len(t.Defaults)+len(params))
+	for k, v := range t.Defaults {
+		merged[k] = v
+	}
+	for k, v := range params {
+		merged[k] = v
+	}
+	for _, key := range t.Required {
+		if _, ok := merged[key]; !ok {
+			return nil, fmt.Errorf("template %s requires param %s", name, key)
+		}
+	}
+
+	b := &TemplateBuilder{
+		library: l,
+		graph:   tg,
+		prefix:  prefix,
+		params:  merged,
+		tasks:   make(map[string]*Task),
+		depth:   depth,
+	}
+	if err := t.Build(b); err != nil {
+		return nil, fmt.Errorf("failed to build template %s: %v", name, err)
+	}
+	return b, nil
+}
+
+// TemplateBuilder 在模板的 Build 中用于添加任务和嵌套其他模板
+type TemplateBuilder struct {
+	library *TemplateLibrary
+	graph   *TaskGraph
+	prefix  string
+	params  TemplateParams
+	tasks   map[string]*Task // 局部ID到任务
+	depth   int
+}
+
+// Param 返回模板参数
+func (b *TemplateBuilder) Param(key string) interface{} {
+	return b.params[key]
+}
+
+// Params 返回全部模板参数
+func (b *TemplateBuilder) Params() TemplateParams {
+	return b.params
+}
+
+// ID 将模板内的局部ID转换为任务图中的完整ID
+func (b *TemplateBuilder) ID(local string) string {
+	if b.prefix == "" {
+		return local
+	}
+	return b.prefix + "." + local
+}
+
+// Task 返回本模板（不含嵌套模板）中指定局部ID的任务
+func (b *TemplateBuilder) Task(local string) *Task {
+	return b.tasks[Substitute(local, b.params)]
+}
+
+// Add 将任务加入任务图。任务的 ID、Tags 和 Executor 中的 ${param} 会被替换为参数值，
+// ID 和绑定到本模板任务的 Inputs 会加上实例前缀
+func (b *TemplateBuilder) Add(task *Task) (*Task, error) {
+	local := Substitute(task.ID, b.params)
+	if _, ok := b.tasks[local]; ok {
+		return nil, fmt.Errorf("task %s already added to template", local)
+	}
+
+	task.ID = b.ID(local)
+	task.Executor = Substitute(task.Executor, b.params)
+	tags := make([]string, len(task.Tags))
+	for i, tag := range task.Tags {
+		tags[i] = Substitute(tag, b.params)
+	}
+	if task.Tags != nil {
+		task.Tags = tags
+	}
+	for name, binding := range task.Inputs {
+		if _, ok := b.tasks[binding.TaskID]; ok {
+			binding.TaskID = b.ID(binding.TaskID)
+			task.Inputs[name] = binding
+		}
+	}
+
+	if err := b.graph.AddTask(task); err != nil {
+		return nil, err
+	}
+	b.tasks[local] = task
+	return task, nil
+}
+
+// Include 在当前模板中嵌套实例化另一个模板，嵌套模板的任务ID以 "当前前缀.local." 为前缀
+func (b *TemplateBuilder) Include(name, local string, params TemplateParams) (*TemplateBuilder, error) {
+	return b.library.instantiate(b.graph, name, b.ID(Substitute(local, b.params)), params, b.depth+1)
+}
+
+// Substitute 将字符串中的 ${key} 替换为参数值，未提供的参数保持原样
+func Substitute(s string, params TemplateParams) string {
+	return os.Expand(s, func(key string) string {
+		if v, ok := params[key]; ok {
+			return fmt.Sprint(v)
+		}
+		return "${" + key + "}"
+	})
+}
@@ -0,0 +1,124 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrTwoPhaseAborted 表示两阶段任务已准备但因同层其他任务失败而被放弃
+var ErrTwoPhaseAborted = errors.New("two-phase task aborted")
+
+// TwoPhase 是两阶段提交的任务实现。同一层的两阶段任务先全部执行 Prepare，
+// 都成功后引擎按任务ID顺序调用 Commit；同层有任务失败或执行被取消时，已准备的任务调用 Abort，
+// 使多个存储的写入要么全部生效要么都不生效。Prepare 成功的任务处于 prepared 状态，
+// 提交后才进入 completed，放弃或提交失败时进入 failed。
+//
+// Prepare 应只暂存写入（如写入临时表、预留额度），返回值作为任务结果，也是传给 Commit 和 Abort 的句柄；
+// Prepare 失败时由它自己清理，按 Retries 重试。Commit 在失败时按 Retries 重试，需要幂等；
+// 重试后仍失败时同层尚未提交的任务被放弃，已提交的无法撤销，因此校验和加锁应在 Prepare 中完成
+type TwoPhase interface {
+	Prepare(ctx context.Context, inputs map[string]interface{}) (interface{}, error)
+	Commit(ctx context.Context, prepared interface{}) error
+	Abort(ctx context.Context, prepared interface{}) error
+}
+
+// preparedTask 是本层已准备、等待提交的两阶段任务
+type preparedTask struct {
+	task     *Task
+	prepared interface{}
+}
+
+// twoPhaseLayer 收集当前层已准备的两阶段任务
+type twoPhaseLayer struct {
+	mu       sync.Mutex
+	prepared []preparedTask
+}
+
+func (l *twoPhaseLayer) add(task *Task, prepared interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.prepared = append(l.prepared, preparedTask{task: task, prepared: prepared})
+}
+
+// take 取出本层已准备的任务（按任务ID排序）并清空
+func (l *twoPhaseLayer) take() []preparedTask {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	prepared := l.prepared
+	l.prepared = nil
+	sort.Slice(prepared, func(i, j int) bool { return prepared[i].task.ID < prepared[j].task.ID })
+	return prepared
+}
+
+// resolveTwoPhase 在一层任务结束后提交或放弃该层已准备的两阶段任务，layerErr 是该层的执行结果
+func (run *runContext) resolveTwoPhase(ctx context.Context, results *ordinalResults, layerErr error) error {
+	prepared := run.twoPhase.take()
+	if len(prepared) == 0 {
+		return layerErr
+	}
+	// 提交和放弃不受执行取消的影响，避免停在已准备的状态
+	ctx = context.WithoutCancel(ctx)
+	if layerErr != nil {
+		run.abortPrepared(ctx, results, prepared, layerErr)
+		return layerErr
+	}
+
+	var committed []string
+	for i, p := range prepared {
+		var err error
+		for attempt := 0; attempt <= run.taskRetries(p.task); attempt++ {
+			if err = p.task.TwoPhase.Commit(ctx, p.prepared); err == nil {
+				break
+			}
+			run.logger.Warn("two-phase commit failed", slog.String("task_id", p.task.ID), slog.Int("attempt", attempt+1), slog.Any("error", err))
+		}
+		if err != nil {
+			err = fmt.Errorf("commit failed: %v", err)
+			run.failPrepared(results, p.task, err)
+			run.abortPrepared(ctx, results, prepared[i+1:], fmt.Errorf("task %s failed to commit", p.task.ID))
+			if len(committed) > 0 {
+				return fmt.Errorf("task %s failed: %v (already committed: %s)", p.task.ID, err, strings.Join(committed, ", "))
+			}
+			return fmt.Errorf("task %s failed: %v", p.task.ID, err)
+		}
+		run.commitPrepared(p.task)
+		committed = append(committed, p.task.ID)
+	}
+	return nil
+}
+
+// commitPrepared 把已提交的任务标记为完成
+func (run *runContext) commitPrepared(task *Task) {
+	run.report.update(task.ID, func(tr *TaskReport) {
+		if tr.Status == TaskStatusPrepared {
+			tr.Status = TaskStatusCompleted
+		}
+	})
+}
+
+// abortPrepared 放弃已准备的任务，Abort 失败只记录日志
+func (run *runContext) abortPrepared(ctx context.Context, results *ordinalResults, prepared []preparedTask, reason error) {
+	for _, p := range prepared {
+		if err := p.task.TwoPhase.Abort(ctx, p.prepared); err != nil {
+			run.logger.Warn("two-phase abort failed", slog.String("task_id", p.task.ID), slog.Any("error", err))
+		}
+		run.failPrepared(results, p.task, fmt.Errorf("%w: %v", ErrTwoPhaseAborted, reason))
+	}
+}
+
+// failPrepared 把已准备的任务标记为失败，并从结果中移除其句柄
+func (run *runContext) failPrepared(results *ordinalResults, task *Task, err error) {
+	results.clear(task.ID)
+	run.report.update(task.ID, func(tr *TaskReport) {
+		// 准备之后因其他原因（如结果内存超限）已失败的任务保留原来的错误
+		if tr.Status != TaskStatusFailed {
+			tr.Status = TaskStatusFailed
+			tr.Error = err
+		}
+	})
+}
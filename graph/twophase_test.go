@@ -0,0 +1,84 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+)
+
+// fakeTwoPhase 是记录提交的两阶段实现，commitErr 不为空时提交失败
+type fakeTwoPhase struct {
+	mu        *sync.Mutex
+	committed map[string]bool
+	id        string
+	commitErr error
+}
+
+func (f *fakeTwoPhase) Prepare(ctx context.Context, inputs map[string]interface{}) (interface{}, error) {
+	return f.id, nil
+}
+
+func (f *fakeTwoPhase) Commit(ctx context.Context, prepared interface{}) error {
+	if f.commitErr != nil {
+		return f.commitErr
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.committed[f.id] = true
+	return nil
+}
+
+func (f *fakeTwoPhase) Abort(ctx context.Context, prepared interface{}) error {
+	return nil
+}
+
+// 两阶段任务在提交后才进入 completed，提交失败或被放弃的任务只产生一次 failed 结束事件
+func TestTwoPhaseCompletesOnlyAfterCommit(t *testing.T) {
+	var mu sync.Mutex
+	committed := make(map[string]bool)
+	tg := NewTaskGraph()
+	for _, task := range []*Task{
+		{ID: "a", TwoPhase: &fakeTwoPhase{mu: &mu, committed: committed, id: "a"}},
+		{ID: "b", TwoPhase: &fakeTwoPhase{mu: &mu, committed: committed, id: "b", commitErr: errors.New("constraint violated")}},
+		{ID: "c", TwoPhase: &fakeTwoPhase{mu: &mu, committed: committed, id: "c"}},
+	} {
+		if err := tg.AddTask(task); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var ended []TaskReport
+	onTaskEnd := func(tr TaskReport) {
+		mu.Lock()
+		defer mu.Unlock()
+		if tr.Status == TaskStatusCompleted && !committed[tr.ID] {
+			t.Errorf("task %s completed before it was committed", tr.ID)
+		}
+		ended = append(ended, tr)
+	}
+	report, err := tg.ExecuteWithReport(context.Background(), ExecuteOptions{WorkerCount: 3, OnTaskEnd: onTaskEnd})
+	if err == nil {
+		t.Fatal("expected the commit failure to fail the run")
+	}
+
+	want := map[string]TaskStatus{"a": TaskStatusCompleted, "b": TaskStatusFailed, "c": TaskStatusFailed}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(ended) != len(want) {
+		t.Fatalf("expected one end event per task, got %+v", ended)
+	}
+	for _, tr := range ended {
+		if tr.Status != want[tr.ID] {
+			t.Errorf("task %s ended as %s, expected %s", tr.ID, tr.Status, want[tr.ID])
+		}
+	}
+	for id, status := range want {
+		if got := report.Tasks[id].Status; got != status {
+			t.Errorf("task %s is %s in the report, expected %s", id, got, status)
+		}
+	}
+	if !errors.Is(report.Tasks["c"].Error, ErrTwoPhaseAborted) {
+		t.Errorf("task c should be aborted, got %v", report.Tasks["c"].Error)
+	}
+}
@@ -0,0 +1,106 @@
+package graph
+
+import (
+	"context"
+	"os"
+	"runtime"
+	"runtime/metrics"
+	"sync"
+	"time"
+)
+
+// ResourceUsage 是任务所有尝试合计的资源使用情况，通过 ExecuteOptions.ResourceUsage 开启
+type ResourceUsage struct {
+	// UserTime 和 SystemTime 是执行 Execute 的 goroutine 所在线程的用户态和内核态 CPU 时间（仅 Linux），
+	// 加上 Subprocess 隔离时子进程的 CPU 时间；任务自己启动的 goroutine 和 Recover 隔离时的执行不计入
+	UserTime   time.Duration
+	SystemTime time.Duration
+	// AllocBytes 是执行期间进程堆分配字节数的增量，同时执行的任务会互相计入，只能作为近似值
+	AllocBytes int64
+	// Goroutines 是执行结束时相对开始时的 goroutine 数变化，持续为正说明任务泄漏了 goroutine
+	Goroutines int
+	// MaxRSS 是子进程的最大常驻内存（字节），仅 Subprocess 隔离且在 Linux 上有效
+	MaxRSS int64
+}
+
+// CPUTime 返回用户态和内核态 CPU 时间之和
+func (u *ResourceUsage) CPUTime() time.Duration {
+	return u.UserTime + u.SystemTime
+}
+
+// usageRecorder 在任务的尝试之间累计资源使用，子进程结束时也会写入
+type usageRecorder struct {
+	mu    sync.Mutex
+	usage ResourceUsage
+}
+
+type usageKey struct{}
+
+func withUsage(ctx context.Context, u *usageRecorder) context.Context {
+	return context.WithValue(ctx, usageKey{}, u)
+}
+
+func usageFrom(ctx context.Context) *usageRecorder {
+	u, _ := ctx.Value(usageKey{}).(*usageRecorder)
+	return u
+}
+
+// addProcess 记录已结束子进程的资源使用
+func (u *usageRecorder) addProcess(ps *os.ProcessState) {
+	if u == nil || ps == nil {
+		return
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.usage.UserTime += ps.UserTime()
+	u.usage.SystemTime += ps.SystemTime()
+	if rss := processMaxRSS(ps); rss > u.usage.MaxRSS {
+		u.usage.MaxRSS = rss
+	}
+}
+
+func (u *usageRecorder) snapshot() *ResourceUsage {
+	if u == nil {
+		return nil
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	usage := u.usage
+	return &usage
+}
+
+const heapAllocsMetric = "/gc/heap/allocs:bytes"
+
+func heapAllocs() int64 {
+	sample := []metrics.Sample{{Name: heapAllocsMetric}}
+	metrics.Read(sample)
+	if sample[0].Value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+	return int64(sample[0].Value.Uint64())
+}
+
+// measure 在当前 goroutine 锁定的线程上执行 fn 并把资源使用计入 u；u 为空时直接执行
+func (u *usageRecorder) measure(fn func()) {
+	if u == nil {
+		fn()
+		return
+	}
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	goroutines, allocs := runtime.NumGoroutine(), heapAllocs()
+	user, sys, threadOK := threadCPUTime()
+	fn()
+	user2, sys2, _ := threadCPUTime()
+	allocs2, goroutines2 := heapAllocs(), runtime.NumGoroutine()
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if threadOK {
+		u.usage.UserTime += user2 - user
+		u.usage.SystemTime += sys2 - sys
+	}
+	u.usage.AllocBytes += allocs2 - allocs
+	u.usage.Goroutines += goroutines2 - goroutines
+}
@@ -0,0 +1,27 @@
+package graph
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// rusageThread 是 getrusage 的 RUSAGE_THREAD，syscall 包未定义
+const rusageThread = 1
+
+// threadCPUTime 返回当前线程的用户态和内核态 CPU 时间
+func threadCPUTime() (user, sys time.Duration, ok bool) {
+	var ru syscall.Rusage
+	if err := syscall.Getrusage(rusageThread, &ru); err != nil {
+		return 0, 0, false
+	}
+	return time.Duration(ru.Utime.Nano()), time.Duration(ru.Stime.Nano()), true
+}
+
+// processMaxRSS 返回已结束子进程的最大常驻内存（字节），Linux 上 ru_maxrss 的单位为 KB
+func processMaxRSS(ps *os.ProcessState) int64 {
+	if ru, ok := ps.SysUsage().(*syscall.Rusage); ok {
+		return ru.Maxrss * 1024
+	}
+	return 0
+}
@@ -0,0 +1,18 @@
+//go:build !linux
+
+package graph
+
+import (
+	"os"
+	"time"
+)
+
+// threadCPUTime 在非 Linux 平台上不可用
+func threadCPUTime() (user, sys time.Duration, ok bool) {
+	return 0, 0, false
+}
+
+// processMaxRSS 在非 Linux 平台上不可用
+func processMaxRSS(ps *os.ProcessState) int64 {
+	return 0
+}
@@ -0,0 +1,221 @@
+package graph
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	utilizationBuckets = 20 // 时间线的分段数
+	utilizationTop     = 5  // 列出的耗时最长任务数
+)
+
+// Utilization 是根据执行报告得出的工作者利用率摘要
+type Utilization struct {
+	Duration  time.Duration            // 运行总耗时
+	Workers   int                      // 报告中出现过的工作者数
+	Busy      time.Duration            // 所有尝试的耗时之和
+	Occupancy float64                  // Busy / (Duration * Workers)，0~1
+	PerWorker map[string]time.Duration // 工作者 -> 忙碌时间
+	Timeline  []UtilizationBucket      // 按时间等分的占用情况
+	Longest   []TaskDuration           // 耗时最长的任务，从长到短
+	Layers    []LayerUtilization       // 每层的耗时和等待层屏障的空闲时间，按层号排列
+}
+
+// UtilizationBucket 是时间线上的一段
+type UtilizationBucket struct {
+	Offset   time.Duration // 相对运行开始的时间
+	Duration time.Duration
+	Busy     float64 // 该段内平均忙碌的工作者数
+}
+
+// TaskDuration 是任务的执行耗时
+type TaskDuration struct {
+	ID        string
+	Duration  time.Duration
+	Attempts  int
+	QueueWait time.Duration // 就绪后等待名额的时间
+}
+
+// LayerUtilization 记录一层任务的执行情况。按层执行时，同层任务全部结束后下一层才开始，
+// Idle 是该层时间内可用工作者（不超过该层任务数）的空闲时间之和，主要由最慢的任务 Straggler 造成；
+// 其他调度方式下各层可能重叠，Idle 仅供参考
+type LayerUtilization struct {
+	Layer     int
+	Tasks     int
+	Offset    time.Duration // 该层第一次尝试开始时相对运行开始的时间
+	Duration  time.Duration // 从该层第一次尝试开始到最后一次尝试结束
+	Straggler string        // 该层最后结束的任务
+	Idle      time.Duration
+}
+
+// span 是一次尝试占用工作者的时间段
+type span struct {
+	start, end time.Time
+}
+
+// Utilization 根据执行报告汇总工作者的占用情况、耗时最长的任务和层屏障造成的空闲；
+// 工作者数按报告中出现过的工作者计算，没有执行任何尝试的运行返回只含 Duration 的摘要
+func (tg *TaskGraph) Utilization(report *ExecutionReport) *Utilization {
+	u := &Utilization{Duration: report.Duration, PerWorker: make(map[string]time.Duration)}
+	var spans []span
+	layers := make(map[int]*LayerUtilization)
+	layerEnds := make(map[int]time.Time)
+	ids := make([]string, 0, len(report.Tasks))
+	for id := range report.Tasks {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		tr := report.Tasks[id]
+		if len(tr.History) == 0 {
+			continue
+		}
+		var taskBusy time.Duration
+		first, last := tr.History[0].StartTime, tr.History[0].EndTime
+		for _, a := range tr.History {
+			spans = append(spans, span{a.StartTime, a.EndTime})
+			u.PerWorker[a.Worker] += a.Duration
+			taskBusy += a.Duration
+			if a.StartTime.Before(first) {
+				first = a.StartTime
+			}
+			if a.EndTime.After(last) {
+				last = a.EndTime
+			}
+		}
+		u.Busy += taskBusy
+		u.Longest = append(u.Longest, TaskDuration{ID: id, Duration: taskBusy, Attempts: len(tr.History), QueueWait: tr.QueueWait})
+
+		layer, ok := tg.taskLayers[id]
+		if !ok {
+			continue
+		}
+		l := layers[layer]
+		if l == nil {
+			l = &LayerUtilization{Layer: layer, Offset: first.Sub(report.StartTime)}
+			layers[layer] = l
+		}
+		l.Tasks++
+		l.Idle -= taskBusy
+		if off := first.Sub(report.StartTime); off < l.Offset {
+			l.Offset = off
+		}
+		if end, ok := layerEnds[layer]; !ok || last.After(end) {
+			layerEnds[layer] = last
+			l.Straggler = id
+		}
+	}
+	if len(spans) == 0 {
+		return u
+	}
+	u.Workers = len(u.PerWorker)
+	if u.Duration > 0 {
+		u.Occupancy = float64(u.Busy) / (float64(u.Duration) * float64(u.Workers))
+	}
+
+	sort.SliceStable(u.Longest, func(i, j int) bool { return u.Longest[i].Duration > u.Longest[j].Duration })
+	if len(u.Longest) > utilizationTop {
+		u.Longest = u.Longest[:utilizationTop]
+	}
+
+	order := make([]int, 0, len(layers))
+	for layer := range layers {
+		order = append(order, layer)
+	}
+	sort.Ints(order)
+	for _, layer := range order {
+		l := layers[layer]
+		l.Duration = layerEnds[layer].Sub(report.StartTime) - l.Offset
+		slots := l.Tasks
+		if slots > u.Workers {
+			slots = u.Workers
+		}
+		l.Idle += l.Duration * time.Duration(slots)
+		if l.Idle < 0 {
+			l.Idle = 0
+		}
+		u.Layers = append(u.Layers, *l)
+	}
+
+	u.Timeline = timeline(report.StartTime, u.Duration, spans)
+	return u
+}
+
+// timeline 把运行时间等分为 utilizationBuckets 段，计算每段的平均占用
+func timeline(start time.Time, total time.Duration, spans []span) []UtilizationBucket {
+	width := total / utilizationBuckets
+	if width <= 0 {
+		return nil
+	}
+	buckets := make([]UtilizationBucket, utilizationBuckets)
+	for i := range buckets {
+		buckets[i].Offset = time.Duration(i) * width
+		buckets[i].Duration = width
+	}
+	for _, s := range spans {
+		from, to := s.start.Sub(start), s.end.Sub(start)
+		for i := range buckets {
+			lo, hi := buckets[i].Offset, buckets[i].Offset+width
+			if from > lo {
+				lo = from
+			}
+			if to < hi {
+				hi = to
+			}
+			if hi > lo {
+				buckets[i].Busy += float64(hi-lo) / float64(width)
+			}
+		}
+	}
+	return buckets
+}
+
+// String 以适合打印到终端的文本格式输出摘要
+func (u *Utilization) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "run %v, %d workers, %.0f%% occupied (%v busy)\n",
+		u.Duration.Round(time.Millisecond), u.Workers, u.Occupancy*100, u.Busy.Round(time.Millisecond))
+	if len(u.Timeline) > 0 && u.Workers > 0 {
+		const bars = " ▁▂▃▄▅▆▇█"
+		levels := []rune(bars)
+		var line strings.Builder
+		for _, bucket := range u.Timeline {
+			level := int(bucket.Busy / float64(u.Workers) * float64(len(levels)-1))
+			if level >= len(levels) {
+				level = len(levels) - 1
+			}
+			line.WriteRune(levels[level])
+		}
+		fmt.Fprintf(&b, "occupancy |%s|\n", line.String())
+	}
+	if len(u.Longest) > 0 {
+		b.WriteString("longest tasks:\n")
+		for _, t := range u.Longest {
+			fmt.Fprintf(&b, "  %-24s %v", t.ID, t.Duration.Round(time.Millisecond))
+			if t.Attempts > 1 {
+				fmt.Fprintf(&b, " (%d attempts)", t.Attempts)
+			}
+			if t.QueueWait >= time.Millisecond {
+				fmt.Fprintf(&b, ", queued %v", t.QueueWait.Round(time.Millisecond))
+			}
+			b.WriteString("\n")
+		}
+	}
+	var idle []LayerUtilization
+	for _, l := range u.Layers {
+		if l.Idle > 0 && l.Tasks > 1 {
+			idle = append(idle, l)
+		}
+	}
+	if len(idle) > 0 {
+		b.WriteString("idle at layer barriers:\n")
+		for _, l := range idle {
+			fmt.Fprintf(&b, "  layer %d: %d tasks in %v, %v worker time idle waiting for %s\n",
+				l.Layer, l.Tasks, l.Duration.Round(time.Millisecond), l.Idle.Round(time.Millisecond), l.Straggler)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,118 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// TaskTypeWASM 是内置的 WASM 任务类型名称，定义文件中的配置为
+// {"path": "step.wasm", "entry": "run", "capabilities": ["log"], "memory_limit": 67108864}
+const TaskTypeWASM = "wasm"
+
+// WASMCapability 是 WASM 模块可以使用的一组宿主函数
+type WASMCapability string
+
+const (
+	WASMCapabilityLog    WASMCapability = "log"    // 写入任务日志
+	WASMCapabilityClock  WASMCapability = "clock"  // 读取墙上时间和单调时钟
+	WASMCapabilityRandom WASMCapability = "random" // 读取随机数
+	WASMCapabilityHTTP   WASMCapability = "http"   // 发起出站 HTTP 请求
+)
+
+// WASMModule 描述以 WASM 沙箱方式执行的任务：输入以 JSON 传给模块的 Entry 导出函数，
+// 模块返回 JSON 格式的结果。模块只能调用 Capabilities 中列出的宿主函数，
+// 没有文件系统、环境变量和网络访问，除非显式授予
+type WASMModule struct {
+	Path         string // .wasm 文件路径
+	Entry        string // 导出函数名，为空时为 "run"
+	Capabilities []WASMCapability
+}
+
+// WASMRuntime 执行 WASM 模块，wasm 包提供基于 wazero 的实现。实现必须只向模块导出
+// module.Capabilities 对应的宿主函数，并在 memoryLimit 大于0时限制模块的线性内存
+type WASMRuntime interface {
+	Run(ctx context.Context, module *WASMModule, input []byte, memoryLimit int64) ([]byte, error)
+}
+
+var (
+	wasmRuntimeMu sync.RWMutex
+	wasmRuntime   WASMRuntime
+)
+
+// RegisterWASMRuntime 设置执行 WASM 任务使用的运行时，通常在运行时适配包的 init 中调用，
+// 如导入 workflow/wasm 包时注册基于 wazero 的运行时
+func RegisterWASMRuntime(rt WASMRuntime) {
+	wasmRuntimeMu.Lock()
+	defer wasmRuntimeMu.Unlock()
+	wasmRuntime = rt
+}
+
+// run 在注册的 WASM 运行时中执行模块
+func (m *WASMModule) run(ctx context.Context, inputs map[string]interface{}, memoryLimit int64) (interface{}, error) {
+	wasmRuntimeMu.RLock()
+	rt := wasmRuntime
+	wasmRuntimeMu.RUnlock()
+	if rt == nil {
+		return nil, fmt.Errorf("no WASM runtime registered for module %s, import workflow/wasm", m.Path)
+	}
+
+	payload, err := json.Marshal(inputs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode inputs: %v", err)
+	}
+	out, err := rt.Run(ctx, m, payload, memoryLimit)
+	if err != nil {
+		return nil, fmt.Errorf("wasm module %s failed: %v", m.Path, err)
+	}
+	if len(out) == 0 {
+		return nil, nil
+	}
+	var result interface{}
+	if err := json.Unmarshal(out, &result); err != nil {
+		return nil, fmt.Errorf("failed to decode wasm module output: %v", err)
+	}
+	return result, nil
+}
+
+// wasmTaskType 让定义文件可以直接引用 WASM 模块作为任务
+type wasmTaskType struct{}
+
+func (wasmTaskType) Name() string {
+	return TaskTypeWASM
+}
+
+func (wasmTaskType) ConfigSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type":     "object",
+		"required": []string{"path"},
+		"properties": map[string]interface{}{
+			"path":         map[string]interface{}{"type": "string"},
+			"entry":        map[string]interface{}{"type": "string"},
+			"capabilities": map[string]interface{}{"type": "array"},
+			"memory_limit": map[string]interface{}{"type": "integer"},
+		},
+		"additionalProperties": false,
+	}
+}
+
+func (wasmTaskType) New(config map[string]interface{}) (func(ctx context.Context, inputs map[string]interface{}) (interface{}, error), error) {
+	module := &WASMModule{Path: config["path"].(string)}
+	if entry, ok := config["entry"].(string); ok {
+		module.Entry = entry
+	}
+	caps, _ := config["capabilities"].([]interface{})
+	for _, c := range caps {
+		switch capability := WASMCapability(fmt.Sprint(c)); capability {
+		case WASMCapabilityLog, WASMCapabilityClock, WASMCapabilityRandom, WASMCapabilityHTTP:
+			module.Capabilities = append(module.Capabilities, capability)
+		default:
+			return nil, fmt.Errorf("unknown wasm capability %q", c)
+		}
+	}
+	limit, _ := toFloat(config["memory_limit"])
+	return func(ctx context.Context, inputs map[string]interface{}) (interface{}, error) {
+		return module.run(ctx, inputs, int64(limit))
+	}, nil
+}
@@ -0,0 +1,56 @@
+package graphtest
+
+import (
+	"path/filepath"
+	"testing"
+
+	"workflow/graph"
+)
+
+// AssertContracts 为任务图的每项契约检查（见 graph.TaskGraph.CheckContracts）运行一个子测试，
+// 子测试以检查的名称命名，如 "extract->load/orders/sample0"，可以用 -run 单独运行；
+// 检查失败即消费者无法处理生产者的某个样例输出
+func AssertContracts(t *testing.T, tg *graph.TaskGraph) {
+	t.Helper()
+	checks, err := tg.CheckContracts()
+	if err != nil {
+		t.Fatalf("failed to check contracts: %v", err)
+	}
+	for _, check := range checks {
+		check := check
+		t.Run(check.Name(), func(t *testing.T) {
+			if check.Err != nil {
+				t.Error(check.Err)
+			}
+		})
+	}
+}
+
+// AssertDefinitionContracts 为目录下的每个定义文件（见 graph.DefinitionLoader.DefinitionFiles）
+// 运行一个以文件名命名的子测试，构建任务图后执行 AssertContracts，适合在CI中检查全部工作流定义：
+//
+//	func TestContracts(t *testing.T) {
+//		loader := graph.NewDefinitionLoader(graph.NewRegistry(), graph.WithHandler("extract", extract))
+//		graphtest.AssertDefinitionContracts(t, loader, "workflows")
+//	}
+func AssertDefinitionContracts(t *testing.T, loader *graph.DefinitionLoader, dir string) {
+	t.Helper()
+	paths, err := loader.DefinitionFiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range paths {
+		path := path
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			spec, err := loader.Parse(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tg, err := loader.Build(spec)
+			if err != nil {
+				t.Fatalf("invalid definition %s: %v", path, err)
+			}
+			AssertContracts(t, tg)
+		})
+	}
+}
@@ -0,0 +1,123 @@
+// Package graphtest 提供测试任务图的辅助函数。
+//
+// AssertGolden 把任务图的结构快照（见 graph.TaskGraph.Snapshot）与 golden 文件比较，
+// 结构变化时测试失败并输出差异，防止无意中修改了任务、依赖边或任务设置：
+//
+//	func TestOrdersGraph(t *testing.T) {
+//		graphtest.AssertGolden(t, buildOrdersGraph(), "testdata/orders.golden")
+//	}
+//
+// 有意修改结构后，设置环境变量 WORKFLOW_UPDATE_GOLDEN=1 重新运行测试以更新 golden 文件，
+// 并和代码一起提交，变化会出现在代码审查中
+package graphtest
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"workflow/graph"
+)
+
+// UpdateEnv 是请求更新 golden 文件的环境变量
+const UpdateEnv = "WORKFLOW_UPDATE_GOLDEN"
+
+// AssertGolden 比较任务图的结构快照与 path 处的 golden 文件，不一致或文件不存在时使测试失败；
+// 设置了 UpdateEnv 时改为写入快照，目录不存在时自动创建
+func AssertGolden(t testing.TB, tg *graph.TaskGraph, path string) {
+	t.Helper()
+	got, err := tg.Snapshot()
+	if err != nil {
+		t.Fatalf("failed to snapshot task graph: %v", err)
+	}
+
+	if os.Getenv(UpdateEnv) != "" {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("failed to create golden file directory: %v", err)
+		}
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatalf("failed to write golden file: %v", err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		t.Fatalf("golden file %s does not exist, run the test with %s=1 to create it", path, UpdateEnv)
+	}
+	if err != nil {
+		t.Fatalf("failed to read golden file: %v", err)
+	}
+	// 在 Windows 上检出时 golden 文件的换行可能被转换为 CRLF
+	want = bytes.ReplaceAll(want, []byte("\r\n"), []byte("\n"))
+	if !bytes.Equal(want, got) {
+		t.Errorf("task graph structure differs from %s (- golden, + current), run the test with %s=1 if the change is intended:\n%s",
+			path, UpdateEnv, Diff(string(want), string(got)))
+	}
+}
+
+// Diff 返回两份快照按行比较的差异，只输出变化的行及其所属的任务，
+// 删除的行以 "- " 开头，新增的行以 "+ " 开头
+func Diff(want, got string) string {
+	a, b := splitLines(want), splitLines(got)
+
+	// lcs[i][j] 是 a[i:] 和 b[j:] 的最长公共子序列长度
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var out strings.Builder
+	var task, printed string // 当前所在的任务行和最近输出过的任务行
+	emit := func(prefix, line string) {
+		if task != "" && task != printed && task != line {
+			fmt.Fprintf(&out, "  %s\n", task)
+		}
+		printed = task
+		fmt.Fprintf(&out, "%s%s\n", prefix, line)
+	}
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			if strings.HasPrefix(a[i], "task ") {
+				task = a[i]
+			}
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			if strings.HasPrefix(a[i], "task ") {
+				task = a[i]
+			}
+			emit("- ", a[i])
+			i++
+		default:
+			if strings.HasPrefix(b[j], "task ") {
+				task = b[j]
+			}
+			emit("+ ", b[j])
+			j++
+		}
+	}
+	return out.String()
+}
+
+func splitLines(s string) []string {
+	s = strings.TrimSuffix(s, "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
@@ -0,0 +1,216 @@
+// Package kubernetes 在 Kubernetes 集群中执行任务，并提供把 Workflow 自定义资源协调为运行的控制器。
+// 直接访问 Kubernetes REST API，不依赖 client-go；单独成包是为了让不使用集群的程序无需链接这些代码
+package kubernetes
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// serviceAccountDir 是 Pod 内挂载服务账号凭据的目录
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// Config 是访问 API Server 的配置
+type Config struct {
+	Host      string // 如 "https://10.0.0.1:443"
+	Token     string // Bearer 令牌，为空时不认证
+	CAData    []byte // PEM 格式的 CA 证书，为空时使用系统根证书
+	Namespace string // 未指定命名空间的资源使用的默认命名空间，为空时为 "default"
+	// HTTPClient 设置时直接使用，忽略 CAData
+	HTTPClient *http.Client
+}
+
+// InClusterConfig 读取 Pod 内挂载的服务账号凭据和 KUBERNETES_SERVICE_HOST/PORT 环境变量
+func InClusterConfig() (*Config, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, fmt.Errorf("not running in a cluster: KUBERNETES_SERVICE_HOST and KUBERNETES_SERVICE_PORT must be set")
+	}
+	token, err := os.ReadFile(filepath.Join(serviceAccountDir, "token"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read service account token: %v", err)
+	}
+	ca, err := os.ReadFile(filepath.Join(serviceAccountDir, "ca.crt"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read service account CA: %v", err)
+	}
+	cfg := &Config{
+		Host:   "https://" + strings.TrimSpace(host) + ":" + strings.TrimSpace(port),
+		Token:  strings.TrimSpace(string(token)),
+		CAData: ca,
+	}
+	if ns, err := os.ReadFile(filepath.Join(serviceAccountDir, "namespace")); err == nil {
+		cfg.Namespace = strings.TrimSpace(string(ns))
+	}
+	return cfg, nil
+}
+
+// Client 是 Kubernetes REST API 的最小客户端，可被多个 goroutine 并发使用
+type Client struct {
+	host       string
+	token      string
+	namespace  string
+	httpClient *http.Client
+}
+
+// NewClient 根据配置创建客户端
+func NewClient(cfg *Config) (*Client, error) {
+	if cfg.Host == "" {
+		return nil, fmt.Errorf("kubernetes api host is required")
+	}
+	c := &Client{
+		host:       strings.TrimRight(cfg.Host, "/"),
+		token:      cfg.Token,
+		namespace:  cfg.Namespace,
+		httpClient: cfg.HTTPClient,
+	}
+	if c.namespace == "" {
+		c.namespace = "default"
+	}
+	if c.httpClient == nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		if len(cfg.CAData) > 0 {
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(cfg.CAData) {
+				return nil, fmt.Errorf("invalid kubernetes CA data")
+			}
+			transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+		}
+		c.httpClient = &http.Client{Transport: transport}
+	}
+	return c, nil
+}
+
+// Namespace 返回客户端的默认命名空间
+func (c *Client) Namespace() string {
+	return c.namespace
+}
+
+// StatusError 是 API Server 返回的错误
+type StatusError struct {
+	Code    int
+	Reason  string // 如 "NotFound"、"AlreadyExists"、"Conflict"
+	Message string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("kubernetes api returned %d %s: %s", e.Code, e.Reason, e.Message)
+}
+
+// IsNotFound 判断错误是否表示资源不存在
+func IsNotFound(err error) bool {
+	se, ok := err.(*StatusError)
+	return ok && se.Code == http.StatusNotFound
+}
+
+// IsConflict 判断错误是否表示资源版本冲突，调用方应重新读取后重试
+func IsConflict(err error) bool {
+	se, ok := err.(*StatusError)
+	return ok && se.Code == http.StatusConflict
+}
+
+// do 发送请求，in 不为空时以 contentType 编码为 JSON；out 不为空时解码 JSON 响应
+func (c *Client) do(ctx context.Context, method, path, contentType string, in, out interface{}) error {
+	body, err := c.raw(ctx, method, path, contentType, in)
+	if err != nil {
+		return err
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("failed to decode %s %s response: %v", method, path, err)
+	}
+	return nil
+}
+
+// raw 发送请求并返回响应体，非 2xx 响应返回 *StatusError
+func (c *Client) raw(ctx context.Context, method, path, contentType string, in interface{}) ([]byte, error) {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode request: %v", err)
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.host+path, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	if in != nil {
+		if contentType == "" {
+			contentType = "application/json"
+		}
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s failed: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %v", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		se := &StatusError{Code: resp.StatusCode, Message: strings.TrimSpace(string(data))}
+		var status struct {
+			Reason  string `json:"reason"`
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(data, &status) == nil && status.Message != "" {
+			se.Reason, se.Message = status.Reason, status.Message
+		}
+		return nil, se
+	}
+	return data, nil
+}
+
+// ObjectMeta 是资源元数据中用到的字段
+type ObjectMeta struct {
+	Name              string            `json:"name,omitempty"`
+	GenerateName      string            `json:"generateName,omitempty"`
+	Namespace         string            `json:"namespace,omitempty"`
+	UID               string            `json:"uid,omitempty"`
+	ResourceVersion   string            `json:"resourceVersion,omitempty"`
+	Generation        int64             `json:"generation,omitempty"`
+	Labels            map[string]string `json:"labels,omitempty"`
+	Annotations       map[string]string `json:"annotations,omitempty"`
+	CreationTimestamp *time.Time        `json:"creationTimestamp,omitempty"`
+	DeletionTimestamp *time.Time        `json:"deletionTimestamp,omitempty"`
+	Finalizers        []string          `json:"finalizers,omitempty"`
+}
+
+// labelValue 把任意字符串转换为合法的标签值：只保留字母、数字、'-'、'_' 和 '.'，最长63个字符，首尾为字母或数字
+func labelValue(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			b.WriteRune(r)
+		default:
+			b.WriteByte('-')
+		}
+	}
+	v := b.String()
+	if len(v) > 63 {
+		v = v[:63]
+	}
+	return strings.Trim(v, "-_.")
+}
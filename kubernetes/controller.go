@@ -0,0 +1,338 @@
+package kubernetes
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"workflow/graph"
+)
+
+// Workflow 自定义资源的组、版本和资源名
+const (
+	Group    = "workflow.io"
+	Version  = "v1alpha1"
+	Resource = "workflows"
+)
+
+// Workflow 资源的阶段
+const (
+	PhasePending   = "Pending"
+	PhaseRunning   = "Running"
+	PhaseSucceeded = "Succeeded"
+	PhaseFailed    = "Failed"
+	PhaseSuspended = "Suspended"
+)
+
+// crdManifest 是 Workflow 自定义资源的定义
+//
+//go:embed crd.yaml
+var crdManifest []byte
+
+// CRDManifest 返回 Workflow 自定义资源的 CustomResourceDefinition，可直接 kubectl apply
+func CRDManifest() []byte {
+	return crdManifest
+}
+
+// Workflow 是 workflow.io/v1alpha1 的 Workflow 资源
+type Workflow struct {
+	APIVersion string         `json:"apiVersion,omitempty"`
+	Kind       string         `json:"kind,omitempty"`
+	Metadata   ObjectMeta     `json:"metadata"`
+	Spec       WorkflowSpec   `json:"spec"`
+	Status     WorkflowStatus `json:"status,omitempty"`
+}
+
+// WorkflowSpec 声明要执行的工作流：Tasks 为内联定义，以资源名注册；
+// 或以 Workflow 引用引擎中已注册的工作流。每次修改 spec 都会开始一次新的运行
+type WorkflowSpec struct {
+	Namespace string                 `json:"namespace,omitempty"` // 引擎命名空间，为空时为资源所在的命名空间
+	Workflow  string                 `json:"workflow,omitempty"`
+	Tasks     []graph.TaskSpec       `json:"tasks,omitempty"`
+	Params    map[string]interface{} `json:"params,omitempty"`
+	Suspend   bool                   `json:"suspend,omitempty"` // 为 true 时不开始新的运行
+}
+
+// WorkflowStatus 是控制器写回的状态
+type WorkflowStatus struct {
+	ObservedGeneration int64                       `json:"observedGeneration,omitempty"`
+	Phase              string                      `json:"phase,omitempty"`
+	Version            int                         `json:"version,omitempty"` // 运行使用的工作流版本
+	RunID              string                      `json:"runID,omitempty"`
+	StartTime          *time.Time                  `json:"startTime,omitempty"`
+	CompletionTime     *time.Time                  `json:"completionTime,omitempty"`
+	Message            string                      `json:"message,omitempty"`
+	Tasks              map[string]graph.TaskStatus `json:"tasks,omitempty"`
+}
+
+type workflowList struct {
+	Items []Workflow `json:"items"`
+}
+
+// Starter 执行工作流的一次运行，engine.Engine 实现了该接口
+type Starter interface {
+	Start(ctx context.Context, namespace, workflow string, opts graph.ExecuteOptions, extra ...graph.ExecuteOption) (*graph.ExecutionReport, error)
+}
+
+// Controller 把 Workflow 资源协调为引擎中的运行：spec 的每个新 generation 注册内联定义（如有）
+// 并开始一次运行，运行状态和各任务的状态写回资源的 status。
+// 控制器按固定间隔列出资源进行协调，同一时刻只应运行一个副本
+type Controller struct {
+	client    *Client
+	registry  *graph.Registry
+	loader    *graph.DefinitionLoader
+	starter   Starter
+	namespace string // 为空时协调所有命名空间的资源
+	interval  time.Duration
+	logger    *slog.Logger
+
+	mu      sync.Mutex
+	running map[string]string // 资源 UID -> 执行中的 run_id
+}
+
+// ControllerOption 定义控制器的构造选项
+type ControllerOption func(*Controller)
+
+// WithWatchNamespace 只协调指定命名空间中的资源
+func WithWatchNamespace(namespace string) ControllerOption {
+	return func(c *Controller) {
+		c.namespace = namespace
+	}
+}
+
+// WithResyncInterval 设置列出资源进行协调的间隔，默认10秒
+func WithResyncInterval(d time.Duration) ControllerOption {
+	return func(c *Controller) {
+		if d > 0 {
+			c.interval = d
+		}
+	}
+}
+
+// WithControllerLogger 设置控制器日志器
+func WithControllerLogger(logger *slog.Logger) ControllerOption {
+	return func(c *Controller) {
+		c.logger = logger
+	}
+}
+
+// NewController 创建控制器：内联定义通过 loader 构建并注册到 registry，运行通过 starter 执行，
+// 通常分别为 Engine.Definitions()、Engine.Registry() 和 Engine 本身
+func NewController(client *Client, registry *graph.Registry, loader *graph.DefinitionLoader, starter Starter, opts ...ControllerOption) *Controller {
+	c := &Controller{
+		client:   client,
+		registry: registry,
+		loader:   loader,
+		starter:  starter,
+		interval: 10 * time.Second,
+		logger:   slog.Default(),
+		running:  make(map[string]string),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Run 按间隔协调资源直到 ctx 结束；已开始的运行不随 ctx 取消，由引擎的关闭流程排空
+func (c *Controller) Run(ctx context.Context) error {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		if err := c.ReconcileAll(ctx); err != nil && ctx.Err() == nil {
+			c.logger.Warn("failed to reconcile workflows", slog.Any("error", err))
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// ReconcileAll 列出并协调所有资源，单个资源协调失败时记录警告并继续
+func (c *Controller) ReconcileAll(ctx context.Context) error {
+	path := "/apis/" + Group + "/" + Version + "/" + Resource
+	if c.namespace != "" {
+		path = "/apis/" + Group + "/" + Version + "/namespaces/" + url.PathEscape(c.namespace) + "/" + Resource
+	}
+	var list workflowList
+	if err := c.client.do(ctx, "GET", path, "", nil, &list); err != nil {
+		return fmt.Errorf("failed to list workflows: %v", err)
+	}
+	for i := range list.Items {
+		wf := &list.Items[i]
+		if err := c.Reconcile(ctx, wf); err != nil {
+			c.logger.Warn("failed to reconcile workflow", slog.String("resource", wf.Metadata.Namespace+"/"+wf.Metadata.Name), slog.Any("error", err))
+		}
+	}
+	return nil
+}
+
+// Reconcile 协调单个资源
+func (c *Controller) Reconcile(ctx context.Context, wf *Workflow) error {
+	if wf.Metadata.DeletionTimestamp != nil || c.tracking(wf.Metadata.UID) {
+		return nil
+	}
+	if wf.Spec.Suspend {
+		if wf.Status.Phase == PhaseRunning || wf.Status.Phase == PhaseSuspended {
+			return nil
+		}
+		return c.patchStatus(ctx, wf, map[string]interface{}{"phase": PhaseSuspended})
+	}
+	if wf.Status.ObservedGeneration == wf.Metadata.Generation && wf.Status.Phase != PhaseSuspended {
+		if wf.Status.Phase == PhaseRunning {
+			return c.recover(ctx, wf)
+		}
+		return nil
+	}
+
+	namespace := wf.Spec.Namespace
+	if namespace == "" {
+		namespace = wf.Metadata.Namespace
+	}
+	name, def, err := c.definition(namespace, wf)
+	if err != nil {
+		return c.patchStatus(ctx, wf, map[string]interface{}{
+			"observedGeneration": wf.Metadata.Generation,
+			"phase":              PhaseFailed,
+			"message":            err.Error(),
+			"runID":              nil,
+			"startTime":          nil,
+			"completionTime":     nil,
+			"tasks":              nil,
+		})
+	}
+
+	runID := graph.NewRunID()
+	now := time.Now().UTC()
+	if err := c.patchStatus(ctx, wf, map[string]interface{}{
+		"observedGeneration": wf.Metadata.Generation,
+		"phase":              PhaseRunning,
+		"version":            def.Version,
+		"runID":              runID,
+		"startTime":          now,
+		"completionTime":     nil,
+		"message":            nil,
+		"tasks":              nil,
+	}); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.running[wf.Metadata.UID] = runID
+	c.mu.Unlock()
+	logger := c.logger.With(slog.String("resource", wf.Metadata.Namespace+"/"+wf.Metadata.Name), slog.String("run_id", runID))
+	logger.Info("starting workflow run", slog.String("workflow", name), slog.Int("version", def.Version))
+	go func() {
+		defer func() {
+			c.mu.Lock()
+			delete(c.running, wf.Metadata.UID)
+			c.mu.Unlock()
+		}()
+		runCtx := context.WithoutCancel(ctx)
+		report, err := c.starter.Start(runCtx, namespace, name, graph.ExecuteOptions{RunID: runID, Params: wf.Spec.Params})
+		if perr := c.patchStatus(runCtx, wf, finalStatus(report, err)); perr != nil {
+			logger.Warn("failed to write workflow status", slog.Any("error", perr))
+		}
+	}()
+	return nil
+}
+
+// definition 返回资源要执行的工作流名称和版本，内联定义在此时注册
+func (c *Controller) definition(namespace string, wf *Workflow) (string, *graph.WorkflowDefinition, error) {
+	ns := c.registry.Namespace(namespace)
+	switch {
+	case len(wf.Spec.Tasks) > 0 && wf.Spec.Workflow != "":
+		return "", nil, fmt.Errorf("spec.tasks and spec.workflow are mutually exclusive")
+	case len(wf.Spec.Tasks) > 0:
+		tg, err := c.loader.Build(&graph.DefinitionSpec{Namespace: namespace, Name: wf.Metadata.Name, Tasks: wf.Spec.Tasks})
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid definition: %v", err)
+		}
+		revision := "k8s:" + wf.Metadata.Namespace + "/" + wf.Metadata.Name + "@" + strconv.FormatInt(wf.Metadata.Generation, 10)
+		def, err := ns.Register(wf.Metadata.Name, tg, graph.WithRevision(revision))
+		if err != nil {
+			return "", nil, err
+		}
+		return wf.Metadata.Name, def, nil
+	case wf.Spec.Workflow != "":
+		def, err := ns.Latest(wf.Spec.Workflow)
+		if err != nil {
+			return "", nil, err
+		}
+		return wf.Spec.Workflow, def, nil
+	default:
+		return "", nil, fmt.Errorf("one of spec.tasks or spec.workflow is required")
+	}
+}
+
+// recover 处理控制器重启前开始、本进程没有跟踪的运行：运行记录已结束时写回最终状态
+func (c *Controller) recover(ctx context.Context, wf *Workflow) error {
+	namespace := wf.Spec.Namespace
+	if namespace == "" {
+		namespace = wf.Metadata.Namespace
+	}
+	record, err := c.registry.Namespace(namespace).GetRun(ctx, wf.Status.RunID)
+	if err != nil {
+		return fmt.Errorf("failed to get run %s: %v", wf.Status.RunID, err)
+	}
+	if record.Status == graph.RunStatusRunning {
+		return nil
+	}
+	status := finalStatus(record.Report, nil)
+	if record.Status == graph.RunStatusFailed {
+		status["phase"] = PhaseFailed
+		status["message"] = record.Error
+	}
+	if !record.EndTime.IsZero() {
+		status["completionTime"] = record.EndTime.UTC()
+	}
+	return c.patchStatus(ctx, wf, status)
+}
+
+// tracking 判断资源是否有本进程跟踪的执行中的运行
+func (c *Controller) tracking(uid string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.running[uid]
+	return ok
+}
+
+// finalStatus 根据运行结果生成结束时的状态
+func finalStatus(report *graph.ExecutionReport, err error) map[string]interface{} {
+	status := map[string]interface{}{
+		"phase":          PhaseSucceeded,
+		"completionTime": time.Now().UTC(),
+		"message":        nil,
+	}
+	if err == nil && report != nil && report.Error != nil {
+		err = report.Error
+	}
+	if err != nil {
+		status["phase"] = PhaseFailed
+		status["message"] = err.Error()
+	}
+	if report != nil {
+		tasks := make(map[string]graph.TaskStatus, len(report.Tasks))
+		for id, tr := range report.Tasks {
+			tasks[id] = tr.Status
+		}
+		status["tasks"] = tasks
+	}
+	return status
+}
+
+// patchStatus 以 JSON merge patch 更新资源的 status 子资源，值为 nil 的字段被清除
+func (c *Controller) patchStatus(ctx context.Context, wf *Workflow, status map[string]interface{}) error {
+	path := "/apis/" + Group + "/" + Version + "/namespaces/" + url.PathEscape(wf.Metadata.Namespace) + "/" + Resource + "/" + url.PathEscape(wf.Metadata.Name) + "/status"
+	if err := c.client.do(ctx, "PATCH", path, "application/merge-patch+json", map[string]interface{}{"status": status}, nil); err != nil {
+		return fmt.Errorf("failed to update status of %s/%s: %v", wf.Metadata.Namespace, wf.Metadata.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,445 @@
+package kubernetes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"workflow/graph"
+)
+
+// TaskTypeJob 是以 Kubernetes Job 执行的任务类型名称
+const TaskTypeJob = "kubernetes_job"
+
+const (
+	// EnvInputs 是容器中以 JSON 传入任务输入的环境变量
+	EnvInputs = "WORKFLOW_INPUTS"
+	// EnvRunID 是容器中传入 run_id 的环境变量
+	EnvRunID = "WORKFLOW_RUN_ID"
+
+	// LabelRunID 是 Job 和 Pod 上标记 run_id 的标签
+	LabelRunID = "workflow.io/run-id"
+
+	containerName  = "task"
+	maxInputsBytes = 256 << 10 // 环境变量传入的输入上限，超出时应改为从 ArtifactStore 读取
+	failureLogTail = 20        // 失败时错误信息中附带的日志行数
+)
+
+// JobSpec 描述以 Job 执行的任务
+type JobSpec struct {
+	Namespace      string // 为空时使用客户端的默认命名空间
+	NamePrefix     string // Job 名称前缀，为空时为 "workflow-"
+	Image          string
+	Command        []string
+	Args           []string
+	Env            map[string]string
+	ServiceAccount string
+	// CPU 和 Memory 同时作为容器的 requests 和 limits，如 "500m"、"1Gi"；为空时不设置
+	CPU    string
+	Memory string
+	// BackoffLimit 是 Pod 失败后 Job 的重试次数；任务本身的 Retries 会创建新的 Job
+	BackoffLimit int
+	// ActiveDeadline 大于0时作为 Job 的 activeDeadlineSeconds
+	ActiveDeadline time.Duration
+}
+
+// JobRunner 把任务作为 Kubernetes Job 执行：Job 的容器从 WORKFLOW_INPUTS 环境变量读取 JSON 输入，
+// 把 JSON 结果写为标准输出的最后一行。JobRunner 轮询 Job 直到结束，读取 Pod 日志得到结果；
+// 任务被取消或超时时删除 Job
+type JobRunner struct {
+	client       *Client
+	pollInterval time.Duration
+	keepJobs     bool
+	logLimit     int64
+}
+
+// JobRunnerOption 定义 JobRunner 的构造选项
+type JobRunnerOption func(*JobRunner)
+
+// WithPollInterval 设置查询 Job 状态的间隔，默认2秒
+func WithPollInterval(d time.Duration) JobRunnerOption {
+	return func(r *JobRunner) {
+		if d > 0 {
+			r.pollInterval = d
+		}
+	}
+}
+
+// WithKeepJobs 保留已结束的 Job 和 Pod 以便排查，默认在读取结果后删除
+func WithKeepJobs() JobRunnerOption {
+	return func(r *JobRunner) {
+		r.keepJobs = true
+	}
+}
+
+// WithLogLimit 设置读取 Pod 日志的最大字节数，默认 4MiB
+func WithLogLimit(n int64) JobRunnerOption {
+	return func(r *JobRunner) {
+		if n > 0 {
+			r.logLimit = n
+		}
+	}
+}
+
+// NewJobRunner 创建使用 client 创建 Job 的 JobRunner
+func NewJobRunner(client *Client, opts ...JobRunnerOption) *JobRunner {
+	r := &JobRunner{client: client, pollInterval: 2 * time.Second, logLimit: 4 << 20}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Execute 返回以 Job 执行 spec 的任务函数，可直接作为 graph.Task 的 Execute
+func (r *JobRunner) Execute(spec JobSpec) func(ctx context.Context, inputs map[string]interface{}) (interface{}, error) {
+	return func(ctx context.Context, inputs map[string]interface{}) (interface{}, error) {
+		return r.Run(ctx, spec, inputs)
+	}
+}
+
+// job 是 batch/v1 Job 中用到的字段
+type job struct {
+	APIVersion string     `json:"apiVersion"`
+	Kind       string     `json:"kind"`
+	Metadata   ObjectMeta `json:"metadata"`
+	Spec       jobSpec    `json:"spec"`
+	Status     jobStatus  `json:"status,omitempty"`
+}
+
+type jobSpec struct {
+	BackoffLimit            *int        `json:"backoffLimit,omitempty"`
+	ActiveDeadlineSeconds   *int64      `json:"activeDeadlineSeconds,omitempty"`
+	TTLSecondsAfterFinished *int        `json:"ttlSecondsAfterFinished,omitempty"`
+	Template                podTemplate `json:"template"`
+}
+
+type podTemplate struct {
+	Metadata ObjectMeta `json:"metadata"`
+	Spec     podSpec    `json:"spec"`
+}
+
+type podSpec struct {
+	RestartPolicy      string      `json:"restartPolicy"`
+	ServiceAccountName string      `json:"serviceAccountName,omitempty"`
+	Containers         []container `json:"containers"`
+}
+
+type container struct {
+	Name      string               `json:"name"`
+	Image     string               `json:"image"`
+	Command   []string             `json:"command,omitempty"`
+	Args      []string             `json:"args,omitempty"`
+	Env       []envVar             `json:"env,omitempty"`
+	Resources *resourceRequirement `json:"resources,omitempty"`
+}
+
+type envVar struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type resourceRequirement struct {
+	Requests map[string]string `json:"requests,omitempty"`
+	Limits   map[string]string `json:"limits,omitempty"`
+}
+
+type jobStatus struct {
+	Active     int            `json:"active,omitempty"`
+	Succeeded  int            `json:"succeeded,omitempty"`
+	Failed     int            `json:"failed,omitempty"`
+	Conditions []jobCondition `json:"conditions,omitempty"`
+}
+
+type jobCondition struct {
+	Type    string `json:"type"` // Complete 或 Failed
+	Status  string `json:"status"`
+	Reason  string `json:"reason,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type podList struct {
+	Items []struct {
+		Metadata ObjectMeta `json:"metadata"`
+	} `json:"items"`
+}
+
+// Run 创建 Job 执行任务并等待结束，返回容器标准输出最后一行解码后的 JSON；
+// 没有输出时返回 nil。Job 失败时错误中附带日志的最后几行
+func (r *JobRunner) Run(ctx context.Context, spec JobSpec, inputs map[string]interface{}) (interface{}, error) {
+	if spec.Image == "" {
+		return nil, fmt.Errorf("job image is required")
+	}
+	payload, err := json.Marshal(inputs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode inputs: %v", err)
+	}
+	if len(payload) > maxInputsBytes {
+		return nil, fmt.Errorf("inputs are %d bytes, jobs accept at most %d", len(payload), maxInputsBytes)
+	}
+	namespace := spec.Namespace
+	if namespace == "" {
+		namespace = r.client.Namespace()
+	}
+
+	created, err := r.create(ctx, namespace, spec, payload)
+	if err != nil {
+		return nil, err
+	}
+	name := created.Metadata.Name
+	logger := graph.LoggerFrom(ctx).With(slog.String("job", namespace+"/"+name))
+	logger.Info("kubernetes job created")
+	if !r.keepJobs {
+		defer func() {
+			// 任务被取消时 ctx 已结束，清理使用独立的超时
+			cleanupCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 30*time.Second)
+			defer cancel()
+			if err := r.delete(cleanupCtx, namespace, name); err != nil && !IsNotFound(err) {
+				logger.Warn("failed to delete kubernetes job", slog.Any("error", err))
+			}
+		}()
+	}
+
+	cond, err := r.wait(ctx, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	logs, err := r.logs(ctx, namespace, name)
+	if err != nil {
+		return nil, fmt.Errorf("job %s/%s finished but its logs could not be read: %v", namespace, name, err)
+	}
+	if cond.Type == "Failed" {
+		return nil, fmt.Errorf("job %s/%s failed: %s: %s%s", namespace, name, cond.Reason, cond.Message, logTail(logs))
+	}
+	logger.Info("kubernetes job completed")
+	return decodeResult(logs)
+}
+
+// create 创建 Job 并返回 API Server 分配的名称
+func (r *JobRunner) create(ctx context.Context, namespace string, spec JobSpec, payload []byte) (*job, error) {
+	prefix := spec.NamePrefix
+	if prefix == "" {
+		prefix = "workflow-"
+	}
+	labels := map[string]string{}
+	if runID := labelValue(graph.RunIDFrom(ctx)); runID != "" {
+		labels[LabelRunID] = runID
+	}
+	env := []envVar{{Name: EnvInputs, Value: string(payload)}, {Name: EnvRunID, Value: graph.RunIDFrom(ctx)}}
+	keys := make([]string, 0, len(spec.Env))
+	for k := range spec.Env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		env = append(env, envVar{Name: k, Value: spec.Env[k]})
+	}
+	c := container{Name: containerName, Image: spec.Image, Command: spec.Command, Args: spec.Args, Env: env}
+	if spec.CPU != "" || spec.Memory != "" {
+		res := map[string]string{}
+		if spec.CPU != "" {
+			res["cpu"] = spec.CPU
+		}
+		if spec.Memory != "" {
+			res["memory"] = spec.Memory
+		}
+		c.Resources = &resourceRequirement{Requests: res, Limits: res}
+	}
+	backoff := spec.BackoffLimit
+	// 保留时让 Job 在一天后被集群回收，否则由 Run 立即删除
+	ttl := 24 * 60 * 60
+	j := job{
+		APIVersion: "batch/v1",
+		Kind:       "Job",
+		Metadata:   ObjectMeta{GenerateName: prefix, Namespace: namespace, Labels: labels},
+		Spec: jobSpec{
+			BackoffLimit:            &backoff,
+			TTLSecondsAfterFinished: &ttl,
+			Template: podTemplate{
+				Metadata: ObjectMeta{Labels: labels},
+				Spec: podSpec{
+					RestartPolicy:      "Never",
+					ServiceAccountName: spec.ServiceAccount,
+					Containers:         []container{c},
+				},
+			},
+		},
+	}
+	if spec.ActiveDeadline > 0 {
+		seconds := int64(spec.ActiveDeadline / time.Second)
+		if seconds == 0 {
+			seconds = 1
+		}
+		j.Spec.ActiveDeadlineSeconds = &seconds
+	}
+	var created job
+	if err := r.client.do(ctx, "POST", "/apis/batch/v1/namespaces/"+url.PathEscape(namespace)+"/jobs", "", j, &created); err != nil {
+		return nil, fmt.Errorf("failed to create job: %v", err)
+	}
+	return &created, nil
+}
+
+// wait 轮询 Job 直到出现 Complete 或 Failed 条件
+func (r *JobRunner) wait(ctx context.Context, namespace, name string) (*jobCondition, error) {
+	path := "/apis/batch/v1/namespaces/" + url.PathEscape(namespace) + "/jobs/" + url.PathEscape(name)
+	ticker := time.NewTicker(r.pollInterval)
+	defer ticker.Stop()
+	for {
+		var j job
+		if err := r.client.do(ctx, "GET", path, "", nil, &j); err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return nil, fmt.Errorf("failed to get job %s/%s: %v", namespace, name, err)
+		}
+		for _, c := range j.Status.Conditions {
+			if (c.Type == "Complete" || c.Type == "Failed") && c.Status == "True" {
+				return &c, nil
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// logs 返回 Job 最新创建的 Pod 中任务容器的日志
+func (r *JobRunner) logs(ctx context.Context, namespace, name string) ([]byte, error) {
+	base := "/api/v1/namespaces/" + url.PathEscape(namespace) + "/pods"
+	var pods podList
+	if err := r.client.do(ctx, "GET", base+"?labelSelector="+url.QueryEscape("job-name="+name), "", nil, &pods); err != nil {
+		return nil, err
+	}
+	if len(pods.Items) == 0 {
+		return nil, fmt.Errorf("no pods found for job")
+	}
+	latest := pods.Items[0].Metadata
+	for _, p := range pods.Items[1:] {
+		if p.Metadata.CreationTimestamp != nil && (latest.CreationTimestamp == nil || p.Metadata.CreationTimestamp.After(*latest.CreationTimestamp)) {
+			latest = p.Metadata
+		}
+	}
+	query := url.Values{"container": {containerName}, "limitBytes": {fmt.Sprint(r.logLimit)}}
+	return r.client.raw(ctx, "GET", base+"/"+url.PathEscape(latest.Name)+"/log?"+query.Encode(), "", nil)
+}
+
+// delete 删除 Job 及其 Pod
+func (r *JobRunner) delete(ctx context.Context, namespace, name string) error {
+	path := "/apis/batch/v1/namespaces/" + url.PathEscape(namespace) + "/jobs/" + url.PathEscape(name) + "?propagationPolicy=Background"
+	return r.client.do(ctx, "DELETE", path, "", nil, nil)
+}
+
+// decodeResult 把日志的最后一个非空行解码为任务结果
+func decodeResult(logs []byte) (interface{}, error) {
+	lines := bytes.Split(bytes.TrimSpace(logs), []byte("\n"))
+	last := bytes.TrimSpace(lines[len(lines)-1])
+	if len(last) == 0 {
+		return nil, nil
+	}
+	var result interface{}
+	if err := json.Unmarshal(last, &result); err != nil {
+		return nil, fmt.Errorf("failed to decode job output, the last line of stdout must be JSON: %v", err)
+	}
+	return result, nil
+}
+
+// logTail 返回附加在错误信息后的最后几行日志
+func logTail(logs []byte) string {
+	lines := strings.Split(strings.TrimSpace(string(logs)), "\n")
+	if len(lines) > failureLogTail {
+		lines = lines[len(lines)-failureLogTail:]
+	}
+	tail := strings.Join(lines, "\n")
+	if tail == "" {
+		return ""
+	}
+	return "\n" + tail
+}
+
+// TaskType 返回定义文件中以 type: kubernetes_job 引用的任务类型，需通过 graph.RegisterTaskType 注册。
+// 配置字段与 JobSpec 对应：image、command、args、env、namespace、name_prefix、service_account、
+// cpu、memory、backoff_limit 和 active_deadline（如 "10m"）
+func (r *JobRunner) TaskType() graph.TaskType {
+	return jobTaskType{runner: r}
+}
+
+type jobTaskType struct {
+	runner *JobRunner
+}
+
+func (jobTaskType) Name() string {
+	return TaskTypeJob
+}
+
+func (jobTaskType) ConfigSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type":     "object",
+		"required": []string{"image"},
+		"properties": map[string]interface{}{
+			"image":           map[string]interface{}{"type": "string"},
+			"command":         map[string]interface{}{"type": "array"},
+			"args":            map[string]interface{}{"type": "array"},
+			"env":             map[string]interface{}{"type": "object"},
+			"namespace":       map[string]interface{}{"type": "string"},
+			"name_prefix":     map[string]interface{}{"type": "string"},
+			"service_account": map[string]interface{}{"type": "string"},
+			"cpu":             map[string]interface{}{"type": "string"},
+			"memory":          map[string]interface{}{"type": "string"},
+			"backoff_limit":   map[string]interface{}{"type": "integer"},
+			"active_deadline": map[string]interface{}{"type": "string"},
+		},
+		"additionalProperties": false,
+	}
+}
+
+func (t jobTaskType) New(config map[string]interface{}) (func(ctx context.Context, inputs map[string]interface{}) (interface{}, error), error) {
+	spec := JobSpec{Image: config["image"].(string)}
+	spec.Namespace, _ = config["namespace"].(string)
+	spec.NamePrefix, _ = config["name_prefix"].(string)
+	spec.ServiceAccount, _ = config["service_account"].(string)
+	spec.CPU, _ = config["cpu"].(string)
+	spec.Memory, _ = config["memory"].(string)
+	spec.Command = stringSlice(config["command"])
+	spec.Args = stringSlice(config["args"])
+	if env, ok := config["env"].(map[string]interface{}); ok {
+		spec.Env = make(map[string]string, len(env))
+		for k, v := range env {
+			spec.Env[k] = fmt.Sprint(v)
+		}
+	}
+	switch n := config["backoff_limit"].(type) {
+	case float64:
+		spec.BackoffLimit = int(n)
+	case int64:
+		spec.BackoffLimit = int(n)
+	case int:
+		spec.BackoffLimit = n
+	}
+	if s, ok := config["active_deadline"].(string); ok {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid active_deadline: %v", err)
+		}
+		spec.ActiveDeadline = d
+	}
+	return t.runner.Execute(spec), nil
+}
+
+func stringSlice(v interface{}) []string {
+	items, _ := v.([]interface{})
+	if len(items) == 0 {
+		return nil
+	}
+	out := make([]string, len(items))
+	for i, item := range items {
+		out[i] = fmt.Sprint(item)
+	}
+	return out
+}
@@ -0,0 +1,45 @@
+// Package plugins 从 Go 插件（-buildmode=plugin 构建的 .so 文件）加载任务类型。
+// 单独成包是为了让不使用 Go 插件的程序无需链接动态加载器
+package plugins
+
+import (
+	"fmt"
+	"plugin"
+
+	"workflow/graph"
+)
+
+// Symbol 是插件需要导出的函数名，签名为 func() []graph.TaskType
+const Symbol = "TaskTypes"
+
+// Open 打开 Go 插件并返回其导出的任务类型。插件必须与宿主程序使用相同版本的 Go
+// 和相同版本的 workflow 模块构建
+func Open(path string) ([]graph.TaskType, error) {
+	p, err := plugin.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open plugin %s: %v", path, err)
+	}
+	sym, err := p.Lookup(Symbol)
+	if err != nil {
+		return nil, fmt.Errorf("plugin %s does not export %s: %v", path, Symbol, err)
+	}
+	fn, ok := sym.(func() []graph.TaskType)
+	if !ok {
+		return nil, fmt.Errorf("plugin %s exports %s with type %T, expected func() []graph.TaskType", path, Symbol, sym)
+	}
+	return fn(), nil
+}
+
+// Register 打开 Go 插件并全局注册其导出的任务类型
+func Register(path string) error {
+	types, err := Open(path)
+	if err != nil {
+		return err
+	}
+	for _, t := range types {
+		if err := graph.RegisterTaskType(t); err != nil {
+			return fmt.Errorf("plugin %s: %v", path, err)
+		}
+	}
+	return nil
+}
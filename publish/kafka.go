@@ -0,0 +1,568 @@
+package publish
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"workflow/graph"
+)
+
+// TaskTypeKafka 是发布 Kafka 消息的任务类型名称
+const TaskTypeKafka = "kafka_publish"
+
+// Kafka 协议的请求类型和使用的版本
+const (
+	kafkaProduce          = 0
+	kafkaMetadata         = 3
+	kafkaSaslHandshake    = 17
+	kafkaSaslAuthenticate = 36
+
+	kafkaProduceVersion  = 3
+	kafkaMetadataVersion = 1
+)
+
+// 需要刷新元数据后重试的错误码
+const (
+	kafkaUnknownTopicOrPartition = 3
+	kafkaLeaderNotAvailable      = 5
+	kafkaNotLeaderForPartition   = 6
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// KafkaOptions 是连接 Kafka 集群的选项
+type KafkaOptions struct {
+	Brokers  []string // 引导地址，如 "localhost:9092"
+	ClientID string   // 为空时为 "workflow-engine"
+	// Acks 是需要确认写入的副本数：-1 为全部同步副本（默认），1 为只需 leader
+	Acks    int16
+	TLS     *tls.Config   // 不为空时使用 TLS
+	SASL    *SASLPlain    // 不为空时以 SASL/PLAIN 认证
+	Timeout time.Duration // 连接和每次请求的超时，默认10秒
+}
+
+// SASLPlain 是 SASL/PLAIN 认证的用户名和密码
+type SASLPlain struct {
+	Username string
+	Password string
+}
+
+// Kafka 是只支持发布的 Kafka 客户端：有键的消息按与 Java 客户端默认分区器相同的 murmur2 哈希选择分区，
+// 保证同一个键进入同一个分区；没有键的消息轮流写入各分区。不压缩，不支持幂等和事务生产者。
+// 可被多个 goroutine 并发使用：每个 broker 一个连接，同一连接上的请求依次执行，不同 broker 的请求互不阻塞
+type Kafka struct {
+	opts   KafkaOptions
+	next   atomic.Uint32
+	corrID atomic.Int32
+
+	mu      sync.Mutex       // 保护 brokers、leaders 和 conns，不在网络读写期间持有
+	brokers map[int32]string // node_id -> 地址
+	leaders map[string][]int32
+	conns   map[string]*kafkaConn
+}
+
+// kafkaConn 是到一个 broker 的连接，mu 在建立连接和一次请求的读写期间持有
+type kafkaConn struct {
+	mu   sync.Mutex
+	conn net.Conn // 为 nil 时尚未建立或已因错误关闭
+	r    *bufio.Reader
+}
+
+// NewKafka 创建 Kafka 发布者，连接在第一次发布时建立
+func NewKafka(opts KafkaOptions) (*Kafka, error) {
+	if len(opts.Brokers) == 0 {
+		return nil, fmt.Errorf("at least one kafka broker is required")
+	}
+	if opts.ClientID == "" {
+		opts.ClientID = "workflow-engine"
+	}
+	if opts.Acks == 0 {
+		opts.Acks = -1
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	return &Kafka{opts: opts, conns: make(map[string]*kafkaConn)}, nil
+}
+
+// TaskType 返回 kafka_publish 任务类型
+func (k *Kafka) TaskType() graph.TaskType {
+	return NewTaskType(TaskTypeKafka, k)
+}
+
+// KafkaError 是 broker 返回的错误码
+type KafkaError struct {
+	Code int16
+}
+
+func (e *KafkaError) Error() string {
+	return "kafka error code " + strconv.Itoa(int(e.Code))
+}
+
+// Publish 把消息写入主题的一个分区，返回分区和偏移量；分区 leader 变化时刷新元数据重试一次
+func (k *Kafka) Publish(ctx context.Context, msg Message) (map[string]interface{}, error) {
+	var lastErr error
+	for attempt := 0; attempt < 2; attempt++ {
+		k.mu.Lock()
+		known := k.leaders[msg.Topic] != nil
+		k.mu.Unlock()
+		if attempt > 0 || !known {
+			if err := k.refreshMetadata(ctx, msg.Topic); err != nil {
+				return nil, err
+			}
+		}
+		partition, addr, err := k.leader(msg)
+		if err != nil {
+			return nil, err
+		}
+		if addr == "" {
+			lastErr = fmt.Errorf("leader of %s/%d is not available", msg.Topic, partition)
+			continue
+		}
+		offset, err := k.produce(ctx, addr, msg, partition)
+		if err == nil {
+			return map[string]interface{}{"topic": msg.Topic, "partition": partition, "offset": offset}, nil
+		}
+		lastErr = err
+		if ke, ok := err.(*KafkaError); !ok || (ke.Code != kafkaNotLeaderForPartition && ke.Code != kafkaLeaderNotAvailable && ke.Code != kafkaUnknownTopicOrPartition) {
+			return nil, err
+		}
+	}
+	return nil, lastErr
+}
+
+// leader 按已知的元数据选择消息写入的分区，返回分区和其 leader 的地址，leader 未知时地址为空
+func (k *Kafka) leader(msg Message) (int32, string, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	partitions := k.leaders[msg.Topic]
+	if len(partitions) == 0 {
+		return 0, "", fmt.Errorf("topic %s has no partitions", msg.Topic)
+	}
+	var partition int32
+	if len(msg.Key) > 0 {
+		partition = int32((murmur2(msg.Key) & 0x7fffffff) % uint32(len(partitions)))
+	} else {
+		partition = int32(k.next.Add(1) % uint32(len(partitions)))
+	}
+	return partition, k.brokers[partitions[partition]], nil
+}
+
+// refreshMetadata 从任意可用的 broker 读取主题的分区和 leader
+func (k *Kafka) refreshMetadata(ctx context.Context, topic string) error {
+	var lastErr error
+	addrs := append([]string(nil), k.opts.Brokers...)
+	k.mu.Lock()
+	for _, addr := range k.brokers {
+		addrs = append(addrs, addr)
+	}
+	k.mu.Unlock()
+	for _, addr := range addrs {
+		var e kafkaEncoder
+		e.int32(1)
+		e.string(topic)
+		resp, err := k.request(ctx, addr, kafkaMetadata, kafkaMetadataVersion, e.buf)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		d := kafkaDecoder{buf: resp}
+		brokers := make(map[int32]string)
+		for i, n := 0, d.int32(); i < int(n) && d.err == nil; i++ {
+			id, host, port := d.int32(), d.string(), d.int32()
+			d.nullableString() // rack
+			brokers[id] = net.JoinHostPort(host, strconv.Itoa(int(port)))
+		}
+		d.int32() // controller_id
+		leaders := make(map[string][]int32)
+		for i, n := 0, d.int32(); i < int(n) && d.err == nil; i++ {
+			code, name := d.int16(), d.string()
+			d.int8() // is_internal
+			var parts []int32
+			for j, m := 0, d.int32(); j < int(m) && d.err == nil; j++ {
+				d.int16() // error_code
+				index, leader := d.int32(), d.int32()
+				d.int32Array() // replicas
+				d.int32Array() // isr
+				for len(parts) <= int(index) {
+					parts = append(parts, -1)
+				}
+				parts[index] = leader
+			}
+			if code != 0 && name == topic {
+				return fmt.Errorf("failed to get metadata of topic %s: %v", topic, &KafkaError{Code: code})
+			}
+			leaders[name] = parts
+		}
+		if d.err != nil {
+			return fmt.Errorf("invalid kafka metadata response: %v", d.err)
+		}
+		k.mu.Lock()
+		k.brokers = brokers
+		if k.leaders == nil {
+			k.leaders = make(map[string][]int32)
+		}
+		for name, parts := range leaders {
+			k.leaders[name] = parts
+		}
+		k.mu.Unlock()
+		return nil
+	}
+	return fmt.Errorf("no kafka broker is reachable: %v", lastErr)
+}
+
+// produce 以 Produce v3 写入一条记录，返回偏移量
+func (k *Kafka) produce(ctx context.Context, addr string, msg Message, partition int32) (int64, error) {
+	batch := recordBatch(msg, time.Now())
+	var e kafkaEncoder
+	e.int16(-1) // transactional_id
+	e.int16(k.opts.Acks)
+	e.int32(int32(k.opts.Timeout / time.Millisecond))
+	e.int32(1)
+	e.string(msg.Topic)
+	e.int32(1)
+	e.int32(partition)
+	e.bytes(batch)
+	resp, err := k.request(ctx, addr, kafkaProduce, kafkaProduceVersion, e.buf)
+	if err != nil {
+		return 0, err
+	}
+	d := kafkaDecoder{buf: resp}
+	var offset int64 = -1
+	for i, n := 0, d.int32(); i < int(n) && d.err == nil; i++ {
+		d.string()
+		for j, m := 0, d.int32(); j < int(m) && d.err == nil; j++ {
+			d.int32() // partition
+			code := d.int16()
+			offset = d.int64()
+			d.int64() // log_append_time
+			if code != 0 {
+				return 0, &KafkaError{Code: code}
+			}
+		}
+	}
+	if d.err != nil {
+		return 0, fmt.Errorf("invalid kafka produce response: %v", d.err)
+	}
+	return offset, nil
+}
+
+// recordBatch 编码只包含一条记录的 RecordBatch（magic 2）
+func recordBatch(msg Message, now time.Time) []byte {
+	var rec []byte
+	rec = append(rec, 0)              // attributes
+	rec = binary.AppendVarint(rec, 0) // timestamp_delta
+	rec = binary.AppendVarint(rec, 0) // offset_delta
+	if len(msg.Key) == 0 {
+		rec = binary.AppendVarint(rec, -1)
+	} else {
+		rec = binary.AppendVarint(rec, int64(len(msg.Key)))
+		rec = append(rec, msg.Key...)
+	}
+	rec = binary.AppendVarint(rec, int64(len(msg.Value)))
+	rec = append(rec, msg.Value...)
+	names := make([]string, 0, len(msg.Headers))
+	for name := range msg.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	rec = binary.AppendVarint(rec, int64(len(names)))
+	for _, name := range names {
+		rec = binary.AppendVarint(rec, int64(len(name)))
+		rec = append(rec, name...)
+		rec = binary.AppendVarint(rec, int64(len(msg.Headers[name])))
+		rec = append(rec, msg.Headers[name]...)
+	}
+	record := binary.AppendVarint(nil, int64(len(rec)))
+	record = append(record, rec...)
+
+	// CRC 覆盖 attributes 到结尾的部分
+	ts := now.UnixMilli()
+	var body kafkaEncoder
+	body.int16(0) // attributes
+	body.int32(0) // last_offset_delta
+	body.int64(ts)
+	body.int64(ts)
+	body.int64(-1) // producer_id
+	body.int16(-1) // producer_epoch
+	body.int32(-1) // base_sequence
+	body.int32(1)
+	body.buf = append(body.buf, record...)
+
+	var e kafkaEncoder
+	e.int64(0)                                // base_offset
+	e.int32(int32(4 + 1 + 4 + len(body.buf))) // batch_length：partition_leader_epoch 之后的长度
+	e.int32(-1)                               // partition_leader_epoch
+	e.int8(2)                                 // magic
+	e.int32(int32(crc32.Checksum(body.buf, castagnoli)))
+	e.buf = append(e.buf, body.buf...)
+	return e.buf
+}
+
+// request 在到 broker 的连接上发送请求并返回去掉响应头的响应体，需要时建立连接；
+// 读写失败后连接状态未知，关闭连接，下次请求时重连
+func (k *Kafka) request(ctx context.Context, addr string, apiKey, version int16, body []byte) ([]byte, error) {
+	c := k.conn(addr)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		if err := k.dial(ctx, c, addr); err != nil {
+			return nil, err
+		}
+	}
+	resp, err := k.roundTrip(ctx, c, apiKey, version, body)
+	if err != nil {
+		c.close()
+	}
+	return resp, err
+}
+
+func (k *Kafka) roundTrip(ctx context.Context, c *kafkaConn, apiKey, version int16, body []byte) ([]byte, error) {
+	deadline := time.Now().Add(k.opts.Timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	c.conn.SetDeadline(deadline)
+	defer c.conn.SetDeadline(time.Time{})
+
+	corrID := k.corrID.Add(1)
+	var e kafkaEncoder
+	e.int32(0) // 长度，稍后填写
+	e.int16(apiKey)
+	e.int16(version)
+	e.int32(corrID)
+	e.string(k.opts.ClientID)
+	e.buf = append(e.buf, body...)
+	binary.BigEndian.PutUint32(e.buf, uint32(len(e.buf)-4))
+	if _, err := c.conn.Write(e.buf); err != nil {
+		return nil, fmt.Errorf("failed to write to kafka: %v", err)
+	}
+
+	var size [4]byte
+	if _, err := io.ReadFull(c.r, size[:]); err != nil {
+		return nil, fmt.Errorf("failed to read from kafka: %v", err)
+	}
+	resp := make([]byte, binary.BigEndian.Uint32(size[:]))
+	if _, err := io.ReadFull(c.r, resp); err != nil {
+		return nil, fmt.Errorf("failed to read from kafka: %v", err)
+	}
+	if len(resp) < 4 || int32(binary.BigEndian.Uint32(resp)) != corrID {
+		return nil, fmt.Errorf("kafka response does not match the request")
+	}
+	return resp[4:], nil
+}
+
+// conn 返回 broker 对应的连接，连接在第一次请求时建立
+func (k *Kafka) conn(addr string) *kafkaConn {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	c, ok := k.conns[addr]
+	if !ok {
+		c = &kafkaConn{}
+		k.conns[addr] = c
+	}
+	return c
+}
+
+// dial 建立到 broker 的连接并完成 SASL 认证，调用时持有 c.mu
+func (k *Kafka) dial(ctx context.Context, c *kafkaConn, addr string) error {
+	dialer := &net.Dialer{Timeout: k.opts.Timeout}
+	var conn net.Conn
+	var err error
+	if k.opts.TLS != nil {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: k.opts.TLS}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to connect to kafka broker %s: %v", addr, err)
+	}
+	c.conn, c.r = conn, bufio.NewReader(conn)
+	if k.opts.SASL != nil {
+		if err := k.authenticate(ctx, c); err != nil {
+			c.close()
+			return err
+		}
+	}
+	return nil
+}
+
+// authenticate 以 SaslHandshake v1 和 SaslAuthenticate v0 完成 SASL/PLAIN 认证
+func (k *Kafka) authenticate(ctx context.Context, c *kafkaConn) error {
+	var e kafkaEncoder
+	e.string("PLAIN")
+	resp, err := k.roundTrip(ctx, c, kafkaSaslHandshake, 1, e.buf)
+	if err != nil {
+		return err
+	}
+	d := kafkaDecoder{buf: resp}
+	if code := d.int16(); code != 0 {
+		return fmt.Errorf("kafka broker does not accept SASL/PLAIN: %v", &KafkaError{Code: code})
+	}
+
+	e = kafkaEncoder{}
+	e.bytes([]byte("\x00" + k.opts.SASL.Username + "\x00" + k.opts.SASL.Password))
+	resp, err = k.roundTrip(ctx, c, kafkaSaslAuthenticate, 0, e.buf)
+	if err != nil {
+		return err
+	}
+	d = kafkaDecoder{buf: resp}
+	if code := d.int16(); code != 0 {
+		return fmt.Errorf("kafka authentication failed: %s", d.nullableString())
+	}
+	return nil
+}
+
+// close 关闭连接，调用时持有 c.mu
+func (c *kafkaConn) close() {
+	if c.conn != nil {
+		c.conn.Close()
+		c.conn, c.r = nil, nil
+	}
+}
+
+// Close 关闭所有连接，等待连接上正在进行的请求完成
+func (k *Kafka) Close() error {
+	k.mu.Lock()
+	conns := k.conns
+	k.conns = make(map[string]*kafkaConn)
+	k.mu.Unlock()
+	for _, c := range conns {
+		c.mu.Lock()
+		c.close()
+		c.mu.Unlock()
+	}
+	return nil
+}
+
+// murmur2 是 Kafka Java 客户端默认分区器使用的哈希
+func murmur2(data []byte) uint32 {
+	const (
+		seed = 0x9747b28c
+		m    = 0x5bd1e995
+		r    = 24
+	)
+	n := len(data)
+	h := uint32(seed) ^ uint32(n)
+	for i := 0; i+4 <= n; i += 4 {
+		k := binary.LittleEndian.Uint32(data[i:])
+		k *= m
+		k ^= k >> r
+		k *= m
+		h *= m
+		h ^= k
+	}
+	tail := data[n&^3:]
+	switch len(tail) {
+	case 3:
+		h ^= uint32(tail[2]) << 16
+		fallthrough
+	case 2:
+		h ^= uint32(tail[1]) << 8
+		fallthrough
+	case 1:
+		h ^= uint32(tail[0])
+		h *= m
+	}
+	h ^= h >> 13
+	h *= m
+	h ^= h >> 15
+	return h
+}
+
+// kafkaEncoder 按 Kafka 协议编码大端整数和带长度前缀的字符串
+type kafkaEncoder struct {
+	buf []byte
+}
+
+func (e *kafkaEncoder) int8(v int8)   { e.buf = append(e.buf, byte(v)) }
+func (e *kafkaEncoder) int16(v int16) { e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(v)) }
+func (e *kafkaEncoder) int32(v int32) { e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(v)) }
+func (e *kafkaEncoder) int64(v int64) { e.buf = binary.BigEndian.AppendUint64(e.buf, uint64(v)) }
+
+func (e *kafkaEncoder) string(s string) {
+	e.int16(int16(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+func (e *kafkaEncoder) bytes(b []byte) {
+	e.int32(int32(len(b)))
+	e.buf = append(e.buf, b...)
+}
+
+// kafkaDecoder 解码响应，第一个错误之后的读取都返回零值
+type kafkaDecoder struct {
+	buf []byte
+	err error
+}
+
+func (d *kafkaDecoder) next(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || len(d.buf) < n {
+		d.err = fmt.Errorf("unexpected end of response")
+		return nil
+	}
+	b := d.buf[:n]
+	d.buf = d.buf[n:]
+	return b
+}
+
+func (d *kafkaDecoder) int8() int8 {
+	if b := d.next(1); b != nil {
+		return int8(b[0])
+	}
+	return 0
+}
+
+func (d *kafkaDecoder) int16() int16 {
+	if b := d.next(2); b != nil {
+		return int16(binary.BigEndian.Uint16(b))
+	}
+	return 0
+}
+
+func (d *kafkaDecoder) int32() int32 {
+	if b := d.next(4); b != nil {
+		return int32(binary.BigEndian.Uint32(b))
+	}
+	return 0
+}
+
+func (d *kafkaDecoder) int64() int64 {
+	if b := d.next(8); b != nil {
+		return int64(binary.BigEndian.Uint64(b))
+	}
+	return 0
+}
+
+func (d *kafkaDecoder) string() string {
+	return string(d.next(int(d.int16())))
+}
+
+func (d *kafkaDecoder) nullableString() string {
+	n := d.int16()
+	if n < 0 {
+		return ""
+	}
+	return string(d.next(int(n)))
+}
+
+func (d *kafkaDecoder) int32Array() {
+	n := d.int32()
+	if n > 0 {
+		d.next(int(n) * 4)
+	}
+}
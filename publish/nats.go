@@ -0,0 +1,222 @@
+package publish
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"workflow/graph"
+)
+
+// TaskTypeNATS 是发布 NATS 消息的任务类型名称
+const TaskTypeNATS = "nats_publish"
+
+// NATSOptions 是连接 NATS 服务器的选项
+type NATSOptions struct {
+	URL      string // 如 "nats://localhost:4222"，tls:// 时使用 TLS；URL 中的用户信息作为用户名和密码
+	Token    string
+	User     string
+	Password string
+	TLS      *tls.Config   // 服务器要求 TLS 或 URL 为 tls:// 时使用，为空时使用默认配置
+	Timeout  time.Duration // 连接和每次发布等待确认的超时，默认5秒
+}
+
+// NATS 通过单个连接发布消息：每次发布后以 PING/PONG 确认服务器已处理，连接断开时在下次发布时重连。
+// 发布是核心 NATS 的至多一次语义；需要持久化时应发布到 JetStream 的主题
+type NATS struct {
+	opts NATSOptions
+
+	mu      sync.Mutex
+	conn    net.Conn
+	r       *bufio.Reader
+	headers bool // 服务器是否支持消息头
+}
+
+// NewNATS 创建 NATS 发布者，连接在第一次发布时建立
+func NewNATS(opts NATSOptions) (*NATS, error) {
+	u, err := url.Parse(opts.URL)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid nats url %q", opts.URL)
+	}
+	if u.User != nil && opts.User == "" {
+		opts.User = u.User.Username()
+		opts.Password, _ = u.User.Password()
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 5 * time.Second
+	}
+	return &NATS{opts: opts}, nil
+}
+
+// TaskType 返回 nats_publish 任务类型
+func (n *NATS) TaskType() graph.TaskType {
+	return NewTaskType(TaskTypeNATS, n)
+}
+
+type natsInfo struct {
+	TLSRequired bool `json:"tls_required"`
+	Headers     bool `json:"headers"`
+}
+
+// connect 建立连接并完成握手，需持有 mu
+func (n *NATS) connect(ctx context.Context) error {
+	u, _ := url.Parse(n.opts.URL)
+	dialer := &net.Dialer{Timeout: n.opts.Timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", u.Host)
+	if err != nil {
+		return fmt.Errorf("failed to connect to nats: %v", err)
+	}
+	conn.SetDeadline(time.Now().Add(n.opts.Timeout))
+	r := bufio.NewReader(conn)
+	line, err := r.ReadString('\n')
+	if err != nil || !strings.HasPrefix(line, "INFO ") {
+		conn.Close()
+		return fmt.Errorf("unexpected nats greeting %q: %v", strings.TrimSpace(line), err)
+	}
+	var info natsInfo
+	if err := json.Unmarshal([]byte(strings.TrimSpace(line[5:])), &info); err != nil {
+		conn.Close()
+		return fmt.Errorf("invalid nats info: %v", err)
+	}
+	if info.TLSRequired || u.Scheme == "tls" {
+		cfg := n.opts.TLS
+		if cfg == nil {
+			cfg = &tls.Config{MinVersion: tls.VersionTLS12}
+		}
+		if cfg.ServerName == "" {
+			cfg = cfg.Clone()
+			cfg.ServerName = u.Hostname()
+		}
+		tlsConn := tls.Client(conn, cfg)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return fmt.Errorf("nats tls handshake failed: %v", err)
+		}
+		conn, r = tlsConn, bufio.NewReader(tlsConn)
+	}
+
+	connect := map[string]interface{}{
+		"verbose":  false,
+		"pedantic": false,
+		"headers":  info.Headers,
+		"name":     "workflow-engine",
+		"lang":     "go",
+		"version":  "1.0",
+		"protocol": 1,
+	}
+	if n.opts.Token != "" {
+		connect["auth_token"] = n.opts.Token
+	}
+	if n.opts.User != "" {
+		connect["user"], connect["pass"] = n.opts.User, n.opts.Password
+	}
+	b, _ := json.Marshal(connect)
+	if _, err := fmt.Fprintf(conn, "CONNECT %s\r\nPING\r\n", b); err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to send nats connect: %v", err)
+	}
+	n.conn, n.r = conn, r
+	if err := n.awaitPong(); err != nil {
+		n.reset()
+		return err
+	}
+	n.headers = info.Headers
+	n.conn.SetDeadline(time.Time{})
+	return nil
+}
+
+// awaitPong 读取到 PONG 为止，服务器返回 -ERR 时返回错误
+func (n *NATS) awaitPong() error {
+	for {
+		line, err := n.r.ReadString('\n')
+		if err != nil {
+			return fmt.Errorf("failed to read from nats: %v", err)
+		}
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "PONG":
+			return nil
+		case line == "PING":
+			if _, err := n.conn.Write([]byte("PONG\r\n")); err != nil {
+				return fmt.Errorf("failed to write to nats: %v", err)
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			return fmt.Errorf("nats error: %s", strings.Trim(strings.TrimSpace(line[4:]), "'"))
+		}
+	}
+}
+
+func (n *NATS) reset() {
+	if n.conn != nil {
+		n.conn.Close()
+	}
+	n.conn, n.r = nil, nil
+}
+
+// Publish 发布消息并等待服务器确认；消息头需要服务器支持（NATS 2.2 及以上）
+func (n *NATS) Publish(ctx context.Context, msg Message) (map[string]interface{}, error) {
+	if msg.Topic == "" || strings.ContainsAny(msg.Topic, " \t\r\n") {
+		return nil, fmt.Errorf("invalid nats subject %q", msg.Topic)
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.conn == nil {
+		if err := n.connect(ctx); err != nil {
+			return nil, err
+		}
+	}
+	deadline := time.Now().Add(n.opts.Timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	n.conn.SetDeadline(deadline)
+
+	var frame strings.Builder
+	if len(msg.Headers) > 0 && !n.headers {
+		return nil, fmt.Errorf("nats server does not support message headers")
+	}
+	if len(msg.Headers) > 0 {
+		var hdr strings.Builder
+		hdr.WriteString("NATS/1.0\r\n")
+		names := make([]string, 0, len(msg.Headers))
+		for k := range msg.Headers {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+		for _, k := range names {
+			hdr.WriteString(k + ": " + msg.Headers[k] + "\r\n")
+		}
+		hdr.WriteString("\r\n")
+		fmt.Fprintf(&frame, "HPUB %s %d %d\r\n%s", msg.Topic, hdr.Len(), hdr.Len()+len(msg.Value), hdr.String())
+	} else {
+		fmt.Fprintf(&frame, "PUB %s %d\r\n", msg.Topic, len(msg.Value))
+	}
+	frame.Write(msg.Value)
+	frame.WriteString("\r\nPING\r\n")
+	if _, err := n.conn.Write([]byte(frame.String())); err != nil {
+		n.reset()
+		return nil, fmt.Errorf("failed to write to nats: %v", err)
+	}
+	if err := n.awaitPong(); err != nil {
+		n.reset()
+		return nil, err
+	}
+	n.conn.SetDeadline(time.Time{})
+	return map[string]interface{}{"subject": msg.Topic, "bytes": len(msg.Value)}, nil
+}
+
+// Close 关闭连接
+func (n *NATS) Close() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.reset()
+	return nil
+}
@@ -0,0 +1,115 @@
+// Package publish 提供把消息发布到 Kafka、NATS 和 Redis Stream 的任务类型，
+// 使"完成后发出事件"之类的步骤可以在定义文件中声明，而不需要编写处理函数。
+// 各协议直接实现所需的最小子集，不依赖客户端库；SQS 的任务类型见 workflow/aws
+package publish
+
+import (
+	"context"
+	"fmt"
+
+	"workflow/graph"
+)
+
+// Message 是要发布的消息
+type Message struct {
+	Topic   string // Kafka 主题、NATS 主题、Redis Stream 的键或 SQS 队列 URL
+	Key     []byte // 分区键；为空时不设置
+	Value   []byte
+	Headers map[string]string
+}
+
+// Publisher 发布消息，实现需要可被多个 goroutine 并发使用。
+// Publish 返回的元数据（如分区和偏移量、消息ID）作为任务结果
+type Publisher interface {
+	Publish(ctx context.Context, msg Message) (map[string]interface{}, error)
+	Close() error
+}
+
+// NewTaskType 返回以 p 发布消息的任务类型。任务配置：
+//
+//	topic    必填，消息的目标
+//	payload  消息内容的模板（见 graph.PayloadTemplate），为空时为任务输入的 JSON
+//	key      分区键的模板，为空时不设置
+//	headers  固定的消息头
+//
+// 各实现的任务类型名称见 TaskTypeKafka、TaskTypeNATS、TaskTypeRedisStream 和 aws.TaskTypeSQS
+func NewTaskType(name string, p Publisher) graph.TaskType {
+	return publishTaskType{name: name, publisher: p}
+}
+
+type publishTaskType struct {
+	name      string
+	publisher Publisher
+}
+
+func (t publishTaskType) Name() string {
+	return t.name
+}
+
+func (publishTaskType) ConfigSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type":     "object",
+		"required": []string{"topic"},
+		"properties": map[string]interface{}{
+			"topic":   map[string]interface{}{"type": "string"},
+			"payload": map[string]interface{}{"type": "string"},
+			"key":     map[string]interface{}{"type": "string"},
+			"headers": map[string]interface{}{"type": "object"},
+		},
+		"additionalProperties": false,
+	}
+}
+
+func (t publishTaskType) New(config map[string]interface{}) (func(ctx context.Context, inputs map[string]interface{}) (interface{}, error), error) {
+	topic := config["topic"].(string)
+	var payload, key *graph.PayloadTemplate
+	var err error
+	if text, ok := config["payload"].(string); ok {
+		if payload, err = graph.ParsePayloadTemplate(text); err != nil {
+			return nil, err
+		}
+	}
+	if text, ok := config["key"].(string); ok {
+		if key, err = graph.ParsePayloadTemplate(text); err != nil {
+			return nil, fmt.Errorf("invalid key template: %v", err)
+		}
+	}
+	var headers map[string]string
+	if h, ok := config["headers"].(map[string]interface{}); ok {
+		headers = make(map[string]string, len(h))
+		for k, v := range h {
+			headers[k] = fmt.Sprint(v)
+		}
+	}
+	return Execute(t.publisher, topic, payload, key, headers), nil
+}
+
+// Execute 返回以 p 发布消息的任务函数，可直接作为 graph.Task 的 Execute；payload 和 key 可以为空。
+// 在运行中执行时消息头包含 X-Run-ID
+func Execute(p Publisher, topic string, payload, key *graph.PayloadTemplate, headers map[string]string) func(ctx context.Context, inputs map[string]interface{}) (interface{}, error) {
+	return func(ctx context.Context, inputs map[string]interface{}) (interface{}, error) {
+		msg := Message{Topic: topic, Headers: headers}
+		if runID := graph.RunIDFrom(ctx); runID != "" {
+			// 消息头带上 run_id，便于从消费方追溯到运行
+			msg.Headers = make(map[string]string, len(headers)+1)
+			for k, v := range headers {
+				msg.Headers[k] = v
+			}
+			msg.Headers[graph.HeaderRunID] = runID
+		}
+		var err error
+		if msg.Value, err = graph.RenderPayload(ctx, payload, inputs); err != nil {
+			return nil, err
+		}
+		if key != nil {
+			if msg.Key, err = key.Render(ctx, inputs); err != nil {
+				return nil, err
+			}
+		}
+		result, err := p.Publish(ctx, msg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to publish to %s: %v", topic, err)
+		}
+		return result, nil
+	}
+}
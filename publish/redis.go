@@ -0,0 +1,89 @@
+package publish
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"workflow/graph"
+	"workflow/redis"
+)
+
+// TaskTypeRedisStream 是向 Redis Stream 追加消息的任务类型名称
+const TaskTypeRedisStream = "redis_stream_publish"
+
+// Redis Stream 条目中的字段名
+const (
+	RedisFieldPayload = "payload"
+	RedisFieldKey     = "key"
+)
+
+// RedisOptions 是连接 Redis 的选项，连接相关的字段直接使用 redis.Options，Prefix 不用于 Stream
+type RedisOptions struct {
+	redis.Options
+	// MaxLen 大于0时以 MAXLEN ~ 近似裁剪 Stream 的长度
+	MaxLen int64
+}
+
+// RedisStream 以 XADD 向 Stream 追加消息：消息内容写入 payload 字段，
+// 分区键写入 key 字段，消息头作为其余字段。命令通过 redis.Client 执行，连接断开时在下次发布时重连
+type RedisStream struct {
+	opts   RedisOptions
+	client *redis.Client
+}
+
+// NewRedisStream 创建 Redis Stream 发布者，连接在第一次发布时建立
+func NewRedisStream(opts RedisOptions) (*RedisStream, error) {
+	client, err := redis.NewClient(opts.Options)
+	if err != nil {
+		return nil, err
+	}
+	return &RedisStream{opts: opts, client: client}, nil
+}
+
+// TaskType 返回 redis_stream_publish 任务类型
+func (s *RedisStream) TaskType() graph.TaskType {
+	return NewTaskType(TaskTypeRedisStream, s)
+}
+
+// RedisError 是 Redis 返回的错误回复
+type RedisError = redis.Error
+
+// Publish 以 XADD 追加消息，返回条目ID
+func (s *RedisStream) Publish(ctx context.Context, msg Message) (map[string]interface{}, error) {
+	if msg.Topic == "" {
+		return nil, fmt.Errorf("redis stream key is required")
+	}
+	args := []string{"XADD", msg.Topic}
+	if s.opts.MaxLen > 0 {
+		args = append(args, "MAXLEN", "~", strconv.FormatInt(s.opts.MaxLen, 10))
+	}
+	args = append(args, "*", RedisFieldPayload, string(msg.Value))
+	if len(msg.Key) > 0 {
+		args = append(args, RedisFieldKey, string(msg.Key))
+	}
+	names := make([]string, 0, len(msg.Headers))
+	for k := range msg.Headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		if k == RedisFieldPayload || k == RedisFieldKey {
+			return nil, fmt.Errorf("header %s conflicts with a reserved stream field", k)
+		}
+		args = append(args, k, msg.Headers[k])
+	}
+
+	reply, err := s.client.Do(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+	id, _ := reply.(string)
+	return map[string]interface{}{"stream": msg.Topic, "id": id}, nil
+}
+
+// Close 关闭连接
+func (s *RedisStream) Close() error {
+	return s.client.Close()
+}
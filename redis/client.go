@@ -0,0 +1,216 @@
+// Package redis 提供基于 Redis 的协调组件，使多个工作进程共享同一份状态，
+// 如所有进程合计遵守第三方 API 配额的令牌桶（RateStore）和跨进程的任务互斥锁（LockStore），
+// 以及这些组件和 publish.RedisStream 共用的客户端（Client）。
+// 直接实现所需的最小 RESP 子集，不依赖客户端库；需要 Redis 5 或更高版本
+package redis
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Options 是连接 Redis 的选项
+type Options struct {
+	Addr     string // 如 "localhost:6379"
+	Username string // Redis 6 ACL 用户名，为空时只以密码认证
+	Password string
+	DB       int
+	TLS      *tls.Config   // 不为空时使用 TLS
+	Timeout  time.Duration // 连接和每条命令的超时，默认5秒
+	// Prefix 是写入的键的前缀，为空时为 "workflow:"，多个部署共用一个 Redis 时用于区分
+	Prefix string
+}
+
+// Error 是 Redis 返回的错误回复
+type Error struct {
+	Message string
+}
+
+func (e *Error) Error() string {
+	return "redis error: " + e.Message
+}
+
+// Client 通过单个连接顺序执行命令，连接断开时在下次执行命令时重连
+type Client struct {
+	opts Options
+
+	mu   sync.Mutex
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// NewClient 创建客户端，连接在第一次执行命令时建立
+func NewClient(opts Options) (*Client, error) {
+	if opts.Addr == "" {
+		return nil, fmt.Errorf("redis address is required")
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 5 * time.Second
+	}
+	if opts.Prefix == "" {
+		opts.Prefix = "workflow:"
+	}
+	return &Client{opts: opts}, nil
+}
+
+// Do 执行一条命令并返回回复：简单字符串和批量字符串为 string，整数为 int64，数组为 []interface{}，
+// 空回复为 nil；错误回复返回 *Error
+func (c *Client) Do(ctx context.Context, args ...string) (interface{}, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		if err := c.connect(ctx); err != nil {
+			return nil, err
+		}
+	}
+	reply, err := c.command(ctx, args...)
+	if err != nil {
+		if _, ok := err.(*Error); !ok {
+			// 网络错误后连接状态未知，下次重连
+			c.reset()
+		}
+		return nil, err
+	}
+	return reply, nil
+}
+
+// connect 建立连接并完成认证和选择数据库，需持有 mu
+func (c *Client) connect(ctx context.Context) error {
+	dialer := &net.Dialer{Timeout: c.opts.Timeout}
+	var conn net.Conn
+	var err error
+	if c.opts.TLS != nil {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: c.opts.TLS}).DialContext(ctx, "tcp", c.opts.Addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", c.opts.Addr)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to connect to redis: %v", err)
+	}
+	c.conn, c.r = conn, bufio.NewReader(conn)
+	if c.opts.Password != "" {
+		args := []string{"AUTH", c.opts.Password}
+		if c.opts.Username != "" {
+			args = []string{"AUTH", c.opts.Username, c.opts.Password}
+		}
+		if _, err := c.command(ctx, args...); err != nil {
+			c.reset()
+			return fmt.Errorf("redis auth failed: %v", err)
+		}
+	}
+	if c.opts.DB != 0 {
+		if _, err := c.command(ctx, "SELECT", strconv.Itoa(c.opts.DB)); err != nil {
+			c.reset()
+			return fmt.Errorf("failed to select redis db %d: %v", c.opts.DB, err)
+		}
+	}
+	return nil
+}
+
+func (c *Client) reset() {
+	if c.conn != nil {
+		c.conn.Close()
+	}
+	c.conn, c.r = nil, nil
+}
+
+// command 发送命令并读取回复，需持有 mu
+func (c *Client) command(ctx context.Context, args ...string) (interface{}, error) {
+	deadline := time.Now().Add(c.opts.Timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	c.conn.SetDeadline(deadline)
+	defer c.conn.SetDeadline(time.Time{})
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := io.WriteString(c.conn, b.String()); err != nil {
+		return nil, fmt.Errorf("failed to write to redis: %v", err)
+	}
+	return readReply(c.r)
+}
+
+// readReply 读取一个 RESP2 回复；错误回复返回 *Error
+func readReply(r *bufio.Reader) (interface{}, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from redis: %v", err)
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if line == "" {
+		return nil, fmt.Errorf("empty redis reply")
+	}
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, &Error{Message: line[1:]}
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, fmt.Errorf("failed to read from redis: %v", err)
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			if items[i], err = readReply(r); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("unexpected redis reply %q", line)
+}
+
+// script 是通过 EVALSHA 执行的 Lua 脚本
+type script struct {
+	src string
+	sha string
+}
+
+func newScript(src string) script {
+	sum := sha1.Sum([]byte(src))
+	return script{src: src, sha: hex.EncodeToString(sum[:])}
+}
+
+// eval 以键 key 和参数 args 执行脚本，脚本尚未缓存（首次调用或 Redis 重启后）时以 EVAL 执行并缓存
+func (c *Client) eval(ctx context.Context, s script, key string, args ...string) (interface{}, error) {
+	reply, err := c.Do(ctx, append([]string{"EVALSHA", s.sha, "1", key}, args...)...)
+	if rerr, ok := err.(*Error); ok && strings.HasPrefix(rerr.Message, "NOSCRIPT") {
+		reply, err = c.Do(ctx, append([]string{"EVAL", s.src, "1", key}, args...)...)
+	}
+	return reply, err
+}
+
+// Close 关闭连接，之后执行命令时重新连接
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.reset()
+	return nil
+}
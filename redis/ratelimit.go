@@ -0,0 +1,76 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"workflow/graph"
+)
+
+// takeTokenScript 原子地补充并从令牌桶取一个令牌，返回需要等待的微秒数（取到时为0）。
+// 时间取自 Redis 服务器，各工作进程的时钟偏差不影响限流；空闲超过补满所需时间的桶自动过期
+var takeTokenScript = newScript(`
+redis.replicate_commands()
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1])
+local ts = tonumber(state[2])
+if tokens == nil or ts == nil then
+  tokens = burst
+  ts = now
+end
+if now > ts then
+  tokens = math.min(burst, tokens + (now - ts) * rate / 1000000)
+  ts = now
+end
+local wait = 0
+if tokens >= 1 then
+  tokens = tokens - 1
+else
+  wait = math.ceil((1 - tokens) * 1000000 / rate)
+end
+redis.call('HSET', KEYS[1], 'tokens', string.format('%.17g', tokens), 'ts', string.format('%d', ts))
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst * 1000 / rate) + 1000)
+return wait
+`)
+
+// RateStore 是以 Redis 哈希保存令牌桶的 graph.RateStore，每次取令牌是一次 Lua 脚本调用，
+// 连接同一 Redis 的所有工作进程合计遵守配额。通过 engine.WithRateStore 或 graph.WithRateStore 使用
+type RateStore struct {
+	client *Client
+}
+
+var _ graph.RateStore = (*RateStore)(nil)
+
+// NewRateStore 创建基于 Redis 的令牌桶，连接在第一次取令牌时建立
+func NewRateStore(opts Options) (*RateStore, error) {
+	c, err := NewClient(opts)
+	if err != nil {
+		return nil, err
+	}
+	return &RateStore{client: c}, nil
+}
+
+// TakeToken 实现 graph.RateStore
+func (s *RateStore) TakeToken(ctx context.Context, name string, rate float64, burst int) (time.Duration, error) {
+	key := s.client.opts.Prefix + "rate:" + name
+	reply, err := s.client.eval(ctx, takeTokenScript, key, strconv.FormatFloat(rate, 'g', -1, 64), strconv.Itoa(burst))
+	if err != nil {
+		return 0, err
+	}
+	wait, ok := reply.(int64)
+	if !ok {
+		return 0, fmt.Errorf("unexpected reply %v from rate limit script", reply)
+	}
+	return time.Duration(wait) * time.Microsecond, nil
+}
+
+// Close 关闭连接
+func (s *RateStore) Close() error {
+	return s.client.Close()
+}
@@ -0,0 +1,224 @@
+package schedule
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// civilDate 是不带时区的日历日期
+type civilDate struct {
+	year  int
+	month time.Month
+	day   int
+}
+
+func dateOf(t time.Time) civilDate {
+	y, m, d := t.Date()
+	return civilDate{y, m, d}
+}
+
+// Calendar 是工作日历：周末和节假日之外的日期为工作日。日期按时间所在的时区判断，
+// 节假日只记录日期，同一日历可以用于不同时区的调度
+type Calendar struct {
+	name    string
+	weekend [7]bool
+
+	mu       sync.RWMutex
+	holidays map[civilDate]string
+}
+
+// NewCalendar 创建日历，weekend 为空时周六和周日为周末
+func NewCalendar(name string, weekend ...time.Weekday) *Calendar {
+	c := &Calendar{name: name, holidays: make(map[civilDate]string)}
+	if len(weekend) == 0 {
+		weekend = []time.Weekday{time.Saturday, time.Sunday}
+	}
+	for _, d := range weekend {
+		c.weekend[d] = true
+	}
+	return c
+}
+
+// Name 返回日历名称
+func (c *Calendar) Name() string {
+	return c.name
+}
+
+// AddHoliday 添加节假日，只使用 date 的日期部分
+func (c *Calendar) AddHoliday(date time.Time, name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.holidays[dateOf(date)] = name
+}
+
+// LoadHolidays 从文本读取节假日，每行为 "2006-01-02 名称"，名称可以省略，空行和 # 开头的行被忽略
+func (c *Calendar) LoadHolidays(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		date, name, _ := strings.Cut(text, " ")
+		d, err := time.Parse(time.DateOnly, date)
+		if err != nil {
+			return fmt.Errorf("invalid holiday on line %d: %v", line, err)
+		}
+		c.AddHoliday(d, strings.TrimSpace(name))
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read holidays: %v", err)
+	}
+	return nil
+}
+
+// Holiday 返回 t 所在日期的节假日名称
+func (c *Calendar) Holiday(t time.Time) (string, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	name, ok := c.holidays[dateOf(t)]
+	return name, ok
+}
+
+// IsBusinessDay 判断 t 所在日期是否为工作日
+func (c *Calendar) IsBusinessDay(t time.Time) bool {
+	if c.weekend[t.Weekday()] {
+		return false
+	}
+	_, holiday := c.Holiday(t)
+	return !holiday
+}
+
+// BusinessDay 返回 year 年 month 月的第 n 个工作日（零点），n 为负数时从月末倒数，-1 为最后一个工作日；
+// 该月工作日不足时返回 false
+func (c *Calendar) BusinessDay(year int, month time.Month, n int, loc *time.Location) (time.Time, bool) {
+	if n == 0 {
+		return time.Time{}, false
+	}
+	days := daysIn(year, month)
+	step, day := 1, 1
+	if n < 0 {
+		step, day, n = -1, days, -n
+	}
+	for ; day >= 1 && day <= days; day += step {
+		t := time.Date(year, month, day, 0, 0, 0, 0, loc)
+		if c.IsBusinessDay(t) {
+			if n--; n == 0 {
+				return t, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+func daysIn(year int, month time.Month) int {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+// searchYears 是日历调度向后查找触发时间的最长年数，与 Cron 相同
+const searchYears = 5
+
+// dayFilter 只保留 keep 返回 true 的日期上的触发时间
+type dayFilter struct {
+	spec Spec
+	keep func(time.Time) bool
+}
+
+func (f dayFilter) Next(after time.Time) time.Time {
+	limit := after.AddDate(searchYears, 0, 0)
+	for t := f.spec.Next(after); !t.IsZero() && t.Before(limit); t = f.spec.Next(t) {
+		if f.keep(t) {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// OnBusinessDays 返回只在工作日触发的 spec，落在周末或节假日的触发时间被跳过
+func OnBusinessDays(spec Spec, cal *Calendar) Spec {
+	return dayFilter{spec: spec, keep: cal.IsBusinessDay}
+}
+
+// SkipHolidays 返回跳过节假日的 spec，周末照常触发
+func SkipHolidays(spec Spec, cal *Calendar) Spec {
+	return dayFilter{spec: spec, keep: func(t time.Time) bool {
+		_, holiday := cal.Holiday(t)
+		return !holiday
+	}}
+}
+
+// monthly 每月在 day 函数选出的日期的 hour:minute 触发
+type monthly struct {
+	hour, minute int
+	day          func(year int, month time.Month, loc *time.Location) (time.Time, bool)
+}
+
+func (m monthly) Next(after time.Time) time.Time {
+	loc := after.Location()
+	year, month, _ := after.Date()
+	for i := 0; i < searchYears*12; i++ {
+		if d, ok := m.day(year, month+time.Month(i), loc); ok {
+			// 夏令时跳过的本地时间按 time.Date 的规则顺延
+			t := time.Date(d.Year(), d.Month(), d.Day(), m.hour, m.minute, 0, 0, loc)
+			if t.After(after) {
+				return t
+			}
+		}
+	}
+	return time.Time{}
+}
+
+// DayOfMonth 返回每月第 day 天 hour:minute 触发的 spec，day 为负数时从月末倒数：-1 为最后一天，-2 为倒数第二天。
+// day 为正数且超过该月天数时（如 31 日）该月不触发
+func DayOfMonth(day, hour, minute int) (Spec, error) {
+	if day == 0 || day > 31 || day < -31 {
+		return nil, fmt.Errorf("invalid day of month %d", day)
+	}
+	if err := checkClock(hour, minute); err != nil {
+		return nil, err
+	}
+	return monthly{hour: hour, minute: minute, day: func(year int, month time.Month, loc *time.Location) (time.Time, bool) {
+		// 先规范化 month，month 可能超过 12
+		first := time.Date(year, month, 1, 0, 0, 0, 0, loc)
+		days := daysIn(first.Year(), first.Month())
+		d := day
+		if d < 0 {
+			d = days + 1 + d
+		}
+		if d < 1 || d > days {
+			return time.Time{}, false
+		}
+		return time.Date(first.Year(), first.Month(), d, 0, 0, 0, 0, loc), true
+	}}, nil
+}
+
+// LastDayOfMonth 返回每月最后一天 hour:minute 触发的 spec
+func LastDayOfMonth(hour, minute int) (Spec, error) {
+	return DayOfMonth(-1, hour, minute)
+}
+
+// BusinessDayOfMonth 返回每月第 n 个工作日 hour:minute 触发的 spec，n 为负数时从月末倒数，
+// 如 1 为首个工作日，-1 为最后一个工作日
+func BusinessDayOfMonth(cal *Calendar, n, hour, minute int) (Spec, error) {
+	if n == 0 || n > 23 || n < -23 {
+		return nil, fmt.Errorf("invalid business day %d", n)
+	}
+	if err := checkClock(hour, minute); err != nil {
+		return nil, err
+	}
+	return monthly{hour: hour, minute: minute, day: func(year int, month time.Month, loc *time.Location) (time.Time, bool) {
+		first := time.Date(year, month, 1, 0, 0, 0, 0, loc)
+		return cal.BusinessDay(first.Year(), first.Month(), n, loc)
+	}}, nil
+}
+
+func checkClock(hour, minute int) error {
+	if hour < 0 || hour > 23 || minute < 0 || minute > 59 {
+		return fmt.Errorf("invalid time of day %02d:%02d", hour, minute)
+	}
+	return nil
+}
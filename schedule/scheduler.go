@@ -0,0 +1,513 @@
+// Package schedule 按 cron 表达式定时启动工作流，并为历史区间补跑（backfill）。
+// 每次运行都带有逻辑执行时间（graph.ExecuteOptions.LogicalTime），即该次运行所代表的调度时间，
+// 与实际开始时间无关：定时触发时是触发时间，补跑时是被补的那个时间点，
+// 因此同一逻辑时间无论何时执行都读取相同的参数和数据分区。
+//
+// 除 cron 之外，调度可以按工作日历触发（Calendar、OnBusinessDays），
+// 或使用 cron 无法表达的月度规则（LastDayOfMonth、BusinessDayOfMonth）
+package schedule
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"workflow/graph"
+)
+
+// Starter 执行工作流的一次运行，engine.Engine 实现了该接口
+type Starter interface {
+	Start(ctx context.Context, namespace, workflow string, opts graph.ExecuteOptions, extra ...graph.ExecuteOption) (*graph.ExecutionReport, error)
+}
+
+// ConcurrencyPolicy 决定上一次运行尚未结束时如何处理新的触发，与 Kubernetes CronJob 相同
+type ConcurrencyPolicy string
+
+const (
+	// ConcurrencyAllow 允许运行重叠，Schedule.MaxConcurrent 大于0时最多同时执行这么多次，超出的触发被跳过
+	ConcurrencyAllow ConcurrencyPolicy = "Allow"
+	// ConcurrencyForbid 在上一次运行结束前跳过新的触发
+	ConcurrencyForbid ConcurrencyPolicy = "Forbid"
+	// ConcurrencyReplace 取消正在执行的运行，等待其结束后启动新的运行
+	ConcurrencyReplace ConcurrencyPolicy = "Replace"
+)
+
+// ErrReplaced 是 ConcurrencyReplace 取消上一次运行时 context 的原因
+var ErrReplaced = errors.New("replaced by a newer scheduled run")
+
+// Schedule 描述一个定时启动的工作流
+type Schedule struct {
+	Name      string // 调度的唯一名称，用于生成 run_id
+	Namespace string // 为空时为 graph.DefaultNamespace
+	Workflow  string
+	Cron      string // cron 表达式，见 Cron
+	// Spec 设置时代替 Cron 计算触发时间，如 LastDayOfMonth、BusinessDayOfMonth
+	Spec Spec
+	// Calendar 设置时只在该日历的工作日触发，见 OnBusinessDays
+	Calendar *Calendar
+	Location *time.Location // 计算触发时间的时区，为空时为 UTC
+	// Jitter 设置时每次触发推迟 [0, Jitter) 内的一段时间再启动，推迟量由 run_id 决定，同一次触发总是相同
+	Jitter time.Duration
+	// Spread 设置时，SpreadGroup 相同且 Spread 大于0的 N 个调度按名称排序后，
+	// 第 i 个推迟 i*Spread/N 启动，使同时触发的调度均匀分布在 Spread 时间窗内
+	Spread      time.Duration
+	SpreadGroup string
+	// ConcurrencyPolicy 为空时为 ConcurrencyAllow；只作用于定时触发，Backfill 由 BackfillOptions.Concurrency 控制
+	ConcurrencyPolicy ConcurrencyPolicy
+	MaxConcurrent     int
+	Params            map[string]interface{}
+	// Options 是每次运行的执行选项；RunID、Params 和 LogicalTime 由调度器设置
+	Options graph.ExecuteOptions
+}
+
+// entry 是已添加的调度
+type entry struct {
+	Schedule
+	spec Spec
+	next time.Time
+}
+
+// activeRun 是调度正在执行的一次运行
+type activeRun struct {
+	runID  string
+	cancel context.CancelCauseFunc
+	done   chan struct{}
+}
+
+// RunID 返回调度在逻辑时间 t 的运行ID，同一调度的同一逻辑时间总是对应同一个 run_id，
+// 补跑可以据此判断哪些时间点已经执行过
+func RunID(schedule string, t time.Time) string {
+	return schedule + "-" + t.UTC().Format("20060102T150405Z")
+}
+
+// Scheduler 在触发时间启动调度的工作流。运行在独立的 goroutine 中执行，不阻塞后续触发；
+// 调度器只在触发时间到达时启动运行，停止期间错过的时间点不会自动补跑，需要时使用 Backfill
+type Scheduler struct {
+	starter Starter
+	store   graph.Store
+	logger  *slog.Logger
+
+	mu      sync.Mutex
+	entries map[string]*entry
+	active  map[string][]*activeRun
+	wake    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// Option 定义 Scheduler 的构造选项
+type Option func(*Scheduler)
+
+// WithStore 设置运行记录的存储，补跑时跳过已成功完成的逻辑时间；未设置时补跑总是执行所有时间点
+func WithStore(store graph.Store) Option {
+	return func(s *Scheduler) {
+		s.store = store
+	}
+}
+
+// WithLogger 设置日志器
+func WithLogger(logger *slog.Logger) Option {
+	return func(s *Scheduler) {
+		s.logger = logger
+	}
+}
+
+// NewScheduler 创建以 starter 启动运行的调度器
+func NewScheduler(starter Starter, opts ...Option) *Scheduler {
+	s := &Scheduler{
+		starter: starter,
+		logger:  slog.Default(),
+		entries: make(map[string]*entry),
+		active:  make(map[string][]*activeRun),
+		wake:    make(chan struct{}, 1),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Add 添加调度，同名调度已存在时返回错误
+func (s *Scheduler) Add(sched Schedule) error {
+	if sched.Name == "" || sched.Workflow == "" {
+		return fmt.Errorf("schedule name and workflow are required")
+	}
+	spec := sched.Spec
+	if spec == nil {
+		cron, err := ParseCron(sched.Cron)
+		if err != nil {
+			return fmt.Errorf("schedule %s: %v", sched.Name, err)
+		}
+		spec = cron
+	}
+	if sched.Calendar != nil {
+		spec = OnBusinessDays(spec, sched.Calendar)
+	}
+	if sched.Namespace == "" {
+		sched.Namespace = graph.DefaultNamespace
+	}
+	if sched.Location == nil {
+		sched.Location = time.UTC
+	}
+	switch sched.ConcurrencyPolicy {
+	case "":
+		sched.ConcurrencyPolicy = ConcurrencyAllow
+	case ConcurrencyAllow, ConcurrencyForbid, ConcurrencyReplace:
+	default:
+		return fmt.Errorf("schedule %s: unknown concurrency policy %q", sched.Name, sched.ConcurrencyPolicy)
+	}
+	e := &entry{Schedule: sched, spec: spec}
+	e.next = e.spec.Next(time.Now().In(sched.Location))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.entries[sched.Name]; ok {
+		return fmt.Errorf("schedule %s already exists", sched.Name)
+	}
+	s.entries[sched.Name] = e
+	s.notify()
+	return nil
+}
+
+// Remove 删除调度，已启动的运行不受影响；调度不存在时返回 false
+func (s *Scheduler) Remove(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.entries[name]; !ok {
+		return false
+	}
+	delete(s.entries, name)
+	s.notify()
+	return true
+}
+
+// Schedules 返回所有调度的名称（按名称排序）
+func (s *Scheduler) Schedules() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	names := make([]string, 0, len(s.entries))
+	for name := range s.entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Next 返回调度的下一个触发时间，即下一次运行的逻辑时间，不含 Jitter 和 Spread 的推迟
+func (s *Scheduler) Next(name string) (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[name]
+	if !ok {
+		return time.Time{}, fmt.Errorf("schedule %s not found", name)
+	}
+	return e.next, nil
+}
+
+// notify 唤醒 Run 重新计算等待时间，需持有 mu
+func (s *Scheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run 按触发时间启动运行直到 ctx 取消，返回前等待已启动的运行结束
+func (s *Scheduler) Run(ctx context.Context) error {
+	defer s.wg.Wait()
+	for {
+		s.mu.Lock()
+		var earliest time.Time
+		for _, e := range s.entries {
+			if !e.next.IsZero() && (earliest.IsZero() || e.next.Before(earliest)) {
+				earliest = e.next
+			}
+		}
+		s.mu.Unlock()
+
+		var (
+			timer *time.Timer
+			fired <-chan time.Time
+		)
+		if !earliest.IsZero() {
+			timer = time.NewTimer(time.Until(earliest))
+			fired = timer.C
+		}
+		select {
+		case <-ctx.Done():
+			stopTimer(timer)
+			return nil
+		case <-s.wake:
+			stopTimer(timer)
+		case <-fired:
+			s.fire(ctx, time.Now())
+		}
+	}
+}
+
+func stopTimer(t *time.Timer) {
+	if t != nil {
+		t.Stop()
+	}
+}
+
+// fire 启动所有触发时间已到的调度，并计算它们的下一个触发时间
+func (s *Scheduler) fire(ctx context.Context, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range s.entries {
+		if e.next.IsZero() || e.next.After(now) {
+			continue
+		}
+		logical := e.next
+		// 触发晚于多个时间点时（如进程被挂起）只执行最近一次，其余留给补跑
+		for n := e.spec.Next(logical); !n.IsZero() && !n.After(now); n = e.spec.Next(n) {
+			logical = n
+		}
+		e.next = e.spec.Next(logical)
+		sched := e.Schedule
+		delay := s.spreadOffset(e) + jitter(RunID(sched.Name, logical), sched.Jitter)
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			if delay > 0 {
+				timer := time.NewTimer(delay)
+				defer timer.Stop()
+				select {
+				case <-ctx.Done():
+					return
+				case <-timer.C:
+				}
+			}
+			s.run(ctx, sched, logical)
+		}()
+	}
+}
+
+// Active 返回调度正在执行的运行ID
+func (s *Scheduler) Active(name string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var runIDs []string
+	for _, run := range s.active[name] {
+		runIDs = append(runIDs, run.runID)
+	}
+	return runIDs
+}
+
+// run 按调度的并发策略执行一次触发
+func (s *Scheduler) run(ctx context.Context, sched Schedule, t time.Time) {
+	runID := RunID(sched.Name, t)
+	run := &activeRun{runID: runID, done: make(chan struct{})}
+	ctx, run.cancel = context.WithCancelCause(ctx)
+	defer run.cancel(nil)
+
+	s.mu.Lock()
+	running := s.active[sched.Name]
+	var replaced []*activeRun
+	switch {
+	case sched.ConcurrencyPolicy == ConcurrencyForbid && len(running) > 0,
+		sched.ConcurrencyPolicy == ConcurrencyAllow && sched.MaxConcurrent > 0 && len(running) >= sched.MaxConcurrent:
+		s.mu.Unlock()
+		s.logger.Info("skipping scheduled run: previous run still active",
+			slog.String("schedule", sched.Name), slog.String("run_id", runID), slog.String("policy", string(sched.ConcurrencyPolicy)))
+		return
+	case sched.ConcurrencyPolicy == ConcurrencyReplace:
+		replaced = running
+	}
+	s.active[sched.Name] = append(running, run)
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		runs := s.active[sched.Name]
+		for i, r := range runs {
+			if r == run {
+				runs = append(runs[:i:i], runs[i+1:]...)
+				break
+			}
+		}
+		if len(runs) == 0 {
+			delete(s.active, sched.Name)
+		} else {
+			s.active[sched.Name] = runs
+		}
+		close(run.done)
+	}()
+
+	for _, prev := range replaced {
+		s.logger.Info("canceling scheduled run replaced by newer run",
+			slog.String("schedule", sched.Name), slog.String("run_id", prev.runID), slog.String("replaced_by", runID))
+		prev.cancel(ErrReplaced)
+		select {
+		case <-prev.done:
+		case <-ctx.Done():
+			return
+		}
+	}
+	s.start(ctx, sched, t)
+}
+
+// spreadOffset 返回调度在所属 SpreadGroup 中的启动偏移，需持有 mu
+func (s *Scheduler) spreadOffset(e *entry) time.Duration {
+	if e.Spread <= 0 {
+		return 0
+	}
+	var group []string
+	for _, other := range s.entries {
+		if other.Spread > 0 && other.SpreadGroup == e.SpreadGroup {
+			group = append(group, other.Name)
+		}
+	}
+	sort.Strings(group)
+	i := sort.SearchStrings(group, e.Name)
+	return time.Duration(int64(e.Spread) * int64(i) / int64(len(group)))
+}
+
+// jitter 由 runID 的哈希得到 [0, max) 内的推迟量
+func jitter(runID string, max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+	h := fnv.New64a()
+	h.Write([]byte(runID))
+	return time.Duration(h.Sum64() % uint64(max))
+}
+
+// start 以逻辑时间 t 启动一次运行；Jitter 和 Spread 只推迟启动，不改变逻辑时间
+func (s *Scheduler) start(ctx context.Context, sched Schedule, t time.Time) error {
+	runID := RunID(sched.Name, t)
+	logger := s.logger.With(slog.String("schedule", sched.Name), slog.String("run_id", runID), slog.Time("logical_time", t))
+	opts := sched.Options
+	opts.Params = sched.Params
+	ctx = graph.ContextWithActor(ctx, "schedule:"+sched.Name)
+	logger.Info("starting scheduled run")
+	_, err := s.starter.Start(ctx, sched.Namespace, sched.Workflow, opts, graph.WithRunID(runID), graph.WithLogicalTime(t))
+	switch {
+	case err != nil && errors.Is(context.Cause(ctx), ErrReplaced):
+		logger.Info("scheduled run replaced", slog.Any("error", err))
+	case err != nil:
+		logger.Warn("scheduled run failed", slog.Any("error", err))
+	}
+	return err
+}
+
+// FireTimes 返回调度在 [from, to) 内的所有触发时间
+func (s *Scheduler) FireTimes(name string, from, to time.Time) ([]time.Time, error) {
+	s.mu.Lock()
+	e, ok := s.entries[name]
+	s.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("schedule %s not found", name)
+	}
+	return fireTimes(e.spec, from.In(e.Location), to), nil
+}
+
+func fireTimes(spec Spec, from, to time.Time) []time.Time {
+	var times []time.Time
+	// Next 返回严格晚于参数的时间，从 from 之前一分钟开始以包含 from 本身
+	for t := spec.Next(from.Add(-time.Minute)); !t.IsZero() && t.Before(to); t = spec.Next(t) {
+		if !t.Before(from) {
+			times = append(times, t)
+		}
+	}
+	return times
+}
+
+// BackfillOptions 配置补跑
+type BackfillOptions struct {
+	// Concurrency 是同时执行的时间点数，默认为1即按时间顺序逐个执行
+	Concurrency int
+	// Rerun 为 true 时重新执行已成功完成的时间点；默认跳过它们（需要 WithStore）
+	Rerun bool
+	// StopOnError 为 true 时一个时间点失败后不再启动后续时间点
+	StopOnError bool
+}
+
+// BackfillResult 是补跑中一个时间点的结果
+type BackfillResult struct {
+	LogicalTime time.Time
+	RunID       string
+	Skipped     bool // 已成功完成而被跳过
+	Err         error
+}
+
+// Backfill 为调度在 [from, to) 内的每个触发时间执行一次运行，逻辑时间为该触发时间，run_id 见 RunID。
+// 返回按逻辑时间排序的结果；有时间点失败时返回的错误合并了所有失败
+func (s *Scheduler) Backfill(ctx context.Context, name string, from, to time.Time, opts BackfillOptions) ([]BackfillResult, error) {
+	s.mu.Lock()
+	e, ok := s.entries[name]
+	s.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("schedule %s not found", name)
+	}
+	sched := e.Schedule
+	times := fireTimes(e.spec, from.In(sched.Location), to)
+	results := make([]BackfillResult, len(times))
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		stopped bool
+	)
+	sem := make(chan struct{}, concurrency)
+	for i, t := range times {
+		results[i] = BackfillResult{LogicalTime: t, RunID: RunID(name, t)}
+		if s.completed(ctx, sched.Namespace, results[i].RunID) && !opts.Rerun {
+			results[i].Skipped = true
+			continue
+		}
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		mu.Lock()
+		stop := stopped || ctx.Err() != nil
+		mu.Unlock()
+		if stop {
+			<-sem
+			results[i].Err = fmt.Errorf("not started: backfill stopped")
+			if ctx.Err() != nil {
+				results[i].Err = ctx.Err()
+			}
+			continue
+		}
+		wg.Add(1)
+		go func(r *BackfillResult) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if r.Err = s.start(ctx, sched, r.LogicalTime); r.Err != nil && opts.StopOnError {
+				mu.Lock()
+				stopped = true
+				mu.Unlock()
+			}
+		}(&results[i])
+	}
+	wg.Wait()
+
+	var errs []error
+	for _, r := range results {
+		if r.Err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", graph.FormatLogicalTime(r.LogicalTime), r.Err))
+		}
+	}
+	return results, errors.Join(errs...)
+}
+
+// completed 判断运行是否已成功完成
+func (s *Scheduler) completed(ctx context.Context, namespace, runID string) bool {
+	if s.store == nil {
+		return false
+	}
+	rec, err := s.store.GetRun(ctx, namespace, runID)
+	return err == nil && rec.Status == graph.RunStatusCompleted
+}
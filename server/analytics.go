@@ -0,0 +1,127 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"workflow/graph"
+)
+
+// TaskStatsView 是任务聚合统计在接口中的表示
+type TaskStatsView struct {
+	TaskID      string             `json:"task_id"`
+	Runs        int                `json:"runs"`
+	Failures    int                `json:"failures"`
+	FailureRate float64            `json:"failure_rate"`
+	Flaky       int                `json:"flaky"`
+	FlakyRate   float64            `json:"flaky_rate"`
+	P50MS       int64              `json:"p50_ms"`
+	P95MS       int64              `json:"p95_ms"`
+	MaxMS       int64              `json:"max_ms"`
+	Cost        float64            `json:"cost,omitempty"`
+	Costs       map[string]float64 `json:"costs,omitempty"`
+}
+
+// TrendBucketView 是趋势统计中一个时间段在接口中的表示
+type TrendBucketView struct {
+	Start            time.Time          `json:"start"`
+	Runs             int                `json:"runs"`
+	Failures         int                `json:"failures"`
+	FailureRate      float64            `json:"failure_rate"`
+	P50MS            int64              `json:"p50_ms"`
+	P95MS            int64              `json:"p95_ms"`
+	TaskFailureRates map[string]float64 `json:"task_failure_rates,omitempty"`
+	Cost             float64            `json:"cost,omitempty"`
+	Costs            map[string]float64 `json:"costs,omitempty"`
+}
+
+// AnalyticsView 是工作流执行历史统计在接口中的表示
+type AnalyticsView struct {
+	Namespace   string              `json:"namespace"`
+	Workflow    string              `json:"workflow"`
+	Runs        int                 `json:"runs"`
+	Failures    int                 `json:"failures"`
+	FailureRate float64             `json:"failure_rate"`
+	P50MS       int64               `json:"p50_ms"`
+	P95MS       int64               `json:"p95_ms"`
+	Tasks       []TaskStatsView     `json:"tasks"`
+	Trend       []TrendBucketView   `json:"trend"`
+	Cost        float64             `json:"cost,omitempty"`
+	Costs       map[string]float64  `json:"costs,omitempty"`
+	CostByTag   map[string]CostView `json:"cost_by_tag,omitempty"`
+}
+
+// getAnalytics 返回工作流执行历史的统计，支持 since、until（RFC 3339）和 bucket（如 "1h"）查询参数
+func (s *Server) getAnalytics(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	var q graph.AnalyticsQuery
+	for name, dst := range map[string]*time.Time{"since": &q.Since, "until": &q.Until} {
+		if v := query.Get(name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, fmt.Errorf("invalid %s: %v", name, err))
+				return
+			}
+			*dst = t
+		}
+	}
+	if v := query.Get("bucket"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid bucket %q", v))
+			return
+		}
+		q.Bucket = d
+	}
+
+	a, err := s.registry.Namespace(r.PathValue("namespace")).Analytics(r.Context(), r.PathValue("name"), q)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	view := AnalyticsView{
+		Namespace:   a.Namespace,
+		Workflow:    a.Workflow,
+		Runs:        a.Runs,
+		Failures:    a.Failures,
+		FailureRate: a.FailureRate,
+		P50MS:       a.P50.Milliseconds(),
+		P95MS:       a.P95.Milliseconds(),
+		Tasks:       make([]TaskStatsView, 0, len(a.Tasks)),
+		Trend:       make([]TrendBucketView, 0, len(a.Trend)),
+		Cost:        a.Cost.Cost,
+		Costs:       a.Cost.Costs,
+		CostByTag:   costByTagView(a.CostByTag),
+	}
+	for _, t := range a.Tasks {
+		view.Tasks = append(view.Tasks, TaskStatsView{
+			TaskID:      t.TaskID,
+			Runs:        t.Runs,
+			Failures:    t.Failures,
+			FailureRate: t.FailureRate,
+			Flaky:       t.Flaky,
+			FlakyRate:   t.FlakyRate,
+			P50MS:       t.P50.Milliseconds(),
+			P95MS:       t.P95.Milliseconds(),
+			MaxMS:       t.Max.Milliseconds(),
+			Cost:        t.Cost.Cost,
+			Costs:       t.Cost.Costs,
+		})
+	}
+	for _, b := range a.Trend {
+		view.Trend = append(view.Trend, TrendBucketView{
+			Start:            b.Start,
+			Runs:             b.Runs,
+			Failures:         b.Failures,
+			FailureRate:      b.FailureRate,
+			P50MS:            b.P50.Milliseconds(),
+			P95MS:            b.P95.Milliseconds(),
+			TaskFailureRates: b.TaskFailureRates,
+			Cost:             b.Cost.Cost,
+			Costs:            b.Cost.Costs,
+		})
+	}
+	writeJSON(w, http.StatusOK, view)
+}
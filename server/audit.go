@@ -0,0 +1,74 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"workflow/graph"
+)
+
+// AuditEntryView 是审计记录在接口中的表示
+type AuditEntryView struct {
+	Seq       int64             `json:"seq"`
+	Time      time.Time         `json:"time"`
+	Namespace string            `json:"namespace"`
+	RunID     string            `json:"run_id,omitempty"`
+	Workflow  string            `json:"workflow,omitempty"`
+	Actor     string            `json:"actor,omitempty"`
+	Action    graph.AuditAction `json:"action"`
+	TaskID    string            `json:"task_id,omitempty"`
+	From      string            `json:"from,omitempty"`
+	To        string            `json:"to,omitempty"`
+	Detail    string            `json:"detail,omitempty"`
+}
+
+// listAudit 按序号升序返回审计记录，支持 run_id、actor、action、since（RFC 3339）和 limit 查询参数
+func (s *Server) listAudit(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := graph.AuditFilter{
+		RunID:  query.Get("run_id"),
+		Actor:  query.Get("actor"),
+		Action: graph.AuditAction(query.Get("action")),
+	}
+	if since := query.Get("since"); since != "" {
+		t, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid since: %v", err))
+			return
+		}
+		filter.Since = t
+	}
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid limit: %v", err))
+			return
+		}
+		filter.Limit = n
+	}
+
+	entries, err := s.registry.Namespace(r.PathValue("namespace")).Audit(r.Context(), filter)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	views := make([]AuditEntryView, 0, len(entries))
+	for _, e := range entries {
+		views = append(views, AuditEntryView{
+			Seq:       e.Seq,
+			Time:      e.Time,
+			Namespace: e.Namespace,
+			RunID:     e.RunID,
+			Workflow:  e.Workflow,
+			Actor:     e.Actor,
+			Action:    e.Action,
+			TaskID:    e.TaskID,
+			From:      e.From,
+			To:        e.To,
+			Detail:    e.Detail,
+		})
+	}
+	writeJSON(w, http.StatusOK, views)
+}
@@ -0,0 +1,183 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"workflow/graph"
+)
+
+// Role 表示调用方在管理接口上的角色，高级角色包含低级角色的全部权限
+type Role int
+
+const (
+	// RoleViewer 可以查看工作流和运行记录
+	RoleViewer Role = iota + 1
+	// RoleTrigger 额外可以触发运行
+	RoleTrigger
+	// RoleAdmin 拥有全部权限
+	RoleAdmin
+)
+
+// ParseRole 解析角色名称：viewer、trigger 或 admin
+func ParseRole(name string) (Role, error) {
+	switch strings.ToLower(name) {
+	case "viewer", "view":
+		return RoleViewer, nil
+	case "trigger":
+		return RoleTrigger, nil
+	case "admin":
+		return RoleAdmin, nil
+	}
+	return 0, fmt.Errorf("unknown role %q", name)
+}
+
+func (r Role) String() string {
+	switch r {
+	case RoleViewer:
+		return "viewer"
+	case RoleTrigger:
+		return "trigger"
+	case RoleAdmin:
+		return "admin"
+	}
+	return fmt.Sprintf("role(%d)", int(r))
+}
+
+// AllNamespaces 表示调用方可以访问所有命名空间
+const AllNamespaces = "*"
+
+// Principal 表示通过认证的调用方
+type Principal struct {
+	Subject    string
+	Role       Role
+	Namespaces []string // 可访问的命名空间，包含 AllNamespaces 时不限制
+}
+
+// CanAccess 判断调用方能否以指定角色访问命名空间
+func (p *Principal) CanAccess(namespace string, role Role) bool {
+	if p.Role < role {
+		return false
+	}
+	for _, ns := range p.Namespaces {
+		if ns == AllNamespaces || ns == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrUnauthenticated 表示请求未携带有效凭证
+var ErrUnauthenticated = errors.New("unauthenticated")
+
+// Authenticator 从请求中识别调用方，凭证缺失或无效时返回 ErrUnauthenticated
+type Authenticator interface {
+	Authenticate(r *http.Request) (*Principal, error)
+}
+
+// AuthenticatorFunc 将函数适配为 Authenticator
+type AuthenticatorFunc func(r *http.Request) (*Principal, error)
+
+// Authenticate 实现 Authenticator
+func (f AuthenticatorFunc) Authenticate(r *http.Request) (*Principal, error) {
+	return f(r)
+}
+
+// HeaderAPIKey 是携带 API Key 的请求头
+const HeaderAPIKey = "X-API-Key"
+
+// APIKeyAuthenticator 使用静态 API Key 认证，Key 可以放在 X-API-Key 请求头
+// 或 "Authorization: Bearer <key>" 中
+type APIKeyAuthenticator struct {
+	keys map[string]*Principal
+}
+
+// NewAPIKeyAuthenticator 创建 API Key 认证器
+func NewAPIKeyAuthenticator(keys map[string]*Principal) *APIKeyAuthenticator {
+	return &APIKeyAuthenticator{keys: keys}
+}
+
+// Authenticate 实现 Authenticator
+func (a *APIKeyAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	key := r.Header.Get(HeaderAPIKey)
+	if key == "" {
+		key = bearerToken(r)
+	}
+	if key == "" {
+		return nil, ErrUnauthenticated
+	}
+	// 逐个进行常量时间比较，避免通过响应时间猜测 Key
+	for candidate, principal := range a.keys {
+		if subtle.ConstantTimeCompare([]byte(candidate), []byte(key)) == 1 {
+			return principal, nil
+		}
+	}
+	return nil, ErrUnauthenticated
+}
+
+// ChainAuthenticators 依次尝试多个认证器，返回第一个认证成功的调用方
+func ChainAuthenticators(authns ...Authenticator) Authenticator {
+	return AuthenticatorFunc(func(r *http.Request) (*Principal, error) {
+		for _, authn := range authns {
+			principal, err := authn.Authenticate(r)
+			if err == nil {
+				return principal, nil
+			}
+			if !errors.Is(err, ErrUnauthenticated) {
+				return nil, err
+			}
+		}
+		return nil, ErrUnauthenticated
+	})
+}
+
+// bearerToken 返回 Authorization 请求头中的 Bearer 令牌
+func bearerToken(r *http.Request) string {
+	auth := r.Header.Get("Authorization")
+	if len(auth) > 7 && strings.EqualFold(auth[:7], "bearer ") {
+		return strings.TrimSpace(auth[7:])
+	}
+	return ""
+}
+
+type principalKey struct{}
+
+// PrincipalFrom 返回请求上下文中已认证的调用方，未启用认证时返回 nil
+func PrincipalFrom(ctx context.Context) *Principal {
+	p, _ := ctx.Value(principalKey{}).(*Principal)
+	return p
+}
+
+// authorize 认证请求并检查调用方在路径中的命名空间上是否拥有所需角色
+func (s *Server) authorize(role Role, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.authn == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		principal, err := s.authn.Authenticate(r)
+		if err != nil {
+			if errors.Is(err, ErrUnauthenticated) {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				writeError(w, http.StatusUnauthorized, err)
+				return
+			}
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		namespace := r.PathValue("namespace")
+		if !principal.CanAccess(namespace, role) {
+			writeError(w, http.StatusForbidden, fmt.Errorf("%s requires role %s on namespace %s", principal.Subject, role, namespace))
+			return
+		}
+		ctx := context.WithValue(r.Context(), principalKey{}, principal)
+		ctx = graph.ContextWithActor(ctx, principal.Subject)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
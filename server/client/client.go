@@ -0,0 +1,301 @@
+// Package client 是工作流管理接口的 Go 客户端，接口定义见 server/openapi.yaml
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"workflow/graph"
+	"workflow/server"
+)
+
+// APIError 表示服务端返回的错误响应
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("server returned %d: %s", e.StatusCode, e.Message)
+}
+
+// Client 访问管理接口，可被多个 goroutine 并发使用
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	token      string
+	apiKey     string
+}
+
+// Option 定义客户端的构造选项
+type Option func(*Client)
+
+// WithHTTPClient 设置底层 HTTP 客户端，默认使用 http.DefaultClient
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = hc
+	}
+}
+
+// WithBearerToken 使用 JWT 或 API Key 通过 Authorization 请求头认证
+func WithBearerToken(token string) Option {
+	return func(c *Client) {
+		c.token = token
+	}
+}
+
+// WithAPIKey 使用 X-API-Key 请求头认证
+func WithAPIKey(key string) Option {
+	return func(c *Client) {
+		c.apiKey = key
+	}
+}
+
+// New 创建客户端，baseURL 形如 "http://localhost:8080"
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: http.DefaultClient,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Namespace 返回访问指定命名空间的客户端
+func (c *Client) Namespace(namespace string) *NamespaceClient {
+	return &NamespaceClient{client: c, namespace: namespace}
+}
+
+// NamespaceClient 访问单个命名空间下的工作流和运行记录
+type NamespaceClient struct {
+	client    *Client
+	namespace string
+}
+
+// ListWorkflows 返回命名空间中的所有工作流
+func (n *NamespaceClient) ListWorkflows(ctx context.Context) ([]server.WorkflowView, error) {
+	var views []server.WorkflowView
+	err := n.client.do(ctx, http.MethodGet, n.path("workflows"), nil, &views)
+	return views, err
+}
+
+// GetWorkflow 返回工作流及其保留的版本
+func (n *NamespaceClient) GetWorkflow(ctx context.Context, name string) (*server.WorkflowView, error) {
+	var view server.WorkflowView
+	if err := n.client.do(ctx, http.MethodGet, n.path("workflows", name), nil, &view); err != nil {
+		return nil, err
+	}
+	return &view, nil
+}
+
+// TriggerRun 异步开始一次运行，返回的 RunID 可用于 GetRun 查询结果
+func (n *NamespaceClient) TriggerRun(ctx context.Context, name string, req server.TriggerRequest) (*server.TriggerResponse, error) {
+	var resp server.TriggerResponse
+	if err := n.client.do(ctx, http.MethodPost, n.path("workflows", name, "runs"), req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// GetAnalytics 返回工作流执行历史的耗时分位数、失败率和趋势
+func (n *NamespaceClient) GetAnalytics(ctx context.Context, workflow string, q graph.AnalyticsQuery) (*server.AnalyticsView, error) {
+	query := url.Values{}
+	if !q.Since.IsZero() {
+		query.Set("since", q.Since.Format(time.RFC3339))
+	}
+	if !q.Until.IsZero() {
+		query.Set("until", q.Until.Format(time.RFC3339))
+	}
+	if q.Bucket > 0 {
+		query.Set("bucket", q.Bucket.String())
+	}
+	path := n.path("workflows", workflow, "analytics")
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+
+	var view server.AnalyticsView
+	if err := n.client.do(ctx, http.MethodGet, path, nil, &view); err != nil {
+		return nil, err
+	}
+	return &view, nil
+}
+
+// ListRuns 按开始时间倒序返回符合条件的运行记录，不包含任务明细和结果
+func (n *NamespaceClient) ListRuns(ctx context.Context, filter graph.RunFilter) ([]server.RunView, error) {
+	query := url.Values{}
+	if filter.Workflow != "" {
+		query.Set("workflow", filter.Workflow)
+	}
+	if filter.Status != "" {
+		query.Set("status", string(filter.Status))
+	}
+	if filter.Limit > 0 {
+		query.Set("limit", strconv.Itoa(filter.Limit))
+	}
+	path := n.path("runs")
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+
+	var views []server.RunView
+	err := n.client.do(ctx, http.MethodGet, path, nil, &views)
+	return views, err
+}
+
+// GetRun 返回运行记录，包含任务明细和结果
+func (n *NamespaceClient) GetRun(ctx context.Context, runID string) (*server.RunView, error) {
+	var view server.RunView
+	if err := n.client.do(ctx, http.MethodGet, n.path("runs", runID), nil, &view); err != nil {
+		return nil, err
+	}
+	return &view, nil
+}
+
+// RetryRun 异步重新执行失败运行中失败的任务，run_id 保持不变
+func (n *NamespaceClient) RetryRun(ctx context.Context, runID string) (*server.TriggerResponse, error) {
+	var resp server.TriggerResponse
+	if err := n.client.do(ctx, http.MethodPost, n.path("runs", runID, "retry"), nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// ListDeadLetters 按失败时间倒序返回死信，workflow 不为空时只返回该工作流的死信
+func (n *NamespaceClient) ListDeadLetters(ctx context.Context, workflow string) ([]server.DeadLetterView, error) {
+	path := n.path("deadletters")
+	if workflow != "" {
+		path += "?" + url.Values{"workflow": {workflow}}.Encode()
+	}
+	var views []server.DeadLetterView
+	err := n.client.do(ctx, http.MethodGet, path, nil, &views)
+	return views, err
+}
+
+// GetDeadLetter 返回指定运行的死信
+func (n *NamespaceClient) GetDeadLetter(ctx context.Context, runID string) (*server.DeadLetterView, error) {
+	var view server.DeadLetterView
+	if err := n.client.do(ctx, http.MethodGet, n.path("deadletters", runID), nil, &view); err != nil {
+		return nil, err
+	}
+	return &view, nil
+}
+
+// RedriveDeadLetter 异步重新执行死信，返回新运行的 RunID
+func (n *NamespaceClient) RedriveDeadLetter(ctx context.Context, runID string, req server.RedriveRequest) (*server.TriggerResponse, error) {
+	var resp server.TriggerResponse
+	if err := n.client.do(ctx, http.MethodPost, n.path("deadletters", runID, "redrive"), req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// DeleteDeadLetter 删除死信而不重新执行
+func (n *NamespaceClient) DeleteDeadLetter(ctx context.Context, runID string) error {
+	return n.client.do(ctx, http.MethodDelete, n.path("deadletters", runID), nil, nil)
+}
+
+// ListAudit 按序号升序返回符合条件的审计记录，需要 admin 角色
+func (n *NamespaceClient) ListAudit(ctx context.Context, filter graph.AuditFilter) ([]server.AuditEntryView, error) {
+	query := url.Values{}
+	if filter.RunID != "" {
+		query.Set("run_id", filter.RunID)
+	}
+	if filter.Actor != "" {
+		query.Set("actor", filter.Actor)
+	}
+	if filter.Action != "" {
+		query.Set("action", string(filter.Action))
+	}
+	if !filter.Since.IsZero() {
+		query.Set("since", filter.Since.Format(time.RFC3339))
+	}
+	if filter.Limit > 0 {
+		query.Set("limit", strconv.Itoa(filter.Limit))
+	}
+	path := n.path("audit")
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+
+	var views []server.AuditEntryView
+	err := n.client.do(ctx, http.MethodGet, path, nil, &views)
+	return views, err
+}
+
+// path 拼接命名空间下的接口路径，各段会被转义
+func (n *NamespaceClient) path(segments ...string) string {
+	var b strings.Builder
+	b.WriteString("/v1/namespaces/")
+	b.WriteString(url.PathEscape(n.namespace))
+	for _, seg := range segments {
+		b.WriteByte('/')
+		b.WriteString(url.PathEscape(seg))
+	}
+	return b.String()
+}
+
+// do 发送请求并将 JSON 响应解码到 out，非 2xx 响应返回 *APIError
+func (c *Client) do(ctx context.Context, method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %v", err)
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	if c.apiKey != "" {
+		req.Header.Set(server.HeaderAPIKey, c.apiKey)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s %s failed: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %v", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		apiErr := &APIError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(data))}
+		var e struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(data, &e) == nil && e.Error != "" {
+			apiErr.Message = e.Error
+		}
+		return apiErr
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("failed to decode response: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"workflow/graph"
+)
+
+// DeadLetterView 是死信在接口中的表示
+type DeadLetterView struct {
+	Namespace       string                 `json:"namespace"`
+	RunID           string                 `json:"run_id"`
+	Workflow        string                 `json:"workflow"`
+	WorkflowVersion int                    `json:"workflow_version"`
+	Params          map[string]interface{} `json:"params,omitempty"`
+	Results         map[string]interface{} `json:"results,omitempty"`
+	Error           string                 `json:"error"`
+	TaskErrors      map[string]string      `json:"task_errors,omitempty"`
+	FailedAt        time.Time              `json:"failed_at"`
+	Redrives        int                    `json:"redrives"`
+	RedriveOf       string                 `json:"redrive_of,omitempty"`
+}
+
+// RedriveRequest 是重新执行死信的请求体
+type RedriveRequest struct {
+	// Params 不为空时替换死信中记录的参数
+	Params map[string]interface{} `json:"params,omitempty"`
+}
+
+func deadLetterView(dl *graph.DeadLetter) DeadLetterView {
+	return DeadLetterView{
+		Namespace:       dl.Namespace,
+		RunID:           dl.RunID,
+		Workflow:        dl.Workflow,
+		WorkflowVersion: dl.WorkflowVersion,
+		Params:          dl.Params,
+		Results:         graph.RedactResults(dl.Results, dl.Sensitive),
+		Error:           dl.Error,
+		TaskErrors:      dl.TaskErrors,
+		FailedAt:        dl.FailedAt,
+		Redrives:        dl.Redrives,
+		RedriveOf:       dl.RedriveOf,
+	}
+}
+
+func (s *Server) listDeadLetters(w http.ResponseWriter, r *http.Request) {
+	ns := s.registry.Namespace(r.PathValue("namespace"))
+	dls, err := ns.DeadLetters(r.Context(), r.URL.Query().Get("workflow"))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	views := make([]DeadLetterView, 0, len(dls))
+	for _, dl := range dls {
+		views = append(views, deadLetterView(dl))
+	}
+	writeJSON(w, http.StatusOK, views)
+}
+
+func (s *Server) getDeadLetter(w http.ResponseWriter, r *http.Request) {
+	ns := s.registry.Namespace(r.PathValue("namespace"))
+	dl, err := ns.DeadLetter(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, deadLetterView(dl))
+}
+
+// redriveDeadLetter 异步重新执行死信并立即返回新运行的 run_id
+func (s *Server) redriveDeadLetter(w http.ResponseWriter, r *http.Request) {
+	ns := s.registry.Namespace(r.PathValue("namespace"))
+	id := r.PathValue("id")
+
+	var req RedriveRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %v", err))
+			return
+		}
+	}
+	dl, err := ns.DeadLetter(r.Context(), id)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+
+	runID := graph.NewRunID()
+	opts := graph.ExecuteOptions{Logger: s.logger}
+	ref := RunRef{Namespace: ns.Name(), Workflow: dl.Workflow, RunID: runID}
+	started := s.spawn(w, r, ref, func(ctx context.Context) error {
+		_, err := ns.Redrive(ctx, id, req.Params, opts, graph.WithRunID(runID))
+		if err != nil {
+			s.logger.Warn("redrive failed", slog.String("namespace", ns.Name()), slog.String("dead_letter", id), slog.String("run_id", runID), slog.Any("error", err))
+		}
+		return err
+	})
+	if !started {
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, TriggerResponse{RunID: runID, WorkflowVersion: dl.WorkflowVersion})
+}
+
+func (s *Server) deleteDeadLetter(w http.ResponseWriter, r *http.Request) {
+	ns := s.registry.Namespace(r.PathValue("namespace"))
+	if err := ns.DiscardDeadLetter(r.Context(), r.PathValue("id")); err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
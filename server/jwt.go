@@ -0,0 +1,170 @@
+package server
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// KeyFunc 根据令牌头中的 kid 和 alg 返回验签密钥：
+// HS256 返回 []byte，RS256 返回 *rsa.PublicKey。接入 OIDC 时可在此从 JWKS 中查找公钥
+type KeyFunc func(kid, alg string) (interface{}, error)
+
+// JWTAuthenticator 使用 JWT（HS256 或 RS256）认证，令牌放在 "Authorization: Bearer <token>" 中。
+// 角色从 RoleClaim（默认 "role"）读取，可访问的命名空间从 NamespacesClaim（默认 "namespaces"）读取
+type JWTAuthenticator struct {
+	Keys            KeyFunc
+	Issuer          string // 不为空时校验 iss
+	Audience        string // 不为空时校验 aud
+	RoleClaim       string
+	NamespacesClaim string
+	Leeway          time.Duration // 校验 exp/nbf 时允许的时钟偏差
+	// AllowMissingExpiry 为 true 时接受没有 exp 的令牌，默认拒绝，避免泄露的令牌永久有效
+	AllowMissingExpiry bool
+}
+
+// Authenticate 实现 Authenticator
+func (a *JWTAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	token := bearerToken(r)
+	if token == "" || strings.Count(token, ".") != 2 {
+		return nil, ErrUnauthenticated
+	}
+	claims, err := a.verify(token)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnauthenticated, err)
+	}
+	return a.principal(claims)
+}
+
+// verify 校验签名和标准声明，返回令牌的声明
+func (a *JWTAuthenticator) verify(token string) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("invalid header: %v", err)
+	}
+
+	key, err := a.Keys(header.Kid, header.Alg)
+	if err != nil {
+		return nil, fmt.Errorf("no key for token: %v", err)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("invalid signature encoding: %v", err)
+	}
+	signed := []byte(parts[0] + "." + parts[1])
+	digest := sha256.Sum256(signed)
+
+	switch header.Alg {
+	case "HS256":
+		secret, ok := key.([]byte)
+		if !ok {
+			return nil, fmt.Errorf("HS256 requires a []byte key")
+		}
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(signed)
+		if !hmac.Equal(mac.Sum(nil), signature) {
+			return nil, fmt.Errorf("signature mismatch")
+		}
+	case "RS256":
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("RS256 requires an *rsa.PublicKey key")
+		}
+		if err := rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], signature); err != nil {
+			return nil, fmt.Errorf("signature mismatch")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported alg %q", header.Alg)
+	}
+
+	var claims map[string]interface{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("invalid claims: %v", err)
+	}
+
+	now := time.Now()
+	exp, ok := claims["exp"].(float64)
+	if !ok && !a.AllowMissingExpiry {
+		return nil, fmt.Errorf("token has no exp claim")
+	}
+	if ok && now.After(time.Unix(int64(exp), 0).Add(a.Leeway)) {
+		return nil, fmt.Errorf("token expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(a.Leeway).Before(time.Unix(int64(nbf), 0)) {
+		return nil, fmt.Errorf("token not yet valid")
+	}
+	if a.Issuer != "" && claims["iss"] != a.Issuer {
+		return nil, fmt.Errorf("unexpected issuer")
+	}
+	if a.Audience != "" && !containsClaim(claims["aud"], a.Audience) {
+		return nil, fmt.Errorf("unexpected audience")
+	}
+	return claims, nil
+}
+
+// principal 根据声明构造调用方
+func (a *JWTAuthenticator) principal(claims map[string]interface{}) (*Principal, error) {
+	roleClaim := a.RoleClaim
+	if roleClaim == "" {
+		roleClaim = "role"
+	}
+	nsClaim := a.NamespacesClaim
+	if nsClaim == "" {
+		nsClaim = "namespaces"
+	}
+
+	roleName, _ := claims[roleClaim].(string)
+	role, err := ParseRole(roleName)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnauthenticated, err)
+	}
+
+	subject, _ := claims["sub"].(string)
+	p := &Principal{Subject: subject, Role: role}
+	switch ns := claims[nsClaim].(type) {
+	case string:
+		p.Namespaces = []string{ns}
+	case []interface{}:
+		for _, v := range ns {
+			if s, ok := v.(string); ok {
+				p.Namespaces = append(p.Namespaces, s)
+			}
+		}
+	}
+	return p, nil
+}
+
+// decodeSegment 解码 base64url 编码的 JSON 片段
+func decodeSegment(seg string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// containsClaim 判断字符串或字符串数组形式的声明是否包含指定值
+func containsClaim(claim interface{}, want string) bool {
+	switch v := claim.(type) {
+	case string:
+		return v == want
+	case []interface{}:
+		for _, item := range v {
+			if item == want {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,47 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// 没有 exp 的令牌默认被拒绝，只有显式设置 AllowMissingExpiry 时才被接受
+func TestJWTRequiresExpiry(t *testing.T) {
+	secret := []byte("secret")
+	sign := func(claims map[string]interface{}) string {
+		header, _ := json.Marshal(map[string]string{"alg": "HS256"})
+		body, _ := json.Marshal(claims)
+		signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(body)
+		mac := hmac.New(sha256.New, secret)
+		mac.Write([]byte(signed))
+		return signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+	}
+	authenticate := func(a *JWTAuthenticator, token string) error {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Authorization", "Bearer "+token)
+		_, err := a.Authenticate(r)
+		return err
+	}
+	keys := func(kid, alg string) (interface{}, error) { return secret, nil }
+
+	noExpiry := sign(map[string]interface{}{"sub": "alice", "role": "viewer"})
+	withExpiry := sign(map[string]interface{}{"sub": "alice", "role": "viewer", "exp": time.Now().Add(time.Hour).Unix()})
+
+	strict := &JWTAuthenticator{Keys: keys}
+	if err := authenticate(strict, noExpiry); !errors.Is(err, ErrUnauthenticated) {
+		t.Fatalf("expected token without exp to be rejected, got %v", err)
+	}
+	if err := authenticate(strict, withExpiry); err != nil {
+		t.Fatalf("expected token with exp to be accepted, got %v", err)
+	}
+	lenient := &JWTAuthenticator{Keys: keys, AllowMissingExpiry: true}
+	if err := authenticate(lenient, noExpiry); err != nil {
+		t.Fatalf("expected token without exp to be accepted when allowed, got %v", err)
+	}
+}
@@ -0,0 +1,701 @@
+// Package server 通过 REST 接口暴露工作流注册表和运行记录的管理功能
+package server
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"time"
+
+	"workflow/graph"
+)
+
+// Server 是工作流管理接口的 HTTP 服务
+type Server struct {
+	registry  *graph.Registry
+	store     graph.Store
+	authn     Authenticator
+	logger    *slog.Logger
+	baseCtx   context.Context // 异步触发的运行使用的上下文
+	webhooks  *webhookSender
+	admission *graph.AdmissionController // 为空时不限制异步运行的数量
+	runner    Runner
+	status    StatusProvider   // 为空时 /readyz 只检查 Store
+	scheduler *graph.Scheduler // 为空时 /statusz 不报告全局名额
+	mux       *http.ServeMux
+}
+
+// Option 定义服务的构造选项
+type Option func(*Server)
+
+// WithAuthenticator 设置请求认证方式；未设置时不做认证和授权，仅适合本机访问
+func WithAuthenticator(authn Authenticator) Option {
+	return func(s *Server) {
+		s.authn = authn
+	}
+}
+
+// WithLogger 设置服务日志器
+func WithLogger(logger *slog.Logger) Option {
+	return func(s *Server) {
+		s.logger = logger
+	}
+}
+
+// WithBaseContext 设置异步运行使用的上下文，上下文取消时正在执行的运行也会被取消
+func WithBaseContext(ctx context.Context) Option {
+	return func(s *Server) {
+		s.baseCtx = ctx
+	}
+}
+
+// RunRef 标识服务异步启动的一次运行
+type RunRef struct {
+	Namespace string
+	Workflow  string
+	RunID     string
+}
+
+// Runner 在后台执行服务异步启动的运行（触发、重新执行死信和重试），
+// 可用于在进程关闭时拒绝新的运行并排空执行中的运行
+type Runner interface {
+	// Go 在后台以 ctx 调用 fn；不再接受新的运行时返回错误，此时 fn 不会被调用
+	Go(ctx context.Context, run RunRef, fn func(ctx context.Context) error) error
+}
+
+// goRunner 是默认的 Runner，直接在新的协程中执行运行
+type goRunner struct{}
+
+func (goRunner) Go(ctx context.Context, run RunRef, fn func(ctx context.Context) error) error {
+	go fn(ctx)
+	return nil
+}
+
+// WithRunner 设置执行异步运行的 Runner，未设置时直接在新的协程中执行
+func WithRunner(runner Runner) Option {
+	return func(s *Server) {
+		s.runner = runner
+	}
+}
+
+// WithAdmission 设置触发、重新执行死信和重试接口使用的准入控制器：
+// 同时执行的运行已达上限时请求在队列中等待，仍未准入时返回 503 和 Retry-After
+func WithAdmission(a *graph.AdmissionController) Option {
+	return func(s *Server) {
+		s.admission = a
+	}
+}
+
+// WithWebhookClient 设置投递回调使用的 HTTP 客户端。默认客户端超时为10秒，并拒绝连接回环、私有、
+// 链路本地和未指定地址；设置的客户端不做这项检查
+func WithWebhookClient(client *http.Client) Option {
+	return func(s *Server) {
+		s.webhooks.client = client
+	}
+}
+
+// WithWebhookAllowedNetworks 允许默认的回调客户端连接这些网段，如回调接收方部署在内网时
+func WithWebhookAllowedNetworks(networks ...netip.Prefix) Option {
+	return func(s *Server) {
+		s.webhooks.allowed = append(s.webhooks.allowed, networks...)
+	}
+}
+
+// WithWebhookRetry 设置回调的最大投递次数和首次重试的等待时间，之后每次重试等待时间翻倍
+func WithWebhookRetry(maxAttempts int, backoff time.Duration) Option {
+	return func(s *Server) {
+		if maxAttempts > 0 {
+			s.webhooks.maxAttempts = maxAttempts
+		}
+		s.webhooks.backoff = backoff
+	}
+}
+
+// New 创建管理服务，store 用于查询运行记录，应与 registry 使用的 Store 相同
+func New(registry *graph.Registry, store graph.Store, opts ...Option) *Server {
+	s := &Server{
+		registry: registry,
+		store:    store,
+		logger:   slog.Default(),
+		baseCtx:  context.Background(),
+		webhooks: &webhookSender{
+			maxAttempts: 5,
+			backoff:     time.Second,
+		},
+		runner: goRunner{},
+		mux:    http.NewServeMux(),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.webhooks.client == nil {
+		s.webhooks.client = webhookClient(s.webhooks.allowed)
+		s.webhooks.guarded = true
+	}
+	s.webhooks.logger = s.logger
+	s.routes()
+	return s
+}
+
+// ServeHTTP 实现 http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// openAPISpec 是管理接口的 OpenAPI 文档
+//
+//go:embed openapi.yaml
+var openAPISpec []byte
+
+// OpenAPISpec 返回管理接口的 OpenAPI 文档，可用于生成其他语言的客户端
+func OpenAPISpec() []byte {
+	return openAPISpec
+}
+
+// routes 注册所有管理接口及其所需的角色
+func (s *Server) routes() {
+	s.mux.HandleFunc("GET /v1/openapi.yaml", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/yaml")
+		w.Write(openAPISpec)
+	})
+	// 探针和状态页不需要认证
+	s.mux.HandleFunc("GET /healthz", s.healthz)
+	s.mux.HandleFunc("GET /readyz", s.readyz)
+	s.mux.HandleFunc("GET /statusz", s.statusz)
+	s.handle("GET /v1/namespaces/{namespace}/workflows", RoleViewer, s.listWorkflows)
+	s.handle("GET /v1/namespaces/{namespace}/workflows/{name}", RoleViewer, s.getWorkflow)
+	s.handle("POST /v1/namespaces/{namespace}/workflows/{name}/runs", RoleTrigger, s.triggerRun)
+	s.handle("GET /v1/namespaces/{namespace}/workflows/{name}/analytics", RoleViewer, s.getAnalytics)
+	s.handle("GET /v1/namespaces/{namespace}/runs", RoleViewer, s.listRuns)
+	s.handle("GET /v1/namespaces/{namespace}/runs/{id}", RoleViewer, s.getRun)
+	s.handle("POST /v1/namespaces/{namespace}/runs/{id}/retry", RoleTrigger, s.retryRun)
+	s.handle("GET /v1/namespaces/{namespace}/deadletters", RoleViewer, s.listDeadLetters)
+	s.handle("GET /v1/namespaces/{namespace}/deadletters/{id}", RoleViewer, s.getDeadLetter)
+	s.handle("POST /v1/namespaces/{namespace}/deadletters/{id}/redrive", RoleTrigger, s.redriveDeadLetter)
+	s.handle("DELETE /v1/namespaces/{namespace}/deadletters/{id}", RoleAdmin, s.deleteDeadLetter)
+	s.handle("GET /v1/namespaces/{namespace}/audit", RoleAdmin, s.listAudit)
+}
+
+// handle 注册需要指定角色的接口
+func (s *Server) handle(pattern string, role Role, h http.HandlerFunc) {
+	s.mux.Handle(pattern, s.authorize(role, h))
+}
+
+// WorkflowView 是工作流在接口中的表示
+type WorkflowView struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Versions  []int  `json:"versions"`
+	Latest    int    `json:"latest"`
+}
+
+// AttemptView 是任务一次尝试在接口中的表示
+type AttemptView struct {
+	Attempt    int       `json:"attempt"`
+	StartTime  time.Time `json:"start_time"`
+	EndTime    time.Time `json:"end_time"`
+	DurationMS int64     `json:"duration_ms"`
+	Worker     string    `json:"worker,omitempty"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// TaskView 是任务执行情况在接口中的表示
+type TaskView struct {
+	Status      graph.TaskStatus       `json:"status"`
+	StartTime   time.Time              `json:"start_time,omitempty"`
+	EndTime     time.Time              `json:"end_time,omitempty"`
+	QueueWaitMS int64                  `json:"queue_wait_ms,omitempty"`
+	DurationMS  int64                  `json:"duration_ms"`
+	Attempts    int                    `json:"attempts"`
+	History     []AttemptView          `json:"history,omitempty"`
+	Cost        float64                `json:"cost,omitempty"`
+	Costs       map[string]float64     `json:"costs,omitempty"`
+	SkipReason  string                 `json:"skip_reason,omitempty"`
+	Reused      bool                   `json:"reused,omitempty"`
+	Error       string                 `json:"error,omitempty"`
+	Attributes  map[string]interface{} `json:"attributes,omitempty"`
+	Lineage     *LineageView           `json:"lineage,omitempty"`
+	ResultBytes int64                  `json:"result_bytes,omitempty"`
+	Usage       *UsageView             `json:"usage,omitempty"`
+}
+
+// LineageView 是任务血缘在接口中的表示
+type LineageView struct {
+	Version      string             `json:"version,omitempty"`
+	Inputs       []LineageInputView `json:"inputs,omitempty"`
+	OutputDigest string             `json:"output_digest"`
+}
+
+// UsageView 是任务资源使用在接口中的表示
+type UsageView struct {
+	UserMS     int64 `json:"user_ms"`
+	SystemMS   int64 `json:"system_ms"`
+	AllocBytes int64 `json:"alloc_bytes"`
+	Goroutines int   `json:"goroutines"`
+	MaxRSS     int64 `json:"max_rss,omitempty"`
+}
+
+// LineageInputView 是任务消费的一个上游输出
+type LineageInputView struct {
+	TaskID string `json:"task_id"`
+	Name   string `json:"name,omitempty"`
+	Key    string `json:"key,omitempty"`
+	Digest string `json:"digest,omitempty"`
+}
+
+// RunView 是运行记录在接口中的表示
+type RunView struct {
+	Namespace       string                 `json:"namespace"`
+	RunID           string                 `json:"run_id"`
+	Workflow        string                 `json:"workflow"`
+	WorkflowVersion int                    `json:"workflow_version"`
+	Revision        string                 `json:"revision,omitempty"`
+	Status          graph.RunStatus        `json:"status"`
+	Params          map[string]interface{} `json:"params,omitempty"`
+	StartTime       time.Time              `json:"start_time"`
+	EndTime         time.Time              `json:"end_time,omitempty"`
+	Error           string                 `json:"error,omitempty"`
+	Tasks           map[string]TaskView    `json:"tasks,omitempty"`
+	ResultBytes     int64                  `json:"result_bytes,omitempty"`
+	Cost            float64                `json:"cost,omitempty"`
+	Costs           map[string]float64     `json:"costs,omitempty"`
+	CostByTag       map[string]CostView    `json:"cost_by_tag,omitempty"`
+	Results         map[string]interface{} `json:"results,omitempty"`
+}
+
+// CostView 是一组任务的成本之和在接口中的表示
+type CostView struct {
+	Cost  float64            `json:"cost,omitempty"`
+	Costs map[string]float64 `json:"costs,omitempty"`
+}
+
+// costByTagView 将按标签汇总的成本转换为接口表示，没有时返回 nil
+func costByTagView(byTag map[string]graph.CostSummary) map[string]CostView {
+	if len(byTag) == 0 {
+		return nil
+	}
+	views := make(map[string]CostView, len(byTag))
+	for tag, c := range byTag {
+		views[tag] = CostView{Cost: c.Cost, Costs: c.Costs}
+	}
+	return views
+}
+
+// TriggerRequest 是触发运行的请求体
+type TriggerRequest struct {
+	Version int                    `json:"version,omitempty"` // 为0时使用最新版本
+	Params  map[string]interface{} `json:"params,omitempty"`
+	// Priority 是运行在全局调度器中的优先级，名额不足时优先级高的运行先执行
+	Priority int `json:"priority,omitempty"`
+	// Lineage 为 true 时记录每个任务的数据血缘
+	Lineage bool `json:"lineage,omitempty"`
+	// ResourceUsage 为 true 时记录每个任务的资源使用
+	ResourceUsage bool `json:"resource_usage,omitempty"`
+	// TaskOptions 按任务ID覆盖本次运行中任务的超时和重试次数
+	TaskOptions map[string]TaskOptions `json:"task_options,omitempty"`
+	// Callbacks 是运行结束或指定任务结束时需要通知的回调地址
+	Callbacks []Callback `json:"callbacks,omitempty"`
+	// DedupeKey 是运行的业务去重键，见 graph.Dedupe；DedupeWindow 如 "10m"，
+	// DedupePolicy 为 coalesce（默认，返回已有运行的 run_id）或 reject（返回 409）
+	DedupeKey    string             `json:"dedupe_key,omitempty"`
+	DedupeWindow string             `json:"dedupe_window,omitempty"`
+	DedupePolicy graph.DedupePolicy `json:"dedupe_policy,omitempty"`
+}
+
+// TaskOptions 是触发运行时对单个任务配置的覆盖，未设置的字段保持任务定义
+type TaskOptions struct {
+	Timeout string `json:"timeout,omitempty"` // 如 "2s"，"0s" 表示不限制
+	Retries *int   `json:"retries,omitempty"`
+}
+
+// executeOption 将任务配置覆盖转换为执行选项
+func (t TaskOptions) executeOption(taskID string) (graph.ExecuteOption, error) {
+	var opts []graph.TaskOption
+	if t.Timeout != "" {
+		d, err := time.ParseDuration(t.Timeout)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid timeout %q for task %s", t.Timeout, taskID)
+		}
+		opts = append(opts, graph.Timeout(d))
+	}
+	if t.Retries != nil {
+		if *t.Retries < 0 {
+			return nil, fmt.Errorf("invalid retries %d for task %s", *t.Retries, taskID)
+		}
+		opts = append(opts, graph.Retries(*t.Retries))
+	}
+	return graph.WithTaskOptions(taskID, opts...), nil
+}
+
+// TriggerResponse 是触发运行的响应体
+type TriggerResponse struct {
+	RunID           string `json:"run_id"`
+	WorkflowVersion int    `json:"workflow_version"`
+	// Deduplicated 为 true 时没有开始新的运行，RunID 是相同去重键的已有运行
+	Deduplicated bool `json:"deduplicated,omitempty"`
+}
+
+func (s *Server) listWorkflows(w http.ResponseWriter, r *http.Request) {
+	ns := s.registry.Namespace(r.PathValue("namespace"))
+	views := []WorkflowView{}
+	for _, name := range ns.Names() {
+		if def, err := ns.Latest(name); err == nil {
+			views = append(views, WorkflowView{Namespace: ns.Name(), Name: name, Versions: ns.Versions(name), Latest: def.Version})
+		}
+	}
+	writeJSON(w, http.StatusOK, views)
+}
+
+func (s *Server) getWorkflow(w http.ResponseWriter, r *http.Request) {
+	ns := s.registry.Namespace(r.PathValue("namespace"))
+	name := r.PathValue("name")
+	def, err := ns.Latest(name)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, WorkflowView{Namespace: ns.Name(), Name: name, Versions: ns.Versions(name), Latest: def.Version})
+}
+
+// triggerRun 异步开始一次运行并立即返回 run_id，运行状态通过运行记录接口查询
+func (s *Server) triggerRun(w http.ResponseWriter, r *http.Request) {
+	ns := s.registry.Namespace(r.PathValue("namespace"))
+	name := r.PathValue("name")
+
+	var req TriggerRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %v", err))
+			return
+		}
+	}
+	for i := range req.Callbacks {
+		if err := req.Callbacks[i].validate(s.webhooks); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	var def *graph.WorkflowDefinition
+	var err error
+	if req.Version > 0 {
+		def, err = ns.Get(name, req.Version)
+	} else {
+		def, err = ns.Latest(name)
+	}
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+
+	extra := []graph.ExecuteOption{}
+	for taskID, to := range req.TaskOptions {
+		if _, err := def.Graph.GetTaskStatus(taskID); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("task options set for unknown task %s", taskID))
+			return
+		}
+		opt, err := to.executeOption(taskID)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		extra = append(extra, opt)
+	}
+	if req.DedupeKey != "" {
+		opt, dup, err := s.dedupe(r.Context(), ns, def, req)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if dup != nil {
+			if req.DedupePolicy == graph.DedupeReject {
+				writeError(w, http.StatusConflict, dup)
+				return
+			}
+			resp := TriggerResponse{RunID: dup.RunID, WorkflowVersion: def.Version, Deduplicated: true}
+			if rec, err := s.store.GetRun(r.Context(), ns.Name(), dup.RunID); err == nil {
+				resp.WorkflowVersion = rec.WorkflowVersion
+			}
+			writeJSON(w, http.StatusOK, resp)
+			return
+		}
+		extra = append(extra, opt)
+	}
+	runID := graph.NewRunID()
+	base := WebhookPayload{Namespace: ns.Name(), RunID: runID, Workflow: def.Name, WorkflowVersion: def.Version}
+	opts := graph.ExecuteOptions{
+		Params:        req.Params,
+		Priority:      req.Priority,
+		Lineage:       req.Lineage,
+		ResourceUsage: req.ResourceUsage,
+		Logger:        s.logger,
+		OnTaskEnd:     s.webhooks.taskNotifier(s.baseCtx, req.Callbacks, base),
+	}
+	ref := RunRef{Namespace: ns.Name(), Workflow: def.Name, RunID: runID}
+	started := s.spawn(w, r, ref, func(ctx context.Context) error {
+		report, err := ns.StartVersion(ctx, def.Name, def.Version, opts, append(extra, graph.WithRunID(runID))...)
+		if err != nil {
+			s.logger.Warn("run failed", slog.String("namespace", ns.Name()), slog.String("run_id", runID), slog.Any("error", err))
+		}
+		if len(req.Callbacks) > 0 {
+			s.notifyRunEnd(req.Callbacks, base, report, err)
+		}
+		return err
+	})
+	if !started {
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, TriggerResponse{RunID: runID, WorkflowVersion: def.Version})
+}
+
+// dedupe 校验触发请求的去重设置，已有相同去重键的运行时返回描述它的 DuplicateRunError，
+// 否则返回开始运行时使用的去重选项
+func (s *Server) dedupe(ctx context.Context, ns *graph.NamespaceRegistry, def *graph.WorkflowDefinition, req TriggerRequest) (graph.ExecuteOption, *graph.DuplicateRunError, error) {
+	var window time.Duration
+	if req.DedupeWindow != "" {
+		d, err := time.ParseDuration(req.DedupeWindow)
+		if err != nil || d < 0 {
+			return nil, nil, fmt.Errorf("invalid dedupe_window %q", req.DedupeWindow)
+		}
+		window = d
+	}
+	switch req.DedupePolicy {
+	case "", graph.DedupeCoalesce, graph.DedupeReject:
+	default:
+		return nil, nil, fmt.Errorf("invalid dedupe_policy %q", req.DedupePolicy)
+	}
+	runID, status, err := ns.FindDuplicate(ctx, def.Name, req.DedupeKey, window)
+	if err != nil {
+		return nil, nil, err
+	}
+	if runID != "" {
+		return nil, &graph.DuplicateRunError{Key: req.DedupeKey, RunID: runID, Status: status}, nil
+	}
+	return graph.WithDedupe(req.DedupeKey, window, req.DedupePolicy), nil, nil
+}
+
+// notifyRunEnd 在运行结束后发送运行事件，优先使用 Store 中的最终记录
+func (s *Server) notifyRunEnd(callbacks []Callback, base WebhookPayload, report *graph.ExecutionReport, runErr error) {
+	rec, err := s.store.GetRun(s.baseCtx, base.Namespace, base.RunID)
+	if err != nil {
+		rec = &graph.RunRecord{
+			Namespace:       base.Namespace,
+			RunID:           base.RunID,
+			Workflow:        base.Workflow,
+			WorkflowVersion: base.WorkflowVersion,
+			Status:          graph.RunStatusCompleted,
+			Report:          report,
+		}
+		if runErr != nil {
+			rec.Status = graph.RunStatusFailed
+			rec.Error = runErr.Error()
+		}
+	}
+
+	payload := base
+	payload.Event = EventRunCompleted
+	if rec.Status == graph.RunStatusFailed {
+		payload.Event = EventRunFailed
+	}
+	view := runView(rec, true)
+	payload.Run = &view
+	s.webhooks.notify(s.baseCtx, callbacks, payload)
+}
+
+func (s *Server) listRuns(w http.ResponseWriter, r *http.Request) {
+	filter := graph.RunFilter{
+		Workflow: r.URL.Query().Get("workflow"),
+		Status:   graph.RunStatus(r.URL.Query().Get("status")),
+	}
+	if limit := r.URL.Query().Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid limit: %v", err))
+			return
+		}
+		filter.Limit = n
+	}
+
+	records, err := s.store.ListRuns(r.Context(), r.PathValue("namespace"), filter)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	views := make([]RunView, 0, len(records))
+	for _, rec := range records {
+		// 列表中不返回任务明细和结果
+		views = append(views, runView(rec, false))
+	}
+	writeJSON(w, http.StatusOK, views)
+}
+
+func (s *Server) getRun(w http.ResponseWriter, r *http.Request) {
+	rec, err := s.store.GetRun(r.Context(), r.PathValue("namespace"), r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, runView(rec, true))
+}
+
+// retryRun 异步重新执行失败运行中失败的任务，复用已完成任务的结果，run_id 保持不变
+func (s *Server) retryRun(w http.ResponseWriter, r *http.Request) {
+	ns := s.registry.Namespace(r.PathValue("namespace"))
+	id := r.PathValue("id")
+	rec, err := s.store.GetRun(r.Context(), ns.Name(), id)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	if rec.Status != graph.RunStatusFailed {
+		writeError(w, http.StatusConflict, fmt.Errorf("run %s is %s, only failed runs can be retried", id, rec.Status))
+		return
+	}
+
+	ref := RunRef{Namespace: ns.Name(), Workflow: rec.Workflow, RunID: id}
+	started := s.spawn(w, r, ref, func(ctx context.Context) error {
+		_, err := ns.RetryFailed(ctx, id, graph.ExecuteOptions{Logger: s.logger})
+		if err != nil {
+			s.logger.Warn("retry failed", slog.String("namespace", ns.Name()), slog.String("run_id", id), slog.Any("error", err))
+		}
+		return err
+	})
+	if !started {
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, TriggerResponse{RunID: id, WorkflowVersion: rec.WorkflowVersion})
+}
+
+// spawn 申请准入后通过 Runner 在后台执行运行，运行在请求结束后继续执行，只保留调用方标识用于审计；
+// 过载或 Runner 不再接受运行时直接写入 503 响应并返回 false
+func (s *Server) spawn(w http.ResponseWriter, r *http.Request, ref RunRef, fn func(ctx context.Context) error) bool {
+	release, err := s.admission.Admit(r.Context())
+	if err != nil {
+		if errors.Is(err, graph.ErrOverloaded) {
+			w.Header().Set("Retry-After", "1")
+		}
+		writeError(w, http.StatusServiceUnavailable, err)
+		return false
+	}
+	runCtx := graph.ContextWithActor(s.baseCtx, graph.ActorFrom(r.Context()))
+	err = s.runner.Go(runCtx, ref, func(ctx context.Context) error {
+		defer release()
+		return fn(ctx)
+	})
+	if err != nil {
+		release()
+		writeError(w, http.StatusServiceUnavailable, err)
+		return false
+	}
+	return true
+}
+
+// runView 将运行记录转换为接口表示，detail 为 true 时包含任务明细和结果
+func runView(rec *graph.RunRecord, detail bool) RunView {
+	view := RunView{
+		Namespace:       rec.Namespace,
+		RunID:           rec.RunID,
+		Workflow:        rec.Workflow,
+		WorkflowVersion: rec.WorkflowVersion,
+		Revision:        rec.Revision,
+		Status:          rec.Status,
+		Params:          rec.Params,
+		StartTime:       rec.StartTime,
+		EndTime:         rec.EndTime,
+		Error:           rec.Error,
+	}
+	if !detail || rec.Report == nil {
+		return view
+	}
+
+	view.Results = rec.Report.RedactedResults()
+	view.ResultBytes = rec.Report.ResultBytes
+	view.Cost, view.Costs = rec.Report.Cost, rec.Report.Costs
+	view.CostByTag = costByTagView(rec.Report.CostByTag)
+	view.Tasks = make(map[string]TaskView, len(rec.Report.Tasks))
+	for id, tr := range rec.Report.Tasks {
+		view.Tasks[id] = taskView(tr)
+	}
+	return view
+}
+
+// taskView 将任务报告转换为接口表示
+func taskView(tr *graph.TaskReport) TaskView {
+	tv := TaskView{
+		Status:      tr.Status,
+		StartTime:   tr.StartTime,
+		EndTime:     tr.EndTime,
+		QueueWaitMS: tr.QueueWait.Milliseconds(),
+		DurationMS:  tr.Duration.Milliseconds(),
+		Attempts:    tr.Attempts,
+		Cost:        tr.Cost,
+		Costs:       tr.Costs,
+		SkipReason:  tr.SkipReason,
+		Reused:      tr.Reused,
+		Attributes:  tr.Attributes,
+		ResultBytes: tr.ResultBytes,
+	}
+	for _, a := range tr.History {
+		av := AttemptView{
+			Attempt:    a.Attempt,
+			StartTime:  a.StartTime,
+			EndTime:    a.EndTime,
+			DurationMS: a.Duration.Milliseconds(),
+			Worker:     a.Worker,
+		}
+		if a.Error != nil {
+			av.Error = a.Error.Error()
+		}
+		tv.History = append(tv.History, av)
+	}
+	if tr.Error != nil {
+		tv.Error = tr.Error.Error()
+	}
+	if u := tr.Usage; u != nil {
+		tv.Usage = &UsageView{
+			UserMS:     u.UserTime.Milliseconds(),
+			SystemMS:   u.SystemTime.Milliseconds(),
+			AllocBytes: u.AllocBytes,
+			Goroutines: u.Goroutines,
+			MaxRSS:     u.MaxRSS,
+		}
+	}
+	if l := tr.Lineage; l != nil {
+		tv.Lineage = &LineageView{Version: l.Version, OutputDigest: l.OutputDigest}
+		for _, in := range l.Inputs {
+			tv.Lineage.Inputs = append(tv.Lineage.Inputs, LineageInputView{TaskID: in.TaskID, Name: in.Name, Key: in.Key, Digest: in.Digest})
+		}
+	}
+	return tv
+}
+
+// errorResponse 是错误响应体
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("failed to encode response: %v", err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	body, _ := json.Marshal(errorResponse{Error: err.Error()})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(body)
+}
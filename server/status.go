@@ -0,0 +1,159 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"workflow/graph"
+)
+
+// storeCheckTimeout 是 /readyz 和 /statusz 检查 Store 连接的超时
+const storeCheckTimeout = 2 * time.Second
+
+// EngineStatus 是运行服务的进程提供的状态
+type EngineStatus struct {
+	Draining   bool // 正在关闭、不再接受新的运行
+	ActiveRuns int  // 执行中的运行数
+}
+
+// StatusProvider 为 /readyz 和 /statusz 提供引擎状态，通常由 engine.Engine 实现
+type StatusProvider interface {
+	EngineStatus() EngineStatus
+}
+
+// WithStatusProvider 设置引擎状态的来源，未设置时 /statusz 不包含执行中的运行数，/readyz 只检查 Store
+func WithStatusProvider(p StatusProvider) Option {
+	return func(s *Server) {
+		s.status = p
+	}
+}
+
+// WithScheduler 设置 /statusz 报告全局名额利用率时使用的调度器
+func WithScheduler(scheduler *graph.Scheduler) Option {
+	return func(s *Server) {
+		s.scheduler = scheduler
+	}
+}
+
+// StatusView 是 /statusz 的响应
+type StatusView struct {
+	Status              string         `json:"status"` // ok、draining 或 unavailable
+	ActiveRuns          int            `json:"active_runs"`
+	QueuedRuns          int            `json:"queued_runs"`
+	Admission           *AdmissionView `json:"admission,omitempty"`
+	Scheduler           *SchedulerView `json:"scheduler,omitempty"`
+	ResultBytesInFlight int64          `json:"result_bytes_in_flight"`
+	Store               StoreCheckView `json:"store"`
+}
+
+// AdmissionView 是准入控制器状态在接口中的表示
+type AdmissionView struct {
+	MaxInFlight int    `json:"max_in_flight"`
+	InFlight    int    `json:"in_flight"`
+	Queued      int    `json:"queued"`
+	Admitted    uint64 `json:"admitted"`
+	Rejected    uint64 `json:"rejected"`
+}
+
+// SchedulerView 是全局调度器状态在接口中的表示
+type SchedulerView struct {
+	Slots       int     `json:"slots"`
+	InUse       int     `json:"in_use"`
+	Waiting     int     `json:"waiting"`
+	WaitingRuns int     `json:"waiting_runs"`
+	Utilization float64 `json:"utilization"` // InUse / Slots
+}
+
+// StoreCheckView 是 Store 连接检查的结果
+type StoreCheckView struct {
+	OK        bool   `json:"ok"`
+	LatencyMS int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+}
+
+// healthz 是存活探针，进程能处理请求即返回 200
+func (s *Server) healthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte("ok\n"))
+}
+
+// readyz 是就绪探针，引擎正在关闭或 Store 无法访问时返回 503
+func (s *Server) readyz(w http.ResponseWriter, r *http.Request) {
+	if s.status != nil && s.status.EngineStatus().Draining {
+		writeError(w, http.StatusServiceUnavailable, fmt.Errorf("engine is shutting down"))
+		return
+	}
+	if check := s.checkStore(r.Context()); !check.OK {
+		writeError(w, http.StatusServiceUnavailable, fmt.Errorf("store unavailable: %s", check.Error))
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte("ok\n"))
+}
+
+// statusz 返回引擎的运行状态；只包含汇总的计数，不需要认证。
+// 状态不是 ok 时返回 503，便于直接用作告警检查
+func (s *Server) statusz(w http.ResponseWriter, r *http.Request) {
+	view := StatusView{
+		Status:              "ok",
+		ResultBytesInFlight: graph.ResultBytesInFlight(),
+		Store:               s.checkStore(r.Context()),
+	}
+	if s.status != nil {
+		es := s.status.EngineStatus()
+		view.ActiveRuns = es.ActiveRuns
+		if es.Draining {
+			view.Status = "draining"
+		}
+	}
+	if s.admission != nil {
+		st := s.admission.Stats()
+		view.QueuedRuns = st.Queued
+		view.Admission = &AdmissionView{
+			MaxInFlight: st.MaxInFlight,
+			InFlight:    st.InFlight,
+			Queued:      st.Queued,
+			Admitted:    st.Admitted,
+			Rejected:    st.Rejected,
+		}
+	}
+	if s.scheduler != nil {
+		st := s.scheduler.Stats()
+		view.Scheduler = &SchedulerView{
+			Slots:       st.Slots,
+			InUse:       st.InUse,
+			Waiting:     st.Waiting,
+			WaitingRuns: st.WaitingRuns,
+			Utilization: float64(st.InUse) / float64(st.Slots),
+		}
+	}
+	if !view.Store.OK {
+		view.Status = "unavailable"
+	}
+	status := http.StatusOK
+	if view.Status != "ok" {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, view)
+}
+
+// checkStore 检查 Store 是否可以访问：实现了 graph.PingableStore 时调用 Ping，
+// 否则查询一条运行记录
+func (s *Server) checkStore(ctx context.Context) StoreCheckView {
+	ctx, cancel := context.WithTimeout(ctx, storeCheckTimeout)
+	defer cancel()
+	start := time.Now()
+	var err error
+	if p, ok := s.store.(graph.PingableStore); ok {
+		err = p.Ping(ctx)
+	} else {
+		_, err = s.store.ListRuns(ctx, graph.DefaultNamespace, graph.RunFilter{Limit: 1})
+	}
+	check := StoreCheckView{OK: err == nil, LatencyMS: time.Since(start).Milliseconds()}
+	if err != nil {
+		check.Error = err.Error()
+	}
+	return check
+}
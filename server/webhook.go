@@ -0,0 +1,275 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"syscall"
+	"time"
+
+	"workflow/graph"
+)
+
+// WebhookEvent 表示回调通知的事件类型
+type WebhookEvent string
+
+const (
+	EventRunCompleted  WebhookEvent = "run.completed"
+	EventRunFailed     WebhookEvent = "run.failed"
+	EventTaskCompleted WebhookEvent = "task.completed"
+	EventTaskFailed    WebhookEvent = "task.failed"
+)
+
+// 回调请求携带的请求头
+const (
+	HeaderWebhookEvent     = "X-Webhook-Event"
+	HeaderWebhookTimestamp = "X-Webhook-Timestamp"
+	HeaderWebhookSignature = "X-Webhook-Signature"
+)
+
+// Callback 是触发运行时登记的回调地址
+type Callback struct {
+	URL string `json:"url"`
+	// Secret 不为空时使用 HMAC-SHA256 对请求签名，签名放在 X-Webhook-Signature 请求头
+	Secret string `json:"secret,omitempty"`
+	// Events 是需要通知的事件，为空时只通知 run.completed 和 run.failed
+	Events []WebhookEvent `json:"events,omitempty"`
+	// Tasks 限定任务事件只针对这些任务，为空时通知所有任务
+	Tasks []string `json:"tasks,omitempty"`
+}
+
+// wants 判断回调是否订阅了指定事件；taskID 为空表示运行级事件
+func (c *Callback) wants(event WebhookEvent, taskID string) bool {
+	if len(c.Events) == 0 {
+		return event == EventRunCompleted || event == EventRunFailed
+	}
+	subscribed := false
+	for _, e := range c.Events {
+		if e == event {
+			subscribed = true
+			break
+		}
+	}
+	if !subscribed || taskID == "" || len(c.Tasks) == 0 {
+		return subscribed
+	}
+	for _, id := range c.Tasks {
+		if id == taskID {
+			return true
+		}
+	}
+	return false
+}
+
+// validate 检查回调地址和事件类型；地址是IP时在登记时就拒绝不允许访问的地址，
+// 域名解析到的地址在建立连接时检查（见 webhookClient）
+func (c *Callback) validate(w *webhookSender) error {
+	u, err := url.Parse(c.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid callback url %q", c.URL)
+	}
+	if addr, err := netip.ParseAddr(u.Hostname()); err == nil && !w.addressAllowed(addr) {
+		return fmt.Errorf("callback url %q points to a disallowed address", c.URL)
+	}
+	for _, e := range c.Events {
+		switch e {
+		case EventRunCompleted, EventRunFailed, EventTaskCompleted, EventTaskFailed:
+		default:
+			return fmt.Errorf("unknown callback event %q", e)
+		}
+	}
+	return nil
+}
+
+// WebhookPayload 是回调请求的请求体
+type WebhookPayload struct {
+	Event           WebhookEvent `json:"event"`
+	Namespace       string       `json:"namespace"`
+	RunID           string       `json:"run_id"`
+	Workflow        string       `json:"workflow"`
+	WorkflowVersion int          `json:"workflow_version"`
+	TaskID          string       `json:"task_id,omitempty"`
+	Task            *TaskView    `json:"task,omitempty"` // 任务事件时的任务执行情况
+	Run             *RunView     `json:"run,omitempty"`  // 运行事件时的运行记录
+}
+
+// SignWebhook 计算回调请求的签名：对 "<timestamp>.<body>" 做 HMAC-SHA256，
+// 结果形如 "sha256=<hex>"。接收方应使用相同方式计算并用 hmac.Equal 比较，
+// 同时校验时间戳以防止重放
+func SignWebhook(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// errWebhookAddress 表示回调连接的地址不允许访问
+var errWebhookAddress = errors.New("callback address is not allowed")
+
+// webhookAddressAllowed 判断回调能否连接到 addr：回环、私有、链路本地和未指定地址默认不允许，
+// 防止通过回调访问服务所在的内部网络；allowed 中的网段除外
+func webhookAddressAllowed(addr netip.Addr, allowed []netip.Prefix) bool {
+	addr = addr.Unmap()
+	for _, p := range allowed {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return !addr.IsLoopback() && !addr.IsPrivate() && !addr.IsLinkLocalUnicast() &&
+		!addr.IsLinkLocalMulticast() && !addr.IsUnspecified()
+}
+
+// webhookClient 创建默认的回调客户端，在建立连接时检查解析后的地址，域名在检查后被重新解析
+// 到内部地址（DNS 重绑定）也无法绕过；不使用环境变量中的代理，否则检查的只是代理的地址
+func webhookClient(allowed []netip.Prefix) *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			ap, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if !webhookAddressAllowed(ap.Addr(), allowed) {
+				return fmt.Errorf("%w: %s", errWebhookAddress, ap.Addr())
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: 10 * time.Second, Transport: transport}
+}
+
+// webhookSender 负责投递回调，失败时按指数退避重试
+type webhookSender struct {
+	client      *http.Client
+	guarded     bool           // client 是否为检查连接地址的默认客户端
+	allowed     []netip.Prefix // 允许回调访问的内部网段
+	maxAttempts int
+	backoff     time.Duration
+	logger      *slog.Logger
+}
+
+// addressAllowed 判断回调能否访问 addr，使用调用方设置的客户端时不做检查
+func (w *webhookSender) addressAllowed(addr netip.Addr) bool {
+	return !w.guarded || webhookAddressAllowed(addr, w.allowed)
+}
+
+// notify 向订阅了事件的所有回调异步投递通知
+func (w *webhookSender) notify(ctx context.Context, callbacks []Callback, payload WebhookPayload) {
+	for i := range callbacks {
+		cb := &callbacks[i]
+		if !cb.wants(payload.Event, payload.TaskID) {
+			continue
+		}
+		go w.deliver(ctx, cb, payload)
+	}
+}
+
+// deliver 投递单个回调，网络错误、5xx、408 和 429 响应会重试
+func (w *webhookSender) deliver(ctx context.Context, cb *Callback, payload WebhookPayload) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		w.logger.Warn("failed to encode webhook payload", slog.String("run_id", payload.RunID), slog.Any("error", err))
+		return
+	}
+
+	backoff := w.backoff
+	for attempt := 1; attempt <= w.maxAttempts; attempt++ {
+		retry, err := w.send(ctx, cb, payload.Event, body)
+		if err == nil {
+			return
+		}
+		if !retry || attempt == w.maxAttempts {
+			w.logger.Warn("webhook delivery failed",
+				slog.String("run_id", payload.RunID),
+				slog.String("event", string(payload.Event)),
+				slog.String("url", cb.URL),
+				slog.Int("attempts", attempt),
+				slog.Any("error", err))
+			return
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
+
+// send 发送一次回调请求，retry 表示失败后是否值得重试
+func (w *webhookSender) send(ctx context.Context, cb *Callback, event WebhookEvent, body []byte) (retry bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cb.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderWebhookEvent, string(event))
+	req.Header.Set(HeaderWebhookTimestamp, strconv.FormatInt(timestamp, 10))
+	if cb.Secret != "" {
+		req.Header.Set(HeaderWebhookSignature, SignWebhook(cb.Secret, timestamp, body))
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return !errors.Is(err, errWebhookAddress), err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry = resp.StatusCode >= 500 || resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode == http.StatusTooManyRequests
+	return retry, fmt.Errorf("callback returned %d", resp.StatusCode)
+}
+
+// taskNotifier 返回在任务结束时发送任务事件的 OnTaskEnd 钩子，没有回调订阅任务事件时返回 nil
+func (w *webhookSender) taskNotifier(ctx context.Context, callbacks []Callback, base WebhookPayload) func(tr graph.TaskReport) {
+	subscribed := false
+	for i := range callbacks {
+		for _, e := range callbacks[i].Events {
+			if e == EventTaskCompleted || e == EventTaskFailed {
+				subscribed = true
+			}
+		}
+	}
+	if !subscribed {
+		return nil
+	}
+
+	return func(tr graph.TaskReport) {
+		var event WebhookEvent
+		switch tr.Status {
+		case graph.TaskStatusCompleted:
+			event = EventTaskCompleted
+		case graph.TaskStatusFailed:
+			event = EventTaskFailed
+		default:
+			return
+		}
+		payload := base
+		payload.Event = event
+		payload.TaskID = tr.ID
+		view := taskView(&tr)
+		payload.Task = &view
+		w.notify(ctx, callbacks, payload)
+	}
+}
@@ -0,0 +1,153 @@
+package temporal
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"workflow/graph"
+)
+
+// ActivityInput 是任务活动的参数，需要能以 JSON 编码
+type ActivityInput struct {
+	RunID   string                 `json:"run_id,omitempty"` // 通常为 Temporal 工作流的运行ID
+	Attempt int                    `json:"attempt,omitempty"`
+	Results map[string]interface{} `json:"results,omitempty"` // 已完成任务的结果，至少包含该任务的依赖
+	Params  map[string]interface{} `json:"params,omitempty"`
+}
+
+// ActivityResult 是任务活动的返回值；条件不满足的任务返回 Skipped，没有结果
+type ActivityResult struct {
+	Result  interface{} `json:"result,omitempty"`
+	Skipped bool        `json:"skipped,omitempty"`
+}
+
+// ActivityFunc 是任务对应的活动函数，签名满足 Temporal SDK 对活动的要求
+type ActivityFunc func(ctx context.Context, in ActivityInput) (ActivityResult, error)
+
+// PlanStep 是计划中的一个活动
+type PlanStep struct {
+	TaskID   string   `json:"task_id"`
+	Activity string   `json:"activity"`
+	Depends  []string `json:"depends,omitempty"`
+	// StartToCloseTimeout 为任务的 Timeout，为0时由工作流设置默认值
+	StartToCloseTimeout time.Duration `json:"start_to_close_timeout,omitempty"`
+	// MaximumAttempts 为任务的 Retries+1，作为活动重试策略的最大尝试次数
+	MaximumAttempts int  `json:"maximum_attempts"`
+	Optional        bool `json:"optional,omitempty"` // 失败时工作流可以继续
+}
+
+// Plan 是在 Temporal 工作流中执行任务图的计划：Layers 中同一层的活动互不依赖，可以并发执行，
+// 每层都在前一层全部结束后开始，与引擎的按层调度一致。使用 Temporal SDK 的工作流可以
+// 按层调用 workflow.ExecuteActivity，把已完成活动的 Result 一并放入后续活动的 ActivityInput.Results：
+//
+//	for _, layer := range plan.Layers {
+//		futures := make([]workflow.Future, len(layer))
+//		for i, step := range layer {
+//			futures[i] = workflow.ExecuteActivity(ctx, step.Activity, temporal.ActivityInput{Results: results, Params: params})
+//		}
+//		for i, f := range futures {
+//			var r temporal.ActivityResult
+//			if err := f.Get(ctx, &r); err != nil { ... }
+//			if !r.Skipped {
+//				results[layer[i].TaskID] = r.Result
+//			}
+//		}
+//	}
+type Plan struct {
+	Workflow string       `json:"workflow"`
+	Layers   [][]PlanStep `json:"layers"`
+}
+
+// ActivityName 返回任务对应的活动名称，如 "etl.extract"
+func ActivityName(workflow, taskID string) string {
+	return workflow + "." + taskID
+}
+
+// NewPlan 根据任务图生成计划，workflow 用作活动名称的前缀
+func NewPlan(workflow string, tg *graph.TaskGraph) (*Plan, error) {
+	steps, err := tg.Steps()
+	if err != nil {
+		return nil, err
+	}
+	plan := &Plan{Workflow: workflow}
+	for _, s := range steps {
+		for len(plan.Layers) <= s.Layer {
+			plan.Layers = append(plan.Layers, nil)
+		}
+		plan.Layers[s.Layer] = append(plan.Layers[s.Layer], PlanStep{
+			TaskID:              s.TaskID,
+			Activity:            ActivityName(workflow, s.TaskID),
+			Depends:             s.Depends,
+			StartToCloseTimeout: s.Timeout,
+			MaximumAttempts:     s.Retries + 1,
+			Optional:            s.Optional,
+		})
+	}
+	return plan, nil
+}
+
+// Activities 返回任务图中每个任务的活动函数（以 ActivityName 为键），
+// 供 Temporal worker 以 RegisterActivityWithOptions 按名称注册。
+// 活动通过 TaskGraph.ExecuteTask 执行任务，重试由活动的重试策略负责
+func Activities(workflow string, tg *graph.TaskGraph) (map[string]ActivityFunc, error) {
+	steps, err := tg.Steps()
+	if err != nil {
+		return nil, err
+	}
+	activities := make(map[string]ActivityFunc, len(steps))
+	for _, s := range steps {
+		taskID := s.TaskID
+		activities[ActivityName(workflow, taskID)] = func(ctx context.Context, in ActivityInput) (ActivityResult, error) {
+			result, executed, err := tg.ExecuteTask(ctx, taskID, graph.StepInput{
+				RunID:   in.RunID,
+				Attempt: in.Attempt,
+				Results: in.Results,
+				Params:  in.Params,
+			})
+			if err != nil {
+				return ActivityResult{}, err
+			}
+			if !executed {
+				return ActivityResult{Skipped: true}, nil
+			}
+			return ActivityResult{Result: result}, nil
+		}
+	}
+	return activities, nil
+}
+
+// Run 不经过 Temporal 在本进程中按计划执行活动，结果与在 Temporal 工作流中执行相同，
+// 可用于迁移前核对计划和活动；任何活动失败时停止并返回错误，可选任务的失败被忽略
+func (p *Plan) Run(ctx context.Context, activities map[string]ActivityFunc, params map[string]interface{}) (map[string]interface{}, error) {
+	runID := graph.NewRunID()
+	results := make(map[string]interface{})
+	for _, layer := range p.Layers {
+		for _, step := range layer {
+			fn, ok := activities[step.Activity]
+			if !ok {
+				return results, fmt.Errorf("activity %s not found", step.Activity)
+			}
+			var (
+				r   ActivityResult
+				err error
+			)
+			for attempt := 1; attempt <= step.MaximumAttempts; attempt++ {
+				r, err = fn(ctx, ActivityInput{RunID: runID, Attempt: attempt, Results: results, Params: params})
+				if err == nil || ctx.Err() != nil {
+					break
+				}
+			}
+			if err != nil {
+				if step.Optional {
+					continue
+				}
+				return results, err
+			}
+			if !r.Skipped {
+				results[step.TaskID] = r.Result
+			}
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,151 @@
+// Package temporal 提供与 Temporal 的互操作：把 Temporal 工作流作为任务调用，
+// 以及把任务图的任务作为 Temporal 活动执行，便于已经使用 Temporal 的团队逐步迁移。
+// 通过 Temporal 前端服务的 HTTP API 访问，不依赖 Temporal SDK；
+// 单独成包是为了让不使用 Temporal 的程序无需链接这些代码
+package temporal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Config 是访问 Temporal HTTP API 的配置
+type Config struct {
+	Address   string // 前端服务 HTTP API 的地址，如 "http://localhost:7243"
+	Namespace string // 为空时为 "default"
+	APIKey    string // 以 Bearer 令牌发送，如 Temporal Cloud 的 API Key；为空时不认证
+	Identity  string // 启动工作流时上报的身份，为空时为 "workflow-engine"
+	// HTTPClient 为空时使用 http.DefaultClient；结果查询使用长轮询，不应设置过短的超时
+	HTTPClient *http.Client
+}
+
+// Client 是 Temporal HTTP API 的最小客户端，可被多个 goroutine 并发使用
+type Client struct {
+	address    string
+	namespace  string
+	apiKey     string
+	identity   string
+	httpClient *http.Client
+}
+
+// NewClient 根据配置创建客户端
+func NewClient(cfg *Config) (*Client, error) {
+	if cfg.Address == "" {
+		return nil, fmt.Errorf("temporal address is required")
+	}
+	c := &Client{
+		address:    strings.TrimRight(cfg.Address, "/"),
+		namespace:  cfg.Namespace,
+		apiKey:     cfg.APIKey,
+		identity:   cfg.Identity,
+		httpClient: cfg.HTTPClient,
+	}
+	if c.namespace == "" {
+		c.namespace = "default"
+	}
+	if c.identity == "" {
+		c.identity = "workflow-engine"
+	}
+	if c.httpClient == nil {
+		c.httpClient = http.DefaultClient
+	}
+	return c, nil
+}
+
+// Namespace 返回客户端的默认命名空间
+func (c *Client) Namespace() string {
+	return c.namespace
+}
+
+// StatusError 是 Temporal HTTP API 返回的错误，Code 为 gRPC 状态码
+type StatusError struct {
+	HTTPStatus int
+	Code       int
+	Message    string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("temporal api returned %d: %s", e.HTTPStatus, e.Message)
+}
+
+// IsAlreadyStarted 判断错误是否表示相同ID的工作流已在执行
+func IsAlreadyStarted(err error) bool {
+	se, ok := err.(*StatusError)
+	return ok && se.HTTPStatus == http.StatusConflict
+}
+
+// IsNotFound 判断错误是否表示工作流不存在
+func IsNotFound(err error) bool {
+	se, ok := err.(*StatusError)
+	return ok && se.HTTPStatus == http.StatusNotFound
+}
+
+// do 发送请求，in 不为空时编码为 JSON；out 不为空时解码 JSON 响应。
+// query 中的参数按 HTTP API 的约定使用字段路径，如 "execution.runId"
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %v", err)
+		}
+		body = bytes.NewReader(data)
+	}
+	target := c.address + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s %s failed: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %v", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		se := &StatusError{HTTPStatus: resp.StatusCode, Message: strings.TrimSpace(string(data))}
+		var status struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(data, &status) == nil && status.Message != "" {
+			se.Code, se.Message = status.Code, status.Message
+		}
+		return se
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("failed to decode %s %s response: %v", method, path, err)
+	}
+	return nil
+}
+
+// workflowPath 返回工作流资源的路径
+func (c *Client) workflowPath(namespace, workflowID string) string {
+	if namespace == "" {
+		namespace = c.namespace
+	}
+	return "/api/v1/namespaces/" + url.PathEscape(namespace) + "/workflows/" + url.PathEscape(workflowID)
+}
@@ -0,0 +1,306 @@
+package temporal
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"time"
+
+	"workflow/graph"
+)
+
+// TaskTypeWorkflow 是调用 Temporal 工作流的任务类型名称
+const TaskTypeWorkflow = "temporal_workflow"
+
+// WorkflowSpec 描述作为任务调用的 Temporal 工作流
+type WorkflowSpec struct {
+	Namespace    string // 为空时使用客户端的默认命名空间
+	WorkflowType string
+	TaskQueue    string
+	// WorkflowID 为空时为 "<run_id>-<WorkflowType>"：任务重试时发现同ID的工作流已在执行，
+	// 直接等待它的结果而不是重复启动
+	WorkflowID       string
+	ExecutionTimeout time.Duration // 大于0时作为工作流的执行超时
+}
+
+// StartOptions 是启动工作流的参数
+type StartOptions struct {
+	Namespace        string
+	WorkflowID       string
+	WorkflowType     string
+	TaskQueue        string
+	Input            []interface{} // 工作流参数，以 JSON 编码
+	ExecutionTimeout time.Duration
+}
+
+// WorkflowError 表示 Temporal 工作流没有成功完成
+type WorkflowError struct {
+	WorkflowID string
+	RunID      string
+	Status     string // Failed、TimedOut、Canceled 或 Terminated
+	Message    string
+}
+
+func (e *WorkflowError) Error() string {
+	msg := fmt.Sprintf("temporal workflow %s (run %s) %s", e.WorkflowID, e.RunID, strings.ToLower(e.Status))
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	return msg
+}
+
+type startRequest struct {
+	WorkflowType             nameField     `json:"workflowType"`
+	TaskQueue                nameField     `json:"taskQueue"`
+	Input                    []interface{} `json:"input,omitempty"`
+	WorkflowExecutionTimeout string        `json:"workflowExecutionTimeout,omitempty"`
+	Identity                 string        `json:"identity,omitempty"`
+	RequestID                string        `json:"requestId"`
+}
+
+type nameField struct {
+	Name string `json:"name"`
+}
+
+type historyResponse struct {
+	History struct {
+		Events []historyEvent `json:"events"`
+	} `json:"history"`
+	NextPageToken string `json:"nextPageToken"`
+}
+
+type historyEvent struct {
+	EventType string `json:"eventType"`
+	Completed *struct {
+		Result []interface{} `json:"result"`
+	} `json:"workflowExecutionCompletedEventAttributes"`
+	Failed *struct {
+		Failure struct {
+			Message string `json:"message"`
+		} `json:"failure"`
+	} `json:"workflowExecutionFailedEventAttributes"`
+	Terminated *struct {
+		Reason string `json:"reason"`
+	} `json:"workflowExecutionTerminatedEventAttributes"`
+	ContinuedAsNew *struct {
+		NewExecutionRunID string `json:"newExecutionRunId"`
+	} `json:"workflowExecutionContinuedAsNewEventAttributes"`
+}
+
+// Start 启动工作流并返回 Temporal 的运行ID；相同ID的工作流已在执行时返回错误，可用 IsAlreadyStarted 判断
+func (c *Client) Start(ctx context.Context, opts StartOptions) (string, error) {
+	if opts.WorkflowID == "" || opts.WorkflowType == "" || opts.TaskQueue == "" {
+		return "", fmt.Errorf("workflow id, workflow type and task queue are required")
+	}
+	req := startRequest{
+		WorkflowType: nameField{Name: opts.WorkflowType},
+		TaskQueue:    nameField{Name: opts.TaskQueue},
+		Input:        opts.Input,
+		Identity:     c.identity,
+		RequestID:    requestID(),
+	}
+	if opts.ExecutionTimeout > 0 {
+		req.WorkflowExecutionTimeout = fmt.Sprintf("%.3fs", opts.ExecutionTimeout.Seconds())
+	}
+	var resp struct {
+		RunID string `json:"runId"`
+	}
+	if err := c.do(ctx, "POST", c.workflowPath(opts.Namespace, opts.WorkflowID), nil, req, &resp); err != nil {
+		return "", err
+	}
+	return resp.RunID, nil
+}
+
+// Result 长轮询等待工作流结束并返回它的第一个返回值；runID 为空时等待该ID最新的运行。
+// 工作流以 continue-as-new 结束时继续等待新的运行；没有成功完成时返回 *WorkflowError
+func (c *Client) Result(ctx context.Context, namespace, workflowID, runID string) (interface{}, error) {
+	for {
+		query := url.Values{
+			"waitNewEvent":           {"true"},
+			"historyEventFilterType": {"HISTORY_EVENT_FILTER_TYPE_CLOSE_EVENT"},
+		}
+		if runID != "" {
+			query.Set("execution.runId", runID)
+		}
+		var resp historyResponse
+		if err := c.do(ctx, "GET", c.workflowPath(namespace, workflowID)+"/history", query, nil, &resp); err != nil {
+			return nil, err
+		}
+		if len(resp.History.Events) == 0 {
+			// 长轮询超时，工作流仍在执行
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		ev := resp.History.Events[len(resp.History.Events)-1]
+		werr := &WorkflowError{WorkflowID: workflowID, RunID: runID}
+		switch eventType(ev.EventType) {
+		case "completed":
+			if ev.Completed == nil || len(ev.Completed.Result) == 0 {
+				return nil, nil
+			}
+			return ev.Completed.Result[0], nil
+		case "continuedasnew":
+			if ev.ContinuedAsNew == nil || ev.ContinuedAsNew.NewExecutionRunID == "" {
+				return nil, fmt.Errorf("temporal workflow %s continued as new without a run id", workflowID)
+			}
+			runID = ev.ContinuedAsNew.NewExecutionRunID
+			continue
+		case "failed":
+			werr.Status = "Failed"
+			if ev.Failed != nil {
+				werr.Message = ev.Failed.Failure.Message
+			}
+		case "timedout":
+			werr.Status = "TimedOut"
+		case "canceled":
+			werr.Status = "Canceled"
+		case "terminated":
+			werr.Status = "Terminated"
+			if ev.Terminated != nil {
+				werr.Message = ev.Terminated.Reason
+			}
+		default:
+			return nil, fmt.Errorf("unexpected close event %s for temporal workflow %s", ev.EventType, workflowID)
+		}
+		return nil, werr
+	}
+}
+
+// Cancel 请求取消工作流，工作流可以处理取消后再结束
+func (c *Client) Cancel(ctx context.Context, namespace, workflowID, runID, reason string) error {
+	req := map[string]interface{}{
+		"workflowExecution": map[string]string{"workflowId": workflowID, "runId": runID},
+		"identity":          c.identity,
+		"requestId":         requestID(),
+		"reason":            reason,
+	}
+	return c.do(ctx, "POST", c.workflowPath(namespace, workflowID)+"/cancel", nil, req, nil)
+}
+
+// eventType 把 "EVENT_TYPE_WORKFLOW_EXECUTION_COMPLETED" 或 "EventTypeWorkflowExecutionCompleted"
+// 两种枚举写法统一为 "completed"
+func eventType(s string) string {
+	s = strings.ToLower(strings.ReplaceAll(s, "_", ""))
+	return strings.TrimPrefix(s, "eventtypeworkflowexecution")
+}
+
+func requestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// WorkflowRunner 把 Temporal 工作流作为任务执行：任务输入作为工作流的唯一参数，
+// 工作流的返回值作为任务结果。任务被取消或超时时请求取消工作流
+type WorkflowRunner struct {
+	client *Client
+}
+
+// NewWorkflowRunner 创建使用 client 启动工作流的 WorkflowRunner
+func NewWorkflowRunner(client *Client) *WorkflowRunner {
+	return &WorkflowRunner{client: client}
+}
+
+// Execute 返回调用 spec 工作流的任务函数，可直接作为 graph.Task 的 Execute
+func (r *WorkflowRunner) Execute(spec WorkflowSpec) func(ctx context.Context, inputs map[string]interface{}) (interface{}, error) {
+	return func(ctx context.Context, inputs map[string]interface{}) (interface{}, error) {
+		return r.Run(ctx, spec, inputs)
+	}
+}
+
+// Run 启动工作流并等待它结束
+func (r *WorkflowRunner) Run(ctx context.Context, spec WorkflowSpec, inputs map[string]interface{}) (interface{}, error) {
+	workflowID := spec.WorkflowID
+	if workflowID == "" {
+		runID := graph.RunIDFrom(ctx)
+		if runID == "" {
+			runID = graph.NewRunID()
+		}
+		workflowID = runID + "-" + spec.WorkflowType
+	}
+	logger := graph.LoggerFrom(ctx).With(slog.String("temporal_workflow_id", workflowID))
+
+	runID, err := r.client.Start(ctx, StartOptions{
+		Namespace:        spec.Namespace,
+		WorkflowID:       workflowID,
+		WorkflowType:     spec.WorkflowType,
+		TaskQueue:        spec.TaskQueue,
+		Input:            []interface{}{inputs},
+		ExecutionTimeout: spec.ExecutionTimeout,
+	})
+	switch {
+	case IsAlreadyStarted(err):
+		logger.Info("temporal workflow already started, waiting for its result")
+	case err != nil:
+		return nil, fmt.Errorf("failed to start temporal workflow %s: %v", spec.WorkflowType, err)
+	default:
+		logger.Info("temporal workflow started", slog.String("temporal_run_id", runID))
+	}
+
+	result, err := r.client.Result(ctx, spec.Namespace, workflowID, runID)
+	if err != nil && ctx.Err() != nil {
+		// 任务被取消时 ctx 已结束，取消请求使用独立的超时
+		cancelCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+		defer cancel()
+		if cerr := r.client.Cancel(cancelCtx, spec.Namespace, workflowID, runID, "task canceled"); cerr != nil {
+			logger.Warn("failed to cancel temporal workflow", slog.Any("error", cerr))
+		}
+		return nil, ctx.Err()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// TaskType 返回可在定义文件中使用的 temporal_workflow 任务类型，
+// 通过 graph.RegisterTaskType 或 graph.WithTaskType 注册
+func (r *WorkflowRunner) TaskType() graph.TaskType {
+	return workflowTaskType{runner: r}
+}
+
+type workflowTaskType struct {
+	runner *WorkflowRunner
+}
+
+func (workflowTaskType) Name() string {
+	return TaskTypeWorkflow
+}
+
+func (workflowTaskType) ConfigSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type":     "object",
+		"required": []string{"workflow_type", "task_queue"},
+		"properties": map[string]interface{}{
+			"workflow_type":     map[string]interface{}{"type": "string"},
+			"task_queue":        map[string]interface{}{"type": "string"},
+			"namespace":         map[string]interface{}{"type": "string"},
+			"workflow_id":       map[string]interface{}{"type": "string"},
+			"execution_timeout": map[string]interface{}{"type": "string"},
+		},
+		"additionalProperties": false,
+	}
+}
+
+func (t workflowTaskType) New(config map[string]interface{}) (func(ctx context.Context, inputs map[string]interface{}) (interface{}, error), error) {
+	spec := WorkflowSpec{
+		WorkflowType: config["workflow_type"].(string),
+		TaskQueue:    config["task_queue"].(string),
+	}
+	spec.Namespace, _ = config["namespace"].(string)
+	spec.WorkflowID, _ = config["workflow_id"].(string)
+	if s, ok := config["execution_timeout"].(string); ok {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid execution_timeout: %v", err)
+		}
+		spec.ExecutionTimeout = d
+	}
+	return t.runner.Execute(spec), nil
+}
@@ -0,0 +1,247 @@
+// Package trigger 从消息系统（Kafka、NATS 等）消费消息，每条消息启动一次已注册的工作流。
+//
+// 本包只依赖 Source、Message 和 DeadLetter 接口，不绑定具体的客户端库：
+//   - Kafka：使用 consumer group 读取，Ack 提交 offset，Nack 不提交以便重新投递；
+//   - NATS JetStream：使用 durable queue consumer 拉取，Ack/Nack 对应 msg.Ack/msg.Nak。
+//
+// 扩容时在多个进程中以相同的消费组运行 Consumer，由消息系统在实例之间分配分区或消息
+package trigger
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"workflow/graph"
+)
+
+// Message 是从主题或 subject 收到的一条消息
+type Message interface {
+	ID() string // 消息的唯一标识，如 "topic/partition/offset" 或 JetStream 序号
+	Payload() []byte
+	Headers() map[string]string
+	Ack(ctx context.Context) error  // 确认消息已处理
+	Nack(ctx context.Context) error // 请求消息系统稍后重新投递
+}
+
+// Source 从消息系统接收消息，Receive 在 ctx 取消或 Source 关闭时返回错误
+type Source interface {
+	Receive(ctx context.Context) (Message, error)
+	Close() error
+}
+
+// DeadLetter 保存无法处理的消息，如发布到死信主题
+type DeadLetter interface {
+	Publish(ctx context.Context, msg Message, reason error) error
+}
+
+// ParamMapper 将消息转换为工作流参数
+type ParamMapper func(msg Message) (map[string]interface{}, error)
+
+// JSONParams 将 JSON 对象形式的消息体作为工作流参数，是默认的 ParamMapper
+func JSONParams(msg Message) (map[string]interface{}, error) {
+	var params map[string]interface{}
+	if err := json.Unmarshal(msg.Payload(), &params); err != nil {
+		return nil, fmt.Errorf("payload is not a JSON object: %v", err)
+	}
+	return params, nil
+}
+
+// Consumer 为每条消息启动一次工作流
+type Consumer struct {
+	source      Source
+	registry    *graph.NamespaceRegistry
+	workflow    string
+	params      ParamMapper
+	concurrency int
+	maxAttempts int
+	deadLetter  DeadLetter
+	execOpts    graph.ExecuteOptions
+	dedupe      func(msg Message) string
+	dedupeOpts  graph.Dedupe
+	logger      *slog.Logger
+}
+
+// Option 定义 Consumer 的构造选项
+type Option func(*Consumer)
+
+// WithParamMapper 设置消息到工作流参数的转换方式，默认为 JSONParams
+func WithParamMapper(mapper ParamMapper) Option {
+	return func(c *Consumer) {
+		c.params = mapper
+	}
+}
+
+// WithConcurrency 设置同时处理的消息数，默认为1即按顺序处理
+func WithConcurrency(n int) Option {
+	return func(c *Consumer) {
+		if n > 0 {
+			c.concurrency = n
+		}
+	}
+}
+
+// WithMaxAttempts 设置每条消息最多执行工作流的次数，默认为1
+func WithMaxAttempts(n int) Option {
+	return func(c *Consumer) {
+		if n > 0 {
+			c.maxAttempts = n
+		}
+	}
+}
+
+// WithDeadLetter 设置死信处理。设置后，无法解析或用尽执行次数的消息会被发布到死信并确认；
+// 未设置时这类消息会被 Nack，由消息系统决定重新投递
+func WithDeadLetter(dl DeadLetter) Option {
+	return func(c *Consumer) {
+		c.deadLetter = dl
+	}
+}
+
+// WithExecuteOptions 设置每次执行工作流使用的选项，Params 会被消息转换出的参数覆盖
+func WithExecuteOptions(opts graph.ExecuteOptions) Option {
+	return func(c *Consumer) {
+		c.execOpts = opts
+	}
+}
+
+// WithDedupeKey 以 key 从消息中取出的业务键（如订单号）对运行去重，见 graph.Dedupe。
+// key 返回空字符串时该消息不去重；被拒绝的重复消息会被确认，不会重新投递或进入死信
+func WithDedupeKey(key func(msg Message) string, window time.Duration, policy graph.DedupePolicy) Option {
+	return func(c *Consumer) {
+		c.dedupe = key
+		c.dedupeOpts = graph.Dedupe{Window: window, Policy: policy}
+	}
+}
+
+// WithLogger 设置日志器
+func WithLogger(logger *slog.Logger) Option {
+	return func(c *Consumer) {
+		c.logger = logger
+	}
+}
+
+// NewConsumer 创建消费 source 并启动 workflow 的 Consumer
+func NewConsumer(source Source, registry *graph.NamespaceRegistry, workflow string, opts ...Option) *Consumer {
+	c := &Consumer{
+		source:      source,
+		registry:    registry,
+		workflow:    workflow,
+		params:      JSONParams,
+		concurrency: 1,
+		maxAttempts: 1,
+		logger:      slog.Default(),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Run 持续消费消息直到 ctx 取消或 Source 返回错误，返回前等待正在处理的消息结束。
+// ctx 取消时返回 nil
+func (c *Consumer) Run(ctx context.Context) error {
+	sem := make(chan struct{}, c.concurrency)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for {
+		msg, err := c.source.Receive(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("failed to receive message: %v", err)
+		}
+
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			// 未处理的消息交还给消息系统
+			c.nack(context.WithoutCancel(ctx), msg)
+			return nil
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			c.handle(ctx, msg)
+		}()
+	}
+}
+
+// handle 处理单条消息：转换参数、执行工作流，并根据结果确认、重新投递或发布到死信
+func (c *Consumer) handle(ctx context.Context, msg Message) {
+	logger := c.logger.With(slog.String("message_id", msg.ID()), slog.String("workflow", c.workflow))
+	// 确认和死信操作不受 ctx 取消影响，避免已完成的执行被重复投递
+	settleCtx := context.WithoutCancel(ctx)
+
+	params, err := c.params(msg)
+	if err != nil {
+		// 无法解析的消息重试也不会成功
+		logger.Warn("failed to map message to params", slog.Any("error", err))
+		c.reject(settleCtx, logger, msg, err)
+		return
+	}
+
+	extra := []graph.ExecuteOption{graph.WithCorrelationID(msg.ID())}
+	if c.dedupe != nil {
+		if key := c.dedupe(msg); key != "" {
+			extra = append(extra, graph.WithDedupe(key, c.dedupeOpts.Window, c.dedupeOpts.Policy))
+		}
+	}
+
+	var runErr error
+	for attempt := 1; attempt <= c.maxAttempts; attempt++ {
+		opts := c.execOpts
+		opts.Params = params
+		_, runErr = c.registry.Start(ctx, c.workflow, opts, extra...)
+		if runErr == nil || ctx.Err() != nil || errors.Is(runErr, graph.ErrDuplicateRun) {
+			break
+		}
+		logger.Warn("workflow run failed", slog.Int("attempt", attempt), slog.Any("error", runErr))
+	}
+
+	switch {
+	case errors.Is(runErr, graph.ErrDuplicateRun):
+		logger.Info("skipping duplicate message", slog.Any("error", runErr))
+		if err := msg.Ack(settleCtx); err != nil {
+			logger.Warn("failed to ack message", slog.Any("error", err))
+		}
+	case runErr == nil:
+		if err := msg.Ack(settleCtx); err != nil {
+			logger.Warn("failed to ack message", slog.Any("error", err))
+		}
+	case ctx.Err() != nil:
+		// 因关闭而中断的执行交还给消息系统重新投递
+		c.nack(settleCtx, msg)
+	default:
+		c.reject(settleCtx, logger, msg, fmt.Errorf("workflow failed after %d attempts: %v", c.maxAttempts, runErr))
+	}
+}
+
+// reject 将消息发布到死信后确认；未配置死信或发布失败时 Nack
+func (c *Consumer) reject(ctx context.Context, logger *slog.Logger, msg Message, reason error) {
+	if c.deadLetter == nil {
+		c.nack(ctx, msg)
+		return
+	}
+	if err := c.deadLetter.Publish(ctx, msg, reason); err != nil {
+		logger.Warn("failed to publish dead letter", slog.Any("error", err))
+		c.nack(ctx, msg)
+		return
+	}
+	if err := msg.Ack(ctx); err != nil {
+		logger.Warn("failed to ack message", slog.Any("error", err))
+	}
+}
+
+func (c *Consumer) nack(ctx context.Context, msg Message) {
+	if err := msg.Nack(ctx); err != nil {
+		c.logger.Warn("failed to nack message", slog.String("message_id", msg.ID()), slog.Any("error", err))
+	}
+}
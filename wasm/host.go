@@ -0,0 +1,177 @@
+package wasm
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+
+	"workflow/graph"
+)
+
+// maxHTTPBody 是 http 宿主函数读取的响应体的上限
+const maxHTTPBody = 16 << 20
+
+// callKey 是一次执行的状态在上下文中的键
+type callKey struct{}
+
+// call 是一次执行的状态：授予的能力和模块通过 fail 报告的错误
+type call struct {
+	caps    map[graph.WASMCapability]bool
+	failure string
+}
+
+// allowed 判断宿主函数所需的能力是否已授予；导入时已经检查过，这里防止绕过导入检查的调用
+func allowed(ctx context.Context, capability graph.WASMCapability) (*call, bool) {
+	c, ok := ctx.Value(callKey{}).(*call)
+	return c, ok && c.caps[capability]
+}
+
+// instantiateHost 实例化 "workflow" 宿主模块
+func instantiateHost(ctx context.Context, rt wazero.Runtime) (api.Module, error) {
+	return rt.NewHostModuleBuilder(hostModule).
+		NewFunctionBuilder().WithFunc(hostFail).Export("fail").
+		NewFunctionBuilder().WithFunc(hostLog).Export("log").
+		NewFunctionBuilder().WithFunc(hostNow).Export("now").
+		NewFunctionBuilder().WithFunc(hostMonotonic).Export("monotonic").
+		NewFunctionBuilder().WithFunc(hostRandom).Export("random").
+		NewFunctionBuilder().WithFunc(hostHTTP).Export("http").
+		Instantiate(ctx)
+}
+
+// hostFail 记录错误并中止模块
+func hostFail(ctx context.Context, mod api.Module, ptr, size uint32) {
+	msg, _ := mod.Memory().Read(ptr, size)
+	if c, ok := ctx.Value(callKey{}).(*call); ok {
+		c.failure = string(msg)
+	}
+	mod.CloseWithExitCode(ctx, 1)
+}
+
+func hostLog(ctx context.Context, mod api.Module, level int32, ptr, size uint32) {
+	if _, ok := allowed(ctx, graph.WASMCapabilityLog); !ok {
+		return
+	}
+	msg, _ := mod.Memory().Read(ptr, size)
+	graph.LoggerFrom(ctx).Log(ctx, slog.Level(level), string(msg), slog.String("source", "wasm"))
+}
+
+func hostNow(ctx context.Context) int64 {
+	if _, ok := allowed(ctx, graph.WASMCapabilityClock); !ok {
+		return 0
+	}
+	return time.Now().UnixNano()
+}
+
+// start 是单调时钟的起点
+var start = time.Now()
+
+func hostMonotonic(ctx context.Context) int64 {
+	if _, ok := allowed(ctx, graph.WASMCapabilityClock); !ok {
+		return 0
+	}
+	return int64(time.Since(start))
+}
+
+func hostRandom(ctx context.Context, mod api.Module, ptr, size uint32) {
+	if _, ok := allowed(ctx, graph.WASMCapabilityRandom); !ok {
+		return
+	}
+	buf, ok := mod.Memory().Read(ptr, size)
+	if !ok {
+		return
+	}
+	rand.Read(buf)
+}
+
+// httpRequest 和 httpResponse 是 http 宿主函数的请求和响应，Body 为原始字节的字符串
+type httpRequest struct {
+	Method  string            `json:"method"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    string            `json:"body,omitempty"`
+}
+
+type httpResponse struct {
+	Status  int               `json:"status,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    string            `json:"body,omitempty"`
+	Error   string            `json:"error,omitempty"` // 请求未能完成时的原因
+}
+
+// hostHTTP 以任务的 HTTP 客户端（graph.HTTPClient）发起请求，返回写入模块内存的响应
+func hostHTTP(ctx context.Context, mod api.Module, ptr, size uint32) uint64 {
+	var resp httpResponse
+	if _, ok := allowed(ctx, graph.WASMCapabilityHTTP); !ok {
+		resp.Error = "http capability not granted"
+	} else if data, ok := mod.Memory().Read(ptr, size); !ok {
+		resp.Error = "request out of memory range"
+	} else {
+		resp = doHTTP(ctx, data)
+	}
+	out, _ := json.Marshal(resp)
+	p, err := write(ctx, mod, out)
+	if err != nil {
+		return 0
+	}
+	return uint64(p)<<32 | uint64(len(out))
+}
+
+func doHTTP(ctx context.Context, data []byte) httpResponse {
+	var in httpRequest
+	if err := json.Unmarshal(data, &in); err != nil {
+		return httpResponse{Error: fmt.Sprintf("invalid request: %v", err)}
+	}
+	if in.Method == "" {
+		in.Method = http.MethodGet
+	}
+	req, err := http.NewRequestWithContext(ctx, strings.ToUpper(in.Method), in.URL, bytes.NewReader([]byte(in.Body)))
+	if err != nil {
+		return httpResponse{Error: err.Error()}
+	}
+	for k, v := range in.Headers {
+		req.Header.Set(k, v)
+	}
+	res, err := graph.HTTPClient(ctx).Do(req)
+	if err != nil {
+		return httpResponse{Error: err.Error()}
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(res.Body, maxHTTPBody))
+	if err != nil {
+		return httpResponse{Error: fmt.Sprintf("failed to read response: %v", err)}
+	}
+	headers := make(map[string]string, len(res.Header))
+	for k := range res.Header {
+		headers[k] = res.Header.Get(k)
+	}
+	return httpResponse{Status: res.StatusCode, Headers: headers, Body: string(body)}
+}
+
+// logWriter 把 WASI 的标准输出和标准错误按行写入任务日志
+type logWriter struct {
+	ctx context.Context
+	buf []byte
+}
+
+func (w *logWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		graph.LoggerFrom(w.ctx).Info(string(w.buf[:i]), slog.String("source", "wasm"))
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
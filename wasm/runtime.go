@@ -0,0 +1,273 @@
+// Package wasm 提供基于 wazero 的 graph.WASMRuntime，导入本包（import _ "workflow/wasm"）即注册为
+// Isolation.WASM 和 wasm 任务类型使用的运行时。
+//
+// 模块需要导出 memory、alloc(size i32) i32 和入口函数（默认 run）：
+//
+//	run(ptr i32, len i32) i64
+//
+// 宿主把 JSON 格式的输入写入 alloc 分配的内存后调用入口函数，入口函数返回 (ptr << 32) | len
+// 指向的 JSON 结果，返回0表示结果为 null。模块只能导入 "workflow" 宿主模块中已授予能力的函数：
+//
+//	fail(ptr, len)                     任务以该消息失败，始终可用
+//	log(level, ptr, len)               写入任务日志，level 同 slog.Level（能力 log）
+//	now() i64, monotonic() i64         墙上时间和单调时钟的纳秒数（能力 clock）
+//	random(ptr, len)                   以随机字节填充内存（能力 random）
+//	http(ptr, len) i64                 发起出站 HTTP 请求，请求和响应以 JSON 编码（能力 http）
+//
+// 导入未授予能力的函数的模块不会被执行。以 WASI 为目标编译的模块同样可以执行，但没有文件系统、
+// 环境变量和参数；未授予 clock 时 WASI 时钟为确定的假时钟，未授予 random 时随机数为确定的序列，
+// 未授予 log 时标准输出和标准错误被丢弃
+package wasm
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+
+	"workflow/graph"
+)
+
+// hostModule 是宿主函数所在的模块名
+const hostModule = "workflow"
+
+// pageSize 是 WASM 线性内存页的大小
+const pageSize = 64 << 10
+
+func init() {
+	graph.RegisterWASMRuntime(New())
+}
+
+// hostCapabilities 是宿主函数 -> 所需的能力，为空表示始终可用
+var hostCapabilities = map[string]graph.WASMCapability{
+	"fail":      "",
+	"log":       graph.WASMCapabilityLog,
+	"now":       graph.WASMCapabilityClock,
+	"monotonic": graph.WASMCapabilityClock,
+	"random":    graph.WASMCapabilityRandom,
+	"http":      graph.WASMCapabilityHTTP,
+}
+
+// Runtime 是基于 wazero 的 graph.WASMRuntime。每次执行实例化一个新的模块实例，
+// 执行之间不共享模块状态；编译结果按文件缓存，文件修改后重新编译。可被多个 goroutine 并发使用
+type Runtime struct {
+	cache wazero.CompilationCache
+
+	mu       sync.Mutex
+	runtimes map[uint32]*engine // 内存页数上限（0为不限制）-> wazero 运行时
+}
+
+// engine 是一个内存上限对应的 wazero 运行时及其编译过的模块
+type engine struct {
+	rt wazero.Runtime
+
+	mu      sync.Mutex
+	modules map[string]*compiled
+}
+
+// compiled 是编译过的模块和编译时文件的修改时间
+type compiled struct {
+	module  wazero.CompiledModule
+	modTime time.Time
+}
+
+var _ graph.WASMRuntime = (*Runtime)(nil)
+
+// New 创建 WASM 运行时
+func New() *Runtime {
+	return &Runtime{cache: wazero.NewCompilationCache(), runtimes: make(map[uint32]*engine)}
+}
+
+// Run 实现 graph.WASMRuntime：memoryLimit 大于0时模块的线性内存不能超过该字节数（按页向下取整，至少一页），
+// ctx 结束时中止正在执行的模块
+func (r *Runtime) Run(ctx context.Context, module *graph.WASMModule, input []byte, memoryLimit int64) ([]byte, error) {
+	e, err := r.engine(ctx, memoryLimit)
+	if err != nil {
+		return nil, err
+	}
+	cm, err := e.compile(ctx, module.Path)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkImports(cm, module.Capabilities); err != nil {
+		return nil, err
+	}
+
+	c := &call{caps: make(map[graph.WASMCapability]bool)}
+	for _, capability := range module.Capabilities {
+		c.caps[capability] = true
+	}
+	ctx = context.WithValue(ctx, callKey{}, c)
+
+	config := wazero.NewModuleConfig().WithName("").WithStartFunctions("_initialize")
+	if c.caps[graph.WASMCapabilityClock] {
+		config = config.WithSysWalltime().WithSysNanotime().WithSysNanosleep()
+	}
+	if c.caps[graph.WASMCapabilityRandom] {
+		config = config.WithRandSource(rand.Reader)
+	}
+	if c.caps[graph.WASMCapabilityLog] {
+		out := &logWriter{ctx: ctx}
+		config = config.WithStdout(out).WithStderr(out)
+	}
+	mod, err := e.rt.InstantiateModule(ctx, cm, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to instantiate module: %v", err)
+	}
+	defer mod.Close(context.WithoutCancel(ctx))
+
+	entry := module.Entry
+	if entry == "" {
+		entry = "run"
+	}
+	fn := mod.ExportedFunction(entry)
+	if fn == nil {
+		return nil, fmt.Errorf("module does not export %s", entry)
+	}
+	ptr, err := write(ctx, mod, input)
+	if err != nil {
+		return nil, err
+	}
+	results, err := fn.Call(ctx, uint64(ptr), uint64(len(input)))
+	if c.failure != "" {
+		return nil, fmt.Errorf("%s", c.failure)
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+	if len(results) != 1 {
+		return nil, fmt.Errorf("%s must return a single i64", entry)
+	}
+	return read(mod, results[0])
+}
+
+// Close 释放所有 wazero 运行时和编译缓存
+func (r *Runtime) Close(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for pages, e := range r.runtimes {
+		e.rt.Close(ctx)
+		delete(r.runtimes, pages)
+	}
+	return r.cache.Close(ctx)
+}
+
+// engine 返回内存上限对应的 wazero 运行时，第一次使用时创建并实例化宿主模块和 WASI
+func (r *Runtime) engine(ctx context.Context, memoryLimit int64) (*engine, error) {
+	var pages uint32
+	if memoryLimit > 0 {
+		pages = uint32(min(max(memoryLimit/pageSize, 1), 1<<16))
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if e, ok := r.runtimes[pages]; ok {
+		return e, nil
+	}
+
+	config := wazero.NewRuntimeConfig().WithCompilationCache(r.cache).WithCloseOnContextDone(true)
+	if pages > 0 {
+		config = config.WithMemoryLimitPages(pages)
+	}
+	rt := wazero.NewRuntimeWithConfig(context.WithoutCancel(ctx), config)
+	if _, err := instantiateHost(context.WithoutCancel(ctx), rt); err != nil {
+		rt.Close(ctx)
+		return nil, fmt.Errorf("failed to instantiate host module: %v", err)
+	}
+	if _, err := wasi_snapshot_preview1.Instantiate(context.WithoutCancel(ctx), rt); err != nil {
+		rt.Close(ctx)
+		return nil, fmt.Errorf("failed to instantiate wasi: %v", err)
+	}
+	e := &engine{rt: rt, modules: make(map[string]*compiled)}
+	r.runtimes[pages] = e
+	return e, nil
+}
+
+// compile 返回编译过的模块，文件修改后重新编译
+func (e *engine) compile(ctx context.Context, path string) (wazero.CompiledModule, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read module: %v", err)
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if c, ok := e.modules[path]; ok && c.modTime.Equal(info.ModTime()) {
+		return c.module, nil
+	}
+	code, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read module: %v", err)
+	}
+	cm, err := e.rt.CompileModule(context.WithoutCancel(ctx), code)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile module: %v", err)
+	}
+	if old, ok := e.modules[path]; ok {
+		old.module.Close(ctx)
+	}
+	e.modules[path] = &compiled{module: cm, modTime: info.ModTime()}
+	return cm, nil
+}
+
+// checkImports 拒绝导入了未授予能力的宿主函数或未知宿主函数的模块
+func checkImports(cm wazero.CompiledModule, caps []graph.WASMCapability) error {
+	granted := make(map[graph.WASMCapability]bool, len(caps))
+	for _, capability := range caps {
+		granted[capability] = true
+	}
+	for _, fn := range cm.ImportedFunctions() {
+		module, name, _ := fn.Import()
+		if module != hostModule {
+			continue
+		}
+		capability, ok := hostCapabilities[name]
+		if !ok {
+			return fmt.Errorf("module imports unknown host function %s.%s", module, name)
+		}
+		if capability != "" && !granted[capability] {
+			return fmt.Errorf("module imports %s.%s without the %s capability", module, name, capability)
+		}
+	}
+	return nil
+}
+
+// write 以模块导出的 alloc 分配内存并写入 data，返回地址
+func write(ctx context.Context, mod api.Module, data []byte) (uint32, error) {
+	alloc := mod.ExportedFunction("alloc")
+	if alloc == nil {
+		return 0, fmt.Errorf("module does not export alloc")
+	}
+	results, err := alloc.Call(ctx, uint64(len(data)))
+	if err != nil {
+		return 0, fmt.Errorf("alloc failed: %v", err)
+	}
+	if len(results) != 1 {
+		return 0, fmt.Errorf("alloc must return a single i32")
+	}
+	ptr := uint32(results[0])
+	if !mod.Memory().Write(ptr, data) {
+		return 0, fmt.Errorf("alloc returned out of range address %d", ptr)
+	}
+	return ptr, nil
+}
+
+// read 复制 (ptr << 32) | len 指向的内存，packed 为0时返回 nil
+func read(mod api.Module, packed uint64) ([]byte, error) {
+	if packed == 0 {
+		return nil, nil
+	}
+	ptr, size := uint32(packed>>32), uint32(packed)
+	data, ok := mod.Memory().Read(ptr, size)
+	if !ok {
+		return nil, fmt.Errorf("module returned out of range memory %d+%d", ptr, size)
+	}
+	return append([]byte(nil), data...), nil
+}